@@ -0,0 +1,82 @@
+package api
+
+import (
+	"go_app/core"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventQuery – wspólne parametry type= i limit= (domyślnie 100, 1..1000).
+func eventQuery(w http.ResponseWriter, r *http.Request) (typ string, limit int, ok bool) {
+	typ = r.URL.Query().Get("type")
+	if typ != "" && !slices.Contains(core.EventTypes, typ) {
+		writeError(w, http.StatusBadRequest, "type: expected one of "+strings.Join(core.EventTypes, ", "))
+		return "", 0, false
+	}
+	limit = 100
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, http.StatusBadRequest, "limit: integer 1..1000")
+			return "", 0, false
+		}
+		limit = n
+	}
+	return typ, limit, true
+}
+
+// handleEvents – GET /api/events?type=&limit=: ostatnie zdarzenia maszyny z pamięci, z trwającymi
+// przedziałami (bez end), najnowsze najpierw.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	typ, limit, ok := eventQuery(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, core.RecentMachineEvents(typ, limit))
+}
+
+// handleEventsHistory – GET /api/events/history?from=&to=&type=&limit= (RFC3339, domyślnie ostatnie
+// 24 h): zakończone zdarzenia z tabeli machine_events nachodzące na zakres.
+func handleEventsHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	typ, limit, ok := eventQuery(w, r)
+	if !ok {
+		return
+	}
+	to := time.Now().UTC()
+	from := to.Add(-24 * time.Hour)
+	if !parseTimeParam(w, r, "from", &from) || !parseTimeParam(w, r, "to", &to) {
+		return
+	}
+	events, err := core.LoadMachineEventsFromDB(typ, from, to, limit)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "database: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// handleChangeover – POST /api/changeover: operator zgłasza przezbrojenie; trwająca pauza
+// (a w czasie pracy najbliższa) kończy się przezbrojeniem, jeśli nie przekroczy maksymalnego czasu.
+func handleChangeover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if !authorized(w, r) {
+		return
+	}
+	paused := core.SignalChangeover("api")
+	core.RecordInput(core.InputTopicChangeover, core.ChangeoverCommand{Source: "api"})
+	writeJSON(w, http.StatusAccepted, map[string]bool{"pause_active": paused})
+}
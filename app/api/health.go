@@ -0,0 +1,170 @@
+package api
+
+import (
+	"fmt"
+	"go_app/communication"
+	"go_app/config"
+	"go_app/core"
+	"go_app/utils"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stan raportu zdrowia.
+const (
+	statusOK       = "ok"
+	statusDegraded = "degraded" // działa, ale część analizatorów jest OFFLINE
+	statusFail     = "fail"
+)
+
+type check struct {
+	OK     bool        `json:"ok"`
+	Error  string      `json:"error,omitempty"`
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+type healthReport struct {
+	Status string           `json:"status"`
+	Time   time.Time        `json:"time"`
+	Checks map[string]check `json:"checks"`
+}
+
+// handleHealthz – liveness: OEE jest przeliczane i żaden worker nie czeka na restart po panice.
+// Nie zależy od MQTT/DB – restart kontenera nie naprawi brokera.
+func handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+	report(w, now, map[string]check{
+		"oee":     checkOee(now),
+		"workers": checkWorkers(),
+	}, nil)
+}
+
+// handleReadyz – readiness: dodatkowo połączenie MQTT, świeży zapis do DB i stan analizatorów
+// (analizator OFFLINE obniża stan do "degraded", ale nie do "fail").
+func handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+	report(w, now, map[string]check{
+		"oee":       checkOee(now),
+		"workers":   checkWorkers(),
+		"mqtt":      checkMQTT(now),
+		"db":        checkDB(now),
+		"analyzers": checkAnalyzers(),
+	}, map[string]bool{"analyzers": true})
+}
+
+// report – 200 dla ok/degraded, 503 dla fail; soft – sprawdzenia, które dają tylko "degraded".
+func report(w http.ResponseWriter, now time.Time, checks map[string]check, soft map[string]bool) {
+	r := healthReport{Status: statusOK, Time: now, Checks: checks}
+	for name, c := range checks {
+		switch {
+		case c.OK:
+		case soft[name]:
+			if r.Status == statusOK {
+				r.Status = statusDegraded
+			}
+		default:
+			r.Status = statusFail
+		}
+	}
+	code := http.StatusOK
+	if r.Status == statusFail {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, r)
+}
+
+type lastEvent struct {
+	Last time.Time `json:"last"`
+	AgeS float64   `json:"age_s"`
+}
+
+func newLastEvent(now, t time.Time) lastEvent {
+	return lastEvent{Last: t, AgeS: math.Round(now.Sub(t).Seconds()*10) / 10}
+}
+
+func checkOee(now time.Time) check {
+	last := core.LastOeeCalculation()
+	if last.IsZero() {
+		return check{Error: "no OEE calculation yet"}
+	}
+	c := check{OK: now.Sub(last) <= config.HealthOeeMaxAge, Detail: newLastEvent(now, last)}
+	if !c.OK {
+		c.Error = fmt.Sprintf("last OEE calculation %s ago", now.Sub(last).Round(time.Second))
+	}
+	return c
+}
+
+func checkWorkers() check {
+	workers := utils.Workers()
+	var restarting []string
+	for _, s := range workers {
+		if s.State == utils.WorkerRestarting {
+			restarting = append(restarting, s.Name)
+		}
+	}
+	c := check{OK: len(restarting) == 0, Detail: workers}
+	if !c.OK {
+		c.Error = "restarting after panic: " + strings.Join(restarting, ", ")
+	}
+	return c
+}
+
+func checkMQTT(now time.Time) check {
+	online, since, reason := communication.MQTTStatus()
+	detail := map[string]interface{}{"online": online}
+	if !since.IsZero() {
+		detail["since"] = since
+		detail["for_s"] = math.Round(now.Sub(since).Seconds())
+	}
+	c := check{OK: online, Detail: detail}
+	switch {
+	case online:
+	case since.IsZero():
+		c.Error = "not connected yet"
+	default:
+		c.Error = "offline: " + reason
+	}
+	return c
+}
+
+// checkDB – ok, jeśli jakakolwiek tabela została zapisana w ciągu HealthDBMaxAge
+// (oee_temp co OEEUpdateInterval, więc przy działającej bazie zawsze).
+func checkDB(now time.Time) check {
+	tables := map[string]lastEvent{}
+	var newest time.Time
+	for table, t := range core.DBWrites() {
+		tables[table] = newLastEvent(now, t)
+		if t.After(newest) {
+			newest = t
+		}
+	}
+	c := check{OK: !newest.IsZero() && now.Sub(newest) <= config.HealthDBMaxAge, Detail: tables}
+	switch {
+	case newest.IsZero():
+		c.Error = "no successful DB write yet"
+	case !c.OK:
+		c.Error = fmt.Sprintf("last DB write %s ago", now.Sub(newest).Round(time.Second))
+	}
+	return c
+}
+
+func checkAnalyzers() check {
+	states := communication.AnalyzerStates()
+	var offline []string
+	for device, sources := range states {
+		for source, online := range sources {
+			if !online {
+				offline = append(offline, source+" "+device)
+			}
+		}
+	}
+	sort.Strings(offline)
+	c := check{OK: len(offline) == 0, Detail: states}
+	if !c.OK {
+		c.Error = "offline: " + strings.Join(offline, ", ")
+	}
+	return c
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"go_app/core"
+	"net/http"
+	"time"
+)
+
+// handleLosses – GET /api/losses: Six Big Losses bieżącej zmiany.
+func handleLosses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, core.CurrentShiftLosses())
+}
+
+// handleShiftLosses – GET /api/losses/shifts?from=&to= (RFC3339, domyślnie ostatnie 7 dni):
+// straty zakończonych zmian z tabeli shift_summary_loss.
+func handleShiftLosses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	to := time.Now().UTC()
+	from := to.Add(-7 * 24 * time.Hour)
+	if !parseTimeParam(w, r, "from", &from) || !parseTimeParam(w, r, "to", &to) {
+		return
+	}
+	shifts, err := core.LoadShiftLossesFromDB(from, to)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "database: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, shifts)
+}
+
+// parseTimeParam – parametr zapytania w RFC3339; brak = wartość domyślna z dst.
+func parseTimeParam(w http.ResponseWriter, r *http.Request, name string, dst *time.Time) bool {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, name+": RFC3339 time expected, e.g. 2025-03-03T06:00:00Z")
+		return false
+	}
+	*dst = t.UTC()
+	return true
+}
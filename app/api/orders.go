@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"go_app/core"
+	"net/http"
+	"strconv"
+)
+
+// handleOrders – GET /api/orders?order_no=&limit=: zlecenia z tabeli production_orders
+// (najnowsze najpierw, domyślnie 50).
+func handleOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	limit := 50
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 {
+			writeError(w, http.StatusBadRequest, "limit: integer 1..1000")
+			return
+		}
+		limit = n
+	}
+	orders, err := core.LoadOrdersFromDB(r.URL.Query().Get("order_no"), limit)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "database: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, orders)
+}
+
+// handleCurrentOrder – GET /api/orders/current: trwające zlecenie z OEE, pozostałą ilością i ETA.
+func handleCurrentOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	o, ok := core.CurrentOrder()
+	if !ok {
+		writeError(w, http.StatusNotFound, core.ErrOrderNotRunning.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// handleStartOrder – POST /api/orders/start {"order_no", "sku", "planned_qty"}; trwające inne
+// zlecenie jest kończone.
+func handleStartOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if !authorized(w, r) {
+		return
+	}
+	var body struct {
+		OrderNo    string `json:"order_no"`
+		SKU        string `json:"sku"`
+		PlannedQty int    `json:"planned_qty"`
+	}
+	if !decodeBody(w, r, &body) {
+		return
+	}
+	o, err := core.StartOrder(body.OrderNo, body.SKU, body.PlannedQty)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, core.ErrProductNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	core.RecordInput(core.InputTopicOrder, core.OrderCommand{Action: "start", OrderNo: o.OrderNo, SKU: o.SKU, PlannedQty: o.PlannedQty})
+	writeJSON(w, http.StatusCreated, o)
+}
+
+// handleStopOrder – POST /api/orders/stop {"order_no": "..."} (pusty = trwające zlecenie).
+func handleStopOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	if !authorized(w, r) {
+		return
+	}
+	var body struct {
+		OrderNo string `json:"order_no"`
+	}
+	if r.ContentLength != 0 && !decodeBody(w, r, &body) {
+		return
+	}
+	o, err := core.StopOrder(body.OrderNo)
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	core.RecordInput(core.InputTopicOrder, core.OrderCommand{Action: "stop", OrderNo: o.OrderNo})
+	writeJSON(w, http.StatusOK, o)
+}
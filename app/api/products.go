@@ -0,0 +1,154 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"go_app/config"
+	"go_app/core"
+	"net/http"
+	"strings"
+)
+
+// handleProducts – GET /api/products: cały katalog (także nieaktywne pozycje).
+func handleProducts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, core.Products())
+}
+
+// handleProduct – /api/products/{sku}: GET pozycja, PUT dodanie/nadpisanie, DELETE wyłączenie
+// z dopasowania (wiersz zostaje w tabeli).
+func handleProduct(w http.ResponseWriter, r *http.Request) {
+	sku := strings.TrimPrefix(r.URL.Path, "/api/products/")
+	if sku == "" || strings.Contains(sku, "/") {
+		writeError(w, http.StatusNotFound, "product not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		p, ok := core.ProductBySKU(sku)
+		if !ok {
+			writeError(w, http.StatusNotFound, "product not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+
+	case http.MethodPut:
+		if !authorized(w, r) {
+			return
+		}
+		p := core.Product{Active: true}
+		if !decodeBody(w, r, &p) {
+			return
+		}
+		if p.SKU != "" && p.SKU != sku {
+			writeError(w, http.StatusBadRequest, "sku in body does not match the URL")
+			return
+		}
+		p.SKU = sku
+		if err := p.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		saved, err := core.SaveProduct(p)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "database: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, saved)
+
+	case http.MethodDelete:
+		if !authorized(w, r) {
+			return
+		}
+		if err := core.DeactivateProduct(sku); err != nil {
+			if errors.Is(err, core.ErrProductNotFound) {
+				writeError(w, http.StatusNotFound, err.Error())
+			} else {
+				writeError(w, http.StatusServiceUnavailable, "database: "+err.Error())
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// handleCurrentProduct – /api/product/current: GET bieżący produkt silnika OEE,
+// PUT {"sku": "..."} wybór jawny, DELETE powrót do dopasowania po wymiarach.
+func handleCurrentProduct(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, core.CurrentProduct())
+
+	case http.MethodPut, http.MethodDelete:
+		if !authorized(w, r) {
+			return
+		}
+		var body struct {
+			SKU string `json:"sku"`
+		}
+		if r.Method == http.MethodPut {
+			if !decodeBody(w, r, &body) {
+				return
+			}
+			if body.SKU == "" {
+				writeError(w, http.StatusBadRequest, "sku is required (DELETE clears the selection)")
+				return
+			}
+		}
+		if err := core.SetActiveProduct(body.SKU); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, core.ErrProductNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		core.RecordInput(core.InputTopicProduct, core.ProductCommand{SKU: body.SKU})
+		writeJSON(w, http.StatusOK, core.CurrentProduct())
+
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// authorized – zmiany przez API wymagają API_TOKEN, jeśli jest ustawiony.
+func authorized(w http.ResponseWriter, r *http.Request) bool {
+	if config.ApiToken == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(config.ApiToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+		return false
+	}
+	return true
+}
+
+// decodeBody – JSON z ciała żądania (max 64 KiB, nieznane pola = błąd 400).
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"go_app/core"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// handleReliability – GET /api/reliability: MTBF/MTTR i postoje bieżącej zmiany.
+func handleReliability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, core.CurrentShiftReliability())
+}
+
+// handleReliabilityHistory – GET /api/reliability/history?period=shift|day|week&from=&to=
+// (RFC3339, domyślnie dzień i ostatnie 7 dni): MTBF/MTTR zakończonych zmian z tabeli shift_summary.
+func handleReliabilityHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "day"
+	}
+	if !slices.Contains(core.ReliabilityPeriods, period) {
+		writeError(w, http.StatusBadRequest, "period: expected one of "+strings.Join(core.ReliabilityPeriods, ", "))
+		return
+	}
+	to := time.Now().UTC()
+	from := to.Add(-7 * 24 * time.Hour)
+	if !parseTimeParam(w, r, "from", &from) || !parseTimeParam(w, r, "to", &to) {
+		return
+	}
+	rows, err := core.LoadReliabilityFromDB(period, from, to)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "database: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
@@ -0,0 +1,70 @@
+// Package api – serwer HTTP aplikacji (HTTP_ADDR): /healthz i /readyz dla Dockera i monitoringu,
+// /api/... – katalog produktów, bieżący produkt, zlecenia produkcyjne i Six Big Losses.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go_app/config"
+	"go_app/utils"
+	"net/http"
+	"time"
+)
+
+// Start uruchamia serwer HTTP, jeśli ustawiono HTTP_ADDR; zatrzymuje go Shutdown supervisora.
+func Start() {
+	if config.HttpAddr == "" {
+		return
+	}
+	srv := &http.Server{
+		Addr:              config.HttpAddr,
+		Handler:           routes(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	utils.Supervise("HTTP API", func(ctx context.Context) {
+		utils.LogMessage("[API] HTTP server listening on " + config.HttpAddr)
+		stop := context.AfterFunc(ctx, func() {
+			sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(sctx)
+		})
+		defer stop()
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.LogMessage("[API] HTTP server error: " + err.Error())
+		}
+	})
+}
+
+func routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+	mux.HandleFunc("/api/products", handleProducts)
+	mux.HandleFunc("/api/products/", handleProduct)
+	mux.HandleFunc("/api/product/current", handleCurrentProduct)
+	mux.HandleFunc("/api/orders", handleOrders)
+	mux.HandleFunc("/api/orders/current", handleCurrentOrder)
+	mux.HandleFunc("/api/orders/start", handleStartOrder)
+	mux.HandleFunc("/api/orders/stop", handleStopOrder)
+	mux.HandleFunc("/api/losses", handleLosses)
+	mux.HandleFunc("/api/losses/shifts", handleShiftLosses)
+	mux.HandleFunc("/api/speed", handleSpeed)
+	mux.HandleFunc("/api/speed/shifts", handleShiftSpeed)
+	mux.HandleFunc("/api/reliability", handleReliability)
+	mux.HandleFunc("/api/reliability/history", handleReliabilityHistory)
+	mux.HandleFunc("/api/teep", handleTeep)
+	mux.HandleFunc("/api/events", handleEvents)
+	mux.HandleFunc("/api/events/history", handleEventsHistory)
+	mux.HandleFunc("/api/changeover", handleChangeover)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
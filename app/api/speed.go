@@ -0,0 +1,36 @@
+package api
+
+import (
+	"go_app/core"
+	"net/http"
+	"time"
+)
+
+// handleSpeed – GET /api/speed: prędkość obrotnicy bieżącej zmiany i jej okresów cyklu.
+func handleSpeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, core.CurrentShiftSpeed())
+}
+
+// handleShiftSpeed – GET /api/speed/shifts?from=&to= (RFC3339, domyślnie ostatnie 7 dni):
+// prędkość zakończonych zmian z tabeli shift_summary.
+func handleShiftSpeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	to := time.Now().UTC()
+	from := to.Add(-7 * 24 * time.Hour)
+	if !parseTimeParam(w, r, "from", &from) || !parseTimeParam(w, r, "to", &to) {
+		return
+	}
+	shifts, err := core.LoadShiftSpeedFromDB(from, to)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "database: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, shifts)
+}
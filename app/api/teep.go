@@ -0,0 +1,45 @@
+package api
+
+import (
+	"go_app/core"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// handleTeep – GET /api/teep?period=day|week|month&from=&to= (RFC3339): TEEP, OEE i wykorzystanie
+// czasu kalendarzowego z historii shift_summary i bieżącej zmiany. Domyślnie dzień i ostatnie
+// 7 dni (tydzień: 4 tygodnie, miesiąc: 3 miesiące).
+func handleTeep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "day"
+	}
+	if !slices.Contains(core.TeepPeriods, period) {
+		writeError(w, http.StatusBadRequest, "period: expected one of "+strings.Join(core.TeepPeriods, ", "))
+		return
+	}
+	to := time.Now().UTC()
+	if !parseTimeParam(w, r, "to", &to) {
+		return
+	}
+	from := core.TeepDefaultFrom(period, to)
+	if !parseTimeParam(w, r, "from", &from) {
+		return
+	}
+	if !from.Before(to) {
+		writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	periods, err := core.LoadTeepFromDB(period, from, to)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "database: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, periods)
+}
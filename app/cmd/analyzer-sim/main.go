@@ -0,0 +1,272 @@
+// Command analyzer-sim udaje N analizatorów energii z API REST (/api/v1/measurements,
+// /api/v1/meters), każdy na osobnym porcie, z opóźnieniami, błędami i timeoutami.
+// Pozwala sprawdzić retry i logikę ONLINE/OFFLINE pollera REST bez urządzeń.
+//
+//	go run ./cmd/analyzer-sim -n 3 -port 8081 -error-rate 0.05 -timeout-rate 0.02
+//	ANALYZER_IP01=127.0.0.1:8081 ANALYZER_IP02=127.0.0.1:8082 ANALYZER_IP03=127.0.0.1:8083 ./app
+//
+// Tryb Modbus TCP (mapa domyślna albo -map), dla ANALYZER_TRANSPORTxx=modbus:
+//
+//	go run ./cmd/analyzer-sim -modbus -n 2 -port 5021
+//	ANALYZER_TRANSPORT01=modbus ANALYZER_IP01=127.0.0.1:5021 ./app
+//
+// Sterowanie w trakcie pracy (REST, na porcie danego analizatora):
+//
+//	curl 'http://127.0.0.1:8082/sim/offline?for=30s'   # analizator zrywa połączenia przez 30 s
+//	curl 'http://127.0.0.1:8082/sim/status'
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go_app/communication/fake"
+	"go_app/modbus"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type simConfig struct {
+	latency     time.Duration
+	jitter      time.Duration
+	errorRate   float64
+	timeoutRate float64
+	dropRate    float64
+	hang        time.Duration
+	dashIDs     bool
+}
+
+type device struct {
+	index    int
+	analyzer *fake.Analyzer
+	cfg      simConfig
+
+	mu           sync.Mutex
+	rng          *rand.Rand
+	offlineUntil time.Time
+	requests     int
+	failures     int
+}
+
+func main() {
+	n := flag.Int("n", 3, "liczba analizatorów")
+	host := flag.String("listen", "127.0.0.1", "adres nasłuchu")
+	port := flag.Int("port", 8081, "port pierwszego analizatora (kolejne: port+1, ...)")
+	load := flag.Float64("load", 8, "średnie obciążenie analizatora [kW]")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "ziarno generatora")
+	var cfg simConfig
+	flag.DurationVar(&cfg.latency, "latency", 30*time.Millisecond, "opóźnienie odpowiedzi")
+	flag.DurationVar(&cfg.jitter, "jitter", 20*time.Millisecond, "losowy dodatek do opóźnienia (0..jitter)")
+	flag.Float64Var(&cfg.errorRate, "error-rate", 0, "udział odpowiedzi HTTP 500 (0..1)")
+	flag.Float64Var(&cfg.timeoutRate, "timeout-rate", 0, "udział odpowiedzi opóźnionych o -hang (0..1)")
+	flag.Float64Var(&cfg.dropRate, "drop-rate", 0, "udział zerwanych połączeń bez odpowiedzi (0..1)")
+	flag.DurationVar(&cfg.hang, "hang", 5*time.Second, "opóźnienie dla timeoutów (klient REST czeka 2 s)")
+	flag.BoolVar(&cfg.dashIDs, "dash-ids", false, "identyfikatory liczników z '-' zamiast '_' (ea-pos-total)")
+	modbusMode := flag.Bool("modbus", false, "Modbus TCP zamiast REST")
+	mapPath := flag.String("map", "", "mapa rejestrów YAML dla -modbus (domyślnie mapa wbudowana)")
+	flag.Parse()
+
+	regMap := modbus.DefaultAnalyzerMap()
+	if *mapPath != "" {
+		m, err := modbus.LoadRegisterMap(*mapPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		regMap = m
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= *n; i++ {
+		d := &device{
+			index:    i,
+			analyzer: fake.NewAnalyzer(i, *load*(1+0.1*float64(i-1)), *seed),
+			cfg:      cfg,
+			rng:      rand.New(rand.NewSource(*seed + int64(100*i))),
+		}
+		addr := fmt.Sprintf("%s:%d", *host, *port+i-1)
+		fmt.Printf("ANALYZER_IP%02d=%s\n", i, addr)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if *modbusMode {
+				log.Printf("[device_%d] Modbus TCP on %s (map %s)", d.index, addr, regMap.Name)
+				if err := d.serveModbus(addr, regMap); err != nil {
+					log.Printf("[device_%d] %v", d.index, err)
+				}
+				return
+			}
+			log.Printf("[device_%d] listening on %s", d.index, addr)
+			if err := http.ListenAndServe(addr, d.routes()); err != nil {
+				log.Printf("[device_%d] %v", d.index, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *device) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/measurements", d.serve(func(now time.Time) []fake.Reading {
+		m, _ := d.analyzer.Sample(now)
+		return m
+	}))
+	mux.HandleFunc("/api/v1/meters", d.serve(func(now time.Time) []fake.Reading {
+		_, mt := d.analyzer.Sample(now)
+		if d.cfg.dashIDs {
+			for i := range mt {
+				mt[i].ID = strings.ReplaceAll(mt[i].ID, "_", "-")
+			}
+		}
+		return mt
+	}))
+	mux.HandleFunc("/sim/offline", d.handleOffline)
+	mux.HandleFunc("/sim/status", d.handleStatus)
+	return mux
+}
+
+// fault – losuje zachowanie dla jednego żądania: "", "error", "timeout", "drop".
+func (d *device) fault() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests++
+	if time.Now().Before(d.offlineUntil) {
+		d.failures++
+		return "drop"
+	}
+	r := d.rng.Float64()
+	switch {
+	case r < d.cfg.dropRate:
+		d.failures++
+		return "drop"
+	case r < d.cfg.dropRate+d.cfg.errorRate:
+		d.failures++
+		return "error"
+	case r < d.cfg.dropRate+d.cfg.errorRate+d.cfg.timeoutRate:
+		d.failures++
+		return "timeout"
+	}
+	return ""
+}
+
+func (d *device) delay() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delay := d.cfg.latency
+	if d.cfg.jitter > 0 {
+		delay += time.Duration(d.rng.Int63n(int64(d.cfg.jitter)))
+	}
+	return delay
+}
+
+func (d *device) serve(items func(time.Time) []fake.Reading) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch d.fault() {
+		case "drop":
+			dropConnection(w)
+			return
+		case "error":
+			time.Sleep(d.delay())
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		case "timeout":
+			time.Sleep(d.cfg.hang)
+		}
+		time.Sleep(d.delay())
+
+		now := time.Now()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"timestamp": now.UTC().Format(time.RFC3339Nano),
+			"items":     items(now),
+		})
+	}
+}
+
+// dropConnection zamyka połączenie TCP bez odpowiedzi (jak urządzenie, które zniknęło z sieci).
+func dropConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+func (d *device) handleOffline(w http.ResponseWriter, r *http.Request) {
+	dur, err := time.ParseDuration(r.URL.Query().Get("for"))
+	if err != nil || dur <= 0 {
+		http.Error(w, "use ?for=30s", http.StatusBadRequest)
+		return
+	}
+	d.mu.Lock()
+	d.offlineUntil = time.Now().Add(dur)
+	d.mu.Unlock()
+	log.Printf("[device_%d] offline for %s", d.index, dur)
+	fmt.Fprintf(w, "device_%d offline for %s\n", d.index, dur)
+}
+
+func (d *device) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"device":        fmt.Sprintf("device_%d", d.index),
+		"requests":      d.requests,
+		"failures":      d.failures,
+		"offline_until": d.offlineUntil.UTC().Format(time.RFC3339),
+		"offline":       time.Now().Before(d.offlineUntil),
+	})
+}
+
+// serveModbus wystawia wartości analizatora w rejestrach wg mapy, odświeżanych co 200 ms.
+func (d *device) serveModbus(addr string, m *modbus.RegisterMap) error {
+	var holding, input int
+	for _, r := range m.Registers {
+		end := int(r.Address) + r.Words()
+		if r.Area == modbus.AreaHolding && end > holding {
+			holding = end
+		} else if r.Area != modbus.AreaHolding && end > input {
+			input = end
+		}
+	}
+	bank := modbus.NewBank(holding, input, 0)
+
+	refresh := func() {
+		meas, meters := d.analyzer.Sample(time.Now())
+		values := map[string]float64{}
+		for _, r := range append(meas, meters...) {
+			values[r.ID] = r.Value
+		}
+		for _, r := range m.Registers {
+			v, ok := values[r.ID]
+			if !ok {
+				continue
+			}
+			if r.Area == modbus.AreaHolding {
+				bank.SetHolding(r.Address, r.Encode(v))
+			} else {
+				bank.SetInput(r.Address, r.Encode(v))
+			}
+		}
+	}
+	refresh()
+	go func() {
+		for range time.Tick(200 * time.Millisecond) {
+			refresh()
+		}
+	}()
+	return modbus.NewServer(bank).ListenAndServe(addr)
+}
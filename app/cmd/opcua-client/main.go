@@ -0,0 +1,199 @@
+// Command opcua-client – prosty klient OPC UA do sprawdzania serwera go_app (OPCUA_ENDPOINT):
+// lista endpointów, przeglądanie drzewa, odczyt i subskrypcja wartości. Generuje też
+// samopodpisane certyfikaty aplikacji (serwer i klient) dla Basic256Sha256.
+//
+//	go run ./cmd/opcua-client -endpoint opc.tcp://127.0.0.1:4840 -endpoints
+//	go run ./cmd/opcua-client -endpoint opc.tcp://127.0.0.1:4840                      # drzewo od Objects/Machines
+//	go run ./cmd/opcua-client -endpoint opc.tcp://127.0.0.1:4840 -read 'ns=1;s=Line1.KPI.oee'
+//	go run ./cmd/opcua-client -endpoint opc.tcp://127.0.0.1:4840 -sub 'ns=1;s=Line1.KPI.oee,ns=1;s=Line1.Status.pauza'
+//
+// Basic256Sha256 (certyfikat klienta musi być zaufany przez serwer, jeśli ustawiono OPCUA_TRUSTED_DIR):
+//
+//	go run ./cmd/opcua-client -gen-cert certs/client -uri urn:oee-monitoring:client
+//	go run ./cmd/opcua-client -endpoint opc.tcp://127.0.0.1:4840 -mode SignAndEncrypt \
+//	    -cert certs/client.crt -key certs/client.key -read 'ns=1;s=Line1.KPI.oee'
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go_app/opcua"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", "opc.tcp://127.0.0.1:4840", "adres serwera OPC UA")
+	modeName := flag.String("mode", "None", "tryb zabezpieczeń: None, Sign, SignAndEncrypt (Basic256Sha256)")
+	certFile := flag.String("cert", "", "certyfikat klienta (PEM/DER), wymagany dla Sign/SignAndEncrypt")
+	keyFile := flag.String("key", "", "klucz prywatny klienta (PEM/DER)")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout żądań")
+	listEndpoints := flag.Bool("endpoints", false, "wypisz endpointy serwera i zakończ")
+	root := flag.String("browse", "", "węzeł początkowy przeglądania (domyślnie ns=1;s=Machines)")
+	depth := flag.Int("depth", 4, "maksymalna głębokość przeglądania")
+	read := flag.String("read", "", "odczytaj węzły (lista NodeId rozdzielona przecinkami)")
+	sub := flag.String("sub", "", "subskrybuj węzły (lista NodeId rozdzielona przecinkami), Ctrl+C kończy")
+	interval := flag.Duration("interval", time.Second, "interwał publikacji subskrypcji")
+	genCert := flag.String("gen-cert", "", "wygeneruj certyfikat <prefiks>.crt i <prefiks>.key i zakończ")
+	appURI := flag.String("uri", "urn:oee-monitoring:client", "URI aplikacji w generowanym certyfikacie")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "nazwy/adresy w generowanym certyfikacie")
+	bits := flag.Int("bits", 2048, "długość klucza RSA generowanego certyfikatu")
+	validity := flag.Duration("validity", 5*365*24*time.Hour, "ważność generowanego certyfikatu")
+	flag.Parse()
+
+	if *genCert != "" {
+		certPEM, keyPEM, err := opcua.GenerateCertificate(*appURI, splitList(*hosts), *bits, *validity)
+		if err != nil {
+			log.Fatalf("generate certificate: %v", err)
+		}
+		if err := os.WriteFile(*genCert+".crt", certPEM, 0o644); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*genCert+".key", keyPEM, 0o600); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s.crt, %s.key (%s)\n", *genCert, *genCert, *appURI)
+		return
+	}
+
+	if *listEndpoints {
+		eps, err := opcua.GetEndpoints(*endpoint, *timeout)
+		if err != nil {
+			log.Fatalf("GetEndpoints: %v", err)
+		}
+		for _, ep := range eps {
+			fmt.Printf("%s  %s  %s  level=%d  app=%s\n", ep.URL, policyShort(ep.Policy), ep.Mode, ep.SecurityLevel, ep.ApplicationURI)
+		}
+		return
+	}
+
+	mode, err := opcua.ParseSecurityMode(*modeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg := opcua.ClientConfig{Endpoint: *endpoint, Policy: opcua.PolicyNone, Mode: mode, Timeout: *timeout}
+	if mode != opcua.SecurityModeNone {
+		if *certFile == "" || *keyFile == "" {
+			log.Fatal("-cert and -key are required for Sign/SignAndEncrypt")
+		}
+		cfg.Policy = opcua.PolicyBasic256Sha256
+		cfg.Certificate, cfg.PrivateKey, err = opcua.LoadCertificate(*certFile, *keyFile)
+		if err != nil {
+			log.Fatalf("load certificate: %v", err)
+		}
+	}
+
+	c, err := opcua.Dial(cfg)
+	if err != nil {
+		log.Fatalf("connect %s: %v", *endpoint, err)
+	}
+	defer c.Close()
+
+	switch {
+	case *read != "":
+		ids := parseIDs(*read)
+		values, err := c.Read(ids...)
+		if err != nil {
+			log.Fatalf("Read: %v", err)
+		}
+		for i, dv := range values {
+			fmt.Printf("%-45s %s\n", ids[i], formatValue(dv))
+		}
+	case *sub != "":
+		ids := parseIDs(*sub)
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt)
+		go func() {
+			<-stop
+			c.Close()
+		}()
+		err := c.Subscribe(*interval, ids, func(id opcua.NodeID, dv opcua.DataValue) {
+			fmt.Printf("%s  %-45s %s\n", time.Now().Format("15:04:05.000"), id, formatValue(dv))
+		})
+		if err != nil {
+			log.Fatalf("Subscribe: %v", err)
+		}
+	default:
+		start := opcua.NewStringNodeID(1, "Machines")
+		if *root != "" {
+			if start, err = opcua.ParseNodeID(*root); err != nil {
+				log.Fatal(err)
+			}
+		}
+		browseTree(c, start, "", *depth)
+	}
+}
+
+// browseTree wypisuje drzewo węzłów (zmienne z bieżącą wartością).
+func browseTree(c *opcua.Client, id opcua.NodeID, indent string, depth int) {
+	refs, err := c.Browse(id)
+	if err != nil {
+		log.Fatalf("Browse %s: %v", id, err)
+	}
+	for _, r := range refs {
+		if !r.IsForward || r.Target.Namespace == 0 {
+			continue
+		}
+		if r.Class == opcua.NodeClassVariable {
+			value := ""
+			if values, err := c.Read(r.Target); err == nil && len(values) == 1 {
+				value = formatValue(values[0])
+			}
+			fmt.Printf("%s%-28s %-40s %s\n", indent, r.BrowseName.Name, r.Target, value)
+			continue
+		}
+		fmt.Printf("%s%s/  (%s)\n", indent, r.BrowseName.Name, r.Target)
+		if depth > 1 {
+			browseTree(c, r.Target, indent+"  ", depth-1)
+		}
+	}
+}
+
+func formatValue(dv opcua.DataValue) string {
+	if dv.Status != opcua.StatusGood {
+		return opcua.StatusName(dv.Status)
+	}
+	if dv.Value == nil {
+		return "<null>"
+	}
+	if t, ok := dv.Value.Value.(time.Time); ok {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Local().Format(time.RFC3339)
+	}
+	return fmt.Sprint(dv.Value.Value)
+}
+
+func parseIDs(list string) []opcua.NodeID {
+	var ids []opcua.NodeID
+	for _, s := range splitList(list) {
+		id, err := opcua.ParseNodeID(s)
+		if err != nil {
+			log.Fatalf("node id %q: %v", s, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func policyShort(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Fragment != "" {
+		return u.Fragment
+	}
+	return uri
+}
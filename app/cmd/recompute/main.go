@@ -0,0 +1,191 @@
+// Command recompute przelicza zapisane zmiany (shift_summary, oee_temp) z nagrania rejestratora
+// MQTT bieżącym silnikiem OEE – po zmianie tuningu, katalogu produktów albo poprawce logiki.
+// Bez -apply tylko wypisuje różnice; z -apply nadpisuje zmiany, a poprzednie wartości zapisuje
+// w shift_summary_revision.
+//
+//	go run ./cmd/recompute -recording logs/mqtt_rec -from 2026-10-01T00:00:00Z -to 2026-10-08T00:00:00Z
+//	go run ./cmd/recompute -recording logs/mqtt_rec -from ... -to ... -apply -reason "nowy cykl SKU 1200"
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go_app/communication"
+	"go_app/config"
+	"go_app/core"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func main() {
+	recording := flag.String("recording", "", "nagranie rejestratora MQTT (plik .jsonl.gz lub katalog)")
+	fromFlag := flag.String("from", "", "początek zakresu (RFC3339); przeliczane są zmiany w całości w [from, to)")
+	toFlag := flag.String("to", "", "koniec zakresu (RFC3339)")
+	apply := flag.Bool("apply", false, "zapisz wynik w bazie (domyślnie tylko różnice)")
+	reason := flag.String("reason", "", "powód przeliczenia (wymagany z -apply, zapisywany w shift_summary_revision)")
+	maxGap := flag.Duration("max-gap", 5*time.Minute, "pomiń zmianę z dłuższą przerwą w nagraniu (0 = bez limitu)")
+	flag.Parse()
+
+	if *recording == "" || *fromFlag == "" || *toFlag == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	from, err := time.Parse(time.RFC3339, *fromFlag)
+	if err != nil {
+		fatal(fmt.Errorf("-from: %w", err))
+	}
+	to, err := time.Parse(time.RFC3339, *toFlag)
+	if err != nil {
+		fatal(fmt.Errorf("-to: %w", err))
+	}
+	if *apply && *reason == "" {
+		fatal(errors.New("-apply requires -reason"))
+	}
+	shifts := core.ShiftRanges(from, to)
+	if len(shifts) == 0 {
+		fatal(errors.New("no complete shift in range"))
+	}
+	if err := config.Validate(); err != nil {
+		fatal(err)
+	}
+	core.LoadProductCatalogue()
+
+	rc := &run{shifts: shifts, apply: *apply, reason: *reason, maxGap: *maxGap}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err = communication.ReplayRecordingContext(ctx, *recording, 0, func(rec communication.RecordedMessage, msg mqtt.Message) {
+		ts := rec.ReceivedTime()
+		if ts.IsZero() {
+			if !core.IsInputTopic(msg.Topic()) {
+				communication.HandleMessage(msg)
+			}
+			return
+		}
+		if !rc.message(ts, msg) {
+			cancel()
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fatal(err)
+	}
+	rc.finishRecording()
+
+	mode := "dry run, nothing written"
+	if rc.apply {
+		mode = "applied"
+	}
+	fmt.Printf("%d shifts: %d changed, %d unchanged, %d skipped (%s)\n",
+		len(shifts), rc.changed, rc.unchanged, rc.skipped, mode)
+}
+
+// run – przebieg nagrania: zmiany są przeliczane po kolei, jedna naraz.
+type run struct {
+	shifts []core.ShiftRange
+	next   int // indeks pierwszej nierozpoczętej zmiany
+	cur    *core.ShiftRecompute
+	apply  bool
+	reason string
+	maxGap time.Duration
+	inputs []core.ReplayInput // wszystkie wejścia nagrania (zlecenia) – stan na początek każdej zmiany
+
+	changed, unchanged, skipped int
+}
+
+// message – jedna wiadomość nagrania; false = wszystkie zmiany zakresu przeliczone.
+func (r *run) message(ts time.Time, msg mqtt.Message) bool {
+	for {
+		if r.cur != nil && !ts.Before(r.cur.End) {
+			r.report(r.cur.Finish(), "")
+			r.cur = nil
+			continue
+		}
+		if r.cur == nil && r.next < len(r.shifts) && !ts.Before(r.shifts[r.next].Start) {
+			// stan portów sprzed pierwszej wiadomości zmiany
+			r.cur = core.NewShiftRecompute(r.shifts[r.next], communication.GetMQTTData(), r.inputs)
+			r.next++
+			continue
+		}
+		break
+	}
+	var inputs []core.ReplayInput
+	if in, ok := core.InputFromMessage(msg.Topic(), msg.Payload(), msg.Retained()); ok {
+		inputs = []core.ReplayInput{in}
+		r.inputs = append(r.inputs, in)
+	} else if !core.IsInputTopic(msg.Topic()) {
+		communication.HandleMessage(msg)
+	}
+	if r.cur != nil {
+		r.cur.Step(core.ReplayFrame{Timestamp: ts, Ports: communication.GetMQTTData(), Inputs: inputs})
+	}
+	return r.cur != nil || r.next < len(r.shifts)
+}
+
+// finishRecording – koniec nagrania: zmiany niezakończone albo bez nagrania są pomijane.
+func (r *run) finishRecording() {
+	if r.cur != nil {
+		r.report(r.cur.Finish(), "recording ends before shift end")
+		r.cur = nil
+	}
+	for ; r.next < len(r.shifts); r.next++ {
+		r.skip(r.shifts[r.next], "not in recording")
+	}
+}
+
+func (r *run) skip(sr core.ShiftRange, why string) {
+	r.skipped++
+	fmt.Printf("%s  skipped: %s\n", shiftLabel(sr), why)
+}
+
+func (r *run) report(rs core.RecomputedShift, incomplete string) {
+	if incomplete != "" {
+		r.skip(rs.ShiftRange, incomplete)
+		return
+	}
+	if r.maxGap > 0 && rs.MaxGap > r.maxGap {
+		r.skip(rs.ShiftRange, fmt.Sprintf("gap of %s in recording", rs.MaxGap.Round(time.Second)))
+		return
+	}
+	stored, err := core.LoadStoredShift(rs.End)
+	if err != nil {
+		fatal(err)
+	}
+	if stored == nil {
+		r.skip(rs.ShiftRange, "no shift_summary row")
+		return
+	}
+
+	values := core.RecomputedValues(rs, stored)
+	changes := core.DiffShift(stored, values)
+	if len(changes) == 0 {
+		r.unchanged++
+		fmt.Printf("%s  unchanged (%d messages)\n", shiftLabel(rs.ShiftRange), rs.Messages)
+		return
+	}
+	r.changed++
+	fmt.Printf("%s  %d changes (%d messages)\n", shiftLabel(rs.ShiftRange), len(changes), rs.Messages)
+	for _, c := range changes {
+		old := "NULL"
+		if c.Old != nil {
+			old = fmt.Sprintf("%.4f", *c.Old)
+		}
+		fmt.Printf("    %-24s %12s -> %.4f\n", c.Column, old, c.New)
+	}
+	if r.apply {
+		if err := core.ApplyRecomputedShift(stored, rs, values, r.reason); err != nil {
+			fatal(fmt.Errorf("%s: %w", shiftLabel(rs.ShiftRange), err))
+		}
+	}
+}
+
+func shiftLabel(sr core.ShiftRange) string {
+	return sr.Start.UTC().Format("2006-01-02 15:04") + " - " + sr.End.UTC().Format("15:04") + " UTC"
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
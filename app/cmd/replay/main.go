@@ -0,0 +1,207 @@
+// Command replay przepuszcza nagraną sekwencję snapshotów portów MQTT przez silnik OEE
+// (deterministyczny zegar) i wypisuje oś czasu OeeFileFlat.
+//
+//	go run ./cmd/replay -in core/testdata/replay/idle.jsonl -every 20
+//	go run ./cmd/replay -in core/testdata/replay/idle.jsonl -golden core/testdata/replay/idle.golden.json
+//	go run ./cmd/replay -check core/testdata/replay            # wszystkie scenariusze vs pliki .golden.json
+//	go run ./cmd/replay -check core/testdata/replay -update    # przepisz pliki golden
+//	go run ./cmd/replay -recording logs/mqtt_rec               # surowe nagranie z rejestratora MQTT
+//
+// Te same pliki sprawdza go test ./core (TestReplayGolden).
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go_app/communication"
+	"go_app/core"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func main() {
+	in := flag.String("in", "", "plik z ramkami (JSON lines lub tablica JSON)")
+	recording := flag.String("recording", "", "nagranie rejestratora MQTT (plik .jsonl.gz lub katalog)")
+	out := flag.String("out", "", "plik wynikowy osi czasu (domyślnie stdout)")
+	every := flag.Int("every", 20, "co ile ramek zapisać stan do osi czasu")
+	interval := flag.Duration("interval", core.TempUpdaterInterval, "okres updaterów *_temp")
+	golden := flag.String("golden", "", "porównaj wynik z plikiem golden")
+	check := flag.String("check", "", "katalog scenariuszy: *.jsonl porównywane z *.golden.json")
+	update := flag.Bool("update", false, "zapisz wynik jako nowy plik golden")
+	flag.Parse()
+
+	opts := core.ReplayOptions{TempInterval: *interval, Every: *every}
+
+	if *check != "" {
+		if !checkDir(*check, opts, *update) {
+			os.Exit(1)
+		}
+		return
+	}
+	if *in == "" && *recording == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var timeline []byte
+	var err error
+	if *recording != "" {
+		timeline, err = runRecording(*recording, opts)
+	} else {
+		timeline, err = runScenario(*in, opts)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *golden != "" {
+		ok, err := compareOrUpdate(*golden, timeline, *update)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *out == "" {
+		os.Stdout.Write(timeline)
+		return
+	}
+	if err := os.WriteFile(*out, timeline, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func checkDir(dir string, opts core.ReplayOptions, update bool) bool {
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil || len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "no scenarios in %s\n", dir)
+		return false
+	}
+	sort.Strings(files)
+
+	allOK := true
+	for _, f := range files {
+		goldenPath := strings.TrimSuffix(f, ".jsonl") + ".golden.json"
+		timeline, err := runScenario(f, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", f, err)
+			allOK = false
+			continue
+		}
+		ok, err := compareOrUpdate(goldenPath, timeline, update)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", f, err)
+			allOK = false
+		case !ok:
+			fmt.Fprintf(os.Stderr, "FAIL %s\n", f)
+			allOK = false
+		default:
+			fmt.Printf("ok   %s\n", f)
+		}
+	}
+	return allOK
+}
+
+func runScenario(path string, opts core.ReplayOptions) ([]byte, error) {
+	frames, err := core.LoadReplayFrames(path)
+	if err != nil {
+		return nil, err
+	}
+	return marshalTimeline(core.ReplayFrames(frames, opts))
+}
+
+// runRecording przepuszcza nagranie rejestratora przez silnik wiadomość po wiadomości:
+// dane przechodzą przez handler MQTT, a po nich liczona jest ramka ze snapshotem portów
+// z czasem odbioru; zlecenia (core.IsInputTopic) są stosowane w chwili, w której przyszły.
+func runRecording(path string, opts core.ReplayOptions) ([]byte, error) {
+	if opts.Every <= 0 {
+		opts.Every = 1
+	}
+	var (
+		r        *core.Replayer
+		timeline []core.OeeFileFlat
+		pending  []core.ReplayInput
+		n        int
+	)
+	_, err := communication.ReplayRecording(path, 0, func(rec communication.RecordedMessage, msg mqtt.Message) {
+		if in, ok := core.InputFromMessage(msg.Topic(), msg.Payload(), msg.Retained()); ok {
+			pending = append(pending, in)
+		} else if !core.IsInputTopic(msg.Topic()) {
+			communication.HandleMessage(msg)
+		}
+		ts := rec.ReceivedTime()
+		if ts.IsZero() {
+			return
+		}
+		if r == nil {
+			r = core.NewReplayer(ts, opts)
+		}
+		r.Step(core.ReplayFrame{Timestamp: ts, Ports: communication.GetMQTTData(), Inputs: pending})
+		pending = nil
+		if n%opts.Every == 0 {
+			timeline = append(timeline, core.BuildOeeFlat())
+		}
+		n++
+	})
+	if r == nil {
+		if err == nil {
+			err = fmt.Errorf("%s: no messages", path)
+		}
+		return nil, err
+	}
+	if (n-1)%opts.Every != 0 {
+		timeline = append(timeline, core.BuildOeeFlat()) // ostatnia ramka zawsze, jak ReplayFrames
+	}
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	return marshalTimeline(timeline)
+}
+
+func marshalTimeline(timeline []core.OeeFileFlat) ([]byte, error) {
+	raw, err := json.MarshalIndent(timeline, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(raw, '\n'), nil
+}
+
+func compareOrUpdate(goldenPath string, got []byte, update bool) (bool, error) {
+	if update {
+		return true, os.WriteFile(goldenPath, got, 0644)
+	}
+	want, err := os.ReadFile(goldenPath)
+	if err != nil {
+		return false, err
+	}
+
+	diffs, err := core.DiffGolden(want, got)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", goldenPath, err)
+	}
+	if len(diffs) == 0 {
+		return true, nil
+	}
+	const maxShown = 20
+	for i, d := range diffs {
+		if i == maxShown {
+			fmt.Fprintf(os.Stderr, "  ... and %d more\n", len(diffs)-maxShown)
+			break
+		}
+		fmt.Fprintln(os.Stderr, "  "+d)
+	}
+	return false, nil
+}
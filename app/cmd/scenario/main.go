@@ -0,0 +1,153 @@
+// Command scenario przepuszcza scenariusz produkcji (YAML, communication/fake) przez silnik OEE
+// na ręcznym zegarze i porównuje wynik z wartościami oczekiwanymi ze scenariusza.
+//
+//	go run ./cmd/scenario -file cmd/scenario/scenarios/breakdown.yaml
+//	go run ./cmd/scenario -check cmd/scenario/scenarios
+//	go run ./cmd/scenario -file scen.yaml -frames scen.jsonl   # ramki dla cmd/replay
+//
+// Ten sam plik można uruchomić „na żywo”: SIMULATION=1 SIMULATION_SCENARIO=scen.yaml ./app
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go_app/communication/fake"
+	"go_app/core"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func main() {
+	file := flag.String("file", "", "scenariusz YAML")
+	check := flag.String("check", "", "katalog scenariuszy: każdy *.yaml musi spełnić oczekiwania")
+	frames := flag.String("frames", "", "zapisz ramki MQTT (JSON lines, format cmd/replay)")
+	flag.Parse()
+
+	var files []string
+	switch {
+	case *check != "":
+		matches, err := filepath.Glob(filepath.Join(*check, "*.yaml"))
+		if err != nil || len(matches) == 0 {
+			fmt.Fprintln(os.Stderr, "no scenarios in", *check)
+			os.Exit(1)
+		}
+		sort.Strings(matches)
+		files = matches
+	case *file != "":
+		files = []string{*file}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ok := true
+	for _, f := range files {
+		passed, err := runFile(f, *frames)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		ok = ok && passed
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func runFile(path, framesOut string) (bool, error) {
+	sc, err := fake.LoadScenario(path)
+	if err != nil {
+		return false, err
+	}
+
+	run := fake.NewScenarioRun(sc, sc.Start)
+	var replay []core.ReplayFrame
+	for {
+		fr, ok := run.Next()
+		if !ok {
+			break
+		}
+		var inputs []core.ReplayInput
+		for _, in := range fr.Inputs {
+			inputs = append(inputs, core.ReplayInput{Topic: in.Topic, Payload: in.Payload})
+		}
+		replay = append(replay, core.ReplayFrame{Timestamp: fr.Time, Ports: fr.Ports, Inputs: inputs})
+	}
+	if framesOut != "" {
+		if err := writeFrames(framesOut, replay); err != nil {
+			return false, err
+		}
+	}
+
+	timeline := core.ReplayFrames(replay, core.ReplayOptions{Every: len(replay)})
+	got := timeline[len(timeline)-1].OEE
+	losses := timeline[len(timeline)-1].Losses
+	rel := timeline[len(timeline)-1].Reliability
+	exp := run.Expected()
+
+	name := sc.Name
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	fmt.Printf("== %s (%s, %d frames)\n", name, sc.Duration(), len(replay))
+
+	checks := []struct {
+		key      string
+		got, exp float64
+		tol      float64
+	}{
+		{"ilosc_elementow", float64(got.IloscElementow), float64(exp.IloscElementow), 0},
+		{"czas_pomiaru", got.CzasPomiaru, exp.CzasPomiaru, sc.Tolerance},
+		{"czas_pracy", got.CzasPracy, exp.CzasPracy, sc.Tolerance},
+		{"czas_postoju", got.CzasPostoju, exp.CzasPostoju, sc.Tolerance},
+		{"czas_przezbrojenia", got.CzasPrzezbrojenia, exp.CzasPrzezbrojenia, sc.Tolerance},
+		{"awarie", losses.Breakdowns, exp.Awarie, sc.Tolerance},
+		{"krotkie_postoje", losses.MinorStops, exp.KrotkiePostoje, sc.Tolerance},
+		{"mikroprzestoje", float64(got.Mikroprzestoje), float64(exp.Mikroprzestoje), 0},
+		{"postoje", float64(rel.Stops), float64(exp.Postoje), 0},
+		{"odrzuty", float64(losses.Rejects()), float64(exp.Odrzuty), 0},
+	}
+
+	passed := true
+	for _, c := range checks {
+		if v, ok := sc.Expect[c.key]; ok {
+			c.exp = v
+		}
+		status := "ok"
+		if math.Abs(c.got-c.exp) > c.tol+1e-9 {
+			status = "FAIL"
+			passed = false
+		}
+		fmt.Printf("   %-20s got %10.2f  expected %10.2f  %s\n", c.key, c.got, c.exp, status)
+	}
+	fmt.Printf("   produced %d elements, %d changeover(s), energy kWh %v\n", exp.Wyprodukowane, exp.Przezbrojenia, exp.EnergiaKWh)
+	fmt.Printf("   losses [s]: setup %.1f, reduced speed %.1f, startup rejects %d (%.1f), production rejects %d (%.1f)\n",
+		losses.Setup, losses.ReducedSpeed, losses.StartupRejectPieces, losses.StartupRejects,
+		losses.ProductionRejectPieces, losses.ProductionRejects)
+	fmt.Printf("   reliability: MTBF %.1f s, MTTR %.1f s, longest stop %.1f s, %d breakdown(s)\n",
+		rel.MTBF, rel.MTTR, rel.LongestStop, rel.Breakdowns)
+	speed := timeline[len(timeline)-1].Speed.Shift
+	fmt.Printf("   speed [rpm]: mean %.2f, min %.2f, below nominal %.1f s, %.0f%% of reduced speed\n",
+		speed.MeanRPM, speed.MinRPM, speed.BelowNominalSeconds, speed.ReducedSpeedShare*100)
+	return passed, nil
+}
+
+func writeFrames(path string, frames []core.ReplayFrame) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, fr := range frames {
+		if err := enc.Encode(fr); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
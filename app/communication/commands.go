@@ -0,0 +1,72 @@
+package communication
+
+import (
+	"sync"
+	"time"
+)
+
+// commandHandlers – tematy MQTT z poleceniami (nie danymi procesu), np. start/stop zlecenia.
+// Tematy local nie są subskrybowane na brokerze – przychodzą tylko z nagrania (MQTT_REPLAY_PATH).
+var commandHandlers = struct {
+	sync.RWMutex
+	byTopic map[string]func([]byte) error
+	local   map[string]bool
+}{
+	byTopic: make(map[string]func([]byte) error),
+	local:   make(map[string]bool),
+}
+
+// HandleCommand – rejestruje obsługę tematu z poleceniami. Wywoływać przed communication.Start,
+// subskrypcja (QoS 1) powstaje przy połączeniu z brokerem.
+func HandleCommand(topic string, fn func([]byte) error) {
+	commandHandlers.Lock()
+	defer commandHandlers.Unlock()
+	commandHandlers.byTopic[topic] = fn
+}
+
+// HandleInput – obsługa wejścia zapisanego przez RecordInput (np. zlecenie z API) przy replay
+// nagrania; temat nie jest subskrybowany na brokerze.
+func HandleInput(topic string, fn func([]byte) error) {
+	commandHandlers.Lock()
+	defer commandHandlers.Unlock()
+	commandHandlers.byTopic[topic] = fn
+	commandHandlers.local[topic] = true
+}
+
+// RecordInput – wejście spoza brokera (API, PLC) do nagrania rejestratora, jak wiadomość MQTT.
+func RecordInput(topic string, payload []byte) {
+	recordMessage(topic, payload, false, 1)
+}
+
+func commandTopics() []string {
+	commandHandlers.RLock()
+	defer commandHandlers.RUnlock()
+	out := make([]string, 0, len(commandHandlers.byTopic))
+	for topic := range commandHandlers.byTopic {
+		if !commandHandlers.local[topic] {
+			out = append(out, topic)
+		}
+	}
+	return out
+}
+
+// dispatchCommand – true, jeśli temat jest tematem poleceń (wiadomość obsłużona tutaj).
+// Polecenia retained są pomijane: po restarcie brokera/programu nie mogą się wykonać drugi raz.
+func dispatchCommand(topic string, payload []byte, retained bool) bool {
+	commandHandlers.RLock()
+	fn, ok := commandHandlers.byTopic[topic]
+	commandHandlers.RUnlock()
+	if !ok {
+		return false
+	}
+	if retained {
+		mqttLog.Limit("retained "+topic, time.Minute).Warn("retained command ignored", "topic", topic)
+		return true
+	}
+	if err := fn(payload); err != nil {
+		mqttLog.Warn("command rejected", "topic", topic, "error", err)
+		return true
+	}
+	mqttLog.Info("command executed", "topic", topic)
+	return true
+}
@@ -0,0 +1,183 @@
+package fake
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Reading – jedna wartość analizatora w formacie items[] z /api/v1/measurements i /api/v1/meters.
+type Reading struct {
+	ID    string  `json:"id"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Analyzer – wirtualny trójfazowy analizator energii. Obciążenie zmienia się powoli
+// wokół LoadKW, liczniki (sumy i taryfy t1–t4) rosną o całkę mocy od poprzedniej próbki.
+type Analyzer struct {
+	mu     sync.Mutex
+	rng    *rand.Rand
+	loadKW float64
+	phase  float64 // przesunięcie profilu obciążenia, żeby analizatory nie szły równo
+
+	last     time.Time
+	lastP    [3]float64 // W na fazę z ostatniej próbki
+	lastQ    [3]float64
+	eaNeg    float64
+	erPos    float64
+	erNeg    float64
+	es       float64
+	tariffEa [4]float64
+	tariffEs [4]float64
+}
+
+// NewAnalyzer tworzy analizator o średnim obciążeniu loadKW; liczniki startują od 1000*index kWh.
+func NewAnalyzer(index int, loadKW float64, seed int64) *Analyzer {
+	a := &Analyzer{
+		rng:    rand.New(rand.NewSource(seed + int64(index))),
+		loadKW: loadKW,
+		phase:  float64(index) * 1.3,
+	}
+	start := 1000 * float64(index)
+	for t := range a.tariffEa {
+		a.tariffEa[t] = start / 4
+		a.tariffEs[t] = start / 4 / 0.95
+	}
+	a.erPos = start * 0.3
+	a.es = start / 0.95
+	return a
+}
+
+// tariffIndex – strefa taryfowa 0..3 (t1..t4) wg godziny czasu lokalnego.
+func tariffIndex(t time.Time) int {
+	h := t.Local().Hour()
+	switch {
+	case h >= 6 && h < 13:
+		return 0
+	case h >= 13 && h < 19:
+		return 1
+	case h >= 19 && h < 22:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Sample zwraca pomiary chwilowe i liczniki na chwilę now.
+func (a *Analyzer) Sample(now time.Time) ([]Reading, []Reading) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// liczniki: całka mocy z poprzedniej próbki (metoda prostokątów)
+	if !a.last.IsZero() && now.After(a.last) {
+		h := now.Sub(a.last).Hours()
+		var p, q, s float64
+		for i := 0; i < 3; i++ {
+			p += a.lastP[i]
+			q += a.lastQ[i]
+			s += math.Hypot(a.lastP[i], a.lastQ[i])
+		}
+		t := tariffIndex(now)
+		a.tariffEa[t] += p / 1000 * h
+		a.tariffEs[t] += s / 1000 * h
+		a.es += s / 1000 * h
+		a.erPos += q / 1000 * h
+		a.eaNeg += 0.00001 * h
+		a.erNeg += 0.00002 * h
+	}
+	a.last = now
+
+	// obciążenie: wolna sinusoida (~20 min) + szum, nierówno rozłożone na fazy
+	minutes := float64(now.UnixNano()) / float64(time.Minute)
+	load := a.loadKW * 1000 * (1 + 0.15*math.Sin(2*math.Pi*minutes/20+a.phase) + 0.03*(a.rng.Float64()*2-1))
+	if load < 0 {
+		load = 0
+	}
+	share := [3]float64{0.34, 0.32, 0.34}
+	freq := 50 + 0.02*(a.rng.Float64()*2-1)
+
+	var m []Reading
+	m = append(m, Reading{"f", round(freq), "Hz"})
+
+	var u [3]float64
+	for i := range u {
+		u[i] = 230 * (1 + 0.005*(a.rng.Float64()*2-1))
+		m = append(m, Reading{"u" + phaseName(i), round(u[i]), "V"})
+	}
+	for i := range u {
+		j := (i + 1) % 3
+		// napięcie międzyfazowe dla faz przesuniętych o 120°
+		ull := math.Sqrt(u[i]*u[i] + u[j]*u[j] + u[i]*u[j])
+		m = append(m, Reading{"u" + phaseName(i) + phaseName(j), round(ull), "V"})
+	}
+
+	var cur [3]float64
+	var pf [3]float64
+	var sumP, sumQ, sumS float64
+	for i := 0; i < 3; i++ {
+		pf[i] = 0.95 + 0.02*(a.rng.Float64()*2-1)
+		a.lastP[i] = load * share[i]
+		s := a.lastP[i] / pf[i]
+		a.lastQ[i] = math.Sqrt(s*s - a.lastP[i]*a.lastP[i])
+		cur[i] = s / u[i]
+		sumP += a.lastP[i]
+		sumQ += a.lastQ[i]
+		sumS += s
+	}
+	for i := range cur {
+		m = append(m, Reading{"i" + phaseName(i), round(cur[i]), "A"})
+	}
+	// prąd neutralny – suma wektorowa prądów fazowych
+	re := cur[0] - 0.5*cur[1] - 0.5*cur[2]
+	im := math.Sqrt(3) / 2 * (cur[1] - cur[2])
+	m = append(m, Reading{"in", round(math.Hypot(re, im)), "A"})
+
+	for i := 0; i < 3; i++ {
+		m = append(m, Reading{"p" + phaseName(i), round(a.lastP[i]), "W"})
+	}
+	for i := 0; i < 3; i++ {
+		m = append(m, Reading{"q" + phaseName(i), round(a.lastQ[i]), "var"})
+	}
+	for i := 0; i < 3; i++ {
+		m = append(m, Reading{"s" + phaseName(i), round(a.lastP[i] / pf[i]), "VA"})
+	}
+	for i := 0; i < 3; i++ {
+		m = append(m, Reading{"pf" + phaseName(i), round(pf[i]), ""})
+	}
+	m = append(m,
+		Reading{"p", round(sumP), "W"},
+		Reading{"q", round(sumQ), "var"},
+		Reading{"s", round(sumS), "VA"},
+		Reading{"pf", round(sumP / sumS), ""},
+	)
+
+	eaTotal := a.tariffEa[0] + a.tariffEa[1] + a.tariffEa[2] + a.tariffEa[3]
+	mt := []Reading{
+		{"ea_pos_total", round(eaTotal), "kWh"},
+		{"ea_neg_total", round(a.eaNeg), "kWh"},
+		{"er_pos_total", round(a.erPos), "kvarh"},
+		{"er_neg_total", round(a.erNeg), "kvarh"},
+		{"es_total", round(a.es), "kVAh"},
+		{"er_total", round(a.erPos + a.erNeg), "kvarh"},
+		{"ea_pos", round(eaTotal), "kWh"},
+		{"ea_neg", round(a.eaNeg), "kWh"},
+		{"er_pos", round(a.erPos), "kvarh"},
+		{"er_neg", round(a.erNeg), "kvarh"},
+		{"es", round(a.es), "kVAh"},
+		{"er", round(a.erPos + a.erNeg), "kvarh"},
+	}
+	for t := 0; t < 4; t++ {
+		n := string(rune('1' + t))
+		mt = append(mt,
+			Reading{"t" + n + "_ea_pos", round(a.tariffEa[t]), "kWh"},
+			Reading{"t" + n + "_es", round(a.tariffEs[t]), "kVAh"},
+		)
+	}
+	return m, mt
+}
+
+func phaseName(i int) string {
+	return string(rune('1' + i))
+}
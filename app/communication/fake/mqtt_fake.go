@@ -1,6 +1,7 @@
-package communication
+package fake
 
 import (
+	"go_app/config"
 	"math/rand"
 	"sync"
 	"time"
@@ -11,20 +12,46 @@ var (
 	lastSpeedSignal    = time.Now()
 	predkoscOn         = false
 	elementOn          = false
+	rejectOn           = false
 	mqttFakeMutex      sync.Mutex
 	nextElementDelayMs = rand.Intn(10000) + 2000
 
-	totaliser1 float64 = 9451.0
-	totaliser2 float64 = 2239.0
+	// totalisery przepływomierzy w kolejności config.FlowPorts
+	totalisers = initialTotalisers()
 )
 
-// GenerateMockMQTTData symuluje dane MQTT z dynamicznymi impulsami i elementami
+// flowProfiles – parametry kolejnych przepływomierzy (powtarzane, gdy FLOW_PORTS ma więcej portów).
+var flowProfiles = []struct {
+	start, step, flow, pressure, temperature float64
+}{
+	{9451.0, 0.1, 204, 669, 2930},
+	{2239.0, 0.05, 41, 668, 2690},
+	{5120.0, 0.08, 120, 671, 2810},
+	{871.0, 0.02, 12, 665, 2750},
+	{3310.0, 0.04, 77, 670, 2880},
+}
+
+func flowProfile(idx int) (start, step, flow, pressure, temperature float64) {
+	p := flowProfiles[idx%len(flowProfiles)]
+	return p.start + 1000*float64(idx/len(flowProfiles)), p.step, p.flow, p.pressure, p.temperature
+}
+
+func initialTotalisers() []float64 {
+	out := make([]float64, len(config.FlowPorts))
+	for i := range out {
+		out[i], _, _, _, _ = flowProfile(i)
+	}
+	return out
+}
+
+// GenerateMockMQTTData symuluje dane MQTT z dynamicznymi impulsami i elementami.
+// Klucze portów są takie same jak w communication.GetMQTTData ("master1/port1", ...).
 func GenerateMockMQTTData() map[string]map[string]interface{} {
 	mqttFakeMutex.Lock()
 	defer mqttFakeMutex.Unlock()
 
 	now := time.Now()
-	timestamp := now.UTC().Format(time.RFC3339)
+	timestamp := now.UTC().Format(time.RFC3339Nano)
 
 	if time.Since(lastSpeedSignal) > 200*time.Millisecond {
 		predkoscOn = !predkoscOn
@@ -35,49 +62,53 @@ func GenerateMockMQTTData() map[string]map[string]interface{} {
 		elementOn = true
 		lastElementTime = now
 		nextElementDelayMs = rand.Intn(10000) + 2000
+		rejectOn = rand.Intn(50) == 0 // ~2% odrzutów
 	} else {
 		elementOn = false
+		rejectOn = false
 	}
 
 	// Inkrementacja totaliserów — symulacja rzeczywistego przyrostu
-	totaliser1 += 0.1
-	totaliser2 += 0.05
+	for i := range totalisers {
+		_, step, _, _, _ := flowProfile(i)
+		totalisers[i] += step
+	}
 
 	//utils.LogMessage(fmt.Sprintf("NOWY TIMESTAMP: %s", timestamp))
 
-	return map[string]map[string]interface{}{
-		"port1": {
-			"maszyna_on/off":  false,
+	ports := map[string]map[string]interface{}{
+		"master1/port1": {
+			"maszyna_on/off":  true,
 			"Elementy":        elementOn,
 			"Predkosc_sygnal": predkoscOn,
+			"Odrzut":          rejectOn,
 			"is_valid":        true,
 			"timestamp":       timestamp,
 		},
-		"port2": {
+		"master1/port2": {
 			"Dlugosc":   22648,
 			"Szerokosc": 1286,
 			"Wysokosc":  2205,
 			"is_valid":  true,
 			"timestamp": timestamp,
 		},
-		"port3": {
-			"device_status": 0,
-			"flow":          204,
-			"is_valid":      true,
-			"pressure":      669,
-			"temperature":   2930,
-			"timestamp":     timestamp,
-			"totaliser":     totaliser1,
-		},
-		"port4": {
-			"device_status": 0,
-			"flow":          41,
-			"is_valid":      true,
-			"pressure":      668,
-			"temperature":   2690,
-			"timestamp":     timestamp,
-			"totaliser":     totaliser2,
-		},
+	}
+	for i, port := range config.FlowPorts {
+		_, _, flow, pressure, temperature := flowProfile(i)
+		ports[port] = flowPort(i, flow, pressure, temperature, timestamp)
+	}
+	return ports
+}
+
+func flowPort(idx int, flow, pressure, temperature float64, timestamp string) map[string]interface{} {
+	return map[string]interface{}{
+		"device_status": 0,
+		"flow":          flow,
+		"is_valid":      true,
+		"pressure":      pressure,
+		"temperature":   temperature,
+		"timestamp":     timestamp,
+		"totaliser":     totalisers[idx],
 	}
 }
 
@@ -91,8 +122,7 @@ func ResetMQTTGeneratorState() {
 	nextElementDelayMs = rand.Intn(10000) + 2000
 	predkoscOn = false
 	elementOn = false
-	totaliser1 = 9451.0
-	totaliser2 = 2239.0
+	totalisers = initialTotalisers()
 }
 
 // ForceMQTTGeneratorUpdate wymusza aktualizację stanu generatora
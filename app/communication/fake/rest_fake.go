@@ -1,37 +1,52 @@
-package communication
+package fake
 
 import (
 	"go_app/config"
 	"go_app/utils"
 	"math"
 	"math/rand"
+	"os"
 	"strconv"
+	"sync"
 	"time"
 )
 
+// Stan generatorów REST trzymany w pamięci: pomiary oscylują wokół wartości startowych,
+// a liczniki energii rosną monotonicznie z czasem.
+var (
+	restFakeMutex   sync.Mutex
+	fakeMeasurement map[string][]map[string]interface{}
+	fakeMeters      map[string][]map[string]interface{}
+	lastMetersTick  time.Time
+)
+
 func GenerateMockRestData() map[string][]map[string]interface{} {
-	existing := utils.LoadFromJSONMapArray(config.FakeMeasurementFilePath)
-	if len(existing) == 0 {
-		return generateEmptyMeasurement()
+	restFakeMutex.Lock()
+	defer restFakeMutex.Unlock()
+
+	if fakeMeasurement == nil {
+		fakeMeasurement = loadSeed(config.FakeMeasurementFilePath)
+		if len(fakeMeasurement) == 0 {
+			fakeMeasurement = generateEmptyMeasurement()
+		}
 	}
 
+	now := time.Now().UTC().Format(time.RFC3339Nano)
 	updated := make(map[string][]map[string]interface{})
-	for deviceID, records := range existing {
+	for deviceID, records := range fakeMeasurement {
 		newRecords := make([]map[string]interface{}, 0, len(records))
 		for _, rec := range records {
-			id := rec["id"].(string)
 			value := utils.ToFloat(rec["value"])
-			unit := rec["unit"].(string)
 
 			// ±0.01% zmiana
 			delta := value * 0.0001
 			newValue := value + (rand.Float64()*2-1)*delta
 
 			newRecords = append(newRecords, map[string]interface{}{
-				"id":        id,
+				"id":        rec["id"],
 				"value":     round(newValue),
-				"unit":      unit,
-				"timestamp": time.Now().UTC().Format(time.RFC3339),
+				"unit":      rec["unit"],
+				"timestamp": now,
 			})
 		}
 		updated[deviceID] = newRecords
@@ -40,28 +55,36 @@ func GenerateMockRestData() map[string][]map[string]interface{} {
 }
 
 func GenerateMockMetersData() map[string][]map[string]interface{} {
-	existing := utils.LoadFromJSONMapArray(config.FakeMetersFilePath)
-	if len(existing) == 0 {
-		return generateEmptyMeters()
+	restFakeMutex.Lock()
+	defer restFakeMutex.Unlock()
+
+	if fakeMeters == nil {
+		fakeMeters = loadSeed(config.FakeMetersFilePath)
+		if len(fakeMeters) == 0 {
+			fakeMeters = generateEmptyMeters()
+		}
+		lastMetersTick = time.Now()
 	}
 
+	// przyrost energii proporcjonalny do czasu od poprzedniego odczytu (~5–15 kW na analizator)
+	dtHours := time.Since(lastMetersTick).Hours()
+	lastMetersTick = time.Now()
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
 	updated := make(map[string][]map[string]interface{})
-	for deviceID, records := range existing {
+	for deviceID, records := range fakeMeters {
 		newRecords := make([]map[string]interface{}, 0, len(records))
 		for _, rec := range records {
-			id := rec["id"].(string)
-			unit := rec["unit"].(string)
-			value := utils.ToFloat(rec["value"])
-
-			// Dodaj losowy narastający wzrost (symulacja energii)
-			increment := rand.Float64() * 0.1
-			newValue := value + increment
+			// Dodaj losowy narastający wzrost (symulacja energii) i zapamiętaj go
+			increment := (5 + rand.Float64()*10) * dtHours
+			value := utils.ToFloat(rec["value"]) + increment
+			rec["value"] = value
 
 			newRecords = append(newRecords, map[string]interface{}{
-				"id":        id,
-				"value":     round(newValue),
-				"unit":      unit,
-				"timestamp": time.Now().UTC().Format(time.RFC3339),
+				"id":        rec["id"],
+				"value":     round(value),
+				"unit":      rec["unit"],
+				"timestamp": now,
 			})
 		}
 		updated[deviceID] = newRecords
@@ -69,13 +92,47 @@ func GenerateMockMetersData() map[string][]map[string]interface{} {
 	return updated
 }
 
+// loadSeed czyta opcjonalny plik startowy; brak pliku nie jest błędem.
+func loadSeed(path string) map[string][]map[string]interface{} {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	return utils.LoadFromJSONMapArray(path)
+}
+
+var measurementDefaults = []struct {
+	id    string
+	value float64
+	unit  string
+}{
+	{"f", 50.0, "Hz"},
+	{"u1", 230.0, "V"}, {"u2", 231.0, "V"}, {"u3", 229.5, "V"},
+	{"u12", 399.0, "V"}, {"u23", 400.0, "V"}, {"u31", 398.5, "V"},
+	{"i1", 5.0, "A"}, {"i2", 4.8, "A"}, {"i3", 5.2, "A"}, {"in", 0.3, "A"},
+	{"p1", 1100.0, "W"}, {"p2", 1050.0, "W"}, {"p3", 1150.0, "W"},
+	{"q1", 210.0, "var"}, {"q2", 200.0, "var"}, {"q3", 220.0, "var"},
+	{"s1", 1150.0, "VA"}, {"s2", 1100.0, "VA"}, {"s3", 1200.0, "VA"},
+	{"pf1", 0.96, ""}, {"pf2", 0.95, ""}, {"pf3", 0.96, ""},
+	{"p", 3300.0, "W"}, {"q", 630.0, "var"}, {"s", 3450.0, "VA"}, {"pf", 0.96, ""},
+}
+
+var meterIDs = []struct {
+	id   string
+	unit string
+}{
+	{"ea_pos_total", "kWh"}, {"ea_neg_total", "kWh"}, {"er_pos_total", "kvarh"}, {"er_neg_total", "kvarh"},
+	{"es_total", "kVAh"}, {"er_total", "kvarh"},
+	{"ea_pos", "kWh"}, {"ea_neg", "kWh"}, {"er_pos", "kvarh"}, {"er_neg", "kvarh"}, {"es", "kVAh"}, {"er", "kvarh"},
+	{"t1_ea_pos", "kWh"}, {"t1_es", "kVAh"}, {"t2_ea_pos", "kWh"}, {"t2_es", "kVAh"},
+	{"t3_ea_pos", "kWh"}, {"t3_es", "kVAh"}, {"t4_ea_pos", "kWh"}, {"t4_es", "kVAh"},
+}
+
 func generateEmptyMeasurement() map[string][]map[string]interface{} {
 	mock := make(map[string][]map[string]interface{})
-	for i := 1; i <= config.MeasurementDeviceCount; i++ {
+	for i := 1; i <= len(config.AnalyzerIPs); i++ {
 		key := "device_" + strconv.Itoa(i)
-		mock[key] = []map[string]interface{}{
-			{"id": "u1", "value": 230.0, "unit": "V", "timestamp": time.Now().UTC().Format(time.RFC3339)},
-			{"id": "i1", "value": 5.0, "unit": "A", "timestamp": time.Now().UTC().Format(time.RFC3339)},
+		for _, d := range measurementDefaults {
+			mock[key] = append(mock[key], map[string]interface{}{"id": d.id, "value": d.value, "unit": d.unit})
 		}
 	}
 	return mock
@@ -83,11 +140,10 @@ func generateEmptyMeasurement() map[string][]map[string]interface{} {
 
 func generateEmptyMeters() map[string][]map[string]interface{} {
 	mock := make(map[string][]map[string]interface{})
-	for i := 1; i <= config.MeasurementDeviceCount; i++ {
+	for i := 1; i <= len(config.AnalyzerIPs); i++ {
 		key := "device_" + strconv.Itoa(i)
-		mock[key] = []map[string]interface{}{
-			{"id": "t4_ea_pos", "value": 100.0, "unit": "kWh", "timestamp": time.Now().UTC().Format(time.RFC3339)},
-			{"id": "t4_es", "value": 80.0, "unit": "kVarh", "timestamp": time.Now().UTC().Format(time.RFC3339)},
+		for _, m := range meterIDs {
+			mock[key] = append(mock[key], map[string]interface{}{"id": m.id, "value": 1000.0 * float64(i), "unit": m.unit})
 		}
 	}
 	return mock
@@ -0,0 +1,509 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Akcje kroków scenariusza.
+const (
+	ActionRun           = "run"            // produkcja z zadaną wydajnością (szt./min)
+	ActionStop          = "stop"           // maszyna włączona, brak elementów
+	ActionBreakdown     = "breakdown"      // awaria – maszyna wyłączona
+	ActionChangeProduct = "change_product" // zmiana wymiarów produktu (natychmiast)
+	ActionSensorOffline = "sensor_offline" // port MQTT / analizator niedostępny przez duration
+	ActionMeterRollover = "meter_rollover" // przekręcenie licznika energii analizatora (natychmiast)
+	ActionInput         = "input"          // polecenie spoza portów (zlecenie, sygnał przezbrojenia) w następnej ramce
+)
+
+var scenarioStart = time.Date(2025, 1, 6, 6, 0, 0, 0, time.UTC)
+
+// Scenario – opis przebiegu produkcji dla symulatora (plik YAML).
+type Scenario struct {
+	Name      string             `yaml:"name"`
+	Start     time.Time          `yaml:"start"`     // czas pierwszej ramki w trybie offline
+	Step      time.Duration      `yaml:"step"`      // odstęp ramek MQTT (domyślnie 500ms)
+	Analyzers int                `yaml:"analyzers"` // liczba analizatorów (domyślnie len(config.AnalyzerIPs))
+	Loop      bool               `yaml:"loop"`      // tryb na żywo: po ostatnim kroku zacznij od nowa
+	Product   Product            `yaml:"product"`   // produkt na starcie
+	Steps     []ScenarioStep     `yaml:"steps"`
+	Expect    map[string]float64 `yaml:"expect"`    // jawne wartości oczekiwane (nadpisują model)
+	Tolerance float64            `yaml:"tolerance"` // dopuszczalna różnica czasów [s], domyślnie 2*step
+}
+
+// Product – wymiary produktu w mm (wartości *_calc silnika OEE).
+type Product struct {
+	Length float64 `yaml:"length"`
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+}
+
+// ScenarioStep – jeden krok scenariusza. Kroki natychmiastowe nie mają duration.
+type ScenarioStep struct {
+	Action   string        `yaml:"action"`
+	Duration time.Duration `yaml:"duration"`
+	Rate     float64       `yaml:"rate"`     // run: szt./min, 0 = cykl idealny z tuning.cycle_table
+	Rejects  int           `yaml:"rejects"`  // run: co który element jest odrzutem (0 = bez odrzutów)
+	Product  *Product      `yaml:"product"`  // change_product
+	Port     string        `yaml:"port"`     // sensor_offline: klucz portu ("master1/port1")
+	Analyzer int           `yaml:"analyzer"` // sensor_offline / meter_rollover: numer analizatora 1..N
+	Topic    string        `yaml:"topic"`    // input: temat polecenia (core.InputTopic*, MQTT_ORDER_TOPIC)
+	Payload  interface{}   `yaml:"payload"`  // input: treść polecenia (JSON)
+}
+
+// LoadScenario czyta i waliduje scenariusz YAML, uzupełniając wartości domyślne.
+func LoadScenario(path string) (*Scenario, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := yaml.Unmarshal(raw, &sc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+func (sc *Scenario) validate() error {
+	if sc.Step <= 0 {
+		sc.Step = 500 * time.Millisecond
+	}
+	if sc.Start.IsZero() {
+		sc.Start = scenarioStart
+	}
+	if sc.Analyzers <= 0 {
+		sc.Analyzers = len(config.AnalyzerIPs)
+	}
+	if sc.Tolerance <= 0 {
+		sc.Tolerance = 2 * sc.Step.Seconds()
+	}
+	if sc.Product.Length <= 0 || sc.Product.Width <= 0 {
+		return fmt.Errorf("product: length and width are required")
+	}
+	if len(sc.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+
+	var total time.Duration
+	for i, st := range sc.Steps {
+		where := "step " + strconv.Itoa(i+1) + " (" + st.Action + ")"
+		switch st.Action {
+		case ActionRun:
+			if st.Rate < 0 {
+				return fmt.Errorf("%s: negative rate", where)
+			}
+			if st.Rejects < 0 {
+				return fmt.Errorf("%s: negative rejects", where)
+			}
+			rate := st.Rate
+			if rate == 0 {
+				rate = cycleFor(sc.Product)
+			}
+			// element musi zgasnąć między impulsami, inaczej silnik nie zobaczy zbocza
+			if time.Duration(60/rate*float64(time.Second)) < 2*sc.Step {
+				return fmt.Errorf("%s: rate %.2f/min too high for step %s", where, rate, sc.Step)
+			}
+		case ActionStop, ActionBreakdown:
+		case ActionSensorOffline:
+			if st.Port == "" && st.Analyzer == 0 {
+				return fmt.Errorf("%s: port or analyzer is required", where)
+			}
+		case ActionChangeProduct:
+			if st.Product == nil || st.Product.Length <= 0 || st.Product.Width <= 0 {
+				return fmt.Errorf("%s: product with length and width is required", where)
+			}
+			continue
+		case ActionMeterRollover:
+			if st.Analyzer < 1 || st.Analyzer > sc.Analyzers {
+				return fmt.Errorf("%s: analyzer must be 1..%d", where, sc.Analyzers)
+			}
+			continue
+		case ActionInput:
+			if st.Topic == "" {
+				return fmt.Errorf("%s: topic is required", where)
+			}
+			if _, err := json.Marshal(st.Payload); err != nil {
+				return fmt.Errorf("%s: payload: %w", where, err)
+			}
+			continue
+		default:
+			return fmt.Errorf("%s: unknown action", where)
+		}
+		if st.Duration <= 0 {
+			return fmt.Errorf("%s: duration is required", where)
+		}
+		if st.Analyzer < 0 || st.Analyzer > sc.Analyzers {
+			return fmt.Errorf("%s: analyzer must be 1..%d", where, sc.Analyzers)
+		}
+		total += st.Duration
+	}
+	if total == 0 {
+		return fmt.Errorf("scenario has no timed steps")
+	}
+	return nil
+}
+
+// Duration – łączny czas scenariusza (suma kroków z duration).
+func (sc *Scenario) Duration() time.Duration {
+	var d time.Duration
+	for _, st := range sc.Steps {
+		d += st.Duration
+	}
+	return d
+}
+
+// cycleFor – cykl idealny produktu, ta sama reguła co core.determineCycleRate (produkt spoza katalogu).
+func cycleFor(p Product) float64 {
+	t := config.CurrentTuning()
+	for _, entry := range t.CycleTable {
+		if p.Length <= float64(entry.MaxLength) && p.Width <= float64(entry.MaxWidth) {
+			return entry.CycleLPM
+		}
+	}
+	return t.ProductionCycleDefault
+}
+
+// rawDimensions – odwrotność core.updateDimensions (wartości surowe z czujników portu 2).
+func rawDimensions(p Product) (float64, float64, float64) {
+	return (p.Length + 20) * 10, (p.Width - 100) * 10, (p.Height + 5.5) * 100
+}
+
+// ScenarioFrame – stan portów MQTT w chwili Time (jak z communication.GetMQTTData) i polecenia
+// wykonywane w tej chwili.
+type ScenarioFrame struct {
+	Time   time.Time
+	Ports  map[string]map[string]interface{}
+	Inputs []ScenarioInput
+}
+
+// ScenarioInput – polecenie z kroku input.
+type ScenarioInput struct {
+	Topic   string
+	Payload []byte
+}
+
+// ScenarioRun – jeden przebieg scenariusza. Ramki powstają leniwie przez Next(),
+// dane analizatorów (Measurements/Meters) odpowiadają ostatniej ramce.
+type ScenarioRun struct {
+	sc    *Scenario
+	start time.Time
+	end   time.Duration
+
+	t        time.Duration // offset następnej ramki
+	stepIdx  int
+	stepEnd  time.Duration
+	cursor   time.Duration // początek następnego kroku
+	mode     string
+	period   time.Duration
+	nextElem time.Duration
+	rejects  int // co który element jest odrzutem w bieżącym kroku run
+	product  Product
+	speedOn  bool
+	produced int
+	inputs   []ScenarioInput // polecenia do następnej ramki
+
+	portOffline     map[string]time.Duration
+	analyzerOffline map[int]time.Duration
+	power           []float64 // kW, aktualny pobór analizatorów
+	counters        []float64 // kWh, stan licznika (z przekręceniami)
+	consumed        []float64 // kWh, faktyczne zużycie od startu
+	totalisers      []float64
+
+	model expectModel
+	last  time.Time
+}
+
+// NewScenarioRun przygotowuje przebieg. start – czas pierwszej ramki
+// (zero = Scenario.Start; tryb na żywo podaje time.Now()).
+func NewScenarioRun(sc *Scenario, start time.Time) *ScenarioRun {
+	if start.IsZero() {
+		start = sc.Start
+	}
+	r := &ScenarioRun{
+		sc:              sc,
+		start:           start.UTC(),
+		end:             sc.Duration(),
+		mode:            ActionStop,
+		product:         sc.Product,
+		portOffline:     map[string]time.Duration{},
+		analyzerOffline: map[int]time.Duration{},
+		power:           make([]float64, sc.Analyzers),
+		counters:        make([]float64, sc.Analyzers),
+		consumed:        make([]float64, sc.Analyzers),
+		totalisers:      initialTotalisers(),
+		model: expectModel{
+			timeout:       float64(config.CurrentTuning().IdleTimeoutSeconds),
+			maxChangeover: config.CurrentTuning().MaxChangeoverDuration,
+			tolerance:     config.CurrentTuning().ChangeoverTolerance,
+			breakdownAt:   config.CurrentTuning().BreakdownThresholdSeconds,
+			microFactor:   config.CurrentTuning().MicroStopFactor,
+			step:          sc.Step.Seconds(),
+		},
+	}
+	for i := range r.counters {
+		r.counters[i] = 1000 * float64(i+1)
+	}
+	return r
+}
+
+// Next zwraca kolejną ramkę; false po końcu scenariusza.
+func (r *ScenarioRun) Next() (ScenarioFrame, bool) {
+	if r.t > r.end {
+		return ScenarioFrame{}, false
+	}
+	t := r.t
+	r.advanceSteps(t)
+
+	if t > 0 {
+		r.accumulate(r.sc.Step.Hours())
+	}
+
+	element, reject := false, false
+	if r.mode == ActionRun && t >= r.nextElem {
+		element = true
+		r.produced++
+		r.nextElem += r.period
+		reject = r.rejects > 0 && r.produced%r.rejects == 0
+	}
+	machineOn := r.mode != ActionBreakdown
+	if r.mode == ActionRun {
+		r.speedOn = !r.speedOn
+	} else {
+		r.speedOn = false
+	}
+
+	now := r.start.Add(t)
+	ts := now.Format(time.RFC3339Nano)
+	l, w, h := rawDimensions(r.product)
+	ports := map[string]map[string]interface{}{
+		"master1/port1": {
+			"maszyna_on/off":  machineOn,
+			"Elementy":        element,
+			"Predkosc_sygnal": r.speedOn,
+			"Odrzut":          reject,
+			"is_valid":        true,
+			"timestamp":       ts,
+		},
+		"master1/port2": {
+			"Dlugosc":   l,
+			"Szerokosc": w,
+			"Wysokosc":  h,
+			"is_valid":  true,
+			"timestamp": ts,
+		},
+	}
+	for i, key := range config.FlowPorts {
+		ports[key] = r.flowPort(i, machineOn, ts)
+	}
+	for key, until := range r.portOffline {
+		if t < until {
+			ports[key] = map[string]interface{}{"is_valid": false, "timestamp": ts}
+		}
+	}
+
+	// model oczekiwań widzi to samo co silnik: element i wymiary z portów, nie stan maszyny
+	p1 := ports["master1/port1"]
+	r.model.observe(t.Seconds(), asBool(p1["Elementy"]), asBool(p1["Odrzut"]),
+		observedCycle(ports["master1/port2"]), observedDims(ports["master1/port2"]))
+
+	r.last = now
+	r.t += r.sc.Step
+	inputs := r.inputs
+	r.inputs = nil
+	return ScenarioFrame{Time: now, Ports: ports, Inputs: inputs}, true
+}
+
+// advanceSteps stosuje wszystkie kroki, które zaczynają się najpóźniej w t.
+func (r *ScenarioRun) advanceSteps(t time.Duration) {
+	for r.stepIdx < len(r.sc.Steps) && t >= r.stepEnd {
+		st := r.sc.Steps[r.stepIdx]
+		r.stepIdx++
+		switch st.Action {
+		case ActionChangeProduct:
+			r.product = *st.Product
+			continue
+		case ActionMeterRollover:
+			r.counters[st.Analyzer-1] = 0
+			continue
+		case ActionInput:
+			payload, _ := json.Marshal(st.Payload) // sprawdzone w validate
+			r.inputs = append(r.inputs, ScenarioInput{Topic: st.Topic, Payload: payload})
+			continue
+		case ActionRun:
+			rate := st.Rate
+			if rate == 0 {
+				rate = cycleFor(r.product)
+			}
+			r.mode = ActionRun
+			r.rejects = st.Rejects
+			r.period = time.Duration(60 / rate * float64(time.Second))
+			r.nextElem = r.cursor + r.period
+		case ActionStop, ActionBreakdown:
+			r.mode = st.Action
+		case ActionSensorOffline:
+			// maszyna pracuje dalej jak w poprzednim kroku, znika tylko odczyt
+			if st.Port != "" {
+				r.portOffline[st.Port] = r.cursor + st.Duration
+			}
+			if st.Analyzer > 0 {
+				r.analyzerOffline[st.Analyzer] = r.cursor + st.Duration
+			}
+		}
+		r.cursor += st.Duration
+		r.stepEnd = r.cursor
+	}
+}
+
+// accumulate – przyrost liczników energii (za dt godzin) i totaliserów (na ramkę) w bieżącym trybie.
+func (r *ScenarioRun) accumulate(dtHours float64) {
+	for i := range r.power {
+		base := 1 + 0.1*float64(i)
+		switch r.mode {
+		case ActionRun:
+			r.power[i] = 8 * base
+		case ActionStop:
+			r.power[i] = 2 * base
+		default:
+			r.power[i] = 0.3 * base
+		}
+		r.counters[i] += r.power[i] * dtHours
+		r.consumed[i] += r.power[i] * dtHours
+	}
+	factor := 0.0
+	switch r.mode {
+	case ActionRun:
+		factor = 1
+	case ActionStop:
+		factor = 0.2
+	}
+	for i := range r.totalisers {
+		_, step, _, _, _ := flowProfile(i)
+		r.totalisers[i] += step * factor
+	}
+}
+
+func (r *ScenarioRun) flowPort(idx int, machineOn bool, ts string) map[string]interface{} {
+	flow := 0.0
+	if machineOn {
+		_, _, flow, _, _ = flowProfile(idx)
+		if r.mode != ActionRun {
+			flow *= 0.2
+		}
+	}
+	return map[string]interface{}{
+		"device_status": 0,
+		"flow":          flow,
+		"is_valid":      true,
+		"pressure":      669.0,
+		"temperature":   2900.0,
+		"timestamp":     ts,
+		"totaliser":     math.Round(r.totalisers[idx]*1000) / 1000,
+	}
+}
+
+// Measurements – pomiary analizatorów dla ostatniej ramki (offline analizatory pominięte).
+func (r *ScenarioRun) Measurements() map[string][]map[string]interface{} {
+	ts := r.last.Format(time.RFC3339Nano)
+	out := make(map[string][]map[string]interface{})
+	for i, kw := range r.power {
+		if r.isAnalyzerOffline(i + 1) {
+			continue
+		}
+		p := kw * 1000
+		phaseI := p / 3 / 230 / 0.96
+		values := map[string]float64{
+			"p": p, "p1": p / 3, "p2": p / 3, "p3": p / 3,
+			"q": p * 0.29, "q1": p * 0.29 / 3, "q2": p * 0.29 / 3, "q3": p * 0.29 / 3,
+			"s": p / 0.96, "s1": p / 0.96 / 3, "s2": p / 0.96 / 3, "s3": p / 0.96 / 3,
+			"i1": phaseI, "i2": phaseI, "i3": phaseI, "in": phaseI * 0.05,
+		}
+		key := "device_" + strconv.Itoa(i+1)
+		for _, d := range measurementDefaults {
+			v, ok := values[d.id]
+			if !ok {
+				v = d.value
+			}
+			out[key] = append(out[key], map[string]interface{}{"id": d.id, "value": round(v), "unit": d.unit, "timestamp": ts})
+		}
+	}
+	return out
+}
+
+// Meters – liczniki analizatorów dla ostatniej ramki (offline analizatory pominięte).
+func (r *ScenarioRun) Meters() map[string][]map[string]interface{} {
+	ts := r.last.Format(time.RFC3339Nano)
+	out := make(map[string][]map[string]interface{})
+	for i, kwh := range r.counters {
+		if r.isAnalyzerOffline(i + 1) {
+			continue
+		}
+		key := "device_" + strconv.Itoa(i+1)
+		for _, m := range meterIDs {
+			v := 0.0
+			switch m.unit {
+			case "kWh":
+				if m.id != "ea_neg_total" && m.id != "ea_neg" {
+					v = kwh
+				}
+			case "kVAh":
+				v = kwh / 0.96
+			case "kvarh":
+				v = kwh * 0.29
+			}
+			out[key] = append(out[key], map[string]interface{}{"id": m.id, "value": round(v), "unit": m.unit, "timestamp": ts})
+		}
+	}
+	return out
+}
+
+func (r *ScenarioRun) isAnalyzerOffline(n int) bool {
+	until, ok := r.analyzerOffline[n]
+	return ok && r.t-r.sc.Step < until
+}
+
+// Expected – wynik, który silnik OEE powinien pokazać po ostatniej ramce.
+func (r *ScenarioRun) Expected() ScenarioResult {
+	res := r.model.result()
+	res.Wyprodukowane = r.produced
+	res.EnergiaKWh = make(map[string]float64, len(r.consumed))
+	for i, kwh := range r.consumed {
+		res.EnergiaKWh["device_"+strconv.Itoa(i+1)] = round(kwh)
+	}
+	return res
+}
+
+func asBool(v interface{}) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+// observedDims – wymiary *_calc z portu 2 jak w silniku; nil = port niedostępny.
+func observedDims(port map[string]interface{}) *[3]float64 {
+	if _, ok := port["Dlugosc"]; !ok || !asBool(port["is_valid"]) {
+		return nil
+	}
+	raw := func(k string) float64 {
+		f, _ := port[k].(float64)
+		return f
+	}
+	return &[3]float64{raw("Dlugosc")/10 - 20, raw("Szerokosc")/10 + 100, raw("Wysokosc")/100 - 5.5}
+}
+
+// observedCycle – cykl, który silnik wyliczy z portu 2 (niedostępny port = wymiary zerowe).
+func observedCycle(port map[string]interface{}) float64 {
+	raw := func(k string) float64 {
+		f, _ := port[k].(float64)
+		return f
+	}
+	return cycleFor(Product{Length: raw("Dlugosc")/10 - 20, Width: raw("Szerokosc")/10 + 100})
+}
@@ -0,0 +1,157 @@
+package fake
+
+import (
+	"go_app/config"
+	"math"
+)
+
+// ScenarioResult – oczekiwany stan OEE po przebiegu scenariusza (nazwy jak w oee.json).
+type ScenarioResult struct {
+	IloscElementow    int                `json:"ilosc_elementow"` // elementy widziane przez czujnik
+	Wyprodukowane     int                `json:"wyprodukowane"`   // wszystkie, także gdy port był offline
+	CzasPomiaru       float64            `json:"czas_pomiaru"`
+	CzasPracy         float64            `json:"czas_pracy"`
+	CzasPostoju       float64            `json:"czas_postoju"`
+	CzasPrzezbrojenia float64            `json:"czas_przezbrojenia"`
+	Przezbrojenia     int                `json:"przezbrojenia"`
+	Awarie            float64            `json:"awarie"`          // postoje >= tuning.breakdown_threshold_seconds [s]
+	KrotkiePostoje    float64            `json:"krotkie_postoje"` // krótsze postoje i mikroprzestoje [s]
+	Mikroprzestoje    int                `json:"mikroprzestoje"`
+	Postoje           int                `json:"postoje"`     // postoje (bez przezbrojeń), także trwający
+	Odrzuty           int                `json:"odrzuty"`     // odrzuty widziane przez silnik
+	EnergiaKWh        map[string]float64 `json:"energia_kwh"` // faktyczne zużycie (bez przekręceń licznika)
+}
+
+// expectModel – niezależny od silnika zapis reguł updateIdleTime:
+//   - pauza zaczyna się IdleTimeoutSeconds po ostatnim elemencie, jeśli w tym czasie
+//     była ramka bez elementu, i kończy się na następnym elemencie,
+//   - pauza ze zmianą wymiarów ponad ChangeoverTolerance (wymiary ostatniego elementu przed
+//     pauzą – z poprzedniej ramki, jak w detectElement – wobec wymiarów z ramki pierwszego
+//     elementu po pauzie) nie dłuższa niż MaxChangeoverDuration to przezbrojenie, każda inna to
+//     postój – awaria od BreakdownThresholdSeconds, krótszy to krótki postój (także trwający na
+//     końcu); scenariusze nie mają zleceń ani sygnału przezbrojenia,
+//   - port wymiarów offline nie zmienia ostatnich zmierzonych wymiarów,
+//   - pierwszy element nie przesuwa ElementLastTime (jak detectElement),
+//   - przerwa między kolejnymi elementami (także od pierwszego) bez pauzy, dłuższa niż
+//     MicroStopFactor × cykl idealny z poprzedniej ramki, to mikroprzestój (przerwa − cykl),
+//   - do pierwszego elementu cały czas pomiaru jest postojem.
+type expectModel struct {
+	timeout       float64
+	maxChangeover float64
+	tolerance     config.DimTolerance
+	step          float64
+	breakdownAt   float64
+	microFactor   float64
+
+	now         float64
+	elements    int
+	lastEl      float64
+	prevEl      float64     // ostatni element, także pierwszy
+	frameCycle  float64     // cykl z poprzedniej ramki (silnik przelicza go po detekcji elementu)
+	dims        *[3]float64 // ostatnie zmierzone wymiary (nil = jeszcze brak)
+	fromDims    *[3]float64 // wymiary ostatniego elementu przed pauzą
+	pause       float64
+	changeover  float64
+	changeovers int
+	breakdowns  float64
+	minorStops  float64
+	rejects     int
+	stops       int
+	micro       int
+	microTime   float64
+}
+
+// classify – postój do awarii albo krótkich postojów.
+func (m *expectModel) classify(dur float64, breakdowns, minorStops *float64) {
+	if dur >= m.breakdownAt {
+		*breakdowns += dur
+	} else {
+		*minorStops += dur
+	}
+}
+
+// dimsChanged – zmiana wymiarów ponad tolerancję (0 = wymiar nie jest porównywany).
+func (m *expectModel) dimsChanged() bool {
+	if m.dims == nil || m.fromDims == nil {
+		return false
+	}
+	tol := [3]float64{m.tolerance.Length, m.tolerance.Width, m.tolerance.Height}
+	for i, t := range tol {
+		if t > 0 && math.Abs(m.dims[i]-m.fromDims[i]) > t {
+			return true
+		}
+	}
+	return false
+}
+
+// observe – jedna ramka; dims = wymiary z portu wymiarów (nil = port offline).
+func (m *expectModel) observe(t float64, element, reject bool, cycle float64, dims *[3]float64) {
+	m.now = t
+	if reject {
+		m.rejects++
+	}
+	prevCycle := m.frameCycle
+	m.frameCycle = cycle
+	prevDims := m.dims
+	if dims != nil {
+		m.dims = dims
+	}
+	if !element {
+		return
+	}
+
+	m.elements++
+	gap := t - m.lastEl
+	paused := gap-m.step >= m.timeout-1e-9
+	if m.elements > 1 && !paused && prevCycle > 0 {
+		cycleS := 60 / prevCycle
+		if d := t - m.prevEl; d > m.microFactor*cycleS {
+			m.micro++
+			m.microTime += d - cycleS
+		}
+	}
+	m.prevEl = t
+	if !paused {
+		m.fromDims = prevDims
+	}
+	if paused {
+		dur := gap - m.timeout
+		if m.dimsChanged() && dur <= m.maxChangeover {
+			m.changeover += dur
+			m.changeovers++
+		} else {
+			m.pause += dur
+			m.stops++
+			m.classify(dur, &m.breakdowns, &m.minorStops)
+		}
+		m.fromDims = m.dims
+	}
+	if m.elements > 1 {
+		m.lastEl = t
+	}
+}
+
+func (m *expectModel) result() ScenarioResult {
+	res := ScenarioResult{IloscElementow: m.elements, CzasPomiaru: m.now, Przezbrojenia: m.changeovers, Odrzuty: m.rejects}
+	res.Awarie, res.KrotkiePostoje = m.breakdowns, m.minorStops+m.microTime
+	res.Mikroprzestoje, res.Postoje = m.micro, m.stops
+	if m.elements == 0 {
+		res.CzasPostoju = m.now
+		m.classify(m.now, &res.Awarie, &res.KrotkiePostoje)
+		if m.now > 0 {
+			res.Postoje++
+		}
+		return res
+	}
+	res.CzasPostoju = m.pause
+	if open := m.now - m.lastEl - m.timeout; open >= 0 {
+		res.CzasPostoju += open
+		m.classify(open, &res.Awarie, &res.KrotkiePostoje)
+		if open > 0 {
+			res.Postoje++
+		}
+	}
+	res.CzasPrzezbrojenia = m.changeover
+	res.CzasPracy = math.Max(0, m.now-res.CzasPostoju-res.CzasPrzezbrojenia)
+	return res
+}
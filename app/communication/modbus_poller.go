@@ -0,0 +1,124 @@
+package communication
+
+import (
+	"context"
+	"fmt"
+	"go_app/config"
+	"go_app/modbus"
+	"go_app/utils"
+	"math"
+	"net"
+	"strconv"
+	"time"
+)
+
+// analyzerTransport – transport analizatora deviceID (1..N) wg config.AnalyzerTransports.
+func analyzerTransport(deviceID int) string {
+	if deviceID-1 < len(config.AnalyzerTransports) && config.AnalyzerTransports[deviceID-1] == "modbus" {
+		return "modbus"
+	}
+	return "rest"
+}
+
+// analyzerRegisterMap – mapa rejestrów z ANALYZER_MODBUS_MAPxx albo mapa domyślna.
+func analyzerRegisterMap(deviceID int) *modbus.RegisterMap {
+	path := ""
+	if deviceID-1 < len(config.AnalyzerModbusMaps) {
+		path = config.AnalyzerModbusMaps[deviceID-1]
+	}
+	if path == "" {
+		return modbus.DefaultAnalyzerMap()
+	}
+	m, err := modbus.LoadRegisterMap(path)
+	if err != nil {
+		utils.NewLogger("MODBUS").Error("register map error, using default map", "device", fmt.Sprintf("device_%d", deviceID), "file", path, "error", err)
+		return modbus.DefaultAnalyzerMap()
+	}
+	return m
+}
+
+func analyzerModbusAddress(deviceID int) (string, byte) {
+	addr := deviceIPs[deviceID-1]
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, config.ModbusDefaultPort)
+	}
+	unit := 1
+	if deviceID-1 < len(config.AnalyzerModbusUnits) {
+		if n, err := strconv.Atoi(config.AnalyzerModbusUnits[deviceID-1]); err == nil && n >= 0 && n <= 255 {
+			unit = n
+		}
+	}
+	return addr, byte(unit)
+}
+
+// fetchAndStoreModbusData – odpowiednik pollerów measurements + meters dla analizatorów
+// Modbus TCP: jeden odczyt mapy wypełnia oba magazyny rekordami w tym samym formacie co REST.
+func fetchAndStoreModbusData(ctx context.Context, deviceID int) {
+	key := fmt.Sprintf("device_%d", deviceID)
+	stateKey := fmt.Sprintf("MODBUS %s", key)
+	addr, unit := analyzerModbusAddress(deviceID)
+	regMap := analyzerRegisterMap(deviceID)
+	client := modbus.NewClient(addr, unit, config.ModbusTimeout)
+	defer client.Close()
+
+	log := utils.NewLogger("MODBUS").With("device", key, "addr", addr)
+	log.Info("polling", "unit", unit, "map", regMap.Name, "registers", len(regMap.Registers))
+
+	backoff := RetryPolicy{BackoffMax: config.RestBackoffMax, BackoffMultiplier: 2}
+	failures := 0
+
+	for ctx.Err() == nil {
+		func() {
+			defer utils.Catch(fmt.Sprintf("fetchAndStoreModbusData(device_%d) iteration", deviceID))()
+
+			var values []modbus.Value
+			var lastErr error
+			for attempt := 1; attempt <= 3; attempt++ {
+				values, lastErr = regMap.ReadAll(client)
+				if lastErr == nil {
+					break
+				}
+				if !utils.Sleep(ctx, config.IntervalRestData) {
+					return
+				}
+			}
+			if lastErr != nil {
+				if prev, ok := deviceState.Load(stateKey); !ok || !prev.(bool) {
+					log.Error("read failed", "error", lastErr)
+				}
+				updateDeviceState(log, stateKey, false)
+				failures++
+				return
+			}
+			failures = 0
+
+			timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+			measurements := make([]map[string]interface{}, 0, len(values))
+			meters := make([]map[string]interface{}, 0, len(values))
+			for _, v := range values {
+				rec := map[string]interface{}{
+					"id":        v.ID,
+					"value":     math.Round(v.Value*1000) / 1000, // bez szumu float32 (230.13999938 → 230.14)
+					"unit":      v.Unit,
+					"timestamp": timestamp,
+				}
+				if v.Kind == modbus.KindMeter {
+					meters = append(meters, rec)
+				} else {
+					measurements = append(measurements, rec)
+				}
+			}
+
+			if len(measurements) > 0 {
+				storeEndpointData(EndpointMeasurements, key, measurements)
+			}
+			if len(meters) > 0 {
+				storeEndpointData(EndpointMeters, key, meters)
+			}
+			updateDeviceState(log, stateKey, true)
+		}()
+
+		utils.Heartbeat(ctx)
+		utils.Sleep(ctx, backoff.nextWait(config.IntervalModbusData, failures))
+	}
+}
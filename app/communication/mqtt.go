@@ -1,6 +1,7 @@
 package communication
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"go_app/config"
@@ -11,29 +12,21 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+var mqttLog = utils.NewLogger("MQTT")
+
+// mqttData – ostatnia wiadomość z każdego portu (klucz "master1/port1", ...; porty z config.MqttTopics).
 var mqttData = struct {
 	sync.RWMutex
-	Master1Port1 map[string]interface{}
-	Master1Port2 map[string]interface{}
-	Master1Port3 map[string]interface{}
-	Master1Port4 map[string]interface{}
-	Master2Port0 map[string]interface{}
-	Master2Port1 map[string]interface{}
-	Master2Port2 map[string]interface{}
+	ports map[string]map[string]interface{}
 }{
-	Master1Port1: make(map[string]interface{}),
-	Master1Port2: make(map[string]interface{}),
-	Master1Port3: make(map[string]interface{}),
-	Master1Port4: make(map[string]interface{}),
-	Master2Port0: make(map[string]interface{}),
-	Master2Port1: make(map[string]interface{}),
-	Master2Port2: make(map[string]interface{}),
+	ports: make(map[string]map[string]interface{}),
 }
 
 var mqttFieldMapping = map[string]string{
 	"Switch State X01 - Pin 2": "maszyna_on/off",
 	"Switch State X01 - Pin 4": "Elementy",
 	"Switch State X02 - Pin 2": "Predkosc_sygnal",
+	"Switch State X02 - Pin 4": "Odrzut",
 	"Analog value port 0":      "Dlugosc",
 	"Analog value port 1":      "Wysokosc",
 	"Analog value port 2":      "Szerokosc",
@@ -59,6 +52,9 @@ var mqttEventMapping = map[string]string{
 var mqttState = struct {
 	sync.Mutex
 	offline bool
+	known   bool      // był już co najmniej jeden wynik połączenia
+	since   time.Time // ostatnie przejście ONLINE/OFFLINE
+	reason  string    // przyczyna ostatniego OFFLINE
 }{}
 
 // mqttUpdateState loguje przejścia ONLINE/OFFLINE tylko raz
@@ -66,6 +62,14 @@ func mqttUpdateState(online bool, reason string) {
 	mqttState.Lock()
 	defer mqttState.Unlock()
 
+	if !mqttState.known || mqttState.offline == online {
+		mqttState.since = time.Now()
+	}
+	mqttState.known = true
+	if !online {
+		mqttState.reason = reason
+	}
+
 	if online {
 		if mqttState.offline {
 			utils.LogMessage("[MQTT] is ONLINE again")
@@ -79,15 +83,25 @@ func mqttUpdateState(online bool, reason string) {
 	}
 }
 
+// MQTTStatus – stan połączenia źródła MQTT (broker, replay, symulacja): online, od kiedy
+// i przyczyna ostatniego rozłączenia. Przed pierwszą próbą połączenia online = false.
+func MQTTStatus() (online bool, since time.Time, reason string) {
+	mqttState.Lock()
+	defer mqttState.Unlock()
+	return mqttState.known && !mqttState.offline, mqttState.since, mqttState.reason
+}
+
 func RunMQTT() {
-	utils.Go("MQTT listener", startMQTTListener)
+	StartMQTTRecorder()
+	utils.Supervise("MQTT listener", startMQTTListener)
 }
 
-func startMQTTListener() {
+// startMQTTListener – połączenie z brokerem z ponowieniem co backoff; po anulowaniu ctx rozłącza klienta.
+func startMQTTListener(ctx context.Context) {
 	backoff := 10 * time.Second
 	firstLog := true
 
-	for {
+	for ctx.Err() == nil {
 		func() {
 			defer func() {
 				if r := recover(); r != nil {
@@ -137,16 +151,21 @@ func startMQTTListener() {
 			client := mqtt.NewClient(opts)
 			if token := client.Connect(); token.Wait() && token.Error() != nil {
 				mqttUpdateState(false, token.Error().Error())
-				time.Sleep(backoff)
+				utils.Sleep(ctx, backoff)
 				return // zamiast continue
 			}
 
 			utils.LogMessage("[MQTT] Client running, waiting for messages")
 			for client.IsConnected() {
-				time.Sleep(1 * time.Second)
+				utils.Heartbeat(ctx)
+				if !utils.Sleep(ctx, 1*time.Second) {
+					client.Disconnect(250)
+					utils.LogMessage("[MQTT] Disconnected from broker (shutdown)")
+					return
+				}
 			}
 			mqttUpdateState(false, "disconnected")
-			time.Sleep(backoff)
+			utils.Sleep(ctx, backoff)
 		}()
 	}
 }
@@ -160,19 +179,23 @@ func onMessage(_ mqtt.Client, msg mqtt.Message) {
 
 	topic := msg.Topic()
 	payload := msg.Payload()
+	recordMessage(topic, payload, msg.Retained(), msg.Qos()) // także polecenia – replay je odtwarza
+	if dispatchCommand(topic, payload, msg.Retained()) {
+		return
+	}
 
 	if len(payload) == 0 {
-		utils.LogMessage(fmt.Sprintf("[MQTT] Empty payload for topic %s", topic))
+		mqttLog.Limit("empty "+topic, time.Minute).Warn("empty payload", "topic", topic)
 		return
 	}
 
 	var raw map[string]interface{}
 	if err := json.Unmarshal(payload, &raw); err != nil {
-		utils.LogMessage(fmt.Sprintf("[MQTT] JSON decode error from %s: %v", topic, err))
+		mqttLog.Limit("decode "+topic, time.Minute).Warn("JSON decode error", "topic", topic, "error", err)
 		return
 	}
 	if raw == nil {
-		utils.LogMessage(fmt.Sprintf("[MQTT] Nil JSON from %s", topic))
+		mqttLog.Limit("nil "+topic, time.Minute).Warn("nil JSON", "topic", topic)
 		return
 	}
 
@@ -220,39 +243,15 @@ func onMessage(_ mqtt.Client, msg mqtt.Message) {
 }
 
 func assignByTopic(topic string, translated map[string]interface{}) {
-	mqttData.Lock()
-	defer mqttData.Unlock()
-
-	if len(config.MqttTopics) > 0 && topic == config.MqttTopics[0] {
-		mqttData.Master1Port1 = translated
-		return
-	}
-	if len(config.MqttTopics) > 1 && topic == config.MqttTopics[1] {
-		mqttData.Master1Port2 = translated
-		return
-	}
-	if len(config.MqttTopics) > 2 && topic == config.MqttTopics[2] {
-		mqttData.Master1Port3 = translated
-		return
-	}
-	if len(config.MqttTopics) > 3 && topic == config.MqttTopics[3] {
-		mqttData.Master1Port4 = translated
-		return
-	}
-	if len(config.MqttTopics) > 4 && topic == config.MqttTopics[4] {
-		mqttData.Master2Port0 = translated
-		return
-	}
-	if len(config.MqttTopics) > 5 && topic == config.MqttTopics[5] {
-		mqttData.Master2Port1 = translated
-		return
-	}
-	if len(config.MqttTopics) > 6 && topic == config.MqttTopics[6] {
-		mqttData.Master2Port2 = translated
+	port := config.TopicPort(topic)
+	if port == "" {
+		mqttLog.Limit("unknown "+topic, time.Minute).Warn("message from unknown topic", "topic", topic)
 		return
 	}
 
-	utils.LogMessage(fmt.Sprintf("[MQTT] Message from unknown topic: %s", topic))
+	mqttData.Lock()
+	defer mqttData.Unlock()
+	mqttData.ports[port] = translated
 }
 
 func normalizeTimestamp(v interface{}) string {
@@ -281,22 +280,24 @@ func getSubscriptions() map[string]byte {
 		}
 		topics[topic] = 0 // QoS 0
 	}
+	for _, topic := range commandTopics() {
+		topics[topic] = 1 // polecenia nie mogą zginąć
+	}
 	return topics
 }
 
-func GetMQTTData() map[string]map[string]interface{} {
+// brokerSnapshot – kopia ostatnich wiadomości z brokera (lub replay nagrania).
+func brokerSnapshot() map[string]map[string]interface{} {
 	mqttData.RLock()
 	defer mqttData.RUnlock()
 
-	return map[string]map[string]interface{}{
-		"master1/port1": copyMap(mqttData.Master1Port1),
-		"master1/port2": copyMap(mqttData.Master1Port2),
-		"master1/port3": copyMap(mqttData.Master1Port3),
-		"master1/port4": copyMap(mqttData.Master1Port4),
-		"master2/port0": copyMap(mqttData.Master2Port0),
-		"master2/port1": copyMap(mqttData.Master2Port1),
-		"master2/port2": copyMap(mqttData.Master2Port2),
+	out := make(map[string]map[string]interface{}, len(config.OeePorts)+len(config.FlowPorts))
+	for _, ports := range [][]string{config.OeePorts, config.FlowPorts} {
+		for _, port := range ports {
+			out[port] = copyMap(mqttData.ports[port])
+		}
 	}
+	return out
 }
 
 func copyMap(src map[string]interface{}) map[string]interface{} {
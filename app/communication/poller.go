@@ -0,0 +1,331 @@
+package communication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Nazwy endpointów, z których korzysta reszta programu (measurements.json, meters.json, DB).
+const (
+	EndpointMeasurements = "measurements"
+	EndpointMeters       = "meters"
+)
+
+// RetryPolicy – ponowienia w ramach jednego odpytania i backoff urządzenia OFFLINE.
+type RetryPolicy struct {
+	Attempts          int           `yaml:"attempts"`           // próby w jednym odpytaniu (0 = 3)
+	Delay             time.Duration `yaml:"retry_delay"`        // przerwa między próbami (0 = interval)
+	BackoffMax        time.Duration `yaml:"backoff_max"`        // górna granica przerwy po błędach (0 = RestBackoffMax)
+	BackoffMultiplier float64       `yaml:"backoff_multiplier"` // mnożnik przerwy po kolejnym nieudanym odpytaniu (0 = 2)
+}
+
+// ResponseDecoder zamienia odpowiedź HTTP na rekordy {id, value, unit, timestamp}.
+type ResponseDecoder func(body io.Reader) ([]map[string]interface{}, error)
+
+// Endpoint – definicja odpytywanego zasobu analizatora REST.
+type Endpoint struct {
+	Name        string        `yaml:"name"`     // klucz danych (measurements, meters, harmonics, ...)
+	Path        string        `yaml:"path"`     // np. /api/v1/harmonics
+	Interval    time.Duration `yaml:"interval"` // okres odpytywania (0 = IntervalRestData)
+	Timeout     time.Duration `yaml:"timeout"`  // timeout żądania (0 = RestTimeout)
+	Retry       RetryPolicy   `yaml:",inline"`
+	Decoder     string        `yaml:"decoder"`      // nazwa dekodera odpowiedzi (domyślnie "items")
+	IDNormalize string        `yaml:"id_normalize"` // "" albo "dash_to_underscore"
+	Devices     []int         `yaml:"devices"`      // numery analizatorów (pusty = wszystkie REST)
+	LogName     string        `yaml:"log_name"`     // prefiks logów stanu (domyślnie NAME wielkimi literami)
+
+	decode      ResponseDecoder
+	normalizeID func(string) string
+}
+
+// responseDecoders – dekodery dostępne z pliku ANALYZER_ENDPOINTS_FILE.
+var responseDecoders = map[string]ResponseDecoder{
+	"items": decodeItems,
+}
+
+// idNormalizers – normalizacja id rekordów (meters: "ea-pos-total" → "ea_pos_total").
+var idNormalizers = map[string]func(string) string{
+	"":                   nil,
+	"none":               nil,
+	"dash_to_underscore": func(s string) string { return strings.ReplaceAll(s, "-", "_") },
+}
+
+// defaultEndpoints – endpointy odpytywane zawsze (można je nadpisać w pliku po nazwie).
+func defaultEndpoints() []Endpoint {
+	return []Endpoint{
+		{Name: EndpointMeasurements, Path: "/api/v1/measurements", LogName: "REST"},
+		{Name: EndpointMeters, Path: "/api/v1/meters", LogName: "METERS", IDNormalize: "dash_to_underscore"},
+	}
+}
+
+type endpointFile struct {
+	Endpoints []Endpoint `yaml:"endpoints"`
+}
+
+// loadEndpoints – endpointy domyślne uzupełnione/nadpisane plikiem path ("" = tylko domyślne).
+func loadEndpoints(path string) ([]Endpoint, error) {
+	eps := defaultEndpoints()
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var f endpointFile
+		if err := yaml.Unmarshal(raw, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, ep := range f.Endpoints {
+			replaced := false
+			for i := range eps {
+				if eps[i].Name == ep.Name {
+					if ep.LogName == "" {
+						ep.LogName = eps[i].LogName
+					}
+					eps[i], replaced = ep, true
+				}
+			}
+			if !replaced {
+				eps = append(eps, ep)
+			}
+		}
+	}
+	for i := range eps {
+		if err := eps[i].normalize(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return eps, nil
+}
+
+func (ep *Endpoint) normalize() error {
+	if ep.Name == "" {
+		return errors.New("endpoint name is required")
+	}
+	if !strings.HasPrefix(ep.Path, "/") {
+		return fmt.Errorf("endpoint %s: path must start with /", ep.Name)
+	}
+	if ep.Interval <= 0 {
+		ep.Interval = config.IntervalRestData
+	}
+	if ep.Timeout <= 0 {
+		ep.Timeout = config.RestTimeout
+	}
+	if ep.Retry.Attempts <= 0 {
+		ep.Retry.Attempts = 3
+	}
+	if ep.Retry.Delay <= 0 {
+		ep.Retry.Delay = ep.Interval
+	}
+	if ep.Retry.BackoffMax <= 0 {
+		ep.Retry.BackoffMax = config.RestBackoffMax
+	}
+	if ep.Retry.BackoffMultiplier < 1 {
+		ep.Retry.BackoffMultiplier = 2
+	}
+	if ep.Decoder == "" {
+		ep.Decoder = "items"
+	}
+	if ep.LogName == "" {
+		ep.LogName = strings.ToUpper(ep.Name)
+	}
+	var ok bool
+	if ep.decode, ok = responseDecoders[ep.Decoder]; !ok {
+		return fmt.Errorf("endpoint %s: unknown decoder %q", ep.Name, ep.Decoder)
+	}
+	if ep.normalizeID, ok = idNormalizers[ep.IDNormalize]; !ok {
+		return fmt.Errorf("endpoint %s: unknown id_normalize %q", ep.Name, ep.IDNormalize)
+	}
+	return nil
+}
+
+// polls – czy endpoint dotyczy analizatora deviceID.
+func (ep *Endpoint) polls(deviceID int) bool {
+	if len(ep.Devices) == 0 {
+		return true
+	}
+	for _, d := range ep.Devices {
+		if d == deviceID {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWait – przerwa przed kolejnym odpytaniem po failures nieudanych odpytaniach z rzędu:
+// interval, potem interval·m, interval·m², ... do BackoffMax.
+func (p RetryPolicy) nextWait(interval time.Duration, failures int) time.Duration {
+	wait := interval
+	for i := 0; i < failures && wait < p.BackoffMax; i++ {
+		wait = time.Duration(float64(wait) * p.BackoffMultiplier)
+	}
+	if failures > 0 && wait > p.BackoffMax {
+		wait = p.BackoffMax
+	}
+	return wait
+}
+
+// decodeItems – format analizatora: {"timestamp": ..., "items": [{"id", "value", "unit"}, ...]}.
+func decodeItems(body io.Reader) ([]map[string]interface{}, error) {
+	var response map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return nil, err
+	}
+	if response == nil {
+		return nil, errors.New("empty response")
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+	if ts, ok := response["timestamp"]; ok {
+		timestamp = normalizeTimestamp(ts)
+	}
+
+	items, ok := response["items"].([]interface{})
+	if !ok {
+		return nil, errors.New("response has no items")
+	}
+
+	formatted := make([]map[string]interface{}, 0, len(items))
+	for _, raw := range items {
+		if item, ok := raw.(map[string]interface{}); ok {
+			formatted = append(formatted, map[string]interface{}{
+				"id":        item["id"],
+				"value":     item["value"],
+				"unit":      item["unit"],
+				"timestamp": timestamp,
+			})
+		}
+	}
+	return formatted, nil
+}
+
+// --- magazyn danych endpointów ---
+
+var (
+	endpointLock sync.RWMutex
+	endpointData = map[string]map[string][]map[string]interface{}{} // endpoint → device_N → rekordy
+)
+
+func storeEndpointData(endpoint, deviceKey string, records []map[string]interface{}) {
+	endpointLock.Lock()
+	defer endpointLock.Unlock()
+	if endpointData[endpoint] == nil {
+		endpointData[endpoint] = map[string][]map[string]interface{}{}
+	}
+	endpointData[endpoint][deviceKey] = records
+}
+
+func endpointSnapshot(endpoint string) map[string][]map[string]interface{} {
+	endpointLock.RLock()
+	defer endpointLock.RUnlock()
+	return copyMapOfSlices(endpointData[endpoint])
+}
+
+// GetAnalyzerEndpointData – dane dodatkowych endpointów z ANALYZER_ENDPOINTS_FILE
+// (bez measurements i meters, które zwracają GetRestData/GetMetersData).
+func GetAnalyzerEndpointData() map[string]map[string][]map[string]interface{} {
+	endpointLock.RLock()
+	defer endpointLock.RUnlock()
+	out := map[string]map[string][]map[string]interface{}{}
+	for name, data := range endpointData {
+		if name != EndpointMeasurements && name != EndpointMeters {
+			out[name] = copyMapOfSlices(data)
+		}
+	}
+	return out
+}
+
+// --- pętla odpytywania ---
+
+// pollEndpoint odpytuje endpoint ep analizatora deviceID: ep.Retry.Attempts prób co ep.Retry.Delay,
+// po nieudanym odpytaniu przerwa rośnie wykładniczo do ep.Retry.BackoffMax, sukces ją zeruje.
+func pollEndpoint(ctx context.Context, deviceID int, ep Endpoint) {
+	url := fmt.Sprintf("http://%s%s", deviceIPs[deviceID-1], ep.Path)
+	key := fmt.Sprintf("device_%d", deviceID)
+	stateKey := fmt.Sprintf("%s %s", ep.LogName, key)
+	client := &http.Client{Timeout: ep.Timeout}
+	log := utils.NewLogger(ep.LogName).With("device", key, "endpoint", ep.Name)
+
+	firstLog := true
+	failures := 0
+
+	for ctx.Err() == nil {
+		func() {
+			defer utils.Catch(fmt.Sprintf("pollEndpoint(%s, device_%d) iteration", ep.Name, deviceID))()
+
+			var lastErr error
+			for attempt := 1; attempt <= ep.Retry.Attempts; attempt++ {
+				if firstLog {
+					log.Info("first connection attempt", "attempt", attempt, "url", url)
+				}
+
+				var records []map[string]interface{}
+				records, lastErr = fetchEndpoint(client, url, ep)
+				if lastErr == nil {
+					storeEndpointData(ep.Name, key, records)
+					break
+				}
+				if attempt < ep.Retry.Attempts {
+					if !utils.Sleep(ctx, ep.Retry.Delay) {
+						return
+					}
+				}
+			}
+
+			if lastErr != nil {
+				if prev, ok := deviceState.Load(stateKey); !ok || !prev.(bool) {
+					log.Error("request failed", "url", url, "error", lastErr)
+				}
+				failures++
+			} else {
+				failures = 0
+			}
+			updateDeviceState(log, stateKey, lastErr == nil)
+		}()
+
+		firstLog = false
+		utils.Heartbeat(ctx)
+		utils.Sleep(ctx, ep.Retry.nextWait(ep.Interval, failures))
+	}
+}
+
+func fetchEndpoint(client *http.Client, url string, ep Endpoint) ([]map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	records, err := ep.decode(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if ep.normalizeID != nil {
+		for _, rec := range records {
+			if s, ok := rec["id"].(string); ok {
+				rec["id"] = ep.normalizeID(s)
+			}
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,371 @@
+package communication
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// RecordedMessage – jedna surowa wiadomość MQTT zapisana przez rejestrator.
+// Payload jest zapisywany jako tekst; jeśli nie jest poprawnym UTF-8 – w PayloadB64.
+type RecordedMessage struct {
+	Topic      string `json:"topic"`
+	Payload    string `json:"payload,omitempty"`
+	PayloadB64 string `json:"payload_b64,omitempty"`
+	ReceivedAt string `json:"received_at"`
+	Retained   bool   `json:"retained"`
+	Qos        byte   `json:"qos"`
+}
+
+// ReceivedTime zwraca czas odbioru wiadomości (zero, jeśli nie da się sparsować).
+func (r RecordedMessage) ReceivedTime() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, r.ReceivedAt)
+	return t
+}
+
+func (r RecordedMessage) payloadBytes() []byte {
+	if r.PayloadB64 != "" {
+		b, err := base64.StdEncoding.DecodeString(r.PayloadB64)
+		if err == nil {
+			return b
+		}
+	}
+	return []byte(r.Payload)
+}
+
+const (
+	recorderQueueSize     = 4096
+	recorderFlushInterval = time.Second
+	recordFilePrefix      = "mqtt-"
+	recordFileSuffix      = ".jsonl.gz"
+)
+
+var (
+	recorderOnce    sync.Once
+	recorderQueue   chan RecordedMessage
+	recorderDropped atomic.Int64
+)
+
+// StartMQTTRecorder uruchamia rejestrator, jeśli ustawiono MQTT_RECORD_DIR.
+// Wiadomości trafiają do kolejki, a zapis (gzip, rotacja) robi osobna gorutyna,
+// więc onMessage nigdy nie czeka na dysk.
+func StartMQTTRecorder() {
+	if config.MqttRecordDir == "" {
+		return
+	}
+	recorderOnce.Do(func() {
+		recorderQueue = make(chan RecordedMessage, recorderQueueSize)
+		utils.Supervise("MQTT recorder", func(ctx context.Context) { runRecorder(ctx, config.MqttRecordDir) })
+		utils.LogMessage("[MQTT_REC] Recording raw MQTT messages to " + config.MqttRecordDir)
+	})
+}
+
+// recordMessage – nieblokujące dodanie wiadomości do kolejki rejestratora.
+func recordMessage(topic string, payload []byte, retained bool, qos byte) {
+	q := recorderQueue
+	if q == nil {
+		return
+	}
+	rec := RecordedMessage{
+		Topic:      topic,
+		ReceivedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Retained:   retained,
+		Qos:        qos,
+	}
+	if utf8.Valid(payload) {
+		rec.Payload = string(payload)
+	} else {
+		rec.PayloadB64 = base64.StdEncoding.EncodeToString(payload)
+	}
+
+	select {
+	case q <- rec:
+	default:
+		if recorderDropped.Add(1) == 1 {
+			utils.LogMessage("[MQTT_REC] Queue full – dropping messages")
+		}
+	}
+}
+
+type recordFile struct {
+	f       *os.File
+	counter *countingWriter
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	opened  time.Time
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (r *recordFile) flush() error {
+	if err := r.buf.Flush(); err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+func (r *recordFile) close() error {
+	if err := r.buf.Flush(); err != nil {
+		_ = r.f.Close()
+		return err
+	}
+	if err := r.gz.Close(); err != nil {
+		_ = r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+func openRecordFile(dir string) (*recordFile, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	name := filepath.Join(dir, recordFilePrefix+now.Format("20060102-150405.000")+recordFileSuffix)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cw := &countingWriter{w: f}
+	gz := gzip.NewWriter(cw)
+	return &recordFile{f: f, counter: cw, gz: gz, buf: bufio.NewWriter(gz), opened: now}, nil
+}
+
+func runRecorder(ctx context.Context, dir string) {
+	var cur *recordFile
+	ticker := time.NewTicker(recorderFlushInterval)
+	defer ticker.Stop()
+
+	rotate := func() {
+		if cur != nil {
+			if err := cur.close(); err != nil {
+				utils.LogMessage("[MQTT_REC] Close error: " + err.Error())
+			}
+			cur = nil
+		}
+		pruneRecordings(dir, config.MqttRecordKeep)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// zamknięcie domyka strumień gzip – nagranie zostaje kompletne
+			rotate()
+			return
+
+		case rec := <-recorderQueue:
+			if cur == nil {
+				f, err := openRecordFile(dir)
+				if err != nil {
+					utils.LogMessage("[MQTT_REC] Open error: " + err.Error())
+					continue
+				}
+				cur = f
+			}
+			line, err := json.Marshal(rec)
+			if err != nil {
+				continue
+			}
+			line = append(line, '\n')
+			if _, err := cur.buf.Write(line); err != nil {
+				utils.LogMessage("[MQTT_REC] Write error: " + err.Error())
+				rotate()
+				continue
+			}
+			if cur.counter.n >= config.MqttRecordMaxBytes || time.Since(cur.opened) >= config.MqttRecordRotateEvery {
+				rotate()
+			}
+
+		case <-ticker.C:
+			utils.Heartbeat(ctx)
+			if cur == nil {
+				continue
+			}
+			if err := cur.flush(); err != nil {
+				utils.LogMessage("[MQTT_REC] Flush error: " + err.Error())
+				rotate()
+				continue
+			}
+			if n := recorderDropped.Swap(0); n > 0 {
+				utils.LogMessage(fmt.Sprintf("[MQTT_REC] Dropped %d messages (queue full)", n))
+			}
+			if time.Since(cur.opened) >= config.MqttRecordRotateEvery {
+				rotate()
+			}
+		}
+	}
+}
+
+// listRecordings zwraca pliki nagrań w kolejności chronologicznej (nazwa = czas otwarcia).
+func listRecordings(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, recordFilePrefix+"*"+recordFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func pruneRecordings(dir string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	files, err := listRecordings(dir)
+	if err != nil || len(files) <= keep {
+		return
+	}
+	for _, f := range files[:len(files)-keep] {
+		if err := os.Remove(f); err != nil {
+			utils.LogMessage("[MQTT_REC] Cannot remove old recording: " + err.Error())
+		}
+	}
+}
+
+// --- Replay ---
+
+// HandleMessage przepuszcza wiadomość przez ten sam handler co subskrypcja brokera
+// (narzędzia offline, np. cmd/replay -recording).
+func HandleMessage(msg mqtt.Message) {
+	onMessage(nil, msg)
+}
+
+// replayMessage – implementacja mqtt.Message dla wiadomości z nagrania.
+type replayMessage struct {
+	rec     RecordedMessage
+	payload []byte
+}
+
+func (m *replayMessage) Duplicate() bool   { return false }
+func (m *replayMessage) Qos() byte         { return m.rec.Qos }
+func (m *replayMessage) Retained() bool    { return m.rec.Retained }
+func (m *replayMessage) Topic() string     { return m.rec.Topic }
+func (m *replayMessage) MessageID() uint16 { return 0 }
+func (m *replayMessage) Payload() []byte   { return m.payload }
+func (m *replayMessage) Ack()              {}
+
+var _ mqtt.Message = (*replayMessage)(nil)
+
+// RunMQTTReplay odtwarza nagranie przez onMessage zamiast łączyć się z brokerem.
+// speed: 1 = czas rzeczywisty, 10 = dziesięciokrotnie szybciej, <= 0 = bez opóźnień.
+func RunMQTTReplay(path string, speed float64) {
+	utils.Supervise("MQTT replay", func(ctx context.Context) {
+		mqttUpdateState(true, "")
+		n, err := ReplayRecordingContext(ctx, path, speed, func(_ RecordedMessage, msg mqtt.Message) { HandleMessage(msg) })
+		if err != nil {
+			utils.LogMessage(fmt.Sprintf("[MQTT_REPLAY] Stopped after %d messages: %v", n, err))
+			return
+		}
+		utils.LogMessage(fmt.Sprintf("[MQTT_REPLAY] Finished – %d messages replayed", n))
+	})
+}
+
+// ReplayRecording czyta nagranie (plik lub katalog) i woła handler dla każdej wiadomości,
+// zachowując odstępy czasowe z received_at podzielone przez speed.
+func ReplayRecording(path string, speed float64, handler func(RecordedMessage, mqtt.Message)) (int, error) {
+	return ReplayRecordingContext(context.Background(), path, speed, handler)
+}
+
+// ReplayRecordingContext – ReplayRecording przerywany anulowaniem ctx (zwraca ctx.Err()).
+func ReplayRecordingContext(ctx context.Context, path string, speed float64, handler func(RecordedMessage, mqtt.Message)) (int, error) {
+	files := []string{path}
+	if st, err := os.Stat(path); err != nil {
+		return 0, err
+	} else if st.IsDir() {
+		files, err = listRecordings(path)
+		if err != nil {
+			return 0, err
+		}
+		if len(files) == 0 {
+			return 0, fmt.Errorf("no recordings in %s", path)
+		}
+	}
+
+	utils.LogMessage(fmt.Sprintf("[MQTT_REPLAY] Replaying %d file(s) from %s at speed %.2f", len(files), path, speed))
+
+	count := 0
+	var prevRecv time.Time
+	for _, file := range files {
+		err := readRecording(file, func(rec RecordedMessage) bool {
+			if recv := rec.ReceivedTime(); !recv.IsZero() {
+				if speed > 0 && !prevRecv.IsZero() {
+					if gap := recv.Sub(prevRecv); gap > 0 && !utils.Sleep(ctx, time.Duration(float64(gap)/speed)) {
+						return false
+					}
+				}
+				prevRecv = recv
+			}
+			handler(rec, &replayMessage{rec: rec, payload: rec.payloadBytes()})
+			count++
+			return ctx.Err() == nil
+		})
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return count, nil
+}
+
+// readRecording woła fn dla każdej wiadomości pliku; fn zwraca false, aby przerwać odczyt.
+func readRecording(file string, fn func(RecordedMessage) bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var rec RecordedMessage
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue // uszkodzona linia (np. ucięty plik po awarii)
+		}
+		if !fn(rec) {
+			return nil
+		}
+	}
+	err = sc.Err()
+	if err == io.ErrUnexpectedEOF {
+		// plik, który nie został domknięty (crash) – bierzemy to, co się dało odczytać
+		return nil
+	}
+	return err
+}
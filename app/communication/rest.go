@@ -1,221 +1,78 @@
 package communication
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"go_app/config"
 	"go_app/utils"
-	"net/http"
 	"strings"
 	"sync"
-	"time"
 )
 
 var (
 	deviceIPs   = config.AnalyzerIPs
-	restLock    sync.RWMutex
-	metersLock  sync.RWMutex
-	restData    = make(map[string][]map[string]interface{})
-	metersData  = make(map[string][]map[string]interface{})
-	client      = &http.Client{Timeout: 2 * time.Second}
 	deviceState sync.Map // key: "REST device_3" / "METERS device_3", value: bool (true=offline, false=online)
 )
 
-// RunRestCommunication starts both measurement and meter polling goroutines
+// RunRestCommunication uruchamia poller każdego endpointu (measurements, meters i dodatkowe
+// z ANALYZER_ENDPOINTS_FILE) dla każdego analizatora REST
+// (analizatory z ANALYZER_TRANSPORTxx=modbus – jeden poller Modbus TCP)
 func RunRestCommunication() {
-	for i := 1; i <= len(deviceIPs); i++ {
-		id := i
-		utils.Go(fmt.Sprintf("REST device_%d measurements", id), func() { fetchAndStoreRESTData(id) })
-		utils.Go(fmt.Sprintf("METERS device_%d", id), func() { fetchAndStoreMetersData(id) })
+	endpoints, err := loadEndpoints(config.AnalyzerEndpointsFile)
+	if err != nil {
+		utils.NewLogger("REST").Error("endpoint definitions error, using defaults", "file", config.AnalyzerEndpointsFile, "error", err)
+		endpoints, _ = loadEndpoints("")
 	}
-}
-
-// --- MEASUREMENTS ---
-
-func fetchAndStoreRESTData(deviceID int) {
-	url := fmt.Sprintf("http://%s/api/v1/measurements", deviceIPs[deviceID-1])
-	key := fmt.Sprintf("device_%d", deviceID)
-	stateKey := fmt.Sprintf("REST %s", key)
-
-	firstLog := true
-
-	for {
-		func() {
-			defer utils.Catch(fmt.Sprintf("fetchAndStoreRESTData(device_%d) iteration", deviceID))()
-
-			success := false
-			for attempt := 1; attempt <= 3; attempt++ {
-				if firstLog {
-					utils.LogMessage(fmt.Sprintf("[%s] first conn attempt %d", stateKey, attempt))
-				}
-
-				req, _ := http.NewRequest("GET", url, nil)
-				req.Header.Set("Accept-Encoding", "identity")
-
-				resp, err := client.Do(req)
-				if err != nil {
-					time.Sleep(config.IntervalRestData)
-					continue
-				}
-
-				func() {
-					defer resp.Body.Close()
-
-					if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-						return
-					}
 
-					var response map[string]interface{}
-					if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-						return
-					}
-					if response == nil {
-						return
-					}
-
-					timestamp := time.Now().UTC().Format(time.RFC3339Nano)
-					if ts, ok := response["timestamp"]; ok {
-						timestamp = normalizeTimestamp(ts)
-					}
-
-					items, ok := response["items"].([]interface{})
-					if !ok {
-						return
-					}
-
-					formatted := make([]map[string]interface{}, 0, len(items))
-					for _, raw := range items {
-						if item, ok := raw.(map[string]interface{}); ok {
-							formatted = append(formatted, map[string]interface{}{
-								"id":        item["id"],
-								"value":     item["value"],
-								"unit":      item["unit"],
-								"timestamp": timestamp,
-							})
-						}
-					}
-
-					restLock.Lock()
-					restData[key] = formatted
-					restLock.Unlock()
-					success = true
-				}()
-
-				if success {
-					break
-				}
-				time.Sleep(config.IntervalRestData)
+	for i := 1; i <= len(deviceIPs); i++ {
+		id := i
+		if deviceIPs[id-1] == "" {
+			continue // ANALYZER_IPxx pusty – analizator wyłączony
+		}
+		if analyzerTransport(id) == "modbus" {
+			utils.Supervise(fmt.Sprintf("MODBUS device_%d", id), func(ctx context.Context) { fetchAndStoreModbusData(ctx, id) })
+			continue
+		}
+		for _, ep := range endpoints {
+			if !ep.polls(id) {
+				continue
 			}
-
-			updateDeviceState(stateKey, success)
-		}()
-
-		firstLog = false
-		time.Sleep(config.IntervalRestData)
+			ep := ep
+			utils.Supervise(fmt.Sprintf("%s device_%d", ep.LogName, id), func(ctx context.Context) { pollEndpoint(ctx, id, ep) })
+		}
 	}
 }
 
-// --- METERS ---
-
-func fetchAndStoreMetersData(deviceID int) {
-	url := fmt.Sprintf("http://%s/api/v1/meters", deviceIPs[deviceID-1])
-	key := fmt.Sprintf("device_%d", deviceID)
-	stateKey := fmt.Sprintf("METERS %s", key)
-
-	firstLog := true
-
-	for {
-		func() {
-			defer utils.Catch(fmt.Sprintf("fetchAndStoreMetersData(device_%d) iteration", deviceID))()
-
-			success := false
-			for attempt := 1; attempt <= 3; attempt++ {
-				if firstLog {
-					utils.LogMessage(fmt.Sprintf("[%s] first conn attempt %d", stateKey, attempt))
-				}
-
-				req, _ := http.NewRequest("GET", url, nil)
-				req.Header.Set("Accept-Encoding", "identity")
-
-				resp, err := client.Do(req)
-				if err != nil {
-					time.Sleep(config.IntervalRestData)
-					continue
-				}
-
-				func() {
-					defer resp.Body.Close()
-
-					if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-						return
-					}
-
-					var response map[string]interface{}
-					if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-						return
-					}
-					if response == nil {
-						return
-					}
-
-					timestamp := time.Now().UTC().Format(time.RFC3339Nano)
-					if ts, ok := response["timestamp"]; ok {
-						timestamp = normalizeTimestamp(ts)
-					}
-
-					items, ok := response["items"].([]interface{})
-					if !ok {
-						return
-					}
-
-					formatted := make([]map[string]interface{}, 0, len(items))
-					for _, raw := range items {
-						if item, ok := raw.(map[string]interface{}); ok {
-							id := item["id"]
-							if s, ok := id.(string); ok {
-								id = strings.ReplaceAll(s, "-", "_")
-							}
-							formatted = append(formatted, map[string]interface{}{
-								"id":        id,
-								"value":     item["value"],
-								"unit":      item["unit"],
-								"timestamp": timestamp,
-							})
-						}
-					}
-
-					metersLock.Lock()
-					metersData[key] = formatted
-					metersLock.Unlock()
-					success = true
-				}()
-
-				if success {
-					break
-				}
-				time.Sleep(config.IntervalRestData)
-			}
-
-			updateDeviceState(stateKey, success)
-		}()
-
-		firstLog = false
-		time.Sleep(config.IntervalRestData)
-	}
+// AnalyzerStates – stan analizatorów: device_N → źródło (REST, METERS, MODBUS, ...) → online.
+// Źródło pojawia się po pierwszym odpytaniu; wyłączone analizatory (pusty ANALYZER_IPxx) są pomijane.
+func AnalyzerStates() map[string]map[string]bool {
+	out := map[string]map[string]bool{}
+	deviceState.Range(func(k, v interface{}) bool {
+		source, device, ok := strings.Cut(k.(string), " ")
+		if !ok {
+			return true
+		}
+		if out[device] == nil {
+			out[device] = map[string]bool{}
+		}
+		out[device][source] = !v.(bool)
+		return true
+	})
+	return out
 }
 
 // --- State logger helper ---
 
-func updateDeviceState(stateKey string, success bool) {
+func updateDeviceState(log utils.Logger, stateKey string, success bool) {
 	prev, _ := deviceState.LoadOrStore(stateKey, !success)
 	if success {
 		if prev.(bool) { // wcześniej było offline
-			utils.LogMessage(fmt.Sprintf("[%s] is ONLINE again", stateKey))
+			log.Info("device is ONLINE again")
 			deviceState.Store(stateKey, false)
 		}
 	} else {
 		if !prev.(bool) { // wcześniej było online
-			utils.LogMessage(fmt.Sprintf("[%s] went OFFLINE", stateKey))
+			log.Warn("device went OFFLINE")
 			deviceState.Store(stateKey, true)
 		}
 	}
@@ -223,16 +80,12 @@ func updateDeviceState(stateKey string, success bool) {
 
 // --- Read helpers ---
 
-func GetRestData() map[string][]map[string]interface{} {
-	restLock.RLock()
-	defer restLock.RUnlock()
-	return copyMapOfSlices(restData)
+func pollerMeasurements() map[string][]map[string]interface{} {
+	return endpointSnapshot(EndpointMeasurements)
 }
 
-func GetMetersData() map[string][]map[string]interface{} {
-	metersLock.RLock()
-	defer metersLock.RUnlock()
-	return copyMapOfSlices(metersData)
+func pollerMeters() map[string][]map[string]interface{} {
+	return endpointSnapshot(EndpointMeters)
 }
 
 func copyMapOfSlices(input map[string][]map[string]interface{}) map[string][]map[string]interface{} {
@@ -0,0 +1,286 @@
+package communication
+
+import (
+	"context"
+	"fmt"
+	"go_app/communication/fake"
+	"go_app/config"
+	"go_app/utils"
+	"sync"
+	"time"
+)
+
+// MQTTSource – źródło danych portów IO-Link: broker, replay nagrania albo symulator.
+type MQTTSource interface {
+	Name() string
+	Start()
+	Snapshot() map[string]map[string]interface{}
+}
+
+// RestSource – źródło danych analizatorów energii: poller REST albo symulator.
+type RestSource interface {
+	Name() string
+	Start()
+	Measurements() map[string][]map[string]interface{}
+	Meters() map[string][]map[string]interface{}
+}
+
+var (
+	sourceLock   sync.RWMutex
+	activeMQTT   MQTTSource = brokerSource{}
+	activeRest   RestSource = restPollerSource{}
+	sourcesStart sync.Once
+)
+
+// Start wybiera źródła zgodnie z konfiguracją (SIMULATION, SIMULATION_SCENARIO, MQTT_REPLAY_PATH) i je uruchamia.
+func Start() {
+	sourcesStart.Do(func() {
+		var m MQTTSource = brokerSource{}
+		var r RestSource = restPollerSource{}
+		switch {
+		case config.Simulation && config.SimulationScenario != "":
+			sc, err := fake.LoadScenario(config.SimulationScenario)
+			if err != nil {
+				utils.LogMessage("[SOURCES] Scenario error, using random simulation: " + err.Error())
+				m = newSimulatedMQTTSource(config.IntervalMQTTData)
+				r = newSimulatedRestSource(config.IntervalRestData)
+				break
+			}
+			s := newScenarioSource(sc)
+			m, r = s, s
+		case config.Simulation:
+			m = newSimulatedMQTTSource(config.IntervalMQTTData)
+			r = newSimulatedRestSource(config.IntervalRestData)
+		case config.MqttReplayPath != "":
+			m = replaySource{path: config.MqttReplayPath, speed: config.MqttReplaySpeed}
+		}
+		UseSources(m, r)
+		utils.LogMessage("[SOURCES] MQTT: " + m.Name() + ", REST: " + r.Name())
+		m.Start()
+		r.Start()
+	})
+}
+
+// UseSources podmienia aktywne źródła bez ich uruchamiania (symulatory, narzędzia offline).
+func UseSources(m MQTTSource, r RestSource) {
+	sourceLock.Lock()
+	defer sourceLock.Unlock()
+	if m != nil {
+		activeMQTT = m
+	}
+	if r != nil {
+		activeRest = r
+	}
+}
+
+func GetMQTTData() map[string]map[string]interface{} {
+	sourceLock.RLock()
+	src := activeMQTT
+	sourceLock.RUnlock()
+	return src.Snapshot()
+}
+
+func GetRestData() map[string][]map[string]interface{} {
+	sourceLock.RLock()
+	src := activeRest
+	sourceLock.RUnlock()
+	return src.Measurements()
+}
+
+func GetMetersData() map[string][]map[string]interface{} {
+	sourceLock.RLock()
+	src := activeRest
+	sourceLock.RUnlock()
+	return src.Meters()
+}
+
+// --- broker / replay / poller ---
+
+type brokerSource struct{}
+
+func (brokerSource) Name() string                                { return "broker" }
+func (brokerSource) Start()                                      { RunMQTT() }
+func (brokerSource) Snapshot() map[string]map[string]interface{} { return brokerSnapshot() }
+
+type replaySource struct {
+	path  string
+	speed float64
+}
+
+func (s replaySource) Name() string                                { return "replay " + s.path }
+func (s replaySource) Start()                                      { RunMQTTReplay(s.path, s.speed) }
+func (s replaySource) Snapshot() map[string]map[string]interface{} { return brokerSnapshot() }
+
+type restPollerSource struct{}
+
+func (restPollerSource) Name() string { return "rest" }
+func (restPollerSource) Start()       { RunRestCommunication() }
+func (restPollerSource) Measurements() map[string][]map[string]interface{} {
+	return pollerMeasurements()
+}
+func (restPollerSource) Meters() map[string][]map[string]interface{} { return pollerMeters() }
+
+// --- symulacja (communication/fake) ---
+
+type simulatedMQTTSource struct {
+	interval time.Duration
+	mu       sync.RWMutex
+	data     map[string]map[string]interface{}
+}
+
+func newSimulatedMQTTSource(interval time.Duration) *simulatedMQTTSource {
+	return &simulatedMQTTSource{interval: interval, data: map[string]map[string]interface{}{}}
+}
+
+func (s *simulatedMQTTSource) Name() string { return "simulation" }
+
+func (s *simulatedMQTTSource) Start() {
+	mqttUpdateState(true, "")
+	utils.SuperviseLoop("MQTT simulation", s.interval, func() {
+		d := fake.GenerateMockMQTTData()
+		s.mu.Lock()
+		s.data = d
+		s.mu.Unlock()
+	})
+}
+
+func (s *simulatedMQTTSource) Snapshot() map[string]map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]map[string]interface{}, len(s.data))
+	for k, v := range s.data {
+		out[k] = copyMap(v)
+	}
+	return out
+}
+
+type simulatedRestSource struct {
+	interval     time.Duration
+	mu           sync.RWMutex
+	measurements map[string][]map[string]interface{}
+	meters       map[string][]map[string]interface{}
+}
+
+func newSimulatedRestSource(interval time.Duration) *simulatedRestSource {
+	return &simulatedRestSource{
+		interval:     interval,
+		measurements: map[string][]map[string]interface{}{},
+		meters:       map[string][]map[string]interface{}{},
+	}
+}
+
+func (s *simulatedRestSource) Name() string { return "simulation" }
+
+func (s *simulatedRestSource) Start() {
+	utils.SuperviseLoop("REST simulation", s.interval, func() {
+		m := fake.GenerateMockRestData()
+		mt := fake.GenerateMockMetersData()
+		s.mu.Lock()
+		s.measurements, s.meters = m, mt
+		s.mu.Unlock()
+	})
+}
+
+func (s *simulatedRestSource) Measurements() map[string][]map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyMapOfSlices(s.measurements)
+}
+
+func (s *simulatedRestSource) Meters() map[string][]map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyMapOfSlices(s.meters)
+}
+
+// --- symulacja wg scenariusza YAML (communication/fake.Scenario) ---
+
+// scenarioSource zasila jednocześnie MQTT i REST z jednego przebiegu scenariusza,
+// odtwarzanego w czasie rzeczywistym.
+type scenarioSource struct {
+	sc    *fake.Scenario
+	once  sync.Once
+	mu    sync.RWMutex
+	run   *fake.ScenarioRun
+	ports map[string]map[string]interface{}
+}
+
+func newScenarioSource(sc *fake.Scenario) *scenarioSource {
+	return &scenarioSource{sc: sc, ports: map[string]map[string]interface{}{}}
+}
+
+func (s *scenarioSource) Name() string { return "scenario " + s.sc.Name }
+
+// Start jest wołany dla obu interfejsów – przebieg uruchamia się raz.
+func (s *scenarioSource) Start() {
+	s.once.Do(func() {
+		mqttUpdateState(true, "")
+		utils.Supervise("Scenario simulation", s.loop)
+	})
+}
+
+func (s *scenarioSource) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		start := time.Now()
+		run := fake.NewScenarioRun(s.sc, start)
+		s.mu.Lock()
+		s.run = run
+		s.mu.Unlock()
+
+		for {
+			s.mu.Lock()
+			fr, ok := run.Next()
+			if ok {
+				s.ports = fr.Ports
+			}
+			s.mu.Unlock()
+			if !ok {
+				break
+			}
+			utils.Heartbeat(ctx)
+			if !utils.Sleep(ctx, time.Until(fr.Time)) {
+				return
+			}
+			for _, in := range fr.Inputs {
+				if !dispatchCommand(in.Topic, in.Payload, false) {
+					utils.LogMessage("[SCENARIO] no handler for input topic " + in.Topic)
+				}
+			}
+		}
+
+		res := run.Expected()
+		utils.LogMessage(fmt.Sprintf("[SCENARIO] %s finished: elements=%d postoj=%.1fs przezbrojenie=%.1fs praca=%.1fs",
+			s.sc.Name, res.IloscElementow, res.CzasPostoju, res.CzasPrzezbrojenia, res.CzasPracy))
+		if !s.sc.Loop {
+			return
+		}
+	}
+}
+
+func (s *scenarioSource) Snapshot() map[string]map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]map[string]interface{}, len(s.ports))
+	for k, v := range s.ports {
+		out[k] = copyMap(v)
+	}
+	return out
+}
+
+func (s *scenarioSource) Measurements() map[string][]map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.run == nil {
+		return map[string][]map[string]interface{}{}
+	}
+	return s.run.Measurements()
+}
+
+func (s *scenarioSource) Meters() map[string][]map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.run == nil {
+		return map[string][]map[string]interface{}{}
+	}
+	return s.run.Meters()
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
-	"os"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,29 +18,98 @@ var (
 	MqttBroker = getEnv("MQTT_BROKER", "10.10.22.10")
 	MqttPort   = getEnv("MQTT_PORT", "1883")
 
-	MqttTopics = []string{
-		"balluff/cmtk/master1/iolink/devices/port1/data/fromdevice",
-		"balluff/cmtk/master1/iolink/devices/port2/data/fromdevice",
-		"balluff/cmtk/master1/iolink/devices/port3/data/fromdevice",
-		"balluff/cmtk/master1/iolink/devices/port4/data/fromdevice",
-		"balluff/cmtk/master2/iolink/devices/port0/data/fromdevice",
-		"balluff/cmtk/master2/iolink/devices/port1/data/fromdevice",
-		"balluff/cmtk/master2/iolink/devices/port2/data/fromdevice",
-	}
+	// Rejestrator surowych wiadomości MQTT (pusty katalog = wyłączony)
+	MqttRecordDir      = getEnv("MQTT_RECORD_DIR", "")
+	MqttRecordMaxBytes = int64(getEnvInt("MQTT_RECORD_MAX_MB", 64)) * 1024 * 1024
+	MqttRecordKeep     = getEnvInt("MQTT_RECORD_KEEP", 48)
 
-	FlowPorts = []string{
-	"master1/port3",
-	"master1/port4",
-	"master2/port0",
-	"master2/port1",
-	"master2/port2",
-	}
+	// Tryb symulacji: generatory z communication/fake zamiast brokera MQTT i analizatorów REST
+	Simulation = getEnv("SIMULATION", "") == "1"
+	// Scenariusz YAML dla trybu symulacji (pusty = generatory losowe)
+	SimulationScenario = getEnv("SIMULATION_SCENARIO", "")
 
-	AnalyzerIPs = []string{
-	getEnv("ANALYZER_IP01", "192.168.1.130"),
-	getEnv("ANALYZER_IP02", "192.168.1.131"),
-	getEnv("ANALYZER_IP03", "192.168.1.132"),
-	}
+	// Replay nagrania zamiast brokera (plik .jsonl.gz albo katalog z nagraniami)
+	MqttReplayPath  = getEnv("MQTT_REPLAY_PATH", "")
+	MqttReplaySpeed = getEnvFloat("MQTT_REPLAY_SPEED", 1.0) // 1 = czas rzeczywisty, 0 = bez opóźnień
+
+	// Porty IO-Link ("masterN/portM"): sygnały maszyny i wymiary (OEE) oraz przepływomierze powietrza.
+	// FLOW_PORTS – lista portów przepływomierzy po przecinku; numer przepływomierza = pozycja na liście.
+	OeePorts   = []string{"master1/port1", "master1/port2"}
+	FlowPorts  = getEnvList("FLOW_PORTS", []string{"master1/port3", "master1/port4", "master2/port0", "master2/port1", "master2/port2"})
+	MqttTopics = portTopics(OeePorts, FlowPorts)
+
+	// Analizatory energii: ANALYZER_IP01, ANALYZER_IP02, ... – liczba wynika z najwyższego ustawionego
+	// numeru (co najmniej 3 domyślne) albo z ANALYZER_COUNT; pusty adres = analizator wyłączony.
+	AnalyzerIPs = analyzerIPs([]string{"192.168.1.130", "192.168.1.131", "192.168.1.132"})
+
+	// Transport analizatora: "rest" (domyślnie) albo "modbus" (ANALYZER_TRANSPORT01, ...).
+	// Dla Modbus: plik mapy rejestrów (pusty = mapa domyślna) i unit id; port domyślny 502.
+	AnalyzerTransports  = analyzerEnv("ANALYZER_TRANSPORT", "rest")
+	AnalyzerModbusMaps  = analyzerEnv("ANALYZER_MODBUS_MAP", "")
+	AnalyzerModbusUnits = analyzerEnv("ANALYZER_MODBUS_UNIT", "1")
+
+	// Dodatkowe/zmienione endpointy REST analizatorów (YAML; pusty = tylko measurements i meters)
+	AnalyzerEndpointsFile = getEnv("ANALYZER_ENDPOINTS_FILE", "")
+
+	// Serwer Modbus TCP z wartościami OEE dla PLC (np. ":5020"; pusty = wyłączony)
+	ModbusServerAddr = getEnv("MODBUS_SERVER_ADDR", "")
+
+	// Serwer OPC UA (np. "opc.tcp://oee-line1:4840"; pusty = wyłączony). Bez certyfikatu tylko
+	// endpoint None (dev); z OPCUA_CERT_FILE/OPCUA_KEY_FILE – Basic256Sha256 Sign/SignAndEncrypt.
+	OpcUaEndpoint   = getEnv("OPCUA_ENDPOINT", "")
+	OpcUaListenAddr = getEnv("OPCUA_LISTEN", "")
+	OpcUaCertFile   = getEnv("OPCUA_CERT_FILE", "")
+	OpcUaKeyFile    = getEnv("OPCUA_KEY_FILE", "")
+	OpcUaTrustedDir = getEnv("OPCUA_TRUSTED_DIR", "")       // zaufane certyfikaty klientów (pusty = żaden)
+	OpcUaTrustAll   = getEnv("OPCUA_TRUST_ALL", "") == "1"  // akceptuj każdy poprawny certyfikat klienta (dev)
+	OpcUaAllowNone  = getEnv("OPCUA_ALLOW_NONE", "") == "1" // endpoint None także przy certyfikacie
+	MachineName     = getEnv("MACHINE_NAME", "Line1")       // nazwa maszyny w modelu OPC UA
+
+	// Serwer HTTP: /healthz, /readyz, /api/... (np. ":8080"; pusty = wyłączony). API_TOKEN – jeśli
+	// ustawiony, zmiany przez API (PUT/POST/DELETE) wymagają nagłówka "Authorization: Bearer <token>".
+	HttpAddr = getEnv("HTTP_ADDR", "")
+	ApiToken = getEnv("API_TOKEN", "")
+
+	// Katalog produktów: tolerancja dopasowania zmierzonych wymiarów do zakresów produktu [mm]
+	ProductDimTolerance = getEnvFloat("PRODUCT_DIM_TOLERANCE_MM", 2)
+
+	// Zlecenia produkcyjne: temat MQTT z poleceniami start/stop (pusty = tylko przez API)
+	MqttOrderTopic = getEnv("MQTT_ORDER_TOPIC", "")
+
+	// Log systemowy (SystemLogPath): poziom debug/info/warn/error, format text/json, kopia na stdout
+	// (docker logs), rotacja po LOG_MAX_MB albo LOG_MAX_AGE z kompresją gzip, LOG_KEEP zrotowanych plików.
+	LogLevel    = getEnv("LOG_LEVEL", "info")
+	LogFormat   = getEnv("LOG_FORMAT", "text")
+	LogStdout   = getEnv("LOG_STDOUT", "") == "1"
+	LogMaxBytes = int64(getEnvInt("LOG_MAX_MB", 50)) * 1024 * 1024
+	LogMaxAge   = getEnvDuration("LOG_MAX_AGE", 24*time.Hour)
+	LogKeep     = getEnvInt("LOG_KEEP", 14)
+	LogCompress = getEnv("LOG_COMPRESS", "1") == "1"
+
+	// --- Interwały odczytu i zapisu danych (CONFIG_FILE intervals.*) ---
+	IntervalMQTTData          = fileDuration(fileConfig.Intervals.MQTTData, 50*time.Millisecond)          // okres odświeżania danych z MQTT
+	IntervalRestData          = fileDuration(fileConfig.Intervals.RestData, 100*time.Millisecond)          // okres odświeżania danych z REST
+	FlowUpdateInterval        = fileDuration(fileConfig.Intervals.FlowUpdate, 10*time.Second)          // zapis danych przepływowych (flow) do DB/JSON
+	MeasurementUpdateInterval = fileDuration(fileConfig.Intervals.MeasurementUpdate, 10*time.Second)   // zapis danych pomiarowych (measurements) do DB/JSON
+	MetersUpdateInterval      = fileDuration(fileConfig.Intervals.MetersUpdate, 5*time.Second)         // zapis danych licznikowych (meters) do DB/JSON
+	OEEUpdateInterval         = fileDuration(fileConfig.Intervals.OeeUpdate, 5*time.Second)            // częstotliwość aktualizacji wskaźników OEE
+
+	// --- Ścieżki do plików (CONFIG_FILE paths.*) ---
+	SummaryFilePath         = filePath(fileConfig.Paths.Summary, "logs/summary.json")                  // podsumowania zmian
+	OeeFilePath             = filePath(fileConfig.Paths.Oee, "logs/oee.json")                          // dane OEE (stan bieżący)
+	MqttOeeFilePath         = filePath(fileConfig.Paths.MqttOee, "logs/mqttOEE.json")                  // surowe dane MQTT dla OEE
+	MqttFlowFilePath        = filePath(fileConfig.Paths.MqttFlow, "logs/mqttFlow.json")                // dane przepływów (flow) z MQTT
+	MeasurementFilePath     = filePath(fileConfig.Paths.Measurements, "logs/measurements.json")        // dane pomiarowe z REST
+	MetersFilePath          = filePath(fileConfig.Paths.Meters, "logs/meters.json")                    // dane licznikowe z REST
+	AnalyzerEndpointFileFmt = filePath(fileConfig.Paths.AnalyzerFmt, "logs/analyzer_%s.json")          // dane dodatkowych endpointów analizatorów (ANALYZER_ENDPOINTS_FILE)
+	FakeMeasurementFilePath = filePath(fileConfig.Paths.FakeMeasurement, "logs/fake_measurements.json") // opcjonalne dane startowe symulatora pomiarów (SIMULATION=1)
+	FakeMetersFilePath      = filePath(fileConfig.Paths.FakeMeters, "logs/fake_meters.json")           // opcjonalne dane startowe symulatora liczników (SIMULATION=1)
+	SystemLogPath           = filePath(fileConfig.Paths.SystemLog, "logs/system.log")                  // log systemowy aplikacji
+	ProductsFilePath        = filePath(fileConfig.Paths.Products, "logs/products.json")                // kopia katalogu produktów (start bez DB)
+	OrderFilePath           = filePath(fileConfig.Paths.Order, "logs/order.json")                      // trwające zlecenie produkcyjne (restart programu)
+	SummaryPendingFilePath  = filePath(fileConfig.Paths.SummaryPending, "logs/summary_pending.json")   // podsumowania zmian niezapisane w DB (ponawiane)
+	OeeTempPendingFilePath  = filePath(fileConfig.Paths.OeeTempPending, "logs/oee_temp_pending.json")  // próbki oee_temp niezapisane w DB albo z nierozstrzygniętej pauzy
+	EventsPendingFilePath   = filePath(fileConfig.Paths.EventsPending, "logs/events_pending.json")     // zakończone zdarzenia maszyny niezapisane w DB
 
 	JsonWithBackup = map[string]bool{
     OeeFilePath:      true,
@@ -47,55 +118,172 @@ var (
 )
 
 const (
-	// --- Ustawienia produkcyjne i urządzeń ---
-	ProductionCycleDefault = 14.0 // domyślny cykl produkcji [elementy/min]
-	FlowDeviceCount        = 5    // liczba urządzeń przepływowych (flow meters)
-	MeasurementDeviceCount = 3    // liczba analizatorów energii elektrycznej
-
 	// --- Interwały odczytu i aktualizacji danych ---
-	IntervalMQTTData          = 50 * time.Millisecond  // okres odświeżania danych z MQTT
-	IntervalRestData          = 100 * time.Millisecond // okres odświeżania danych z REST
-	FlowUpdateInterval        = 10 * time.Second       // zapis danych przepływowych (flow) do DB/JSON
-	MeasurementUpdateInterval = 10 * time.Second       // zapis danych pomiarowych (measurements) do DB/JSON
-	MetersUpdateInterval      = 5 * time.Second        // zapis danych licznikowych (meters) do DB/JSON
-	OEEUpdateInterval         = 5 * time.Second        // częstotliwość aktualizacji wskaźników OEE
-
-	// --- Parametry obliczeń OEE ---
-	AirFactor             = 1.0    // współczynnik przeliczeniowy powietrza (skalowanie totalisera)
-	ImpulsyNaObrot        = 8      // liczba impulsów odpowiadających jednemu obrotowi czujnika
+	RestTimeout               = 2 * time.Second        // timeout żądania HTTP do analizatora
+	RestBackoffMax            = 30 * time.Second       // maksymalna przerwa między odpytaniami urządzenia OFFLINE
+	IntervalModbusData        = 1 * time.Second        // okres odpytywania analizatorów Modbus TCP
+	ModbusTimeout             = 2 * time.Second        // timeout połączenia/odpowiedzi Modbus TCP
+	ModbusDefaultPort         = "502"
+	ModbusServerRefresh       = 1 * time.Second        // odświeżanie rejestrów serwera Modbus dla PLC
+	OpcUaRefresh              = 1 * time.Second        // odświeżanie wartości węzłów serwera OPC UA
+	ShutdownTimeout           = 20 * time.Second       // czas na zatrzymanie pętli i zapisy przy SIGTERM (stop_grace_period w compose musi być dłuższy)
+	ConfigWatchInterval       = 5 * time.Second        // sprawdzanie zmiany CONFIG_FILE (przeładowanie tuning.*)
+	ProductsRefreshInterval   = 1 * time.Minute        // odświeżanie katalogu produktów z tabeli products
+	OrderUpdateInterval       = 30 * time.Second       // zapis trwającego zlecenia produkcyjnego do DB
+	SummaryRetryInterval      = 1 * time.Minute        // ponawianie zapisu podsumowań zmian, których nie udało się zapisać do DB
+	MachineEventsUpdateInterval = 10 * time.Second     // zapis zakończonych zdarzeń maszyny (machine_events) do DB
+	HealthOeeMaxAge           = 30 * time.Second       // /healthz: maksymalny wiek ostatniego przeliczenia OEE
+	HealthDBMaxAge            = 2 * time.Minute        // /readyz: maksymalny wiek ostatniego udanego zapisu do DB
+
+	// --- Parametry obliczeń OEE (pozostałe: Tuning, CONFIG_FILE tuning.*) ---
 	ElementWindow         = 10     // długość bufora historii liczby elementów (ostatnie 10 odczytów) (nieużywany w aktualnej logice)
-	IdleTimeoutSeconds    = 10      // po ilu sekundach braku elementów rozpoczyna się zliczanie postoju
-	MaxChangeoverDuration = 10 * 60 // maksymalny czas (s), który może być zaliczony jako przezbrojenie zamiast zwykłego postoju
 
 	// --- Ścieżki do plików ---
-	SummaryFilePath         = "logs/summary.json"              // podsumowania zmian
-	OeeFilePath             = "logs/oee.json"                  // dane OEE (stan bieżący)
-	MqttOeeFilePath         = "logs/mqttOEE.json"              // surowe dane MQTT dla OEE
-	MqttFlowFilePath        = "logs/mqttFlow.json"             // dane przepływów (flow) z MQTT
-	MeasurementFilePath     = "logs/measurements.json"         // dane pomiarowe z REST
-	MetersFilePath          = "logs/meters.json"               // dane licznikowe z REST
-	SystemLogPath           = "logs/system.log"                // log systemowy aplikacji
+	MqttRecordRotateEvery   = time.Hour                        // maksymalny wiek pliku nagrania MQTT przed rotacją
 	DefaultJsonFile         = "logs/system_report.json"        // plik JSON domyślny (nieużywany w aktualnej logice)
 )
 
+// DimTolerance – tolerancja wymiarów elementu w jednostkach *_calc; 0 = wymiar nie jest porównywany.
+type DimTolerance struct {
+	Length float64 `yaml:"length"`
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+}
+
 // CycleRule defines rules for dynamic cycle assignment
 type CycleRule struct {
-	MaxLength int
-	MaxWidth  int
-	CycleLPM  float64
+	MaxLength int     `yaml:"max_length"`
+	MaxWidth  int     `yaml:"max_width"`
+	CycleLPM  float64 `yaml:"cycle_lpm"`
 }
 
-// From excel file cycles
-var CycleTable = []CycleRule{
-	{MaxLength: 600, MaxWidth: 9999, CycleLPM: 15.0},    // <600 mm → 4s
-	{MaxLength: 800, MaxWidth: 9999, CycleLPM: 12.875},  // <800 mm → ~4.66s
-	{MaxLength: 1200, MaxWidth: 9999, CycleLPM: 12.0},   // <1200 mm → 5s
-	{MaxLength: 99999, MaxWidth: 9999, CycleLPM: 7.06},  // >=1200 mm → 8.5s
+// defaultTuning – parametry procesu bez CONFIG_FILE (lub gdy plik ich nie ustawia)
+var defaultTuning = Tuning{
+	IdleTimeoutSeconds:     10,      // po ilu sekundach braku elementów rozpoczyna się zliczanie postoju
+	MaxChangeoverDuration:  10 * 60, // maksymalny czas (s), który może być zaliczony jako przezbrojenie zamiast zwykłego postoju
+	ChangeoverTolerance:    DimTolerance{Length: 20, Width: 20, Height: 2}, // zmiana wymiarów elementu = przezbrojenie
+	ImpulsyNaObrot:         8,       // liczba impulsów odpowiadających jednemu obrotowi czujnika
+	AirFactor:              1.0,     // współczynnik przeliczeniowy powietrza (skalowanie totalisera)
+	ProductionCycleDefault: 14.0,    // domyślny cykl produkcji [elementy/min]
+	// From excel file cycles
+	CycleTable: []CycleRule{
+		{MaxLength: 600, MaxWidth: 9999, CycleLPM: 15.0},    // <600 mm → 4s
+		{MaxLength: 800, MaxWidth: 9999, CycleLPM: 12.875},  // <800 mm → ~4.66s
+		{MaxLength: 1200, MaxWidth: 9999, CycleLPM: 12.0},   // <1200 mm → 5s
+		{MaxLength: 99999, MaxWidth: 9999, CycleLPM: 7.06},  // >=1200 mm → 8.5s
+	},
+	BreakdownThresholdSeconds: 5 * 60, // Six Big Losses: postój od 5 min to awaria
+	StartupWindowSeconds:      5 * 60, // odrzuty w 5 min po rozruchu to braki rozruchowe
+	MicroStopFactor:           2,      // przerwa > 2 cykli idealnych (krótsza od IdleTimeoutSeconds) to mikroprzestój
+	NominalSpeedRPM:           7.5,    // 8 impulsów/obrót co sekundę; niżej – spowolnienie obrotnicy
 }
 
 func getEnv(key, fallback string) string {
-	if val, ok := os.LookupEnv(key); ok {
+	if val, ok := lookupEnv(key); ok {
 		return val
 	}
 	return fallback
-}
\ No newline at end of file
+}
+
+// getEnvList – lista wartości rozdzielonych przecinkami (brak zmiennej = fallback).
+func getEnvList(key string, fallback []string) []string {
+	val, ok := lookupEnv(key)
+	if !ok || strings.TrimSpace(val) == "" {
+		return fallback
+	}
+	var out []string
+	for _, part := range strings.Split(val, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// portTopics – tematy MQTT mastera Balluff CMTK dla portów "masterN/portM".
+func portTopics(groups ...[]string) []string {
+	var topics []string
+	for _, ports := range groups {
+		for _, port := range ports {
+			master, p, _ := strings.Cut(port, "/")
+			topics = append(topics, fmt.Sprintf("balluff/cmtk/%s/iolink/devices/%s/data/fromdevice", master, p))
+		}
+	}
+	return topics
+}
+
+// TopicPort – klucz portu ("master1/port3") dla tematu MQTT z MqttTopics ("" gdy nieznany).
+func TopicPort(topic string) string {
+	for i, t := range MqttTopics {
+		if t != topic {
+			continue
+		}
+		if i < len(OeePorts) {
+			return OeePorts[i]
+		}
+		return FlowPorts[i-len(OeePorts)]
+	}
+	return ""
+}
+
+// analyzerIPs – adresy ANALYZER_IP01..; domyślne dla pierwszych analizatorów, dalsze tylko gdy ustawione.
+func analyzerIPs(defaults []string) []string {
+	n := len(defaults)
+	for i := n + 1; i <= 99; i++ {
+		if getEnv(fmt.Sprintf("ANALYZER_IP%02d", i), "") != "" {
+			n = i
+		}
+	}
+	if c := getEnvInt("ANALYZER_COUNT", 0); c > 0 {
+		n = c
+	}
+	out := make([]string, n)
+	for i := range out {
+		fallback := ""
+		if i < len(defaults) {
+			fallback = defaults[i]
+		}
+		out[i] = getEnv(fmt.Sprintf("ANALYZER_IP%02d", i+1), fallback)
+	}
+	return out
+}
+
+// analyzerEnv – wartość per analizator: PREFIX01, PREFIX02, ... (tyle, ile AnalyzerIPs).
+func analyzerEnv(prefix, fallback string) []string {
+	out := make([]string, len(AnalyzerIPs))
+	for i := range out {
+		out[i] = getEnv(fmt.Sprintf("%s%02d", prefix, i+1), fallback)
+	}
+	return out
+}
+
+func getEnvInt(key string, fallback int) int {
+	if val, ok := lookupEnv(key); ok && val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			return n
+		}
+		envError(key, val, "an integer")
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if val, ok := lookupEnv(key); ok && val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return f
+		}
+		envError(key, val, "a number")
+	}
+	return fallback
+}
+
+// getEnvDuration – czas w formacie Go ("90s", "24h"); niepoprawna wartość = fallback.
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if val, ok := lookupEnv(key); ok && val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			return d
+		}
+		envError(key, val, `a duration like "90s" or "24h"`)
+	}
+	return fallback
+}
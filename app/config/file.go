@@ -0,0 +1,401 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Plik konfiguracyjny CONFIG_FILE (YAML; JSON też jest poprawnym YAML-em) – warstwa nad
+// zmiennymi środowiskowymi:
+//
+//	env:       zmienne środowiskowe (MQTT_BROKER, LOG_LEVEL, ...) – wartość z pliku wygrywa ze środowiskiem
+//	intervals: okresy pętli odczytu i zapisu                          – zmiana wymaga restartu
+//	paths:     pliki JSON stanu i log systemowy                       – zmiana wymaga restartu
+//	tuning:    parametry procesu (postój, przezbrojenie, cykle, ...)  – przeładowanie SIGHUP / zmiana pliku
+//
+// Przykład: config.example.yaml.
+
+// Tuning – parametry procesu zmieniane w locie; bieżąca wartość: CurrentTuning().
+type Tuning struct {
+	IdleTimeoutSeconds     int                `yaml:"idle_timeout_seconds"`     // po ilu sekundach braku elementów rozpoczyna się zliczanie postoju
+	MaxChangeoverDuration  float64            `yaml:"max_changeover_duration"`  // maksymalny czas (s) zaliczany jako przezbrojenie zamiast zwykłego postoju
+	MaxChangeoverByFamily  map[string]float64 `yaml:"max_changeover_by_family"` // [s] jak wyżej dla rodziny produktu (products.family); brak = max_changeover_duration
+	ChangeoverTolerance    DimTolerance       `yaml:"changeover_tolerance"`     // zmiana wymiarów elementu po pauzie ponad tyle to przezbrojenie
+	ImpulsyNaObrot         float64            `yaml:"impulsy_na_obrot"`         // liczba impulsów odpowiadających jednemu obrotowi czujnika
+	AirFactor              float64            `yaml:"air_factor"`               // współczynnik przeliczeniowy powietrza (skalowanie totalisera)
+	ProductionCycleDefault float64            `yaml:"production_cycle_default"` // cykl produkcji [elementy/min], gdy wymiary nie pasują do CycleTable
+	CycleTable             []CycleRule        `yaml:"cycle_table"`              // cykl wg wymiarów, rosnąco po max_length
+
+	BreakdownThresholdSeconds float64 `yaml:"breakdown_threshold_seconds"` // postój od tylu sekund to awaria, krótszy – krótki postój
+	StartupWindowSeconds      float64 `yaml:"startup_window_seconds"`      // odrzuty do tylu sekund po rozruchu to braki rozruchowe
+	MicroStopFactor           float64 `yaml:"micro_stop_factor"`           // przerwa między elementami > tyle cykli idealnych to mikroprzestój
+	NominalSpeedRPM           float64 `yaml:"nominal_speed_rpm"`           // nominalna prędkość obrotnicy [obr/min]; 0 = bez progu
+
+	PlannedWeekdays []string `yaml:"planned_weekdays"` // dni (mon..sun) ze zmianami w planie produkcji (TEEP); pusta = każdy dzień
+}
+
+type fileIntervals struct {
+	MQTTData          *time.Duration `yaml:"mqtt_data"`
+	RestData          *time.Duration `yaml:"rest_data"`
+	FlowUpdate        *time.Duration `yaml:"flow_update"`
+	MeasurementUpdate *time.Duration `yaml:"measurement_update"`
+	MetersUpdate      *time.Duration `yaml:"meters_update"`
+	OeeUpdate         *time.Duration `yaml:"oee_update"`
+}
+
+type filePaths struct {
+	Summary         *string `yaml:"summary"`
+	Oee             *string `yaml:"oee"`
+	MqttOee         *string `yaml:"mqtt_oee"`
+	MqttFlow        *string `yaml:"mqtt_flow"`
+	Measurements    *string `yaml:"measurements"`
+	Meters          *string `yaml:"meters"`
+	AnalyzerFmt     *string `yaml:"analyzer_fmt"`
+	FakeMeasurement *string `yaml:"fake_measurements"`
+	FakeMeters      *string `yaml:"fake_meters"`
+	SystemLog       *string `yaml:"system_log"`
+	Products        *string `yaml:"products"`
+	Order           *string `yaml:"order"`
+	SummaryPending  *string `yaml:"summary_pending"`
+	OeeTempPending  *string `yaml:"oee_temp_pending"`
+	EventsPending   *string `yaml:"events_pending"`
+}
+
+type fileSettings struct {
+	Env       map[string]string `yaml:"env"`
+	Intervals fileIntervals     `yaml:"intervals"`
+	Paths     filePaths         `yaml:"paths"`
+	Tuning    Tuning            `yaml:"tuning"`
+}
+
+var (
+	// ConfigFile – ścieżka pliku konfiguracyjnego (tylko ze środowiska; pusty = bez pliku)
+	ConfigFile = os.Getenv("CONFIG_FILE")
+
+	fileConfig, fileConfigErr = readConfigFile(ConfigFile)
+	fileModTime               = configModTime(ConfigFile)
+	lastApplied               = fileConfig // ostatnio przyjęty plik (start albo Reload); chronione reloadMu
+
+	tuning atomic.Pointer[Tuning]
+
+	envMu     sync.Mutex
+	envKnown  = map[string]bool{} // zmienne odczytane przez konfigurację (walidacja sekcji env)
+	envErrors []string            // niepoprawne wartości liczbowe/czasowe
+
+	reloadMu sync.Mutex
+)
+
+func readConfigFile(path string) (*fileSettings, error) {
+	s := &fileSettings{Tuning: defaultTuning.clone()}
+	if path == "" {
+		return s, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		return &fileSettings{Tuning: defaultTuning.clone()}, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+func configModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	if st, err := os.Stat(path); err == nil {
+		return st.ModTime()
+	}
+	return time.Time{}
+}
+
+// lookupEnv – wartość z sekcji env pliku, a gdy jej brak – ze środowiska.
+func lookupEnv(key string) (string, bool) {
+	envMu.Lock()
+	envKnown[key] = true
+	envMu.Unlock()
+	if v, ok := fileConfig.Env[key]; ok {
+		return v, true
+	}
+	return os.LookupEnv(key)
+}
+
+func envError(key, val, want string) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	envErrors = append(envErrors, fmt.Sprintf("env %s=%q: expected %s", key, val, want))
+}
+
+func fileDuration(v *time.Duration, fallback time.Duration) time.Duration {
+	if v != nil {
+		return *v
+	}
+	return fallback
+}
+
+func filePath(v *string, fallback string) string {
+	if v != nil {
+		return *v
+	}
+	return fallback
+}
+
+// CurrentTuning – bieżące parametry procesu (kopia; CycleTable tylko do odczytu).
+func CurrentTuning() Tuning {
+	if t := tuning.Load(); t != nil {
+		return *t
+	}
+	return fileConfig.Tuning
+}
+
+func (t Tuning) clone() Tuning {
+	t.CycleTable = append([]CycleRule(nil), t.CycleTable...)
+	t.PlannedWeekdays = append([]string(nil), t.PlannedWeekdays...)
+	if t.MaxChangeoverByFamily != nil {
+		byFamily := make(map[string]float64, len(t.MaxChangeoverByFamily))
+		for k, v := range t.MaxChangeoverByFamily {
+			byFamily[k] = v
+		}
+		t.MaxChangeoverByFamily = byFamily
+	}
+	return t
+}
+
+// MaxChangeoverFor – maksymalny czas przezbrojenia [s] na produkt z rodziny family.
+func (t Tuning) MaxChangeoverFor(family string) float64 {
+	if v, ok := t.MaxChangeoverByFamily[family]; ok && family != "" {
+		return v
+	}
+	return t.MaxChangeoverDuration
+}
+
+// MaxChangeoverLimit – najdłuższy czas przezbrojenia dowolnej rodziny (pauza dłuższa to na pewno postój).
+func (t Tuning) MaxChangeoverLimit() float64 {
+	limit := t.MaxChangeoverDuration
+	for _, v := range t.MaxChangeoverByFamily {
+		limit = math.Max(limit, v)
+	}
+	return limit
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// PlannedOn – czy zmiany rozpoczęte w dniu d są w planie produkcji (tuning.planned_weekdays).
+func (t Tuning) PlannedOn(d time.Weekday) bool {
+	if len(t.PlannedWeekdays) == 0 {
+		return true
+	}
+	for _, name := range t.PlannedWeekdays {
+		if strings.EqualFold(name, weekdayNames[d]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidationError – lista błędów konfiguracji.
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// Validate sprawdza plik konfiguracyjny i wartości zmiennych środowiskowych; wołane na starcie,
+// po inicjalizacji wszystkich ustawień. Błąd = program nie powinien startować.
+func Validate() error {
+	if fileConfigErr != nil {
+		return ValidationError{fileConfigErr.Error()}
+	}
+	errs := fileConfig.validate()
+	envMu.Lock()
+	errs = append(errs, envErrors...)
+	var unknown []string
+	for key := range fileConfig.Env {
+		if !envKnown[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	envMu.Unlock()
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Sprintf("env.%s: unknown setting", key))
+	}
+	if len(errs) > 0 {
+		return ValidationError(errs)
+	}
+	t := fileConfig.Tuning.clone()
+	tuning.Store(&t)
+	return nil
+}
+
+func (s *fileSettings) validate() []string {
+	var errs []string
+	add := func(format string, args ...interface{}) { errs = append(errs, fmt.Sprintf(format, args...)) }
+
+	t := s.Tuning
+	if t.IdleTimeoutSeconds < 1 {
+		add("tuning.idle_timeout_seconds: must be >= 1, got %d", t.IdleTimeoutSeconds)
+	}
+	if t.MaxChangeoverDuration < 0 {
+		add("tuning.max_changeover_duration: must be >= 0, got %g", t.MaxChangeoverDuration)
+	}
+	for family, v := range t.MaxChangeoverByFamily {
+		if family == "" || v < 0 {
+			add("tuning.max_changeover_by_family[%q]: family name required and value must be >= 0, got %g", family, v)
+		}
+	}
+	if t.ChangeoverTolerance.Length < 0 || t.ChangeoverTolerance.Width < 0 || t.ChangeoverTolerance.Height < 0 {
+		add("tuning.changeover_tolerance: values must be >= 0")
+	}
+	if t.ImpulsyNaObrot <= 0 {
+		add("tuning.impulsy_na_obrot: must be > 0, got %g", t.ImpulsyNaObrot)
+	}
+	if t.AirFactor <= 0 {
+		add("tuning.air_factor: must be > 0, got %g", t.AirFactor)
+	}
+	if t.ProductionCycleDefault <= 0 {
+		add("tuning.production_cycle_default: must be > 0, got %g", t.ProductionCycleDefault)
+	}
+	if t.BreakdownThresholdSeconds <= 0 {
+		add("tuning.breakdown_threshold_seconds: must be > 0, got %g", t.BreakdownThresholdSeconds)
+	}
+	if t.StartupWindowSeconds < 0 {
+		add("tuning.startup_window_seconds: must be >= 0, got %g", t.StartupWindowSeconds)
+	}
+	if t.MicroStopFactor <= 1 {
+		add("tuning.micro_stop_factor: must be > 1, got %g", t.MicroStopFactor)
+	}
+	if t.NominalSpeedRPM < 0 {
+		add("tuning.nominal_speed_rpm: must be >= 0, got %g", t.NominalSpeedRPM)
+	}
+	for i, name := range t.PlannedWeekdays {
+		known := false
+		for _, w := range weekdayNames {
+			known = known || strings.EqualFold(name, w)
+		}
+		if !known {
+			add("tuning.planned_weekdays[%d]: must be one of mon, tue, wed, thu, fri, sat, sun, got %q", i, name)
+		}
+	}
+	if len(t.CycleTable) == 0 {
+		add("tuning.cycle_table: at least one rule is required")
+	}
+	for i, r := range t.CycleTable {
+		if r.MaxLength <= 0 || r.MaxWidth <= 0 {
+			add("tuning.cycle_table[%d]: max_length and max_width must be > 0", i)
+		}
+		if r.CycleLPM <= 0 {
+			add("tuning.cycle_table[%d].cycle_lpm: must be > 0, got %g", i, r.CycleLPM)
+		}
+		if i > 0 && r.MaxLength <= t.CycleTable[i-1].MaxLength {
+			add("tuning.cycle_table[%d].max_length: rules must be sorted by increasing max_length (%d after %d)",
+				i, r.MaxLength, t.CycleTable[i-1].MaxLength)
+		}
+	}
+
+	eachField(s.Intervals, "intervals", func(name string, v reflect.Value) {
+		if d := v.Interface().(*time.Duration); d != nil && *d <= 0 {
+			add("%s: must be > 0, got %s", name, *d)
+		}
+	})
+	eachField(s.Paths, "paths", func(name string, v reflect.Value) {
+		if p := v.Interface().(*string); p != nil && strings.TrimSpace(*p) == "" {
+			add("%s: must not be empty", name)
+		}
+	})
+	return errs
+}
+
+// eachField woła fn dla każdego pola struktury z nazwą "<prefix>.<klucz yaml>".
+func eachField(v interface{}, prefix string, fn func(name string, field reflect.Value)) {
+	rv := reflect.ValueOf(v)
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		key, _, _ := strings.Cut(rt.Field(i).Tag.Get("yaml"), ",")
+		fn(prefix+"."+key, rv.Field(i))
+	}
+}
+
+// ReloadResult – wynik przeładowania pliku.
+type ReloadResult struct {
+	Changed         []string // zmienione i zastosowane parametry tuning.*
+	RestartRequired []string // env/intervals/paths zmienione od poprzedniego odczytu pliku (działają po restarcie)
+	PendingRestart  []string // wszystkie env/intervals/paths różne od wartości ze startu programu
+}
+
+// Reload ponownie czyta CONFIG_FILE i podmienia parametry tuning. Przy błędzie (składnia,
+// walidacja) obowiązują dotychczasowe wartości.
+func Reload() (ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	var res ReloadResult
+	if ConfigFile == "" {
+		return res, errors.New("CONFIG_FILE is not set")
+	}
+	fileModTime = configModTime(ConfigFile)
+	s, err := readConfigFile(ConfigFile)
+	if err != nil {
+		return res, err
+	}
+	if errs := s.validate(); len(errs) > 0 {
+		return res, ValidationError(errs)
+	}
+
+	old := CurrentTuning()
+	res.Changed = diffFields(reflect.ValueOf(old), reflect.ValueOf(s.Tuning), "tuning")
+	t := s.Tuning.clone()
+	tuning.Store(&t)
+
+	res.RestartRequired = restartDiff(lastApplied, s)
+	res.PendingRestart = restartDiff(fileConfig, s)
+	lastApplied = s
+	return res, nil
+}
+
+// restartDiff – ustawienia env/intervals/paths różne w a i b.
+func restartDiff(a, b *fileSettings) []string {
+	var out []string
+	if !reflect.DeepEqual(a.Env, b.Env) {
+		out = append(out, "env")
+	}
+	out = append(out, diffFields(reflect.ValueOf(a.Intervals), reflect.ValueOf(b.Intervals), "intervals")...)
+	return append(out, diffFields(reflect.ValueOf(a.Paths), reflect.ValueOf(b.Paths), "paths")...)
+}
+
+func diffFields(a, b reflect.Value, prefix string) []string {
+	var out []string
+	for i := 0; i < a.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			key, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("yaml"), ",")
+			out = append(out, prefix+"."+key)
+		}
+	}
+	return out
+}
+
+// ConfigFileChanged – czy CONFIG_FILE zmienił się od startu / ostatniego Reload (czas modyfikacji).
+func ConfigFileChanged() bool {
+	if ConfigFile == "" {
+		return false
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	mt := configModTime(ConfigFile)
+	return !mt.IsZero() && !mt.Equal(fileModTime)
+}
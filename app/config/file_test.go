@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestReloadRestartRequired – zmiana intervals jest zgłaszana raz, ale pozostaje w PendingRestart.
+func TestReloadRestartRequired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	oldFile, oldApplied, oldTuning := ConfigFile, lastApplied, tuning.Load()
+	ConfigFile = path
+	t.Cleanup(func() {
+		ConfigFile, lastApplied = oldFile, oldApplied
+		tuning.Store(oldTuning)
+	})
+
+	reload := func(yaml string) ReloadResult {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		res, err := Reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := reload("intervals:\n  oee_update: 7s\n")
+	if want := []string{"intervals.oee_update"}; !reflect.DeepEqual(res.RestartRequired, want) || !reflect.DeepEqual(res.PendingRestart, want) {
+		t.Fatalf("first reload: %+v", res)
+	}
+	res = reload("intervals:\n  oee_update: 7s\ntuning:\n  idle_timeout_seconds: 12\n")
+	if len(res.RestartRequired) != 0 || !reflect.DeepEqual(res.Changed, []string{"tuning.idle_timeout_seconds"}) {
+		t.Errorf("second reload reported restart again: %+v", res)
+	}
+	if !reflect.DeepEqual(res.PendingRestart, []string{"intervals.oee_update"}) {
+		t.Errorf("pending restart lost: %+v", res)
+	}
+	res = reload("tuning:\n  idle_timeout_seconds: 12\n")
+	if !reflect.DeepEqual(res.RestartRequired, []string{"intervals.oee_update"}) || len(res.PendingRestart) != 0 {
+		t.Errorf("revert to startup value: %+v", res)
+	}
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"math"
+)
+
+// --- Rozpoznawanie przezbrojenia ---
+//
+// Pauza kończy się przezbrojeniem (updateIdleTime), jeśli między ostatnim elementem przed pauzą
+// a pierwszym po niej:
+//   - przyszedł jawny sygnał przezbrojenia (SignalChangeover – PLC, API),
+//   - zaczęło się inne zlecenie produkcyjne,
+//   - zmierzone wymiary elementu (*_calc) zmieniły się ponad tuning.changeover_tolerance,
+// i pauza nie trwała dłużej niż maksymalny czas przezbrojenia rodziny nowego produktu
+// (tuning.max_changeover_by_family, domyślnie tuning.max_changeover_duration). Sam cykl nie
+// decyduje: dwa produkty z tej samej reguły CycleTable też są przezbrojeniem.
+
+// Powód przezbrojenia (machine_events.reason).
+const (
+	ChangeoverSignal     = "signal"
+	ChangeoverOrder      = "order"
+	ChangeoverDimensions = "dimensions"
+)
+
+// ChangeoverInternal – element sprzed pauzy i sygnał w oee.json (internal.changeover).
+type ChangeoverInternal struct {
+	Length       float64 `json:"length"`
+	Width        float64 `json:"width"`
+	Height       float64 `json:"height"`
+	HasDims      bool    `json:"has_dims"` // wymiary zmierzone (port wymiarów dostępny)
+	SKU          string  `json:"sku"`
+	OrderNo      string  `json:"order_no,omitempty"`
+	Signal       bool    `json:"signal,omitempty"`
+	SignalSource string  `json:"signal_source,omitempty"`
+}
+
+var (
+	changeoverFrom ChangeoverInternal // chronione calcLock
+	measuredDims   [3]float64         // wymiary z ostatniej ramki portu wymiarów
+	measuredOK     bool
+	changeoverLog  = utils.NewLogger("CHANGEOVER")
+)
+
+// measureChangeoverDims – wymiary elementu z portu wymiarów; port niedostępny (is_valid = false,
+// brak wartości) nie zmienia ostatniego pomiaru. Wymaga calcLock.
+func measureChangeoverDims(port map[string]interface{}) {
+	if _, ok := port["Dlugosc"]; !ok {
+		return
+	}
+	if v, ok := port["is_valid"]; ok && !utils.ToBool(v) {
+		return
+	}
+	measuredDims = [3]float64{
+		utils.ToFloat(CalculatedData["Dlugosc_calc"]),
+		utils.ToFloat(CalculatedData["Szerokosc_calc"]),
+		utils.ToFloat(CalculatedData["Wysokosc_calc"]),
+	}
+	measuredOK = true
+}
+
+// changeoverElement – nowy element poza pauzą: punkt odniesienia dla następnej pauzy
+// (wymiary z poprzedniej ramki, jak cykl w detectElement). Wymaga calcLock.
+func changeoverElement() {
+	if CzasPomiarowy.PauseStartTime != nil {
+		return // pierwszy element po pauzie – rozstrzyga updateIdleTime
+	}
+	signal, source := changeoverFrom.Signal, changeoverFrom.SignalSource
+	changeoverFrom = ChangeoverInternal{
+		Length: measuredDims[0], Width: measuredDims[1], Height: measuredDims[2], HasDims: measuredOK,
+		SKU: currentProductSKU, OrderNo: currentOrderNo(),
+		Signal: signal, SignalSource: source, // sygnał w czasie pracy dotyczy następnej pauzy
+	}
+}
+
+func currentOrderNo() string {
+	if currentOrder == nil {
+		return ""
+	}
+	return currentOrder.OrderNo
+}
+
+// changeoverReason – powód przezbrojenia dla pauzy kończącej się teraz; "" = zwykła pauza.
+// Wymaga calcLock.
+func changeoverReason() string {
+	from := changeoverFrom
+	switch {
+	case from.Signal:
+		return ChangeoverSignal
+	case currentOrderNo() != "" && currentOrderNo() != from.OrderNo:
+		return ChangeoverOrder
+	case from.HasDims && measuredOK && dimsChanged(from):
+		return ChangeoverDimensions
+	}
+	return ""
+}
+
+func dimsChanged(from ChangeoverInternal) bool {
+	tol := config.CurrentTuning().ChangeoverTolerance
+	beyond := func(a, b, t float64) bool { return t > 0 && math.Abs(a-b) > t }
+	return beyond(measuredDims[0], from.Length, tol.Length) ||
+		beyond(measuredDims[1], from.Width, tol.Width) ||
+		beyond(measuredDims[2], from.Height, tol.Height)
+}
+
+// maxChangeoverSeconds – maksymalny czas przezbrojenia na bieżący produkt (rodzina z katalogu).
+func maxChangeoverSeconds() float64 {
+	p, _ := ProductBySKU(currentProductSKU)
+	return config.CurrentTuning().MaxChangeoverFor(p.Family)
+}
+
+// changeoverResolved – koniec pauzy: pierwszy element po niej jest nowym punktem odniesienia,
+// sygnał jest zużyty. Wymaga calcLock.
+func changeoverResolved() {
+	changeoverFrom = ChangeoverInternal{
+		Length: measuredDims[0], Width: measuredDims[1], Height: measuredDims[2], HasDims: measuredOK,
+		SKU: currentProductSKU, OrderNo: currentOrderNo(),
+	}
+}
+
+// resetChangeoverSignal – granica zmiany: pauza poprzedniej zmiany nie jest już rozstrzygana.
+// Wymaga calcLock.
+func resetChangeoverSignal() {
+	changeoverFrom.Signal, changeoverFrom.SignalSource = false, ""
+}
+
+// loadChangeover – stan z oee.json (internal.changeover); wymaga calcLock.
+func loadChangeover(data map[string]interface{}) {
+	changeoverFrom = ChangeoverInternal{}
+	if in, ok := data["internal"].(map[string]interface{}); ok {
+		decodeSection(in["changeover"], &changeoverFrom)
+	}
+	measuredDims = [3]float64{changeoverFrom.Length, changeoverFrom.Width, changeoverFrom.Height}
+	measuredOK = false
+}
+
+// clearChangeover – stan początkowy (replay); wymaga calcLock.
+func clearChangeover() {
+	changeoverFrom = ChangeoverInternal{}
+	measuredDims, measuredOK = [3]float64{}, false
+}
+
+// SignalChangeover – jawny sygnał przezbrojenia (PLC, operator): trwająca pauza zakończy się
+// przezbrojeniem, a w czasie pracy – najbliższa pauza. Zwraca true, jeśli trwa pauza.
+func SignalChangeover(source string) bool {
+	calcLock.Lock()
+	changeoverFrom.Signal, changeoverFrom.SignalSource = true, source
+	paused := CzasPomiarowy.PauseStartTime != nil
+	calcLock.Unlock()
+	changeoverLog.Info("changeover signalled", "source", source, "pause_active", paused)
+	return paused
+}
+
+// ChangeoverCommand – {"source": "api"}: sygnał przezbrojenia z nagrania (InputTopicChangeover).
+type ChangeoverCommand struct {
+	Source string `json:"source"`
+}
+
+// HandleChangeoverCommand wykonuje sygnał przezbrojenia z nagrania.
+func HandleChangeoverCommand(payload []byte) error {
+	var cmd ChangeoverCommand
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		return fmt.Errorf("invalid changeover command: %w", err)
+	}
+	SignalChangeover(cmd.Source)
+	return nil
+}
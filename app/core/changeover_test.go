@@ -0,0 +1,45 @@
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestChangeoverReason – powód każdej pauzy w scenariuszach testdata/replay/changeover*.jsonl
+// ("" = zwykły postój); źródła scenariuszy: pliki .yaml obok.
+func TestChangeoverReason(t *testing.T) {
+	cases := []struct {
+		file  string
+		pause []string
+	}{
+		{"changeover.jsonl", []string{"", ChangeoverDimensions}},
+		{"changeover_tolerance.jsonl", []string{"", "", ChangeoverDimensions}},
+		{"changeover_signal.jsonl", []string{"", ChangeoverSignal, ChangeoverSignal, ""}},
+		{"changeover_order.jsonl", []string{"", ChangeoverOrder, ""}},
+	}
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			frames, err := LoadReplayFrames(filepath.Join("testdata", "replay", c.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ReplayFrames(frames, ReplayOptions{Every: len(frames)})
+			calcLock.Lock()
+			events := append([]MachineEvent(nil), pendingEvents...)
+			calcLock.Unlock()
+			var got []string
+			for _, e := range events {
+				switch e.Type {
+				case "stop":
+					got = append(got, "")
+				case "changeover":
+					got = append(got, e.Reason)
+				}
+			}
+			if !reflect.DeepEqual(got, c.pause) {
+				t.Errorf("pause reasons = %q, want %q", got, c.pause)
+			}
+		})
+	}
+}
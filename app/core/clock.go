@@ -0,0 +1,72 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock – źródło czasu dla silnika OEE.
+// Produkcyjnie to zegar systemowy; replay i narzędzia offline podstawiają ManualClock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// ManualClock – zegar przestawiany ręcznie (deterministyczny replay).
+type ManualClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{t: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// Set ustawia bieżący czas zegara.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+// Advance przesuwa zegar o d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+var (
+	clockLock   sync.RWMutex
+	engineClock Clock = systemClock{}
+)
+
+// SetClock podmienia zegar silnika OEE; nil przywraca zegar systemowy.
+// Zwraca poprzedni zegar, żeby wywołujący mógł go odtworzyć.
+func SetClock(c Clock) Clock {
+	if c == nil {
+		c = systemClock{}
+	}
+	clockLock.Lock()
+	defer clockLock.Unlock()
+	prev := engineClock
+	engineClock = c
+	return prev
+}
+
+// nowUTC – jedyne miejsce, z którego silnik OEE pobiera „teraz”.
+func nowUTC() time.Time {
+	clockLock.RLock()
+	c := engineClock
+	clockLock.RUnlock()
+	return c.Now().UTC()
+}
@@ -8,8 +8,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-	"math"
-	"runtime/debug"
 
 	_ "github.com/lib/pq"
 )
@@ -19,14 +17,18 @@ var (
 	lastMeasurementsOK bool
 	lastMetersOK       bool
 	lastFlowOK         bool
-	lastOeeOK          bool
 	lastShiftOK        bool
 )
 
 func getConnection() (*sql.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		config.DbHost, config.DbPort, config.DbUser, config.DbPassword, config.DbName)
-	return sql.Open("postgres", dsn)
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	ensureSchema(conn)
+	return conn, nil
 }
 
 func SaveMeasurementsToDB(filename string) {
@@ -61,7 +63,8 @@ func SaveMeasurementsToDB(filename string) {
 		utils.LogMessage("[DB] Transaction begin error: " + err.Error())
 		return
 	}
-	defer tx.Commit()
+	written := map[string]bool{}
+	defer commitTx(tx, "SaveMeasurementsToDB", written)
 
 	for key, items := range data {
 		deviceID := extractDeviceID(key)
@@ -124,6 +127,7 @@ func SaveMeasurementsToDB(filename string) {
 			utils.LogMessage(fmt.Sprintf("[DB] Error inserting measurements for device_%d: %v", deviceID, err))
 			continue
 		}
+		written["measurements"] = true
 	}
 }
 
@@ -169,7 +173,8 @@ func SaveMetersToDB(filename string) {
 		utils.LogMessage("[DB] Transaction error: " + err.Error())
 		return
 	}
-	defer tx.Commit()
+	written := map[string]bool{}
+	defer commitTx(tx, "SaveMetersToDB", written)
 
 	tables := map[string][]string{
 		"meters_total_temp": {"ea_pos_total", "ea_neg_total", "er_pos_total", "er_neg_total", "es_total", "er_total", "ea_pos", "ea_neg", "er_pos", "er_neg", "es", "er", "e_runtime"},
@@ -238,6 +243,7 @@ func SaveMetersToDB(filename string) {
 				utils.LogMessage(fmt.Sprintf("[DB] Error inserting into %s for device_%d: %v", tableName, deviceID, err))
 				continue
 			}
+			written[tableName] = true
 		}
 	}
 }
@@ -274,21 +280,17 @@ func SaveFlowDataToDB(filename string) {
 		utils.LogMessage("[DB] Transaction error: " + err.Error())
 		return
 	}
-	defer tx.Commit()
-
-	portMapping := map[string]int{
-		"master1/port3": 1,
-		"master1/port4": 2,
-		"master2/port0": 3,
-		"master2/port1": 4,
-		"master2/port2": 5,
-	}
+	written := map[string]bool{}
+	defer commitTx(tx, "SaveFlowDataToDB", written)
 
+	// numer przepływomierza = pozycja portu w config.FlowPorts (FLOW_PORTS)
 	var globalTimestamp time.Time
-	if port3, ok := data["master1/port3"].(map[string]interface{}); ok {
-		if tsStr, ok := port3["timestamp"].(string); ok {
-			if t, err := time.Parse(time.RFC3339Nano, tsStr); err == nil {
-				globalTimestamp = t
+	if len(config.FlowPorts) > 0 {
+		if first, ok := data[config.FlowPorts[0]].(map[string]interface{}); ok {
+			if tsStr, ok := first["timestamp"].(string); ok {
+				if t, err := time.Parse(time.RFC3339Nano, tsStr); err == nil {
+					globalTimestamp = t
+				}
 			}
 		}
 	}
@@ -296,7 +298,8 @@ func SaveFlowDataToDB(filename string) {
 		globalTimestamp = time.Now().UTC()
 	}
 
-	for port, deviceID := range portMapping {
+	for i, port := range config.FlowPorts {
+		deviceID := i + 1
 		var flow, pressure, temperature, totaliser float64
 
 		if entryRaw, ok := data[port]; ok {
@@ -304,7 +307,7 @@ func SaveFlowDataToDB(filename string) {
 				flow = utils.ToFloat(entry["flow"])
 				pressure = utils.ToFloat(entry["pressure"])
 				temperature = utils.ToFloat(entry["temperature"])
-				totaliser = utils.ToFloat(entry["totaliser"]) * config.AirFactor
+				totaliser = utils.ToFloat(entry["totaliser"]) * config.CurrentTuning().AirFactor
 			}
 		}
 
@@ -318,37 +321,14 @@ func SaveFlowDataToDB(filename string) {
 			utils.LogMessage(fmt.Sprintf("[DB] Error inserting flow_data for device %d: %v", deviceID, err))
 			continue
 		}
+		written["flow_data"] = true
 	}
 }
 
-func SaveOeeTempToDB(filename string) {
-	defer func() {
-		if r := recover(); r != nil {
-			utils.LogMessage(fmt.Sprintf("[PANIC] SaveOeeTempToDB: %v", r))
-		}
-	}()
-
-	data := utils.LoadFromJSON(filename)
-	if len(data) == 0 {
-		if lastOeeOK {
-			utils.LogMessage("[OEE_TEMP] No data in oee.json or error reading from file")
-			lastOeeOK = false
-		}
-		return
-	}
-	if !lastOeeOK {
-		utils.LogMessage("[OEE_TEMP] Data restored in oee.json")
-		lastOeeOK = true
-	}
-
-	db, err := getConnection()
-	if err != nil {
-		utils.LogMessage("[DB] Connection error: " + err.Error())
-		return
-	}
-	defer db.Close()
-
-	// nested float/bool/int
+// insertShiftDetails – wiersze produktów, strat i postojów zmiany (sekcje products, losses, stops)
+// z data_utworzenia = created (data_utworzenia wiersza zmiany – klucz powiązania z shift_summary).
+// Pierwszy błąd przerywa wstawianie: transakcja jest już przerwana, wywołujący ją wycofuje.
+func insertShiftDetails(tx *sql.Tx, created, start time.Time, data map[string]any) error {
 	nf := func(keys ...string) float64 {
 		var cur any = data
 		for _, k := range keys {
@@ -360,98 +340,97 @@ func SaveOeeTempToDB(filename string) {
 		}
 		return utils.ToFloat(cur)
 	}
-	nb := func(keys ...string) bool {
-		var cur any = data
-		for _, k := range keys {
-			m, ok := cur.(map[string]any)
-			if !ok {
-				return false
-			}
-			cur = m[k]
-		}
-		return utils.ToBool(cur)
-	}
-	ni := func(keys ...string) int {
-		var cur any = data
-		for _, k := range keys {
-			m, ok := cur.(map[string]any)
-			if !ok {
-				return 0
-			}
-			cur = m[k]
-		}
-		return utils.ToInt(cur)
-	}
 
-	query := `
-		INSERT INTO oee_temp (
-			timestamp, predkosc_obrotnica, czas_pracy, czas_postoju,
-			czas_pomiaru, czas_przezbrojenia,
-			status_maszyny, ilosc_elementow,
-			dlugosc_calc, szerokosc_calc, wysokosc_calc,
-			dostepnosc, wydajnosc, jakosc, cykl, oee,
-			czas_przezbrojenia_temp, status_pracy, W_na_szt, M3_na_szt
-		) VALUES (
-			now(), $1, $2, $3, $4,
-			$5, $6,
-			$7, $8,
-			$9, $10, $11,
-			$12, $13, $14, $15, $16,
-			$17, $18, $19
+	// produkcja per produkt (sekcja products)
+	const productQuery = `
+		INSERT INTO shift_summary_product (
+			data_utworzenia, start_zmiany, sku, name,
+			pieces, work_seconds, ideal_cycle_s, expected_pieces, wydajnosc,
+			micro_stops, micro_stop_seconds
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT DO NOTHING
+	`
+	products, _ := data["products"].(map[string]any)
+	for sku := range products {
+		pf := func(key string) float64 { return nf("products", sku, key) }
+		row, _ := products[sku].(map[string]any)
+		name, _ := row["name"].(string)
+		_, err := tx.Exec(productQuery,
+			created, start, sku, sql.NullString{String: name, Valid: name != ""},
+			int(pf("pieces")), pf("work_seconds"), pf("ideal_cycle_s"), pf("expected_pieces"), pf("wydajnosc"),
+			int(pf("micro_stops")), pf("micro_stop_seconds"),
 		)
-		ON CONFLICT DO NOTHING`
-
-	args := []interface{}{
-		nf("oee", "predkosc_obrotnica"),
-		nf("oee", "czas_pracy"),
-		nf("oee", "czas_postoju"),
-		nf("oee", "czas_pomiaru"),
-		nf("oee", "czas_przezbrojenia"),
-		nb("oee", "status_maszyny"),
-		ni("oee", "ilosc_elementow"),
-		nf("product", "dlugosc_calc"),
-		nf("product", "szerokosc_calc"),
-		nf("product", "wysokosc_calc"),
-		nf("oee", "dostepnosc"),
-		nf("oee", "wydajnosc"),
-		nf("oee", "jakosc"),
-		nf("product", "cykl"),
-		nf("oee", "oee"),
-		nf("oee", "czas_przezbrojenia_temp"),
-		nb("oee", "status_pracy"),
-		nf("oee", "W_na_szt"),
-		nf("oee", "M3_na_szt"),
+		if err != nil {
+			return fmt.Errorf("shift_summary_product %s: %w", sku, err)
+		}
 	}
 
-	if _, err := db.Exec(query, args...); err != nil {
-		utils.LogMessage(fmt.Sprintf("[DB] Error inserting into oee_temp: %v", err))
+	// Six Big Losses (sekcja losses) – jeden wiersz na kategorię
+	const lossQuery = `
+		INSERT INTO shift_summary_loss (
+			data_utworzenia, start_zmiany, category, seconds, pieces
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING
+	`
+	losses := lossesFromMap(data["losses"])
+	for _, cat := range LossCategories {
+		var pieces sql.NullInt64
+		if strings.HasSuffix(cat, "_rejects") {
+			pieces = sql.NullInt64{Int64: int64(losses.Pieces(cat)), Valid: true}
+		}
+		if _, err := tx.Exec(lossQuery, created, start, cat, losses.Seconds(cat), pieces); err != nil {
+			return fmt.Errorf("shift_summary_loss %s: %w", cat, err)
+		}
 	}
-}
 
-func SaveShiftSummaryToDB(filename string) {
-	defer func() {
-		if r := recover(); r != nil {
-			utils.LogMessage(fmt.Sprintf("[PANIC] SaveShiftSummaryToDB: %v", r))
+	// historia postojów zmiany (sekcja stops; trwający postój z koniec = NULL)
+	const stopQuery = `
+		INSERT INTO stop_events (
+			data_utworzenia, start_zmiany, start, koniec, seconds, breakdown
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING
+	`
+	var stops []StopEvent
+	decodeSection(data["stops"], &stops)
+	for _, e := range stops {
+		if _, err := tx.Exec(stopQuery, created, start, e.Start, e.End, e.Seconds, e.Breakdown); err != nil {
+			return fmt.Errorf("stop_events %s: %w", e.Start.Format(time.RFC3339), err)
 		}
-	}()
+	}
+	return nil
+}
 
+// SaveShiftSummaryToDB – podsumowanie zmiany z pliku do DB; błąd = nic nie zapisano
+// (executeShiftSummary odkłada wtedy podsumowanie do ponowienia, shift_summary_retry.go).
+func SaveShiftSummaryToDB(filename string) error {
 	data := utils.LoadFromJSON(filename)
 	if len(data) == 0 {
 		if lastShiftOK {
 			utils.LogMessage("[SHIFT_SUMMARY] No data in shift_summary.json or error reading from file")
 			lastShiftOK = false
 		}
-		return
+		return fmt.Errorf("no shift summary in %s", filename)
 	}
 	if !lastShiftOK {
 		utils.LogMessage("[SHIFT_SUMMARY] Data restored in shift_summary.json")
 		lastShiftOK = true
 	}
+	return saveShiftSummary(data)
+}
+
+// saveShiftSummary – wiersz zmiany, urządzeń i szczegółów w jednej transakcji; błąd któregokolwiek
+// wstawienia wycofuje całość. Zmiana już zapisana (ten sam start i koniec) nie jest powtarzana.
+func saveShiftSummary(data map[string]any) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.LogMessage(fmt.Sprintf("[PANIC] SaveShiftSummaryToDB: %v", r))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
 
 	db, err := getConnection()
 	if err != nil {
-		utils.LogMessage("[DB] Connection error: " + err.Error())
-		return
+		return err
 	}
 	defer db.Close()
 
@@ -472,51 +451,63 @@ func SaveShiftSummaryToDB(filename string) {
 		}
 		return utils.ToFloat(cur)
 	}
-	an := func(i int, id string) float64 { return nf("analizator", "device_"+strconv.Itoa(i), id) }
-	tp := func(i int) float64            { return nf("totaliser", "per_port", strconv.Itoa(i)) }
-	ec := func(label string) int {
-		if m, ok := data["elements_per_cycle"].(map[string]any); ok {
-			return utils.ToInt(m[label])
-		}
-		return 0
-	}
 
 	wNaSzt := nf("energy", "W_na_szt")
 	M3naSzt := nf("totaliser", "M3_na_szt")
 
+	// klucz zmiany = Summary.DataUtworzenia (koniec zmiany), także przy ponowieniu z kolejki;
+	// wspólny dla wiersza zmiany i wszystkich wierszy szczegółów
+	created := t("data_utworzenia")
+	if created.IsZero() {
+		created = t("koniec_zmiany")
+	}
+	if created.IsZero() {
+		return fmt.Errorf("shift summary without data_utworzenia")
+	}
+
+	// wiersz zmiany i wiersze szczegółów w jednej transakcji
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// ponowienie po niejednoznacznym commicie nie dubluje zmiany
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM shift_summary WHERE start_zmiany = $1 AND koniec_zmiany = $2)`,
+		t("start_zmiany"), t("koniec_zmiany")).Scan(&exists); err != nil {
+		return fmt.Errorf("shift_summary lookup: %w", err)
+	}
+	if exists {
+		utils.LogMessage("[SHIFT_SUMMARY] Shift " + t("start_zmiany").Format(time.RFC3339) + " already in DB – skipped")
+		return nil
+	}
+
 	query := `
 		INSERT INTO shift_summary (
 			data_utworzenia, start_zmiany, koniec_zmiany,
 			czas_pracy, czas_postoju, czas_przezbrojenia, czas_pomiaru,
 			ilosc_elementow, dostepnosc, wydajnosc, jakosc, oee,
-
-			analizator_1_ea_pos, analizator_1_ea_neg, analizator_1_er_pos, analizator_1_er_neg, analizator_1_es, analizator_1_er,
-			 analizator_2_ea_pos, analizator_2_ea_neg, analizator_2_er_pos, analizator_2_er_neg, analizator_2_es, analizator_2_er,
-			 analizator_3_ea_pos, analizator_3_ea_neg, analizator_3_er_pos, analizator_3_er_neg, analizator_3_es, analizator_3_er,
-
-			totaliser_1, totaliser_2, totaliser_3, totaliser_4, totaliser_5,
 			W_na_szt, M3_na_szt,
-
-			cykl0, cykl1, cykl2, cykl3
+			mikroprzestoje, czas_mikroprzestojow,
+			predkosc_czas, predkosc_srednia, predkosc_min, predkosc_max, predkosc_nominalna,
+			czas_ponizej_nominalnej, strata_predkosci,
+			liczba_postojow, liczba_awarii, najdluzszy_postoj, mtbf, mttr
 		) VALUES (
-			now(), $1, $2,
-			$3, $4, $5, $6,
-			$7, $8, $9, $10, $11,
-
-			$12, $13, $14, $15, $16, $17,
-			$18, $19, $20, $21, $22, $23,
-			$24, $25, $26, $27, $28, $29,
-
-			$30, $31, $32, $33, $34,
-			$35, $36,
-
-			$37, $38, $39, $40
+			$1, $2, $3,
+			$4, $5, $6, $7,
+			$8, $9, $10, $11, $12,
+			$13, $14,
+			$15, $16,
+			$17, $18, $19, $20, $21,
+			$22, $23,
+			$24, $25, $26, $27, $28
 		)
 		ON CONFLICT DO NOTHING
 	`
 
 	args := []interface{}{
-		t("start_zmiany"), t("koniec_zmiany"),
+		created, t("start_zmiany"), t("koniec_zmiany"),
 
 		nf("oee", "czas_pracy"),
 		nf("oee", "czas_postoju"),
@@ -529,97 +520,99 @@ func SaveShiftSummaryToDB(filename string) {
 		nf("oee", "jakosc"),
 		nf("oee", "oee"),
 
-		an(1, "ea_pos"), an(1, "ea_neg"), an(1, "er_pos"), an(1, "er_neg"), an(1, "es"), an(1, "er"),
-		an(2, "ea_pos"), an(2, "ea_neg"), an(2, "er_pos"), an(2, "er_neg"), an(2, "es"), an(2, "er"),
-		an(3, "ea_pos"), an(3, "ea_neg"), an(3, "er_pos"), an(3, "er_neg"), an(3, "es"), an(3, "er"),
+		wNaSzt, M3naSzt,
 
-		tp(1), tp(2), tp(3), tp(4), tp(5),
+		int(nf("oee", "mikroprzestoje")),
+		nf("oee", "czas_mikroprzestojow"),
 
-		wNaSzt, M3naSzt,
+		nf("speed", "shift", "seconds"),
+		nf("speed", "shift", "mean_rpm"),
+		nf("speed", "shift", "min_rpm"),
+		nf("speed", "shift", "max_rpm"),
+		nf("speed", "shift", "nominal_rpm"),
+		nf("speed", "shift", "below_nominal_seconds"),
+		nf("speed", "shift", "speed_loss_seconds"),
 
-		ec("cykl0"), ec("cykl1"), ec("cykl2"), ec("cykl3"),
+		int(nf("reliability", "stops")),
+		int(nf("reliability", "breakdowns")),
+		nf("reliability", "longest_stop_seconds"),
+		nf("reliability", "mtbf_seconds"),
+		nf("reliability", "mttr_seconds"),
 	}
 
-	if _, err := db.Exec(query, args...); err != nil {
-		utils.LogMessage(fmt.Sprintf("[DB] Error inserting into shift_summary: %v", err))
+	if _, err := tx.Exec(query, args...); err != nil {
+		return fmt.Errorf("shift_summary: %w", err)
 	}
-}
 
-func AdjustIdleToChangeover(start, end float64, _ float64) {
-	defer func() {
-		if r := recover(); r != nil {
-			// pełny stacktrace do logów
-			utils.LogMessage(fmt.Sprintf("[PANIC] AdjustIdleToChangeover: %v\n%s", r, string(debug.Stack())))
-		}
-	}()
+	const deviceQuery = `
+		INSERT INTO shift_summary_device (
+			data_utworzenia, start_zmiany, device_type, device_id, source,
+			consumption, unit, counter_start, counter_end,
+			ea_pos, ea_neg, er_pos, er_neg, es, er
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT DO NOTHING
+	`
 
-	// sanity checks
-	if math.IsNaN(start) || math.IsNaN(end) {
-		utils.LogMessage("[WARN] AdjustIdleToChangeover: NaN in start/end")
-		return
+	// analizatory energii: zużycie zmiany [Wh] + stany liczników (sekcje energy i analizator)
+	for i := 1; i <= len(config.AnalyzerIPs); i++ {
+		k := strconv.Itoa(i)
+		an := func(id string) float64 { return nf("analizator", "device_"+k, id) }
+		_, err := tx.Exec(deviceQuery,
+			created, t("start_zmiany"), "analyzer", i, config.AnalyzerIPs[i-1],
+			nf("energy", "per_device_Wh", k), "Wh", nf("energy", "start", k), nf("energy", "last", k),
+			an("ea_pos"), an("ea_neg"), an("er_pos"), an("er_neg"), an("es"), an("er"),
+		)
+		if err != nil {
+			return fmt.Errorf("shift_summary_device analyzer %d: %w", i, err)
+		}
 	}
-	if end < start {
-		start, end = end, start
+
+	// przepływomierze powietrza: zużycie zmiany [m3] (sekcja totaliser)
+	for i, port := range config.FlowPorts {
+		k := strconv.Itoa(i + 1)
+		_, err := tx.Exec(deviceQuery,
+			created, t("start_zmiany"), "flow", i+1, port,
+			nf("totaliser", "per_port", k), "m3", nf("totaliser", "start", k), nf("totaliser", "last", k),
+			nil, nil, nil, nil, nil, nil,
+		)
+		if err != nil {
+			return fmt.Errorf("shift_summary_device flow meter %d: %w", i+1, err)
+		}
 	}
 
-	db, err := getConnection()
-	if err != nil {
-		utils.LogMessage("[DB] Connection error in AdjustIdleToChangeover: " + err.Error())
-		return
+	if err := insertShiftDetails(tx, created, t("start_zmiany"), data); err != nil {
+		return err
 	}
-	defer db.Close()
 
-	// utils.LogMessage(fmt.Sprintf("[INFO] AdjustIdleToChangeover: range start=%.3f end=%.3f (sec epoch)", start, end))
-
-	// 1) Pobierz pierwsze czas_postoju z zakresu
-	var firstPostoj sql.NullFloat64
-	const queryFirst = `
-		SELECT czas_postoju
-		FROM oee_temp
-		WHERE EXTRACT(EPOCH FROM timestamp) >= $1
-		  AND EXTRACT(EPOCH FROM timestamp) <= $2
-		ORDER BY timestamp ASC
-		LIMIT 1
-	`
-	err = db.QueryRow(queryFirst, start, end).Scan(&firstPostoj)
-	if err != nil && err != sql.ErrNoRows {
-		utils.LogMessage("[DB] QueryRow error in AdjustIdleToChangeover: " + err.Error())
-		return
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
 	}
+	markDBWrite("shift_summary", "shift_summary_device", "shift_summary_product", "shift_summary_loss", "stop_events")
+	return nil
+}
 
-	// 2) Aktualizacja zakresu
-	if err == sql.ErrNoRows || !firstPostoj.Valid {
-		// brak rekordu → nie nadpisujemy czas_postoju
-		const q = `
-			UPDATE oee_temp
-			SET czas_przezbrojenia = czas_przezbrojenia_temp
-			WHERE EXTRACT(EPOCH FROM timestamp) >= $1
-			  AND EXTRACT(EPOCH FROM timestamp) <= $2
-		`
-		res, err := db.Exec(q, start, end)
-		if err != nil {
-			utils.LogMessage("[DB] Update (no-row) error in AdjustIdleToChangeover: " + err.Error())
-			return
-		}
-		if n, _ := res.RowsAffected(); n == 0 {
-			utils.LogMessage("[INFO] AdjustIdleToChangeover: no rows updated (no-row branch)")
+func SaveDowntimeAckToDB(ack DowntimeAck) {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.LogMessage(fmt.Sprintf("[PANIC] SaveDowntimeAckToDB: %v", r))
 		}
-		return
-	}
+	}()
 
-	const queryUpdate = `
-		UPDATE oee_temp
-		SET czas_przezbrojenia = czas_przezbrojenia_temp,
-		    czas_postoju = $3
-		WHERE EXTRACT(EPOCH FROM timestamp) >= $1
-		  AND EXTRACT(EPOCH FROM timestamp) <= $2
-	`
-	res, err := db.Exec(queryUpdate, start, end, firstPostoj.Float64)
+	db, err := getConnection()
 	if err != nil {
-		utils.LogMessage("[DB] Update error in AdjustIdleToChangeover: " + err.Error())
+		utils.LogMessage("[DB] Connection error: " + err.Error())
 		return
 	}
-	if n, _ := res.RowsAffected(); n == 0 {
-		utils.LogMessage("[INFO] AdjustIdleToChangeover: no rows updated (normal branch)")
+	defer db.Close()
+
+	const q = `
+		INSERT INTO downtime_ack (timestamp, reason, source, pause_start, pause_end, pause_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (timestamp) DO NOTHING
+	`
+	if _, err := db.Exec(q, ack.Timestamp, ack.Reason, ack.Source, ack.PauseStart, ack.PauseEnd, ack.PauseSeconds); err != nil {
+		utils.LogMessage("[DB] Insert error in SaveDowntimeAckToDB: " + err.Error())
+	} else {
+		markDBWrite("downtime_ack")
 	}
 }
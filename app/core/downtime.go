@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"go_app/utils"
+	"sync"
+	"time"
+)
+
+// DowntimeAck – potwierdzenie przyczyny postoju (np. z HMI przez Modbus).
+// Dotyczy trwającej pauzy, a jeśli maszyna już pracuje – ostatniej zakończonej.
+type DowntimeAck struct {
+	Timestamp    time.Time  `json:"timestamp"`
+	Reason       int        `json:"reason"`
+	Source       string     `json:"source"`
+	PauseStart   *time.Time `json:"pause_start,omitempty"`
+	PauseEnd     *time.Time `json:"pause_end,omitempty"` // nil = pauza nadal trwa
+	PauseSeconds float64    `json:"pause_seconds"`
+}
+
+var (
+	downtimeLock    sync.RWMutex
+	lastDowntimeAck *DowntimeAck
+	downtimeAckCnt  int
+)
+
+// AcknowledgeDowntime przypisuje przyczynę reason do bieżącej/ostatniej pauzy,
+// zapisuje potwierdzenie do DB (w tle) i zwraca je.
+func AcknowledgeDowntime(reason int, source string) DowntimeAck {
+	calcLock.Lock()
+	now := nowUTC()
+	ack := DowntimeAck{Timestamp: now, Reason: reason, Source: source}
+	switch {
+	case CzasPomiarowy.PauseStartTime != nil:
+		ps := *CzasPomiarowy.PauseStartTime
+		ack.PauseStart = &ps
+		ack.PauseSeconds = now.Sub(ps).Seconds()
+	case !lastPauseStart.IsZero():
+		ps, pe := lastPauseStart, lastPauseEnd
+		ack.PauseStart, ack.PauseEnd = &ps, &pe
+		ack.PauseSeconds = pe.Sub(ps).Seconds()
+	}
+	calcLock.Unlock()
+
+	downtimeLock.Lock()
+	lastDowntimeAck = &ack
+	downtimeAckCnt++
+	downtimeLock.Unlock()
+
+	utils.LogMessage(fmt.Sprintf("[DOWNTIME] Reason %d acknowledged by %s (pause %.0fs)", reason, source, ack.PauseSeconds))
+	if !backgroundDisabled.Load() {
+		utils.Go("SaveDowntimeAckToDB", func() { SaveDowntimeAckToDB(ack) })
+	}
+	return ack
+}
+
+// LastDowntimeAck zwraca ostatnie potwierdzenie i liczbę potwierdzeń od startu programu.
+func LastDowntimeAck() (*DowntimeAck, int) {
+	downtimeLock.RLock()
+	defer downtimeLock.RUnlock()
+	if lastDowntimeAck == nil {
+		return nil, downtimeAckCnt
+	}
+	ack := *lastDowntimeAck
+	return &ack, downtimeAckCnt
+}
+
+// PauseActive – czy trwa pauza (brak elementów dłużej niż IdleTimeoutSeconds).
+func PauseActive() bool {
+	calcLock.Lock()
+	defer calcLock.Unlock()
+	return CzasPomiarowy.PauseStartTime != nil
+}
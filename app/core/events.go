@@ -0,0 +1,378 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"math"
+	"os"
+	"time"
+)
+
+// --- Dziennik zdarzeń maszyny (tabela machine_events) ---
+//
+// Silnik OEE liczy tylko sumy czasów; dziennik zapisuje każdy stan maszyny jako przedział:
+//   - run – praca (status_pracy),
+//   - stop – pauza i oczekiwanie na pierwszy element zmiany,
+//   - changeover – pauza zakończona potwierdzonym przezbrojeniem (typ ustalany na końcu pauzy,
+//     z produktem przed i po oraz powodem: signal / order / dimensions, patrz changeover.go),
+//   - machine_off – maszyna wyłączona (maszyna_on/off); nakłada się na run / stop,
+//   - cycle_change – zmiana cyklu lub produktu (chwila: start = koniec).
+// Przedziały run / stop / changeover nie nachodzą na siebie. Zmiana cyklu dzieli trwającą pracę
+// (run ma jeden cykl i produkt); pauza zachowuje cykl z początku, nawet gdy w jej trakcie zmienił
+// się produkt. Granica zmiany dzieli każdy trwający przedział. Zakończone zdarzenia trafiają do pierścienia
+// w pamięci (API) i do kolejki zapisu do DB. Trwające przedziały są w oee.json (restart); kolejka
+// jest w osobnym pliku config.EventsPendingFilePath, zapisywanym co eventsPersistInterval i przy
+// zamknięciu programu – oee.json jest przepisywany przy każdym przeliczeniu OEE.
+
+// Typy zdarzeń.
+const (
+	EventRun         = "run"
+	EventStop        = "stop"
+	EventChangeover  = "changeover"
+	EventMachineOff  = "machine_off"
+	EventCycleChange = "cycle_change"
+)
+
+// EventTypes – dozwolone typy (filtr API).
+var EventTypes = []string{EventRun, EventStop, EventChangeover, EventMachineOff, EventCycleChange}
+
+const (
+	maxEventRing    = 500   // ostatnie zdarzenia w pamięci
+	maxEventPending = 10000 // niezapisane do DB (brak bazy); najstarsze są odrzucane
+
+	// eventsPersistInterval – okres zapisu kolejki do pliku; po awarii programu brakuje co najwyżej
+	// zdarzeń z tego okresu.
+	eventsPersistInterval = time.Minute
+)
+
+// MachineEvent – jeden stan maszyny albo zmiana cyklu.
+type MachineEvent struct {
+	Type    string     `json:"type"`
+	Start   time.Time  `json:"start"`
+	End     *time.Time `json:"end,omitempty"` // nil = trwa
+	Seconds float64    `json:"seconds"`
+	Cycle   float64    `json:"cycle"`
+	SKU     string     `json:"sku,omitempty"`
+	FromSKU string     `json:"from_sku,omitempty"` // changeover: produkt przed i po
+	ToSKU   string     `json:"to_sku,omitempty"`
+	Reason  string     `json:"reason,omitempty"` // changeover: signal / order / dimensions
+}
+
+// EventInternal – trwające przedziały w oee.json (sekcja internal).
+type EventInternal struct {
+	State      *MachineEvent `json:"state,omitempty"`
+	MachineOff *MachineEvent `json:"machine_off,omitempty"`
+}
+
+var (
+	openState     *MachineEvent // run / stop / changeover; chronione calcLock
+	openOff       *MachineEvent
+	eventRing     []MachineEvent
+	pendingEvents []MachineEvent
+	eventsLog     = utils.NewLogger("EVENTS")
+
+	// stan pliku kolejki – tylko pętla „EVENTS to DB” i zamknięcie programu
+	eventsPersisted time.Time
+	eventsOnDisk    = true // plik może istnieć (z poprzedniego uruchomienia)
+)
+
+// newEvent – zdarzenie od t z bieżącym cyklem i produktem (wymaga calcLock).
+func newEvent(typ string, t time.Time) *MachineEvent {
+	return &MachineEvent{Type: typ, Start: t.UTC(), Cycle: currentCycleValue, SKU: currentProductSKU}
+}
+
+// closeEvent – koniec zdarzenia w t (nie wcześniej niż start); przedziały zerowe są pomijane.
+func closeEvent(e *MachineEvent, t time.Time) {
+	end := t.UTC()
+	if end.Before(e.Start) {
+		end = e.Start
+	}
+	e.End = &end
+	e.Seconds = math.Round(end.Sub(e.Start).Seconds()*100) / 100
+	if e.Seconds <= 0 && e.Type != EventCycleChange {
+		return
+	}
+	appendEvent(*e)
+}
+
+func appendEvent(e MachineEvent) {
+	eventRing = append(eventRing, e)
+	if len(eventRing) > maxEventRing {
+		eventRing = eventRing[len(eventRing)-maxEventRing:]
+	}
+	pendingEvents = append(pendingEvents, e)
+	if len(pendingEvents) > maxEventPending {
+		eventsLog.Limit("pending", 10*time.Minute).Warn("event queue full, oldest events dropped",
+			"dropped", len(pendingEvents)-maxEventPending)
+		pendingEvents = pendingEvents[len(pendingEvents)-maxEventPending:]
+	}
+}
+
+// stateTransition – trwający stan kończy się w t (closeAs != "" zmienia jego typ), zaczyna się next.
+func stateTransition(next string, t time.Time, closeAs string) {
+	if openState != nil {
+		if t.Before(openState.Start) {
+			t = openState.Start
+		}
+		if closeAs != "" {
+			openState.Type = closeAs
+		}
+		closeEvent(openState, t)
+	}
+	openState = newEvent(next, t)
+}
+
+// eventPauseStart – początek pauzy (wsteczny: ostatni element + idle timeout).
+func eventPauseStart(t time.Time) {
+	stateTransition(EventStop, t, "")
+}
+
+// eventPauseEnd – nowy element po pauzie albo pierwszy element zmiany; changeover = powód
+// potwierdzonego przezbrojenia ("" = zwykła pauza).
+func eventPauseEnd(t time.Time, changeover string) {
+	closeAs := ""
+	if changeover != "" {
+		closeAs = EventChangeover
+		if openState != nil {
+			openState.Reason, openState.FromSKU, openState.ToSKU = changeover, changeoverFrom.SKU, currentProductSKU
+		}
+	}
+	stateTransition(EventRun, t, closeAs)
+}
+
+// eventCycleChange – nowy cykl / produkt: chwila cycle_change, trwająca praca dzielona w t.
+func eventCycleChange(t time.Time) {
+	if openState != nil && openState.Type == EventRun {
+		stateTransition(EventRun, t, "")
+	}
+	closeEvent(newEvent(EventCycleChange, t), t)
+}
+
+// trackMachineEvents – po każdym przeliczeniu: maszyna włączona / wyłączona i stan początkowy
+// (pierwsze uruchomienie, oee.json bez dziennika). Wymaga calcLock.
+func trackMachineEvents(now time.Time) {
+	on := utils.ToBool(CalculatedData["status_maszyny"])
+	switch {
+	case !on && openOff == nil:
+		openOff = newEvent(EventMachineOff, now)
+	case on && openOff != nil:
+		closeEvent(openOff, now)
+		openOff = nil
+	}
+	if openState == nil {
+		typ := EventStop
+		if utils.ToBool(CalculatedData["status_pracy"]) {
+			typ = EventRun
+		}
+		openState = newEvent(typ, now)
+	}
+}
+
+// splitEventsAtReset – granica zmiany: trwające przedziały kończą się w t, nowa zmiana zaczyna
+// się od oczekiwania na pierwszy element. Wymaga calcLock.
+func splitEventsAtReset(t time.Time) {
+	if openState != nil {
+		stateTransition(EventStop, t, "")
+	}
+	if openOff != nil {
+		closeEvent(openOff, t)
+		openOff = newEvent(EventMachineOff, t)
+	}
+}
+
+// eventInternalLocked – stan dziennika do oee.json (wymaga calcLock).
+func eventInternalLocked() EventInternal {
+	var in EventInternal
+	if openState != nil {
+		e := *openState
+		in.State = &e
+	}
+	if openOff != nil {
+		e := *openOff
+		in.MachineOff = &e
+	}
+	return in
+}
+
+// loadEvents – trwające przedziały z oee.json (internal.events) i kolejka zapisu
+// z config.EventsPendingFilePath; bez pliku przejmuje kolejkę z oee.json sprzed jej wydzielenia
+// (internal.events.pending). Wymaga calcLock.
+func loadEvents(data map[string]interface{}) {
+	var in struct {
+		EventInternal
+		Pending []MachineEvent `json:"pending"`
+	}
+	if m, ok := data["internal"].(map[string]interface{}); ok {
+		decodeSection(m["events"], &in)
+	}
+	openState, openOff, pendingEvents = in.State, in.MachineOff, in.Pending
+	if raw, err := os.ReadFile(config.EventsPendingFilePath); err == nil {
+		pendingEvents = nil
+		if err := json.Unmarshal(raw, &pendingEvents); err != nil {
+			eventsLog.Warn("pending machine events unreadable", "file", config.EventsPendingFilePath, "error", err)
+		}
+	}
+}
+
+// persistEvents – kolejka do pliku, najwyżej co eventsPersistInterval (force – zamknięcie
+// programu); pusta kolejka usuwa plik.
+func persistEvents(force bool) {
+	calcLock.Lock()
+	pending := append([]MachineEvent(nil), pendingEvents...)
+	calcLock.Unlock()
+	if len(pending) == 0 {
+		if eventsOnDisk {
+			if err := os.Remove(config.EventsPendingFilePath); err != nil && !os.IsNotExist(err) {
+				eventsLog.Warn("pending machine events not removed", "error", err)
+				return
+			}
+			eventsOnDisk = false
+		}
+		return
+	}
+	if !force && time.Since(eventsPersisted) < eventsPersistInterval {
+		return
+	}
+	utils.SaveToJSON(pending, config.EventsPendingFilePath)
+	eventsPersisted, eventsOnDisk = time.Now(), true
+}
+
+// SaveMachineEventsPending – zapis kolejki przy zamknięciu programu (po ostatnim SaveMachineEventsToDB).
+func SaveMachineEventsPending() {
+	persistEvents(true)
+}
+
+// clearMachineEvents – pusty dziennik (replay); wymaga calcLock.
+func clearMachineEvents() {
+	openState, openOff = nil, nil
+	eventRing, pendingEvents = nil, nil
+}
+
+// RecentMachineEvents – ostatnie zdarzenia z pamięci z trwającymi przedziałami (najnowsze najpierw),
+// opcjonalnie tylko typu typ.
+func RecentMachineEvents(typ string, limit int) []MachineEvent {
+	calcLock.Lock()
+	now := nowUTC()
+	all := append([]MachineEvent{}, eventRing...)
+	for _, e := range []*MachineEvent{openOff, openState} {
+		if e != nil {
+			open := *e
+			open.Seconds = math.Round(math.Max(now.Sub(open.Start).Seconds(), 0)*100) / 100
+			all = append(all, open)
+		}
+	}
+	calcLock.Unlock()
+
+	out := []MachineEvent{}
+	for i := len(all) - 1; i >= 0 && len(out) < limit; i-- {
+		if typ == "" || all[i].Type == typ {
+			out = append(out, all[i])
+		}
+	}
+	return out
+}
+
+// StartMachineEventWriter – okresowy zapis zakończonych zdarzeń do DB.
+func StartMachineEventWriter() {
+	utils.SuperviseLoop("EVENTS to DB", config.MachineEventsUpdateInterval, SaveMachineEventsToDB)
+}
+
+// SaveMachineEventsToDB – kolejka zakończonych zdarzeń do machine_events (także przy zamknięciu);
+// przy błędzie zdarzenia zostają do następnej próby.
+func SaveMachineEventsToDB() {
+	defer persistEvents(false)
+	calcLock.Lock()
+	pending := append([]MachineEvent(nil), pendingEvents...)
+	calcLock.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		eventsLog.Limit("db", 10*time.Minute).Warn("events not saved", "pending", len(pending), "error", err)
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		eventsLog.Limit("db", 10*time.Minute).Warn("events not saved", "pending", len(pending), "error", err)
+		return
+	}
+	const q = `
+		INSERT INTO machine_events (start_time, type, end_time, seconds, cycle, sku, from_sku, to_sku, reason)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), NULLIF($9, ''))
+		ON CONFLICT (start_time, type) DO NOTHING
+	`
+	for _, e := range pending {
+		if _, err := tx.Exec(q, e.Start, e.Type, e.End, e.Seconds, e.Cycle, e.SKU, e.FromSKU, e.ToSKU, e.Reason); err != nil {
+			_ = tx.Rollback()
+			utils.LogMessage(fmt.Sprintf("[DB] Insert error in SaveMachineEventsToDB: %v", err))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogMessage(fmt.Sprintf("[DB] Commit error in SaveMachineEventsToDB: %v", err))
+		return
+	}
+	markDBWrite("machine_events")
+
+	// zdarzenia dopisane w czasie zapisu zostają w kolejce (także gdy maxEventPending przyciął
+	// w tym czasie jej początek)
+	type eventKey struct {
+		start time.Time
+		typ   string
+	}
+	written := make(map[eventKey]bool, len(pending))
+	for _, e := range pending {
+		written[eventKey{e.Start, e.Type}] = true
+	}
+	calcLock.Lock()
+	kept := pendingEvents[:0]
+	for _, e := range pendingEvents {
+		if !written[eventKey{e.Start, e.Type}] {
+			kept = append(kept, e)
+		}
+	}
+	pendingEvents = kept
+	calcLock.Unlock()
+}
+
+// LoadMachineEventsFromDB – zdarzenia nachodzące na [from, to), opcjonalnie tylko typu typ
+// (najnowsze najpierw, co najwyżej limit).
+func LoadMachineEventsFromDB(typ string, from, to time.Time, limit int) ([]MachineEvent, error) {
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT type, start_time, end_time, COALESCE(seconds, 0), COALESCE(cycle, 0), COALESCE(sku, ''),
+			COALESCE(from_sku, ''), COALESCE(to_sku, ''), COALESCE(reason, '')
+		FROM machine_events
+		WHERE end_time >= $1 AND start_time < $2 AND ($3 = '' OR type = $3)
+		ORDER BY start_time DESC
+		LIMIT $4`, from, to, typ, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []MachineEvent{}
+	for rows.Next() {
+		var e MachineEvent
+		var end time.Time
+		if err := rows.Scan(&e.Type, &e.Start, &end, &e.Seconds, &e.Cycle, &e.SKU,
+			&e.FromSKU, &e.ToSKU, &e.Reason); err != nil {
+			return nil, err
+		}
+		e.Start, end = e.Start.UTC(), end.UTC()
+		e.End = &end
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
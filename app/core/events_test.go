@@ -0,0 +1,52 @@
+package core
+
+import (
+	"go_app/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestEventsPendingFile – kolejka zdarzeń przeżywa restart w osobnym pliku; bez pliku przejmuje
+// kolejkę z oee.json (internal.events.pending), pusta usuwa plik.
+func TestEventsPendingFile(t *testing.T) {
+	prevPath, prevQueue, prevState, prevOff := config.EventsPendingFilePath, pendingEvents, openState, openOff
+	defer func() {
+		config.EventsPendingFilePath, pendingEvents, openState, openOff = prevPath, prevQueue, prevState, prevOff
+	}()
+	config.EventsPendingFilePath = filepath.Join(t.TempDir(), "events_pending.json")
+
+	t0 := time.Date(2026, 3, 2, 6, 10, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	legacy := map[string]interface{}{"internal": map[string]interface{}{"events": map[string]interface{}{
+		"state":   map[string]interface{}{"type": EventRun, "start": t1.Format(time.RFC3339)},
+		"pending": []interface{}{map[string]interface{}{"type": EventStop, "start": t0.Format(time.RFC3339), "seconds": 60}},
+	}}}
+
+	calcLock.Lock()
+	loadEvents(legacy)
+	got := append([]MachineEvent(nil), pendingEvents...)
+	calcLock.Unlock()
+	if len(got) != 1 || got[0].Type != EventStop || !got[0].Start.Equal(t0) {
+		t.Fatalf("queue from oee.json = %+v", got)
+	}
+
+	SaveMachineEventsPending()
+	calcLock.Lock()
+	pendingEvents = nil
+	loadEvents(map[string]interface{}{})
+	got = append([]MachineEvent(nil), pendingEvents...)
+	calcLock.Unlock()
+	if len(got) != 1 || got[0].Type != EventStop || got[0].Seconds != 60 {
+		t.Fatalf("queue from file = %+v", got)
+	}
+
+	calcLock.Lock()
+	pendingEvents = nil
+	calcLock.Unlock()
+	persistEvents(false)
+	if _, err := os.Stat(config.EventsPendingFilePath); !os.IsNotExist(err) {
+		t.Errorf("empty queue: file not removed (%v)", err)
+	}
+}
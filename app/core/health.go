@@ -0,0 +1,55 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"go_app/utils"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- stan dla /healthz i /readyz ---
+
+var (
+	lastOeeCalc atomic.Int64 // czas (UnixNano, zegar ścienny) ostatniego CalculateData
+	dbWrites    sync.Map     // tabela → time.Time ostatniego udanego zapisu
+)
+
+func markOeeCalculated() { lastOeeCalc.Store(time.Now().UnixNano()) }
+
+// LastOeeCalculation – czas ostatniego przeliczenia OEE (zero, jeśli jeszcze nie było).
+func LastOeeCalculation() time.Time {
+	if n := lastOeeCalc.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+func markDBWrite(tables ...string) {
+	now := time.Now()
+	for _, t := range tables {
+		dbWrites.Store(t, now)
+	}
+}
+
+// DBWrites – czas ostatniego udanego zapisu do każdej tabeli (od startu programu).
+func DBWrites() map[string]time.Time {
+	out := map[string]time.Time{}
+	dbWrites.Range(func(k, v interface{}) bool {
+		out[k.(string)] = v.(time.Time)
+		return true
+	})
+	return out
+}
+
+// commitTx zatwierdza transakcję i odnotowuje zapis do tabel z written (co najmniej jeden wiersz).
+func commitTx(tx *sql.Tx, context string, written map[string]bool) {
+	if err := tx.Commit(); err != nil {
+		utils.LogMessage(fmt.Sprintf("[DB] Commit error in %s: %v", context, err))
+		return
+	}
+	for table := range written {
+		markDBWrite(table)
+	}
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"strings"
+)
+
+// --- Wejścia spoza portów MQTT w nagraniu (replay, recompute) ---
+//
+// Wynik silnika zależy nie tylko od portów MQTT: zlecenia produkcyjne (MQTT_ORDER_TOPIC, API),
+// wybór produktu (API) i sygnał przezbrojenia (API, PLC) też go zmieniają. Polecenia z brokera
+// rejestrator zapisuje z ich tematem, a polecenia z API i PLC jako wiadomości z tematem
+// InputTopic* (nie istnieją na brokerze). Replay i recompute odtwarzają je w tej samej chwili
+// co na żywo (ReplayFrame.Inputs).
+
+const (
+	inputTopicPrefix     = "$oee/input/"
+	InputTopicOrder      = inputTopicPrefix + "order"      // OrderCommand
+	InputTopicProduct    = inputTopicPrefix + "product"    // ProductCommand
+	InputTopicChangeover = inputTopicPrefix + "changeover" // ChangeoverCommand
+)
+
+// ReplayInput – jedno wejście (temat i JSON polecenia) stosowane przed przeliczeniem ramki.
+type ReplayInput struct {
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// InputRecorder – zapis wejścia do nagrania MQTT (communication.RecordInput); nil = bez zapisu.
+var InputRecorder func(topic string, payload []byte)
+
+var inputsLog = utils.NewLogger("INPUT")
+
+// RecordInput – wejście z API lub PLC do nagrania (poza replay).
+func RecordInput(topic string, v interface{}) {
+	if InputRecorder == nil || backgroundDisabled.Load() {
+		return
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		inputsLog.Warn("input not recorded", "topic", topic, "error", err)
+		return
+	}
+	InputRecorder(topic, payload)
+}
+
+// IsInputTopic – temat nagrania będący wejściem, a nie danymi portu.
+func IsInputTopic(topic string) bool {
+	return strings.HasPrefix(topic, inputTopicPrefix) || (config.MqttOrderTopic != "" && topic == config.MqttOrderTopic)
+}
+
+// InputFromMessage – wejście z wiadomości nagrania; false = dane portu albo polecenie retained
+// (na żywo pomijane) lub niepoprawny JSON.
+func InputFromMessage(topic string, payload []byte, retained bool) (ReplayInput, bool) {
+	if !IsInputTopic(topic) || retained || !json.Valid(payload) {
+		return ReplayInput{}, false
+	}
+	return ReplayInput{Topic: topic, Payload: append(json.RawMessage(nil), payload...)}, true
+}
+
+// ApplyInput – wykonanie wejścia z nagrania.
+func ApplyInput(in ReplayInput) error {
+	switch {
+	case in.Topic == InputTopicOrder, config.MqttOrderTopic != "" && in.Topic == config.MqttOrderTopic:
+		return HandleOrderCommand(in.Payload)
+	case in.Topic == InputTopicProduct:
+		return HandleProductCommand(in.Payload)
+	case in.Topic == InputTopicChangeover:
+		return HandleChangeoverCommand(in.Payload)
+	}
+	return fmt.Errorf("unknown input topic %q", in.Topic)
+}
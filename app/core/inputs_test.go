@@ -0,0 +1,92 @@
+package core
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReplayOrderInputs – zlecenia z nagrania są stosowane w czasie ramki i nie przechodzą
+// do następnego przebiegu.
+func TestReplayOrderInputs(t *testing.T) {
+	start := time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC)
+	order := func(action, no string) []ReplayInput {
+		payload, _ := json.Marshal(OrderCommand{Action: action, OrderNo: no})
+		return []ReplayInput{{Topic: InputTopicOrder, Payload: payload}}
+	}
+
+	r := NewReplayer(start, ReplayOptions{})
+	r.Step(ReplayFrame{Timestamp: start})
+	r.Step(ReplayFrame{Timestamp: start.Add(time.Minute), Inputs: order("start", "ZP/1")})
+	o, ok := CurrentOrder()
+	if !ok || o.OrderNo != "ZP/1" || !o.StartedAt.Equal(start.Add(time.Minute)) {
+		t.Fatalf("after start input: %+v, running %v", o.ProductionOrder, ok)
+	}
+	r.Step(ReplayFrame{Timestamp: start.Add(2 * time.Minute), Inputs: order("stop", "ZP/1")})
+	if _, ok := CurrentOrder(); ok {
+		t.Fatal("order still running after stop input")
+	}
+	r.Step(ReplayFrame{Timestamp: start.Add(3 * time.Minute), Inputs: order("start", "ZP/2")})
+	r.Close()
+
+	r = NewReplayer(start, ReplayOptions{})
+	defer r.Close()
+	if o, ok := CurrentOrder(); ok {
+		t.Errorf("order %q carried into a new replay", o.OrderNo)
+	}
+}
+
+// TestReplayProductInputs – wybór produktu z API jest odtwarzany w czasie ramki.
+func TestReplayProductInputs(t *testing.T) {
+	prev := catalogue.Load()
+	defer catalogue.Store(prev)
+	setCatalogue([]Product{{SKU: "PL-1200", Name: "Płyta 1200", IdealCycleS: 5, Active: true}})
+
+	start := time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC)
+	product := func(sku string) []ReplayInput {
+		payload, _ := json.Marshal(ProductCommand{SKU: sku})
+		return []ReplayInput{{Topic: InputTopicProduct, Payload: payload}}
+	}
+
+	r := NewReplayer(start, ReplayOptions{})
+	defer r.Close()
+	r.Step(ReplayFrame{Timestamp: start})
+	r.Step(ReplayFrame{Timestamp: start.Add(time.Minute), Inputs: product("PL-1200")})
+	if got := CurrentProduct().Selected; got != "PL-1200" {
+		t.Fatalf("after product input: selected %q", got)
+	}
+	r.Step(ReplayFrame{Timestamp: start.Add(2 * time.Minute), Inputs: product("")})
+	if got := CurrentProduct().Selected; got != "" {
+		t.Errorf("after clear input: selected %q", got)
+	}
+	if err := ApplyInput(product("missing")[0]); err == nil {
+		t.Error("unknown SKU accepted")
+	}
+}
+
+// TestOrderQuality – zlecenie trwające przez całe nagranie z odrzutami ma jakość silnika OEE.
+func TestOrderQuality(t *testing.T) {
+	frames, err := LoadReplayFrames(filepath.Join("testdata", "replay", "losses.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(OrderCommand{Action: "start", OrderNo: "ZP/Q"})
+	frames[0].Inputs = append(frames[0].Inputs, ReplayInput{Topic: InputTopicOrder, Payload: payload})
+
+	r := NewReplayer(frames[0].Timestamp, ReplayOptions{})
+	defer r.Close()
+	for _, fr := range frames {
+		r.Step(fr)
+	}
+	o, ok := CurrentOrder()
+	if !ok {
+		t.Fatal("order not running")
+	}
+	calcLock.Lock()
+	want, rejects := calculateJakosc(), lossRejects.Rejects()
+	calcLock.Unlock()
+	if rejects == 0 || o.Rejects != rejects || o.Jakosc != want || o.Jakosc >= 1 {
+		t.Errorf("order rejects %d jakosc %g, engine rejects %d jakosc %g", o.Rejects, o.Jakosc, rejects, want)
+	}
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"encoding/json"
+	"go_app/config"
+	"os"
+	"sync"
+)
+
+var (
+	lastSummaryLock   sync.RWMutex
+	lastSummary       Summary
+	lastSummaryLoaded bool
+)
+
+// setLastShiftSummary zapamiętuje podsumowanie ostatnio zamkniętej zmiany.
+func setLastShiftSummary(s Summary) {
+	lastSummaryLock.Lock()
+	lastSummary, lastSummaryLoaded = s, true
+	lastSummaryLock.Unlock()
+}
+
+// LastShiftSummary zwraca podsumowanie ostatniej zmiany; po starcie programu
+// wczytuje je z config.SummaryFilePath. false = brak podsumowania.
+func LastShiftSummary() (Summary, bool) {
+	lastSummaryLock.RLock()
+	s, ok := lastSummary, lastSummaryLoaded
+	lastSummaryLock.RUnlock()
+	if ok {
+		return s, s.StartZmiany != ""
+	}
+
+	raw, err := os.ReadFile(config.SummaryFilePath)
+	if err == nil {
+		err = json.Unmarshal(raw, &s)
+	}
+	if err != nil {
+		s = Summary{}
+	}
+	setLastShiftSummary(s)
+	return s, s.StartZmiany != ""
+}
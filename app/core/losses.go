@@ -0,0 +1,302 @@
+package core
+
+import (
+	"go_app/config"
+	"go_app/utils"
+	"math"
+	"time"
+)
+
+// --- Six Big Losses (TPM) ---
+//
+// Czas utracony od początku zmiany w podziale na sześć kategorii:
+//   - awarie (breakdowns) – postoje nie krótsze niż tuning.breakdown_threshold_seconds,
+//   - przezbrojenia (setup) – czas_przezbrojenia silnika,
+//   - krótkie postoje (minor stops) – postoje krótsze od progu awarii i mikroprzestoje,
+//   - obniżona prędkość (reduced speed) – czas pracy minus czas idealny wyprodukowanych sztuk
+//     i mikroprzestoje,
+//   - braki rozruchowe (startup rejects) – odrzuty w oknie tuning.startup_window_seconds po
+//     rozruchu (koniec przezbrojenia, koniec awarii, pierwszy element po długim postoju),
+//   - braki produkcyjne (production rejects) – pozostałe odrzuty.
+//
+// Odrzut to zbocze narastające sygnału "Odrzut" (port master1/port1); czas braku = cykl idealny
+// bieżącego produktu. Suma awarii i krótkich postojów jest równa czas_postoju + czas_mikroprzestojow,
+// przezbrojenia – czas_przezbrojenia. Trwająca pauza jest klasyfikowana wg dotychczasowego czasu.
+
+// SixBigLosses – straty zmiany [s] i liczba odrzutów [szt.].
+type SixBigLosses struct {
+	Breakdowns             float64 `json:"breakdowns"`
+	Setup                  float64 `json:"setup"`
+	MinorStops             float64 `json:"minor_stops"`
+	ReducedSpeed           float64 `json:"reduced_speed"`
+	StartupRejects         float64 `json:"startup_rejects"`
+	ProductionRejects      float64 `json:"production_rejects"`
+	StartupRejectPieces    int     `json:"startup_reject_pieces"`
+	ProductionRejectPieces int     `json:"production_reject_pieces"`
+}
+
+// LossCategories – kolejność i nazwy kategorii (tabela shift_summary_loss, API).
+var LossCategories = []string{"breakdowns", "setup", "minor_stops", "reduced_speed", "startup_rejects", "production_rejects"}
+
+// Seconds – czas straty dla kategorii z LossCategories.
+func (l SixBigLosses) Seconds(category string) float64 {
+	switch category {
+	case "breakdowns":
+		return l.Breakdowns
+	case "setup":
+		return l.Setup
+	case "minor_stops":
+		return l.MinorStops
+	case "reduced_speed":
+		return l.ReducedSpeed
+	case "startup_rejects":
+		return l.StartupRejects
+	case "production_rejects":
+		return l.ProductionRejects
+	}
+	return 0
+}
+
+// Pieces – liczba odrzutów dla kategorii braków (pozostałe kategorie: 0).
+func (l SixBigLosses) Pieces(category string) int {
+	switch category {
+	case "startup_rejects":
+		return l.StartupRejectPieces
+	case "production_rejects":
+		return l.ProductionRejectPieces
+	}
+	return 0
+}
+
+// Rejects – wszystkie odrzuty zmiany.
+func (l SixBigLosses) Rejects() int {
+	return l.StartupRejectPieces + l.ProductionRejectPieces
+}
+
+// LossInternal – stan klasyfikacji w oee.json (sekcja internal).
+type LossInternal struct {
+	BreakdownClosed float64 `json:"breakdown_closed"` // zakończone postoje >= progu awarii [s]
+	MinorClosed     float64 `json:"minor_closed"`     // zakończone postoje < progu awarii [s]
+	StartupStart    string  `json:"startup_start"`    // początek okna rozruchu ("" = brak)
+	PrevReject      bool    `json:"prev_reject"`
+}
+
+var (
+	lossBreakdownClosed float64 // chronione calcLock
+	lossMinorClosed     float64
+	lossRejects         SixBigLosses // tylko pola braków
+	startupStart        time.Time
+	prevReject          bool
+)
+
+// classifyStop – zakończony postój (bez przezbrojenia); długi postój otwiera okno rozruchu.
+func classifyStop(dur float64, end time.Time) {
+	breakdown := dur >= config.CurrentTuning().BreakdownThresholdSeconds
+	recordStop(dur, end, breakdown)
+	if breakdown {
+		lossBreakdownClosed += dur
+		startupStart = end
+		return
+	}
+	lossMinorClosed += dur
+}
+
+// markStartup – rozruch linii (koniec przezbrojenia, pierwszy element po długim postoju).
+func markStartup(t time.Time) {
+	startupStart = t
+}
+
+// detectReject – zbocze narastające sygnału odrzutu; klasyfikacja wg okna rozruchu.
+func detectReject(port map[string]interface{}, now time.Time) {
+	signal := utils.ToBool(port["Odrzut"])
+	rising := signal && !prevReject
+	prevReject = signal
+	if !rising {
+		return
+	}
+	cycleS := 0.0
+	if currentCycleValue > 0 {
+		cycleS = 60.0 / currentCycleValue
+	}
+	window := time.Duration(config.CurrentTuning().StartupWindowSeconds * float64(time.Second))
+	if !startupStart.IsZero() && now.Sub(startupStart) <= window {
+		lossRejects.StartupRejectPieces++
+		lossRejects.StartupRejects += cycleS
+	} else {
+		lossRejects.ProductionRejectPieces++
+		lossRejects.ProductionRejects += cycleS
+	}
+}
+
+// currentLossesLocked – bieżące straty zmiany (wymaga calcLock).
+func currentLossesLocked() SixBigLosses {
+	l := lossRejects
+	l.Breakdowns, l.MinorStops = lossBreakdownClosed, lossMinorClosed
+
+	// trwająca pauza (albo czas do pierwszego elementu) – klasyfikacja wg dotychczasowej długości
+	if open := openStopSecondsLocked(); open > 0 {
+		if open >= config.CurrentTuning().BreakdownThresholdSeconds {
+			l.Breakdowns += open
+		} else {
+			l.MinorStops += open
+		}
+	}
+	l.MinorStops += microStopSecondsLocked()
+	l.Setup = utils.ToFloat(CalculatedData["czas_przezbrojenia"])
+
+	// obniżona prędkość: czas pracy minus czas idealny sztuk i mikroprzestoje, po okresach cyklu
+	var lost float64
+	for _, p := range cycleHistory {
+		if p.CycleLPM > 0 && p.WorkSeconds > 0 {
+			lost += p.WorkSeconds - float64(p.ElementCounter)*60.0/p.CycleLPM - p.MicroStopSeconds
+		}
+	}
+	if currentCycleValue > 0 && currentCycleWorkSeconds > 0 {
+		lost += currentCycleWorkSeconds - float64(currentCycleElementCnt)*60.0/currentCycleValue - currentCycleMicroStopSeconds
+	}
+	l.ReducedSpeed = math.Max(lost, 0)
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	l.Breakdowns, l.Setup, l.MinorStops = round(l.Breakdowns), round(l.Setup), round(l.MinorStops)
+	l.ReducedSpeed, l.StartupRejects, l.ProductionRejects = round(l.ReducedSpeed), round(l.StartupRejects), round(l.ProductionRejects)
+	return l
+}
+
+// openStopSecondsLocked – dotychczasowy czas trwającego postoju (pauza bez przezbrojenia albo
+// czas do pierwszego elementu); 0 = maszyna pracuje. Wymaga calcLock.
+func openStopSecondsLocked() float64 {
+	if CzasPomiarowy.PauseStartTime == nil && firstElementDetected {
+		return 0
+	}
+	return math.Max(utils.ToFloat(CalculatedData["czas_postoju"])-CzasPomiarowy.TotalPause, 0)
+}
+
+// CurrentLosses – Six Big Losses bieżącej zmiany (od ostatniego resetu OEE).
+func CurrentLosses() SixBigLosses {
+	calcLock.Lock()
+	defer calcLock.Unlock()
+	return currentLossesLocked()
+}
+
+func lossInternalLocked() LossInternal {
+	li := LossInternal{BreakdownClosed: lossBreakdownClosed, MinorClosed: lossMinorClosed, PrevReject: prevReject}
+	if !startupStart.IsZero() {
+		li.StartupStart = startupStart.UTC().Format(time.RFC3339)
+	}
+	return li
+}
+
+// loadLosses – stan strat z oee.json (sekcje losses i internal.losses); wymaga calcLock.
+// Plik sprzed podziału strat: zakończone postoje liczone jako krótkie postoje.
+func loadLosses(data map[string]interface{}) {
+	resetLosses()
+	in, _ := data["internal"].(map[string]interface{})
+	li, ok := in["losses"].(map[string]interface{})
+	if !ok {
+		lossMinorClosed = CzasPomiarowy.TotalPause
+		return
+	}
+	lossBreakdownClosed = utils.ToFloat(li["breakdown_closed"])
+	lossMinorClosed = utils.ToFloat(li["minor_closed"])
+	prevReject = utils.ToBool(li["prev_reject"])
+	if s, _ := li["startup_start"].(string); s != "" {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			startupStart = t
+		}
+	}
+	l := lossesFromMap(data["losses"])
+	lossRejects = SixBigLosses{
+		StartupRejects:         l.StartupRejects,
+		ProductionRejects:      l.ProductionRejects,
+		StartupRejectPieces:    l.StartupRejectPieces,
+		ProductionRejectPieces: l.ProductionRejectPieces,
+	}
+}
+
+// lossesFromMap – sekcja losses z oee.json / summary.json.
+func lossesFromMap(raw interface{}) SixBigLosses {
+	m, _ := raw.(map[string]interface{})
+	return SixBigLosses{
+		Breakdowns:             utils.ToFloat(m["breakdowns"]),
+		Setup:                  utils.ToFloat(m["setup"]),
+		MinorStops:             utils.ToFloat(m["minor_stops"]),
+		ReducedSpeed:           utils.ToFloat(m["reduced_speed"]),
+		StartupRejects:         utils.ToFloat(m["startup_rejects"]),
+		ProductionRejects:      utils.ToFloat(m["production_rejects"]),
+		StartupRejectPieces:    utils.ToInt(m["startup_reject_pieces"]),
+		ProductionRejectPieces: utils.ToInt(m["production_reject_pieces"]),
+	}
+}
+
+// resetLosses – nowa zmiana; wymaga calcLock.
+func resetLosses() {
+	lossBreakdownClosed, lossMinorClosed = 0, 0
+	lossRejects = SixBigLosses{}
+	startupStart = time.Time{}
+	prevReject = false
+}
+
+// ShiftLosses – Six Big Losses jednej zmiany (API).
+type ShiftLosses struct {
+	StartZmiany    time.Time    `json:"start_zmiany"`
+	DataUtworzenia *time.Time   `json:"data_utworzenia,omitempty"` // nil = bieżąca zmiana
+	Losses         SixBigLosses `json:"losses"`
+}
+
+// CurrentShiftLosses – straty bieżącej zmiany (od ostatniego resetu OEE).
+func CurrentShiftLosses() ShiftLosses {
+	calcLock.Lock()
+	defer calcLock.Unlock()
+	return ShiftLosses{StartZmiany: CzasPomiarowy.StartMeasurement.UTC(), Losses: currentLossesLocked()}
+}
+
+// LoadShiftLossesFromDB – straty zmian zakończonych w [from, to) z tabeli shift_summary_loss
+// (najnowsze najpierw).
+func LoadShiftLossesFromDB(from, to time.Time) ([]ShiftLosses, error) {
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT data_utworzenia, start_zmiany, category, seconds, COALESCE(pieces, 0)
+		FROM shift_summary_loss
+		WHERE data_utworzenia >= $1 AND data_utworzenia < $2
+		ORDER BY data_utworzenia DESC`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ShiftLosses{}
+	for rows.Next() {
+		var created, start time.Time
+		var category string
+		var seconds float64
+		var pieces int
+		if err := rows.Scan(&created, &start, &category, &seconds, &pieces); err != nil {
+			return nil, err
+		}
+		if n := len(out); n == 0 || !out[n-1].DataUtworzenia.Equal(created) {
+			c := created.UTC()
+			out = append(out, ShiftLosses{StartZmiany: start.UTC(), DataUtworzenia: &c})
+		}
+		l := &out[len(out)-1].Losses
+		switch category {
+		case "breakdowns":
+			l.Breakdowns = seconds
+		case "setup":
+			l.Setup = seconds
+		case "minor_stops":
+			l.MinorStops = seconds
+		case "reduced_speed":
+			l.ReducedSpeed = seconds
+		case "startup_rejects":
+			l.StartupRejects, l.StartupRejectPieces = seconds, pieces
+		case "production_rejects":
+			l.ProductionRejects, l.ProductionRejectPieces = seconds, pieces
+		}
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"go_app/config"
+	"go_app/utils"
+	"time"
+)
+
+// --- Mikroprzestoje ---
+//
+// Przerwa między kolejnymi elementami dłuższa niż tuning.micro_stop_factor × cykl idealny,
+// po której nie zaczęła się pauza (IdleTimeoutSeconds), to mikroprzestój. Silnik liczy ją jako
+// czas pracy, więc bez tej detekcji widać ją tylko jako niższą wydajność. Czas mikroprzestoju =
+// przerwa minus jeden cykl idealny. Liczniki: zmiana (oee.mikroprzestoje, oee.czas_mikroprzestojow)
+// i okres cyklu (CyclePeriod.MicroStops / MicroStopSeconds). W Six Big Losses czas mikroprzestojów
+// jest częścią minor_stops, nie reduced_speed.
+
+var (
+	prevElementAt                time.Time // ostatni element (także pierwszy zmiany); chronione calcLock
+	currentCycleMicroStops       int
+	currentCycleMicroStopSeconds float64
+)
+
+// detectMicroStop – wołane na zboczu narastającym elementu, przed aktualizacją pauzy.
+func detectMicroStop(now time.Time) {
+	prev := prevElementAt
+	prevElementAt = now
+	if prev.IsZero() || CzasPomiarowy.PauseStartTime != nil || currentCycleValue <= 0 {
+		return // pierwszy element albo koniec pauzy – to nie mikroprzestój
+	}
+	cycleS := 60.0 / currentCycleValue
+	gap := now.Sub(prev).Seconds()
+	if gap <= config.CurrentTuning().MicroStopFactor*cycleS {
+		return
+	}
+	lost := gap - cycleS
+	CalculatedData["mikroprzestoje"] = utils.ToInt(CalculatedData["mikroprzestoje"]) + 1
+	CalculatedData["czas_mikroprzestojow"] = utils.ToFloat(CalculatedData["czas_mikroprzestojow"]) + lost
+	currentCycleMicroStops++
+	currentCycleMicroStopSeconds += lost
+}
+
+func prevElementTime() string {
+	if prevElementAt.IsZero() {
+		return ""
+	}
+	return prevElementAt.UTC().Format(time.RFC3339Nano)
+}
+
+// microStopSecondsLocked – czas mikroprzestojów zmiany (wymaga calcLock).
+func microStopSecondsLocked() float64 {
+	return utils.ToFloat(CalculatedData["czas_mikroprzestojow"])
+}
+
+// resetMicroStops – nowa zmiana; wymaga calcLock.
+func resetMicroStops() {
+	CalculatedData["mikroprzestoje"] = 0
+	CalculatedData["czas_mikroprzestojow"] = 0.0
+	prevElementAt = time.Time{}
+	currentCycleMicroStops = 0
+	currentCycleMicroStopSeconds = 0
+}
@@ -12,11 +12,15 @@ import (
 )
 
 type CyclePeriod struct {
-	StartTime      time.Time
-	EndTime        time.Time
-	CycleLPM       float64
-	ElementCounter int
-	WorkSeconds    float64
+	StartTime        time.Time
+	EndTime          time.Time
+	CycleLPM         float64
+	ElementCounter   int
+	WorkSeconds      float64
+	SKU              string     // produkt z katalogu albo "cyklN" (reguła CycleTable)
+	MicroStops       int        // mikroprzestoje w okresie
+	MicroStopSeconds float64    // ich czas [s]
+	Speed            SpeedStats // prędkość obrotnicy w okresie
 }
 
 type OeeFileFlat struct {
@@ -26,6 +30,10 @@ type OeeFileFlat struct {
 	Internal      OeeInternal   `json:"internal"`
 	HelpersAir    HelpersAir    `json:"helpers_air"`
 	HelpersEnergy HelpersEnergy `json:"helpers_energy"`
+	Losses        SixBigLosses  `json:"losses"`
+	Speed         SpeedSummary  `json:"speed"`
+	Reliability   Reliability   `json:"reliability"`
+	Stops         []StopEvent   `json:"stops"`
 }
 
 type OeeProduct struct {
@@ -33,34 +41,46 @@ type OeeProduct struct {
 	SzerokoscCalc float64 `json:"szerokosc_calc"`
 	WysokoscCalc  float64 `json:"wysokosc_calc"`
 	Cykl          float64 `json:"cykl"`
+	SKU           string  `json:"sku"`
+	Source        string  `json:"source"`                 // manual | dimensions | cycle_table
+	SelectedSKU   string  `json:"selected_sku,omitempty"` // wybór jawny (SetActiveProduct)
 }
 
 // Uwaga: start_measurement / element_last_time przeniesione do internal
 type OeeInternal struct {
-	StartMeasurement      	string        `json:"start_measurement"`
-	ElementLastTime       	string        `json:"element_last_time"`
-	ImpulsesCount         	int           `json:"impulses_count"`
-	CurrentCycleElementCnt	int           `json:"current_cycle_element_cnt"`
-	CurrentCycleStart     	string        `json:"current_cycle_start"`
-	CurrentCycleValue     	float64       `json:"current_cycle_value"`
-	CycleHistory          	[]CyclePeriod `json:"cycle_history"`
-	CurrentCycleWorkSeconds	float64 `json:"current_cycle_work_seconds"`
-	PrevElement            	bool          `json:"prev_element"`
-	PrevSpeed              	bool          `json:"prev_speed"`
-	PauseStartTime         	*string       `json:"pause_start_time"`
-	TotalPause             	float64       `json:"total_pause"`
-	AirBaseline            	float64       `json:"air_baseline"`    // litry (L)
-	EnergyBaseline         	float64       `json:"energy_baseline"` // W
-	FirstElementDetected   	bool          `json:"first_element_detected"`
-	LastCycle              	float64       `json:"last_cycle"`
-	LastWydajnosc          	float64       `json:"last_wydajnosc"`
-	LastWydajnoscFinal     	float64       `json:"last_wydajnosc_final"`
-	LastDostepnosc         	float64       `json:"last_dostepnosc"`
-	LastCycleFinal         	float64       `json:"last_cycle_final"`
-	ElementsUsed           	int           `json:"elements_used"`
-	OeeTemp                	float64       `json:"oee_temp"`
-	WydajnoscTemp          	float64       `json:"wydajnosc_temp"`
-	DostepnoscTemp         	float64       `json:"dostepnosc_temp"`
+	StartMeasurement             string             `json:"start_measurement"`
+	ElementLastTime              string             `json:"element_last_time"`
+	ImpulsesCount                int                `json:"impulses_count"`
+	CurrentCycleElementCnt       int                `json:"current_cycle_element_cnt"`
+	CurrentCycleStart            string             `json:"current_cycle_start"`
+	CurrentCycleValue            float64            `json:"current_cycle_value"`
+	CycleHistory                 []CyclePeriod      `json:"cycle_history"`
+	CurrentCycleWorkSeconds      float64            `json:"current_cycle_work_seconds"`
+	PrevElement                  bool               `json:"prev_element"`
+	PrevSpeed                    bool               `json:"prev_speed"`
+	PauseStartTime               *string            `json:"pause_start_time"`
+	TotalPause                   float64            `json:"total_pause"`
+	AirBaseline                  float64            `json:"air_baseline"`    // litry (L)
+	EnergyBaseline               float64            `json:"energy_baseline"` // W
+	FirstElementDetected         bool               `json:"first_element_detected"`
+	LastCycle                    float64            `json:"last_cycle"`
+	LastWydajnosc                float64            `json:"last_wydajnosc"`
+	LastWydajnoscFinal           float64            `json:"last_wydajnosc_final"`
+	LastDostepnosc               float64            `json:"last_dostepnosc"`
+	LastCycleFinal               float64            `json:"last_cycle_final"`
+	ElementsUsed                 int                `json:"elements_used"`
+	OeeTemp                      float64            `json:"oee_temp"`
+	WydajnoscTemp                float64            `json:"wydajnosc_temp"`
+	DostepnoscTemp               float64            `json:"dostepnosc_temp"`
+	Losses                       LossInternal       `json:"losses"`
+	PrevElementTime              string             `json:"prev_element_time"`
+	CurrentCycleMicroStops       int                `json:"current_cycle_micro_stops"`
+	CurrentCycleMicroStopSeconds float64            `json:"current_cycle_micro_stop_seconds"`
+	Speed                        SpeedStats         `json:"speed"`
+	CurrentCycleSpeed            SpeedStats         `json:"current_cycle_speed"`
+	Stops                        StopInternal       `json:"stops"`
+	Events                       EventInternal      `json:"events"`
+	Changeover                   ChangeoverInternal `json:"changeover"`
 }
 
 type HelpersAir struct {
@@ -100,18 +120,25 @@ type OeeSection struct {
 	StatusPracy   bool `json:"status_pracy"`
 
 	PredkoscObrotnica float64 `json:"predkosc_obrotnica"`
+
+	Mikroprzestoje      int     `json:"mikroprzestoje"`
+	CzasMikroprzestojow float64 `json:"czas_mikroprzestojow"`
 }
 
+// TempUpdaterInterval – okres updaterów wskaźników „chwilowych” (*_temp) i kosztów.
+const TempUpdaterInterval = 10 * time.Second
+
 var (
 	calcLock               sync.Mutex
 	impulsesCount          int
-	lastImpulse            = time.Now().UTC()
+	lastImpulse            = nowUTC()
 	prevSpeed              bool
 	prevSignal             bool
 	prevElement            bool
 	lastSaved              int
 	firstRunFlag           atomic.Bool
 	cycleJustChanged       atomic.Bool
+	backgroundDisabled     atomic.Bool // replay: bez gorutyn updaterów i bez zapisów do DB
 	OeeReadyChan           = make(chan struct{}, 1)
 	shouldStoreToDB        = false
 	lastElementCountOEE    int
@@ -125,11 +152,13 @@ var (
 	lastPostoj             float64
 	lastDostepnosc         float64
 	lastCycle              float64 = 0.0
+	lastPauseStart         time.Time
+	lastPauseEnd           time.Time
 	resetRequested         atomic.Bool
 	cycleHistory                    = []CyclePeriod{}
-	currentCycleStartTime  time.Time = time.Now().UTC()
+	currentCycleStartTime  time.Time = nowUTC()
 	currentCycleElementCnt int
-	currentCycleValue      float64 = config.ProductionCycleDefault
+	currentCycleValue      float64 = config.CurrentTuning().ProductionCycleDefault
 	startCostOnce          sync.Once
 	energyBaselineW       float64
 	airBaselineMeters3      float64
@@ -144,11 +173,13 @@ var (
 		"czas_postoju":                  0.0,
 		"czas_przezbrojenia":            0.0,
 		"czas_pomiaru":                  0.0,
+		"mikroprzestoje":                0,
+		"czas_mikroprzestojow":          0.0,
 		"Dlugosc_calc":                  0.0,
 		"Szerokosc_calc":                0.0,
 		"Wysokosc_calc":                 0.0,
 		"status_maszyny":                false,
-		"cykl":                          config.ProductionCycleDefault,
+		"cykl":                          config.CurrentTuning().ProductionCycleDefault,
 		"dostepnosc_temp":               100.0,
 		"wydajnosc_temp":                100.0,
 		"jakosc_temp":                   100.0,
@@ -189,8 +220,8 @@ var (
 		PauseStartTotal          float64
 		PauseStartChangeoverTemp float64
 	}{
-		StartMeasurement: time.Now().UTC(),
-		ElementLastTime:  time.Now().UTC(),
+		StartMeasurement: nowUTC(),
+		ElementLastTime:  nowUTC(),
 		PauseStartTime:   nil,
 		TotalPause:       0.0,
 	}
@@ -240,120 +271,8 @@ func ClearResetFlag() {
 func ResetOeeStateAndFile(path string) {
 	ResetOeeState() // ma własny lock
 
-	var of OeeFileFlat
 	calcLock.Lock()
-	now := time.Now().UTC()
-
-	// jeżeli helpery już są, użyj ich w pierwszej kolejności
-	var ha, he map[string]interface{}
-	if m, ok := CalculatedData["helpers_air"].(map[string]interface{}); ok {
-		ha = m
-	}
-	if m, ok := CalculatedData["helpers_energy"].(map[string]interface{}); ok {
-		he = m
-	}
-
-	// bezpieczny getter: spróbuj z mapy m[key], potem z CalculatedData[fallbackKey], inaczej 0
-	fFrom := func(m map[string]interface{}, key string, fallbackKey string) float64 {
-		if m != nil {
-			if v, ok := m[key]; ok && v != nil {
-				return utils.ToFloat(v)
-			}
-		}
-		if v, ok := CalculatedData[fallbackKey]; ok && v != nil {
-			return utils.ToFloat(v)
-		}
-		return 0
-	}
-
-	var pauseStrPtr *string
-	if CzasPomiarowy.PauseStartTime != nil {
-		s := CzasPomiarowy.PauseStartTime.UTC().Format(time.RFC3339)
-		pauseStrPtr = &s
-	}
-
-	of = OeeFileFlat{
-		Timestamp: now.Format(time.RFC3339Nano),
-		OEE: OeeSection{
-			CzasPomiaru:           utils.ToFloat(CalculatedData["czas_pomiaru"]),
-			CzasPracy:             utils.ToFloat(CalculatedData["czas_pracy"]),
-			CzasPostoju:           utils.ToFloat(CalculatedData["czas_postoju"]),
-			CzasPrzezbrojenia:     utils.ToFloat(CalculatedData["czas_przezbrojenia"]),
-			CzasPrzezbrojeniaTemp: utils.ToFloat(CalculatedData["czas_przezbrojenia_temp"]),
-			IloscElementow:        utils.ToInt(CalculatedData["ilosc_elementow"]),
-			Dostepnosc:            utils.ToFloat(CalculatedData["dostepnosc"]),
-			Wydajnosc:             utils.ToFloat(CalculatedData["wydajnosc"]),
-			Jakosc:                utils.ToFloat(CalculatedData["jakosc"]),
-			OEE:                   utils.ToFloat(CalculatedData["oee"]),
-			PowietrzeL:            utils.ToFloat(CalculatedData["powietrze_L"]),
-			EnergyW:               utils.ToFloat(CalculatedData["energia_W"]),
-			M3naSzt:               utils.ToFloat(CalculatedData["M3_na_szt"]),
-			WNaSzt:                utils.ToFloat(CalculatedData["W_na_szt"]),
-			StatusMaszyny:         utils.ToBool(CalculatedData["status_maszyny"]),
-			StatusPracy:           utils.ToBool(CalculatedData["status_pracy"]),
-			PredkoscObrotnica:     utils.ToFloat(CalculatedData["Predkosc_obrotnica"]),
-		},
-		Product: OeeProduct{
-			DlugoscCalc:   utils.ToFloat(CalculatedData["Dlugosc_calc"]),
-			SzerokoscCalc: utils.ToFloat(CalculatedData["Szerokosc_calc"]),
-			WysokoscCalc:  utils.ToFloat(CalculatedData["Wysokosc_calc"]),
-			Cykl:          utils.ToFloat(CalculatedData["cykl"]),
-		},
-		Internal: OeeInternal{
-			StartMeasurement:       CzasPomiarowy.StartMeasurement.UTC().Format(time.RFC3339),
-			ElementLastTime:        CzasPomiarowy.ElementLastTime.UTC().Format(time.RFC3339),
-			ImpulsesCount:          impulsesCount,
-			CurrentCycleElementCnt: currentCycleElementCnt,
-			CurrentCycleStart:      currentCycleStartTime.UTC().Format(time.RFC3339),
-			CurrentCycleValue:      currentCycleValue,
-			CycleHistory:           cycleHistory,
-			PrevElement:            prevElement,
-			PrevSpeed:              prevSpeed,
-			PauseStartTime:         pauseStrPtr,
-			TotalPause:             CzasPomiarowy.TotalPause,
-			AirBaseline:            airBaselineMeters3,
-			EnergyBaseline:         energyBaselineW,
-			FirstElementDetected:   firstElementDetected,
-			LastCycle:              lastCycle,
-			LastWydajnosc:          lastWydajnosc,
-			LastWydajnoscFinal:     utils.ToFloat(CalculatedData["lastWydajnoscFinal_internal"]),
-			LastDostepnosc:         lastDostepnosc,
-			LastCycleFinal:         utils.ToFloat(CalculatedData["lastCycleFinal_internal"]),
-			ElementsUsed:           utils.ToInt(CalculatedData["elements_used"]),
-			OeeTemp:                utils.ToFloat(CalculatedData["oee_temp"]),
-			WydajnoscTemp:          utils.ToFloat(CalculatedData["wydajnosc_temp"]),
-			DostepnoscTemp:         utils.ToFloat(CalculatedData["dostepnosc_temp"]),
-			CurrentCycleWorkSeconds: currentCycleWorkSeconds,
-		},
-		HelpersAir: HelpersAir{
-			Baseline:             fFrom(ha, "baseline",                "airBaseline_internal"),
-			Factor:               config.AirFactor,
-			TotalRawBeforeFactor: fFrom(ha, "total_raw_before_factor", "helpers_air_total_raw_before_factor"),
-			TotalCurrentM3:        fFrom(ha, "total_current_M3",        "helpers_air_total_current_M3"),
-			PortsRaw:             map[string]float64{},
-		},
-		HelpersEnergy: HelpersEnergy{
-			Baseline:      fFrom(he, "baseline",        "energyBaseline_internal"),
-			TotalCurrentW: fFrom(he, "total_current_W", "helpers_energy_total_current_W"),
-			DevicesW:      map[string]float64{},
-		},
-	}
-
-	// detale portów/urządzeń tylko jeśli są nie-nil
-	if ha != nil {
-		for k, v := range ha {
-			if strings.HasPrefix(k, "air_port_") && strings.HasSuffix(k, "_raw") && v != nil {
-				of.HelpersAir.PortsRaw[k] = utils.ToFloat(v)
-			}
-		}
-	}
-	if he != nil {
-		for k, v := range he {
-			if strings.HasPrefix(k, "energy_device_") && strings.HasSuffix(k, "_ea_pos_total_W") && v != nil {
-				of.HelpersEnergy.DevicesW[k] = utils.ToFloat(v)
-			}
-		}
-	}
+	of := buildOeeFlatLocked()
 	calcLock.Unlock()
 
 	if err := saveOeeFlat(of, path); err != nil {
@@ -373,13 +292,15 @@ func CalculateData(mqtt map[string]map[string]interface{}) {
 	// 	return
 	// }
 
-	now := time.Now().UTC()
+	now := nowUTC()
 	CalculatedData["status_maszyny"] = utils.ToBool(port1["maszyna_on/off"])
 	CalculatedData["timestamp"] = now.Format(time.RFC3339Nano)
 
 	updateImpulseCount(port1)
 	detectElement(port1, now)
+	detectReject(port1, now)
 	updateDimensions(port2)
+	measureChangeoverDims(port2)
 	updateCycleFromDimensions()
 	updateElementHistory()
 	updateMeasurementTimes(now)
@@ -389,16 +310,21 @@ func CalculateData(mqtt map[string]map[string]interface{}) {
 	checkIfShouldStore()
 	updateStubbedMetrics()
 	UpdateFinalOeeMetrics()
+	accumulateOrder()
+	trackMachineEvents(now)
+	markOeeCalculated()
 
-	startWydajnoscOnce.Do(func() {
-		StartWydajnoscTempUpdater(config.OeeFilePath, 10*time.Second)
-	})
-	startDostepnoscOnce.Do(func() {
-		StartDostepnoscTempUpdater(config.OeeFilePath, 10*time.Second)
-	})
-	startCostOnce.Do(func() {
-		StartCostUpdater(config.MetersFilePath, config.MqttFlowFilePath, 10*time.Second)
-	})
+	if !backgroundDisabled.Load() {
+		startWydajnoscOnce.Do(func() {
+			StartWydajnoscTempUpdater(config.OeeFilePath, TempUpdaterInterval)
+		})
+		startDostepnoscOnce.Do(func() {
+			StartDostepnoscTempUpdater(config.OeeFilePath, TempUpdaterInterval)
+		})
+		startCostOnce.Do(func() {
+			StartCostUpdater(config.MetersFilePath, config.MqttFlowFilePath, TempUpdaterInterval)
+		})
+	}
 
 	select {
 	case OeeReadyChan <- struct{}{}:
@@ -406,22 +332,32 @@ func CalculateData(mqtt map[string]map[string]interface{}) {
 	}
 }
 
-func determineCycleRate(length, width float64) float64 {
-	for _, entry := range config.CycleTable {
+// determineCycleRate – reguła CycleTable dla produktu spoza katalogu: etykieta "cyklN" i cykl.
+func determineCycleRate(length, width float64) (string, float64) {
+	t := config.CurrentTuning()
+	for i, entry := range t.CycleTable {
 		if length <= float64(entry.MaxLength) && width <= float64(entry.MaxWidth) {
-			return entry.CycleLPM
+			return fmt.Sprintf("cykl%d", i), entry.CycleLPM
 		}
 	}
-	return config.ProductionCycleDefault
+	return "", t.ProductionCycleDefault
 }
 
 func updateCycleFromDimensions() {
 	d := utils.ToFloat(CalculatedData["Dlugosc_calc"])
 	s := utils.ToFloat(CalculatedData["Szerokosc_calc"])
-	newCycle := determineCycleRate(d, s)
+	h := utils.ToFloat(CalculatedData["Wysokosc_calc"])
+	sku, newCycle, source := resolveProduct(d, s, h)
+	currentProductSource = source
 
-	if math.Abs(newCycle-currentCycleValue) > 0.01 {
-		now := time.Now().UTC()
+	// okres bez SKU (oee.json sprzed katalogu, świeży reset) przejmuje produkt o tym samym cyklu
+	sameCycle := math.Abs(newCycle-currentCycleValue) <= 0.01
+	if currentProductSKU == "" && sameCycle {
+		currentProductSKU = sku
+	}
+
+	if sku != currentProductSKU || !sameCycle {
+		now := nowUTC()
 
 		// zamknij poprzedni okres cyklu i przenieś skumulowany czas pracy
 		cycleHistory = append(cycleHistory, CyclePeriod{
@@ -430,15 +366,23 @@ func updateCycleFromDimensions() {
 			CycleLPM:       currentCycleValue,
 			ElementCounter: currentCycleElementCnt,
 			WorkSeconds:    currentCycleWorkSeconds, // KLUCZOWE
+			SKU:            currentProductSKU,
+			MicroStops:       currentCycleMicroStops,
+			MicroStopSeconds: currentCycleMicroStopSeconds,
+			Speed:            currentCycleSpeed,
 		})
 
 		// rozpocznij nowy okres
 		currentCycleStartTime   = now
 		currentCycleValue       = newCycle
+		currentProductSKU       = sku
 		currentCycleElementCnt  = 0
 		currentCycleWorkSeconds = 0
+		currentCycleMicroStops, currentCycleMicroStopSeconds = 0, 0
+		currentCycleSpeed       = SpeedStats{}
 		lastWorkTick            = now // uniknij „dociążenia” poprzednim dt
 		cycleJustChanged.Store(true)
+		eventCycleChange(now)
 	}
 
 	CalculatedData["cykl"] = newCycle
@@ -459,10 +403,16 @@ func updateMeasurementTimes(now time.Time) {
 func detectElement(port map[string]interface{}, now time.Time) {
 	elementSignal := utils.ToBool(port["Elementy"])
 	if elementSignal && !prevElement {
+		detectMicroStop(now)
 		CalculatedData["ilosc_elementow"] = utils.ToInt(CalculatedData["ilosc_elementow"]) + 1
 		currentCycleElementCnt++
+		changeoverElement()
 		if !firstElementDetected {
 			firstElementDetected = true
+			eventPauseEnd(now, "")
+			if now.Sub(CzasPomiarowy.StartMeasurement).Seconds() >= config.CurrentTuning().BreakdownThresholdSeconds {
+				markStartup(now) // pierwszy element po długim postoju
+			}
 		} else {
 			CzasPomiarowy.ElementLastTime = now
 		}
@@ -498,12 +448,14 @@ func updateIdleTime(now time.Time) {
 	}
 
 	// --- START PAUZY ---
-	if !currentSignal && idleDuration >= float64(config.IdleTimeoutSeconds) {
+	idleTimeout := config.CurrentTuning().IdleTimeoutSeconds
+	if !currentSignal && idleDuration >= float64(idleTimeout) {
 		if CzasPomiarowy.PauseStartTime == nil {
 			start := CzasPomiarowy.ElementLastTime.Add(
-				time.Duration(config.IdleTimeoutSeconds) * time.Second,
+				time.Duration(idleTimeout) * time.Second,
 			)
 			CzasPomiarowy.PauseStartTime = &start
+			eventPauseStart(start)
 			CzasPomiarowy.PauseStartTotal = CzasPomiarowy.TotalPause
 			CzasPomiarowy.PauseStartChangeoverTemp = utils.ToFloat(CalculatedData["czas_przezbrojenia"])
 		}
@@ -514,37 +466,42 @@ func updateIdleTime(now time.Time) {
 		if CzasPomiarowy.PauseStartTime != nil {
 			// snapshot wartości przed resetem
 			ps := *CzasPomiarowy.PauseStartTime
-			startEpoch := float64(ps.Unix())
-			endEpoch := float64(now.Unix())
 			dur := now.Sub(ps).Seconds()
 			changeoverTemp := dur
 
-			if endEpoch < startEpoch {
-				startEpoch, endEpoch = endEpoch, startEpoch
-			}
-
-			if math.Abs(cycle-lastCycle) > 0.01 {
+			// przezbrojenie: sygnał, nowe zlecenie albo zmiana wymiarów elementu (changeover.go)
+			reason := changeoverReason()
+			changeover := false
+			if reason != "" {
 				// --- PRZEZBROJENIE POTWIERDZONE ---
-				if dur <= config.MaxChangeoverDuration {
+				if dur <= maxChangeoverSeconds() {
+					changeover = true
 					CalculatedData["czas_przezbrojenia"] =
 						CzasPomiarowy.PauseStartChangeoverTemp + changeoverTemp
 					CalculatedData["czas_postoju"] = CzasPomiarowy.PauseStartTotal
-
-					// wywołanie gorutyny z użyciem snapshotów
-					utils.Go("AdjustIdleToChangeover", func() {
-						AdjustIdleToChangeover(startEpoch, endEpoch, changeoverTemp)
-					})
+					markStartup(now)
 				} else {
 					// zbyt długie – traktujemy jako zwykły postój
 					CzasPomiarowy.TotalPause += dur
+					classifyStop(dur, now)
 					CalculatedData["czas_przezbrojenia_temp"] = 0.0
 				}
 			} else {
 				// zwykła pauza
 				CzasPomiarowy.TotalPause += dur
+				classifyStop(dur, now)
 				CalculatedData["czas_przezbrojenia_temp"] = 0.0
 			}
 
+			// ostatnia zakończona pauza (potwierdzenia przyczyn postoju)
+			lastPauseStart, lastPauseEnd = ps, now
+			if !changeover {
+				reason = ""
+			}
+			eventPauseEnd(now, reason)
+			resolvePauseSamples(ps, changeover)
+			changeoverResolved()
+
 			// reset stanu pauzy
 			CzasPomiarowy.PauseStartTime = nil
 			CzasPomiarowy.PauseStartTotal = 0
@@ -599,8 +556,9 @@ func updateIdleTime(now time.Time) {
 
 func updateSpeed(now time.Time) {
 	if now.Sub(lastImpulse) >= time.Second {
-		obroty := float64(impulsesCount) / config.ImpulsyNaObrot
+		obroty := float64(impulsesCount) / config.CurrentTuning().ImpulsyNaObrot
 		CalculatedData["Predkosc_obrotnica"] = obroty * 60
+		sampleSpeed(obroty*60, now.Sub(lastImpulse).Seconds())
 		impulsesCount = 0
 		lastImpulse = now
 	}
@@ -628,88 +586,78 @@ func updateStubbedMetrics() {
 }
 
 func StartDostepnoscTempUpdater(path string, interval time.Duration) {
-	utils.Go("OEE DostepnoscTempUpdater", func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			func() {
-				defer utils.Catch("OEE DostepnoscTempUpdater iteration")()
+	utils.SuperviseLoop("OEE DostepnoscTempUpdater", interval, func() {
+		stepDostepnoscTemp(utils.LoadFromJSON(path))
+	})
+}
 
-				data := utils.LoadFromJSON(path)
+// stepDostepnoscTemp – jedna iteracja updatera dostępności „chwilowej”.
+// data: stan w layoutcie oee.json (lub płaski CalculatedData).
+func stepDostepnoscTemp(data map[string]interface{}) {
+	calcLock.Lock()
+	defer calcLock.Unlock()
 
-				calcLock.Lock()
-				currPomiar := getOeeFloat(data, "czas_pomiaru")
-				currPostoj := getOeeFloat(data, "czas_postoju")
+	currPomiar := getOeeFloat(data, "czas_pomiaru")
+	currPostoj := getOeeFloat(data, "czas_postoju")
 
-				deltaPomiar := currPomiar - lastPomiar
-				deltaPostoj := currPostoj - lastPostoj
+	deltaPomiar := currPomiar - lastPomiar
+	deltaPostoj := currPostoj - lastPostoj
 
-				if deltaPomiar <= 0 {
-					lastDostepnosc = 0.0
-				} else {
-					lastDostepnosc = (deltaPomiar - deltaPostoj) / deltaPomiar
-				}
+	if deltaPomiar <= 0 {
+		lastDostepnosc = 0.0
+	} else {
+		lastDostepnosc = (deltaPomiar - deltaPostoj) / deltaPomiar
+	}
 
-				lastPomiar = currPomiar
-				lastPostoj = currPostoj
-				calcLock.Unlock()
-			}()
-		}
-	})
+	lastPomiar = currPomiar
+	lastPostoj = currPostoj
 }
 
 func StartWydajnoscTempUpdater(path string, interval time.Duration) {
-	utils.Go("OEE WydajnoscTempUpdater", func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			func() {
-				defer utils.Catch("OEE WydajnoscTempUpdater iteration")()
-
-				// Jeśli właśnie zmienił się cykl, pomiń jedną iterację (bez zmian).
-				if cycleJustChanged.Load() {
-					cycleJustChanged.Store(false)
-					return
-				}
+	utils.SuperviseLoop("OEE WydajnoscTempUpdater", interval, func() {
+		// Jeśli właśnie zmienił się cykl, pomiń jedną iterację (bez zmian).
+		if cycleJustChanged.Load() {
+			cycleJustChanged.Store(false)
+			return
+		}
 
-				// Odczytaj „chwilowy” stan z pliku
-				data := utils.LoadFromJSON(path)
+		// Odczytaj „chwilowy” stan z pliku
+		stepWydajnoscTemp(utils.LoadFromJSON(path), interval)
+	})
+}
 
-				// --- policz liczbę sztuk w interwale
-				calcLock.Lock()
-				currCount := getOeeInt(data, "ilosc_elementow")
-				delta := currCount - lastElementCountOEE
-				if delta < 0 {
-					delta = 0 // osłona na reset/licznik wstecz
-				}
-				lastElementCountOEE = currCount
+// stepWydajnoscTemp – jedna iteracja updatera wydajności „chwilowej” dla okna interval.
+func stepWydajnoscTemp(data map[string]interface{}, interval time.Duration) {
+	// --- policz liczbę sztuk w interwale
+	calcLock.Lock()
+	currCount := getOeeInt(data, "ilosc_elementow")
+	delta := currCount - lastElementCountOEE
+	if delta < 0 {
+		delta = 0 // osłona na reset/licznik wstecz
+	}
+	lastElementCountOEE = currCount
 
-				// cykl i status_pracy z bieżącego stanu
-				cyklLpm := utils.ToFloat(CalculatedData["cykl"])
-				isWorking := getBool(data, "status_pracy")
-				calcLock.Unlock()
+	// cykl i status_pracy z bieżącego stanu
+	cyklLpm := utils.ToFloat(CalculatedData["cykl"])
+	isWorking := getBool(data, "status_pracy")
+	calcLock.Unlock()
 
-				// --- oczekiwane sztuki liczymy WYŁĄCZNIE jeśli maszyna faktycznie pracuje
-				var expected float64
-				if isWorking && cyklLpm > 0 {
-					expected = (cyklLpm / 60.0) * interval.Seconds()
-				} else {
-					expected = 0
-				}
+	// --- oczekiwane sztuki liczymy WYŁĄCZNIE jeśli maszyna faktycznie pracuje
+	var expected float64
+	if isWorking && cyklLpm > 0 {
+		expected = (cyklLpm / 60.0) * interval.Seconds()
+	} else {
+		expected = 0
+	}
 
-				// „chwilowa” wydajność z ostatniego okna czasu
-				calcLock.Lock()
-				if expected <= 0 {
-					lastWydajnosc = 0.0
-				} else {
-					lastWydajnosc = float64(delta) / expected
-				}
-				calcLock.Unlock()
-			}()
-		}
-	})
+	// „chwilowa” wydajność z ostatniego okna czasu
+	calcLock.Lock()
+	if expected <= 0 {
+		lastWydajnosc = 0.0
+	} else {
+		lastWydajnosc = float64(delta) / expected
+	}
+	calcLock.Unlock()
 }
 
 func updateElementHistory() {
@@ -752,7 +700,7 @@ func calculateDostepnosc() float64 {
 }
 
 func calculateWydajnosc() float64 {
-	now := time.Now().UTC()
+	now := nowUTC()
 
 	var totalExpected float64
 	var totalActual   float64
@@ -789,8 +737,13 @@ func calculateWydajnosc() float64 {
 }
 
 func calculateJakosc() float64 {
-	// Zakładamy 100% jakości
-	return 1.0
+	// dobre sztuki / wszystkie; bez sygnału odrzutu 100% jakości
+	ilosc := utils.ToInt(CalculatedData["ilosc_elementow"])
+	odrzuty := lossRejects.Rejects()
+	if ilosc <= 0 || odrzuty <= 0 {
+		return 1.0
+	}
+	return math.Round(float64(max(ilosc-odrzuty, 0))/float64(ilosc)*10000) / 10000
 }
 
 func calculateOEE() float64 {
@@ -830,8 +783,12 @@ func ResetOeeState() {
 	airBaselineMeters3 = 0
 	costBaselineSet.Store(false)
 
-	CzasPomiarowy.StartMeasurement = time.Now().UTC()
-	CzasPomiarowy.ElementLastTime = time.Now().UTC()
+	now := nowUTC()
+	splitEventsAtReset(now)
+	settlePauseSamples()
+	resetChangeoverSignal()
+	CzasPomiarowy.StartMeasurement = now
+	CzasPomiarowy.ElementLastTime = now
 	CzasPomiarowy.PauseStartTime = nil
 	CzasPomiarowy.TotalPause = 0.0
 
@@ -850,11 +807,17 @@ func ResetOeeState() {
 
 	// Reset cyklu
 	cycleHistory = []CyclePeriod{}
-	currentCycleStartTime = time.Now().UTC()
+	currentCycleStartTime = now
 	currentCycleElementCnt = 0
-	currentCycleValue = config.ProductionCycleDefault
+	currentCycleValue = config.CurrentTuning().ProductionCycleDefault
 	currentCycleWorkSeconds = 0
-	lastWorkTick = time.Now().UTC()
+	currentProductSKU = "" // wybór jawny (activeProductSKU) obowiązuje dalej
+	lastWorkTick = now
+	resetOrderCounters() // zlecenie trwa dalej, przyrosty od zera
+	resetLosses()
+	resetMicroStops()
+	resetSpeed()
+	resetStops()
 
 	utils.LogMessage("[OEE] OEE data reset after shift ended")
 }
@@ -894,6 +857,8 @@ func LoadOeeFromJSONFile(path string) {
 	CalculatedData["W_na_szt"]                 = utils.ToFloat(oeeMap["W_na_szt"])
 	CalculatedData["status_maszyny"]           = utils.ToBool(oeeMap["status_maszyny"])
 	CalculatedData["status_pracy"]             = utils.ToBool(oeeMap["status_pracy"])
+	CalculatedData["mikroprzestoje"]           = utils.ToInt(oeeMap["mikroprzestoje"])
+	CalculatedData["czas_mikroprzestojow"]     = utils.ToFloat(oeeMap["czas_mikroprzestojow"])
 
 	// --- PRODUCT ---
 	if prod, ok := data["product"].(map[string]interface{}); ok {
@@ -901,6 +866,9 @@ func LoadOeeFromJSONFile(path string) {
 		CalculatedData["Szerokosc_calc"] = utils.ToFloat(prod["szerokosc_calc"])
 		CalculatedData["Wysokosc_calc"]  = utils.ToFloat(prod["wysokosc_calc"])
 		CalculatedData["cykl"]           = utils.ToFloat(prod["cykl"])
+		currentProductSKU, _             = prod["sku"].(string)
+		currentProductSource, _          = prod["source"].(string)
+		activeProductSKU, _              = prod["selected_sku"].(string)
 	}
 
 	// --- INTERNAL ---
@@ -935,6 +903,14 @@ func LoadOeeFromJSONFile(path string) {
 			}
 		}
 		currentCycleWorkSeconds = utils.ToFloat(in["current_cycle_work_seconds"])
+		currentCycleMicroStops = utils.ToInt(in["current_cycle_micro_stops"])
+		currentCycleMicroStopSeconds = utils.ToFloat(in["current_cycle_micro_stop_seconds"])
+		prevElementAt = time.Time{}
+		if s, _ := in["prev_element_time"].(string); s != "" {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				prevElementAt = t
+			}
+		}
 
 		// historia cykli
 		cycleHistory = []CyclePeriod{}
@@ -946,9 +922,14 @@ func LoadOeeFromJSONFile(path string) {
 					cyc := utils.ToFloat(m["CycleLPM"])
 					cnt := utils.ToInt(m["ElementCounter"])
 					ws  := utils.ToFloat(m["WorkSeconds"]) // NOWE
+					sku, _ := m["SKU"].(string)
+					var speed SpeedStats
+					decodeSection(m["Speed"], &speed)
 				
 					cycleHistory = append(cycleHistory, CyclePeriod{
-						StartTime: st, EndTime: en, CycleLPM: cyc, ElementCounter: cnt, WorkSeconds: ws,
+						StartTime: st, EndTime: en, CycleLPM: cyc, ElementCounter: cnt, WorkSeconds: ws, SKU: sku,
+						MicroStops: utils.ToInt(m["MicroStops"]), MicroStopSeconds: utils.ToFloat(m["MicroStopSeconds"]),
+						Speed: speed,
 					})
 				}
 			}
@@ -981,6 +962,14 @@ func LoadOeeFromJSONFile(path string) {
 		}
 	}
 
+	// --- Six Big Losses, prędkość obrotnicy, postoje, dziennik zdarzeń ---
+	loadLosses(data)
+	loadSpeed(data)
+	loadStops(data)
+	loadEvents(data)
+	loadOeeTemp(data)
+	loadChangeover(data)
+
 	// --- HELPERS (wyłącznie do UI) ---
 	if ha, ok := data["helpers_air"].(map[string]interface{}); ok {
 		CalculatedData["helpers_air"] = ha
@@ -1003,16 +992,8 @@ func LoadOeeFromJSONFile(path string) {
 
 // StartCostUpdater – liczy energię (W) i powietrze (L) narastająco oraz wskaźniki "na sztukę".
 func StartCostUpdater(metersPath, flowPath string, interval time.Duration) {
-	utils.Go("OEE CostUpdater", func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			func() {
-				defer utils.Catch("OEE CostUpdater iteration")()
-				updateCostMetrics(metersPath, flowPath)
-			}()
-		}
+	utils.SuperviseLoop("OEE CostUpdater", interval, func() {
+		updateCostMetrics(metersPath, flowPath)
 	})
 }
 
@@ -1026,7 +1007,7 @@ func updateCostMetrics(metersPath, flowPath string) {
 
 	// Powietrze: suma RAW (przed skalowaniem) + szczegóły per port
 	rawAirSum, airParts, haveA := sumAirTotaliserMeters3(flows)
-	factor := config.AirFactor
+	factor := config.CurrentTuning().AirFactor
 	totalAir := rawAirSum * factor // po przeliczeniu do L
 
 	if !(haveE || haveA) {
@@ -1128,7 +1109,10 @@ func sumEnergyWFromMeters(data map[string]interface{}) (float64, map[string]floa
 	found := false
 	details := map[string]float64{}
 
-	targets := map[string]bool{"device_1": true, "device_2": true, "device_3": true}
+	targets := map[string]bool{}
+	for i := 1; i <= len(config.AnalyzerIPs); i++ {
+		targets[fmt.Sprintf("device_%d", i)] = true
+	}
 
 	for devKey, v := range data {
 		if !targets[devKey] {
@@ -1299,10 +1283,20 @@ func clamp(val float64) float64 {
 // Serializer: zapisuje OeeFileFlat w nowym layoutcie
 // Zbuduj strukturę z bieżącego stanu i zapisz w nowym układzie
 func SaveOeeFlat(path string) {
-	var of OeeFileFlat
+	_ = saveOeeFlat(BuildOeeFlat(), path)
+}
 
+// BuildOeeFlat zwraca bieżący stan silnika w layoutcie oee.json (bez zapisu do pliku).
+func BuildOeeFlat() OeeFileFlat {
 	calcLock.Lock()
-	now := time.Now().UTC()
+	defer calcLock.Unlock()
+	return buildOeeFlatLocked()
+}
+
+// buildOeeFlatLocked – wymaga trzymanego calcLock.
+func buildOeeFlatLocked() OeeFileFlat {
+	var of OeeFileFlat
+	now := nowUTC()
 
 	// wyciągnij helpery jeśli już są policzone przez updateCostMetrics()
 	var ha, he map[string]interface{}
@@ -1334,31 +1328,8 @@ func SaveOeeFlat(path string) {
 
 	of = OeeFileFlat{
 		Timestamp: now.Format(time.RFC3339Nano),
-		OEE: OeeSection{
-			CzasPomiaru:           utils.ToFloat(CalculatedData["czas_pomiaru"]),
-			CzasPracy:             utils.ToFloat(CalculatedData["czas_pracy"]),
-			CzasPostoju:           utils.ToFloat(CalculatedData["czas_postoju"]),
-			CzasPrzezbrojenia:     utils.ToFloat(CalculatedData["czas_przezbrojenia"]),
-			CzasPrzezbrojeniaTemp: utils.ToFloat(CalculatedData["czas_przezbrojenia_temp"]),
-			IloscElementow:        utils.ToInt(CalculatedData["ilosc_elementow"]),
-			Dostepnosc:            utils.ToFloat(CalculatedData["dostepnosc"]),
-			Wydajnosc:             utils.ToFloat(CalculatedData["wydajnosc"]),
-			Jakosc:                utils.ToFloat(CalculatedData["jakosc"]),
-			OEE:                   utils.ToFloat(CalculatedData["oee"]),
-			PowietrzeL:            utils.ToFloat(CalculatedData["powietrze_L"]),
-			EnergyW:               utils.ToFloat(CalculatedData["energia_W"]),
-			M3naSzt:               utils.ToFloat(CalculatedData["M3_na_szt"]),
-			WNaSzt:                utils.ToFloat(CalculatedData["W_na_szt"]),
-			StatusMaszyny:         utils.ToBool(CalculatedData["status_maszyny"]),
-			StatusPracy:           utils.ToBool(CalculatedData["status_pracy"]),
-			PredkoscObrotnica:     utils.ToFloat(CalculatedData["Predkosc_obrotnica"]),
-		},
-		Product: OeeProduct{
-			DlugoscCalc:   utils.ToFloat(CalculatedData["Dlugosc_calc"]),
-			SzerokoscCalc: utils.ToFloat(CalculatedData["Szerokosc_calc"]),
-			WysokoscCalc:  utils.ToFloat(CalculatedData["Wysokosc_calc"]),
-			Cykl:          utils.ToFloat(CalculatedData["cykl"]),
-		},
+		OEE:       oeeSectionLocked(),
+		Product:   oeeProductLocked(),
 		Internal: OeeInternal{
 			StartMeasurement:       CzasPomiarowy.StartMeasurement.UTC().Format(time.RFC3339),
 			ElementLastTime:        CzasPomiarowy.ElementLastTime.UTC().Format(time.RFC3339),
@@ -1367,7 +1338,7 @@ func SaveOeeFlat(path string) {
 			CurrentCycleStart:      currentCycleStartTime.UTC().Format(time.RFC3339),
 			CurrentCycleValue:      currentCycleValue,
 			CurrentCycleWorkSeconds: currentCycleWorkSeconds,
-			CycleHistory:           cycleHistory,
+			CycleHistory:           append([]CyclePeriod{}, cycleHistory...),
 			PrevElement:            prevElement,
 			PrevSpeed:              prevSpeed,
 			PauseStartTime:         pauseStrPtr,
@@ -1384,10 +1355,19 @@ func SaveOeeFlat(path string) {
 			OeeTemp:                utils.ToFloat(CalculatedData["oee_temp"]),
 			WydajnoscTemp:          utils.ToFloat(CalculatedData["wydajnosc_temp"]),
 			DostepnoscTemp:         utils.ToFloat(CalculatedData["dostepnosc_temp"]),
+			Losses:                 lossInternalLocked(),
+			PrevElementTime:        prevElementTime(),
+			CurrentCycleMicroStops: currentCycleMicroStops,
+			CurrentCycleMicroStopSeconds: currentCycleMicroStopSeconds,
+			Speed:                  shiftSpeed,
+			CurrentCycleSpeed:      currentCycleSpeed,
+			Stops:                  stopCounters,
+			Events:                 eventInternalLocked(),
+			Changeover:             changeoverFrom,
 		},
 		HelpersAir: HelpersAir{
 			Baseline:             fFrom(ha, "baseline",                "airBaseline_internal"),
-			Factor:               config.AirFactor,
+			Factor:               config.CurrentTuning().AirFactor,
 			TotalRawBeforeFactor: fFrom(ha, "total_raw_before_factor", "helpers_air_total_raw_before_factor"),
 			TotalCurrentM3:        fFrom(ha, "total_current_M3",        "helpers_air_total_current_M3"),
 			PortsRaw:             map[string]float64{},
@@ -1397,6 +1377,10 @@ func SaveOeeFlat(path string) {
 			TotalCurrentW: fFrom(he, "total_current_W", "helpers_energy_total_current_W"),
 			DevicesW:      map[string]float64{},
 		},
+		Losses: currentLossesLocked(),
+		Speed:  currentSpeedLocked(),
+		Reliability: currentReliabilityLocked(),
+		Stops:       currentStopsLocked(),
 	}
 
 	// skopiuj szczegóły portów/urządzeń tylko jeśli wartości są nie-nil
@@ -1414,13 +1398,57 @@ func SaveOeeFlat(path string) {
 			}
 		}
 	}
-	calcLock.Unlock()
 
-	_ = saveOeeFlat(of, path)
+	return of
+}
+
+// oeeSectionLocked – sekcja "oee" (wymaga calcLock).
+func oeeSectionLocked() OeeSection {
+	return OeeSection{
+		CzasPomiaru:           utils.ToFloat(CalculatedData["czas_pomiaru"]),
+		CzasPracy:             utils.ToFloat(CalculatedData["czas_pracy"]),
+		CzasPostoju:           utils.ToFloat(CalculatedData["czas_postoju"]),
+		CzasPrzezbrojenia:     utils.ToFloat(CalculatedData["czas_przezbrojenia"]),
+		CzasPrzezbrojeniaTemp: utils.ToFloat(CalculatedData["czas_przezbrojenia_temp"]),
+		IloscElementow:        utils.ToInt(CalculatedData["ilosc_elementow"]),
+		Dostepnosc:            utils.ToFloat(CalculatedData["dostepnosc"]),
+		Wydajnosc:             utils.ToFloat(CalculatedData["wydajnosc"]),
+		Jakosc:                utils.ToFloat(CalculatedData["jakosc"]),
+		OEE:                   utils.ToFloat(CalculatedData["oee"]),
+		PowietrzeL:            utils.ToFloat(CalculatedData["powietrze_L"]),
+		EnergyW:               utils.ToFloat(CalculatedData["energia_W"]),
+		M3naSzt:               utils.ToFloat(CalculatedData["M3_na_szt"]),
+		WNaSzt:                utils.ToFloat(CalculatedData["W_na_szt"]),
+		StatusMaszyny:         utils.ToBool(CalculatedData["status_maszyny"]),
+		StatusPracy:           utils.ToBool(CalculatedData["status_pracy"]),
+		PredkoscObrotnica:     utils.ToFloat(CalculatedData["Predkosc_obrotnica"]),
+		Mikroprzestoje:        utils.ToInt(CalculatedData["mikroprzestoje"]),
+		CzasMikroprzestojow:   math.Round(utils.ToFloat(CalculatedData["czas_mikroprzestojow"])*100) / 100,
+	}
+}
+
+// oeeProductLocked – sekcja "product" (wymaga calcLock).
+func oeeProductLocked() OeeProduct {
+	return OeeProduct{
+		DlugoscCalc:   utils.ToFloat(CalculatedData["Dlugosc_calc"]),
+		SzerokoscCalc: utils.ToFloat(CalculatedData["Szerokosc_calc"]),
+		WysokoscCalc:  utils.ToFloat(CalculatedData["Wysokosc_calc"]),
+		Cykl:          utils.ToFloat(CalculatedData["cykl"]),
+		SKU:           currentProductSKU,
+		Source:        currentProductSource,
+		SelectedSKU:   activeProductSKU,
+	}
 }
 
 // Serializer: zapis OeeFileFlat w nowym layoutcie do JSON
 func saveOeeFlat(of OeeFileFlat, path string) error {
+	// utils.SaveToJSON nie zwraca błędu – zapisujemy i zwracamy nil
+	utils.SaveToJSON(oeeFlatMap(of), path)
+	return nil
+}
+
+// oeeFlatMap – OeeFileFlat w docelowym layoutcie oee.json (helpery jako płaskie mapy).
+func oeeFlatMap(of OeeFileFlat) map[string]interface{} {
 	// helpers_air jako płaska mapa (baseline/factor/sumy + dynamiczne porty)
 	helpersAir := map[string]interface{}{
 		"baseline":                of.HelpersAir.Baseline,
@@ -1449,9 +1477,10 @@ func saveOeeFlat(of OeeFileFlat, path string) error {
 		"internal":       of.Internal,
 		"helpers_air":    helpersAir,
 		"helpers_energy": helpersEnergy,
+		"losses":         of.Losses,
+		"speed":          of.Speed,
+		"reliability":    of.Reliability,
+		"stops":          of.Stops,
 	}
-
-	// utils.SaveToJSON nie zwraca błędu – zapisujemy i zwracamy nil
-	utils.SaveToJSON(out, path)
-	return nil
+	return out
 }
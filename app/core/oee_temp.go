@@ -0,0 +1,232 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"os"
+	"time"
+)
+
+// --- Próbki oee_temp i rozliczanie pauz ---
+//
+// Pauza (brak elementu dłużej niż idle timeout) jest rozstrzygana dopiero przy pierwszym elemencie
+// po niej (changeover.go): przezbrojenie albo postój.
+// Próbki oee_temp z pauzy, która może jeszcze okazać się przezbrojeniem, są tymczasowe – czekają
+// w kolejce z początkiem pauzy (Pause) i po rozstrzygnięciu dostają wartości końcowe: przy
+// przezbrojeniu czas_postoju sprzed pauzy i czas_przezbrojenia = czas_przezbrojenia_temp. Pauza
+// dłuższa niż najdłuższy czas przezbrojenia (Tuning.MaxChangeoverLimit) i pauza przerwana granicą
+// zmiany są postojem. Do DB trafiają
+// tylko próbki rozstrzygnięte, więc zapisane wiersze oee_temp nigdy nie są poprawiane. Kolejka
+// (także niezapisane przy braku bazy) jest w osobnym pliku config.OeeTempPendingFilePath,
+// zapisywanym co oeeTempPersistInterval i przy zamknięciu programu – nie w oee.json, który
+// jest przepisywany przy każdym przeliczeniu OEE.
+
+// maxOeeTempPending – niezapisane próbki (6 h przy config.OEEUpdateInterval = 5 s); najstarsze są odrzucane.
+const maxOeeTempPending = 4320
+
+// oeeTempPersistInterval – okres zapisu kolejki do pliku; po awarii programu brakuje co najwyżej
+// próbek z tego okresu.
+const oeeTempPersistInterval = time.Minute
+
+// OeeTempSample – wiersz oee_temp.
+type OeeTempSample struct {
+	Timestamp time.Time  `json:"timestamp"`
+	OEE       OeeSection `json:"oee"`
+	Product   OeeProduct `json:"product"`
+	Pause     *time.Time `json:"pause,omitempty"` // początek nierozstrzygniętej pauzy; nil = wartości końcowe
+}
+
+var (
+	pendingOeeTemp []OeeTempSample // chronione calcLock
+	oeeTempLog     = utils.NewLogger("OEE_TEMP")
+
+	// stan pliku kolejki – tylko pętla „OEE to DB” i zamknięcie programu
+	oeeTempPersisted time.Time
+	oeeTempOnDisk    = true // plik może istnieć (z poprzedniego uruchomienia)
+)
+
+// sampleOeeTempLocked – próbka stanu silnika w t do kolejki (wymaga calcLock).
+func sampleOeeTempLocked(t time.Time) {
+	s := OeeTempSample{Timestamp: t.UTC(), OEE: oeeSectionLocked(), Product: oeeProductLocked()}
+	if ps := CzasPomiarowy.PauseStartTime; ps != nil && pauseMayBeChangeover(*ps, t) {
+		start := ps.UTC()
+		s.Pause = &start
+	}
+	expirePauseSamples(t)
+	pendingOeeTemp = append(pendingOeeTemp, s)
+	if len(pendingOeeTemp) > maxOeeTempPending {
+		oeeTempLog.Limit("pending", 10*time.Minute).Warn("oee_temp queue full, oldest samples dropped",
+			"dropped", len(pendingOeeTemp)-maxOeeTempPending)
+		pendingOeeTemp = pendingOeeTemp[len(pendingOeeTemp)-maxOeeTempPending:]
+	}
+}
+
+// pauseMayBeChangeover – pauza od ps trwająca do t może jeszcze zakończyć się przezbrojeniem.
+func pauseMayBeChangeover(ps, t time.Time) bool {
+	return t.Sub(ps).Seconds() <= config.CurrentTuning().MaxChangeoverLimit()
+}
+
+// resolvePauseSamples – koniec pauzy od ps (updateIdleTime, przed resetem stanu pauzy): próbki
+// tymczasowe dostają wartości końcowe. Wymaga calcLock.
+func resolvePauseSamples(ps time.Time, changeover bool) {
+	for i := range pendingOeeTemp {
+		s := &pendingOeeTemp[i]
+		if s.Pause == nil || !s.Pause.Equal(ps) {
+			continue
+		}
+		if changeover {
+			s.OEE.CzasPostoju = CzasPomiarowy.PauseStartTotal
+			s.OEE.CzasPrzezbrojenia = s.OEE.CzasPrzezbrojeniaTemp
+		}
+		s.Pause = nil
+	}
+}
+
+// expirePauseSamples – pauzy dłuższe niż każdy czas przezbrojenia są postojem (wymaga calcLock).
+func expirePauseSamples(t time.Time) {
+	for i := range pendingOeeTemp {
+		if ps := pendingOeeTemp[i].Pause; ps != nil && !pauseMayBeChangeover(*ps, t) {
+			pendingOeeTemp[i].Pause = nil
+		}
+	}
+}
+
+// settlePauseSamples – granica zmiany: trwająca pauza kończy się jako postój (wymaga calcLock).
+func settlePauseSamples() {
+	for i := range pendingOeeTemp {
+		pendingOeeTemp[i].Pause = nil
+	}
+}
+
+// takeOeeTempLocked – wszystkie próbki z kolejki (przeliczenie zmiany); wymaga calcLock.
+func takeOeeTempLocked() []OeeTempSample {
+	out := pendingOeeTemp
+	pendingOeeTemp = nil
+	return out
+}
+
+// loadOeeTemp – kolejka z config.OeeTempPendingFilePath; bez pliku przejmuje kolejkę z oee.json
+// sprzed jej wydzielenia (internal.oee_temp_pending). Wymaga calcLock.
+func loadOeeTemp(data map[string]interface{}) {
+	pendingOeeTemp = nil
+	if raw, err := os.ReadFile(config.OeeTempPendingFilePath); err == nil {
+		if err := json.Unmarshal(raw, &pendingOeeTemp); err != nil {
+			oeeTempLog.Warn("pending oee_temp samples unreadable", "file", config.OeeTempPendingFilePath, "error", err)
+		}
+		return
+	}
+	if in, ok := data["internal"].(map[string]interface{}); ok {
+		decodeSection(in["oee_temp_pending"], &pendingOeeTemp)
+	}
+}
+
+// persistOeeTemp – kolejka do pliku, najwyżej co oeeTempPersistInterval (force – zamknięcie
+// programu); pusta kolejka usuwa plik.
+func persistOeeTemp(force bool) {
+	calcLock.Lock()
+	pending := append([]OeeTempSample(nil), pendingOeeTemp...)
+	calcLock.Unlock()
+	if len(pending) == 0 {
+		if oeeTempOnDisk {
+			if err := os.Remove(config.OeeTempPendingFilePath); err != nil && !os.IsNotExist(err) {
+				oeeTempLog.Warn("pending oee_temp samples not removed", "error", err)
+				return
+			}
+			oeeTempOnDisk = false
+		}
+		return
+	}
+	if !force && time.Since(oeeTempPersisted) < oeeTempPersistInterval {
+		return
+	}
+	utils.SaveToJSON(pending, config.OeeTempPendingFilePath)
+	oeeTempPersisted, oeeTempOnDisk = time.Now(), true
+}
+
+// SaveOeeTempPending – zapis kolejki przy zamknięciu programu (po ostatnim SaveOeeTempToDB).
+func SaveOeeTempPending() {
+	persistOeeTemp(true)
+}
+
+// oeeTempInsert – wiersz oee_temp z jawnym czasem próbki.
+const oeeTempInsert = `
+	INSERT INTO oee_temp (
+		timestamp, predkosc_obrotnica, czas_pracy, czas_postoju,
+		czas_pomiaru, czas_przezbrojenia,
+		status_maszyny, ilosc_elementow,
+		dlugosc_calc, szerokosc_calc, wysokosc_calc,
+		dostepnosc, wydajnosc, jakosc, cykl, oee,
+		czas_przezbrojenia_temp, status_pracy, W_na_szt, M3_na_szt
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+	ON CONFLICT DO NOTHING`
+
+func insertOeeTemp(tx *sql.Tx, s OeeTempSample) error {
+	o, p := s.OEE, s.Product
+	_, err := tx.Exec(oeeTempInsert, s.Timestamp, o.PredkoscObrotnica, o.CzasPracy, o.CzasPostoju,
+		o.CzasPomiaru, o.CzasPrzezbrojenia, o.StatusMaszyny, o.IloscElementow,
+		p.DlugoscCalc, p.SzerokoscCalc, p.WysokoscCalc,
+		o.Dostepnosc, o.Wydajnosc, o.Jakosc, p.Cykl, o.OEE,
+		o.CzasPrzezbrojeniaTemp, o.StatusPracy, o.WNaSzt, o.M3naSzt)
+	return err
+}
+
+// SaveOeeTempToDB – próbka bieżącego stanu (pętla „OEE to DB”, co config.OEEUpdateInterval) i zapis
+// rozstrzygniętych próbek z kolejki; przy błędzie próbki zostają do następnej próby.
+func SaveOeeTempToDB() {
+	defer persistOeeTemp(false)
+	calcLock.Lock()
+	sampleOeeTempLocked(nowUTC())
+	var ready []OeeTempSample
+	for _, s := range pendingOeeTemp {
+		if s.Pause == nil {
+			ready = append(ready, s)
+		}
+	}
+	calcLock.Unlock()
+	if len(ready) == 0 {
+		return
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		oeeTempLog.Limit("db", 10*time.Minute).Warn("oee_temp not saved", "pending", len(ready), "error", err)
+		return
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		oeeTempLog.Limit("db", 10*time.Minute).Warn("oee_temp not saved", "pending", len(ready), "error", err)
+		return
+	}
+	for _, s := range ready {
+		if err := insertOeeTemp(tx, s); err != nil {
+			_ = tx.Rollback()
+			utils.LogMessage(fmt.Sprintf("[DB] Error inserting into oee_temp: %v", err))
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		utils.LogMessage(fmt.Sprintf("[DB] Commit error in SaveOeeTempToDB: %v", err))
+		return
+	}
+	markDBWrite("oee_temp")
+
+	// próbki dodane lub rozstrzygnięte w czasie zapisu zostają w kolejce
+	written := make(map[time.Time]bool, len(ready))
+	for _, s := range ready {
+		written[s.Timestamp] = true
+	}
+	calcLock.Lock()
+	kept := pendingOeeTemp[:0]
+	for _, s := range pendingOeeTemp {
+		if !written[s.Timestamp] {
+			kept = append(kept, s)
+		}
+	}
+	pendingOeeTemp = kept
+	calcLock.Unlock()
+}
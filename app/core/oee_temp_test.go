@@ -0,0 +1,114 @@
+package core
+
+import (
+	"go_app/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestPauseSamplesResolved – próbki oee_temp z pauzy są tymczasowe, dopóki pauza może być
+// przezbrojeniem; pauza zakończona przezbrojeniem przepisuje je (czas_postoju sprzed pauzy,
+// czas_przezbrojenia = czas_przezbrojenia_temp), pauza dłuższa niż przezbrojenie zostaje postojem.
+func TestPauseSamplesResolved(t *testing.T) {
+	frames, err := LoadReplayFrames(filepath.Join("testdata", "replay", "changeover.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// po nagraniu (zatrzymana linia) – postój dłuższy niż tuning.max_changeover_duration
+	last := frames[len(frames)-1]
+	for i := 1; i <= 15*60; i++ {
+		frames = append(frames, ReplayFrame{Timestamp: last.Timestamp.Add(time.Duration(i) * time.Second), Ports: last.Ports})
+	}
+	sr := ShiftRange{Start: frames[0].Timestamp, End: frames[len(frames)-1].Timestamp.Add(time.Minute)}
+	s := NewShiftRecompute(sr, frames[0].Ports, nil)
+
+	provisional := map[time.Time]time.Time{} // próbka -> początek pauzy
+	var pauses []time.Time
+	for _, fr := range frames[1:] {
+		s.Step(fr)
+		calcLock.Lock()
+		for _, x := range pendingOeeTemp {
+			if x.Pause == nil {
+				continue
+			}
+			if _, seen := provisional[x.Timestamp]; !seen && (len(pauses) == 0 || !pauses[len(pauses)-1].Equal(*x.Pause)) {
+				pauses = append(pauses, *x.Pause)
+			}
+			provisional[x.Timestamp] = *x.Pause
+		}
+		calcLock.Unlock()
+	}
+	res := s.Finish()
+	if len(pauses) != 2 {
+		t.Fatalf("provisional samples from %d pauses, want 2 (changeover, stop)", len(pauses))
+	}
+
+	var before OeeTempSample // ostatnia próbka przed pauzą
+	checked := map[time.Time]int{}
+	for _, x := range res.Samples {
+		if x.Pause != nil {
+			t.Errorf("%s: sample still provisional after Finish", x.Timestamp)
+		}
+		ps, ok := provisional[x.Timestamp]
+		if !ok {
+			before = x
+			continue
+		}
+		checked[ps]++
+		o := x.OEE
+		switch {
+		case ps.Equal(pauses[0]):
+			if o.CzasPrzezbrojenia != o.CzasPrzezbrojeniaTemp || o.CzasPrzezbrojenia <= before.OEE.CzasPrzezbrojenia ||
+				o.CzasPostoju != before.OEE.CzasPostoju {
+				t.Errorf("%s: changeover sample postój %g przezbrojenie %g (temp %g), before pause postój %g przezbrojenie %g",
+					x.Timestamp, o.CzasPostoju, o.CzasPrzezbrojenia, o.CzasPrzezbrojeniaTemp,
+					before.OEE.CzasPostoju, before.OEE.CzasPrzezbrojenia)
+			}
+		default:
+			if o.CzasPrzezbrojenia != before.OEE.CzasPrzezbrojenia || o.CzasPostoju <= before.OEE.CzasPostoju {
+				t.Errorf("%s: stop sample postój %g przezbrojenie %g, before pause postój %g przezbrojenie %g",
+					x.Timestamp, o.CzasPostoju, o.CzasPrzezbrojenia, before.OEE.CzasPostoju, before.OEE.CzasPrzezbrojenia)
+			}
+		}
+	}
+	for _, ps := range pauses {
+		if checked[ps] == 0 {
+			t.Errorf("pause %s: no samples in the result", ps)
+		}
+	}
+}
+
+// TestOeeTempPendingFile – kolejka przeżywa restart razem ze stanem pauzy; pusta usuwa plik.
+func TestOeeTempPendingFile(t *testing.T) {
+	prevPath, prevQueue := config.OeeTempPendingFilePath, pendingOeeTemp
+	defer func() { config.OeeTempPendingFilePath, pendingOeeTemp = prevPath, prevQueue }()
+	config.OeeTempPendingFilePath = filepath.Join(t.TempDir(), "oee_temp_pending.json")
+
+	ps := time.Date(2026, 3, 2, 6, 10, 0, 0, time.UTC)
+	calcLock.Lock()
+	pendingOeeTemp = []OeeTempSample{
+		{Timestamp: ps.Add(-5 * time.Second), OEE: OeeSection{CzasPostoju: 12}},
+		{Timestamp: ps.Add(5 * time.Second), OEE: OeeSection{CzasPostoju: 17, CzasPrzezbrojeniaTemp: 5}, Pause: &ps},
+	}
+	calcLock.Unlock()
+	SaveOeeTempPending()
+
+	calcLock.Lock()
+	loadOeeTemp(map[string]interface{}{})
+	got := append([]OeeTempSample(nil), pendingOeeTemp...)
+	calcLock.Unlock()
+	if len(got) != 2 || got[0].Pause != nil || got[1].Pause == nil || !got[1].Pause.Equal(ps) ||
+		got[1].OEE.CzasPrzezbrojeniaTemp != 5 {
+		t.Fatalf("restored queue = %+v", got)
+	}
+
+	calcLock.Lock()
+	pendingOeeTemp = nil
+	calcLock.Unlock()
+	persistOeeTemp(false)
+	if _, err := os.Stat(config.OeeTempPendingFilePath); !os.IsNotExist(err) {
+		t.Errorf("empty queue: file not removed (%v)", err)
+	}
+}
@@ -0,0 +1,468 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Zlecenia produkcyjne (tabela production_orders) ---
+//
+// Trwające zlecenie dostaje przyrosty liczników silnika OEE z każdego przeliczenia
+// (sztuki, odrzuty, czas pracy / postoju / przezbrojenia, energia, powietrze, oczekiwane sztuki
+// wg cyklu idealnego). Reset liczników na granicy zmiany nie przerywa zlecenia. Stan trwającego zlecenia
+// jest w config.OrderFilePath (restart programu), a w DB zapisywany co config.OrderUpdateInterval
+// i przy zakończeniu. Ponowne uruchomienie tego samego numeru tworzy nowy wiersz (PK order_no +
+// started_at).
+
+// Status zlecenia.
+const (
+	OrderRunning  = "running"
+	OrderFinished = "finished"
+)
+
+// ProductionOrder – zlecenie produkcyjne z produkcją przypisaną od startu do zakończenia.
+type ProductionOrder struct {
+	OrderNo           string     `json:"order_no"`
+	SKU               string     `json:"sku,omitempty"` // pusty = produkt wg wymiarów
+	PlannedQty        int        `json:"planned_qty"`   // 0 = bez planu (bez ETA)
+	Status            string     `json:"status"`
+	StartedAt         time.Time  `json:"started_at"`
+	EndedAt           *time.Time `json:"ended_at,omitempty"`
+	Pieces            int        `json:"pieces"`
+	Rejects           int        `json:"rejects"` // odrzuty (Six Big Losses, losses.go)
+	RunSeconds        float64    `json:"run_seconds"`
+	DowntimeSeconds   float64    `json:"downtime_seconds"`
+	ChangeoverSeconds float64    `json:"changeover_seconds"`
+	EnergyWh          float64    `json:"energy_Wh"`
+	AirM3             float64    `json:"air_M3"`
+	ExpectedPieces    float64    `json:"expected_pieces"` // czas pracy / cykl idealny
+}
+
+// OrderView – zlecenie ze wskaźnikami OEE i prognozą (API, tablet operatora).
+type OrderView struct {
+	ProductionOrder
+	Dostepnosc float64    `json:"dostepnosc"`
+	Wydajnosc  float64    `json:"wydajnosc"`
+	Jakosc     float64    `json:"jakosc"`
+	OEE        float64    `json:"oee"`
+	Remaining  int        `json:"remaining"`     // planned_qty - pieces (min. 0)
+	ETA        *time.Time `json:"eta,omitempty"` // wg średniego tempa zlecenia (z postojami)
+}
+
+// orderCounters – liczniki silnika OEE, z których liczone są przyrosty zlecenia.
+type orderCounters struct {
+	pieces, rejects           int
+	run, downtime, changeover float64
+	energyWh, airM3           float64
+}
+
+var (
+	currentOrder       *ProductionOrder // chronione calcLock
+	orderSetProduct    bool             // SKU zlecenia ustawione jako wybór jawny produktu
+	orderLast          orderCounters
+	orderLastValid     bool
+	pendingOrders      []ProductionOrder // zakończone, jeszcze niezapisane do DB
+	pendingOrdersLock  sync.Mutex
+	orderCmdMu         sync.Mutex // start / stop zlecenia (API, MQTT) – jedno polecenie naraz
+	ordersLog          = utils.NewLogger("ORDERS")
+	ErrOrderNotRunning = errors.New("no running order")
+)
+
+// orderFile – zawartość config.OrderFilePath.
+type orderFile struct {
+	Current         *ProductionOrder  `json:"current"`
+	SetProduct      bool              `json:"set_product"`
+	PendingFinished []ProductionOrder `json:"pending_finished,omitempty"`
+}
+
+func readOrderCounters() orderCounters {
+	return orderCounters{
+		pieces:     utils.ToInt(CalculatedData["ilosc_elementow"]),
+		rejects:    lossRejects.Rejects(),
+		run:        utils.ToFloat(CalculatedData["czas_pracy"]),
+		downtime:   utils.ToFloat(CalculatedData["czas_postoju"]),
+		changeover: utils.ToFloat(CalculatedData["czas_przezbrojenia"]),
+		energyWh:   utils.ToFloat(CalculatedData["energia_W"]),
+		airM3:      utils.ToFloat(CalculatedData["powietrze_L"]),
+	}
+}
+
+// accumulateOrder – przyrosty od poprzedniego przeliczenia do trwającego zlecenia (wymaga calcLock).
+// Czasy mogą maleć (postój przeklasyfikowany na przezbrojenie), sumy nie schodzą poniżej zera.
+func accumulateOrder() {
+	cur := readOrderCounters()
+	prev, valid := orderLast, orderLastValid
+	orderLast, orderLastValid = cur, true
+	if currentOrder == nil || !valid {
+		return
+	}
+	o := currentOrder
+	dRun := cur.run - prev.run
+	o.Pieces += max(cur.pieces-prev.pieces, 0)
+	o.Rejects += max(cur.rejects-prev.rejects, 0)
+	o.RunSeconds = math.Max(o.RunSeconds+dRun, 0)
+	o.DowntimeSeconds = math.Max(o.DowntimeSeconds+cur.downtime-prev.downtime, 0)
+	o.ChangeoverSeconds = math.Max(o.ChangeoverSeconds+cur.changeover-prev.changeover, 0)
+	o.EnergyWh += math.Max(cur.energyWh-prev.energyWh, 0)
+	o.AirM3 += math.Max(cur.airM3-prev.airM3, 0)
+	if dRun > 0 && currentCycleValue > 0 {
+		o.ExpectedPieces += dRun / (60.0 / currentCycleValue)
+	}
+}
+
+// resetOrderCounters – liczniki silnika wyzerowane (granica zmiany); wymaga calcLock.
+func resetOrderCounters() {
+	orderLast, orderLastValid = orderCounters{}, true
+}
+
+// StartOrder rozpoczyna zlecenie; trwające inne zlecenie jest najpierw kończone.
+// SKU z katalogu staje się jawnie wybranym produktem silnika OEE.
+func StartOrder(orderNo, sku string, plannedQty int) (OrderView, error) {
+	orderCmdMu.Lock()
+	defer orderCmdMu.Unlock()
+	orderNo = strings.TrimSpace(orderNo)
+	if orderNo == "" || len(orderNo) > 64 {
+		return OrderView{}, errors.New("order_no: required, at most 64 characters")
+	}
+	if plannedQty < 0 {
+		return OrderView{}, errors.New("planned_qty: must be >= 0")
+	}
+	if cur, ok := CurrentOrder(); ok && cur.OrderNo == orderNo {
+		return OrderView{}, fmt.Errorf("order %q is already running", orderNo)
+	}
+	if sku != "" {
+		p, ok := ProductBySKU(sku)
+		if !ok {
+			return OrderView{}, fmt.Errorf("%w: %q", ErrProductNotFound, sku)
+		}
+		if !p.Active {
+			return OrderView{}, fmt.Errorf("product %q is inactive", sku)
+		}
+	}
+	if _, err := stopOrder(""); err == nil {
+		ordersLog.Info("previous order finished by the start of a new one", "new_order", orderNo)
+	}
+	if sku != "" {
+		if err := SetActiveProduct(sku); err != nil {
+			return OrderView{}, err
+		}
+	}
+
+	calcLock.Lock()
+	o := &ProductionOrder{
+		OrderNo:    orderNo,
+		SKU:        sku,
+		PlannedQty: plannedQty,
+		Status:     OrderRunning,
+		StartedAt:  nowUTC(),
+	}
+	currentOrder, orderSetProduct = o, sku != ""
+	orderLast, orderLastValid = readOrderCounters(), true
+	view := orderView(*o, nowUTC(), currentCycleValue)
+	calcLock.Unlock()
+
+	ordersLog.Info("order started", "order", orderNo, "sku", sku, "planned_qty", plannedQty)
+	saveOrderState()
+	if !backgroundDisabled.Load() {
+		utils.Go("SaveOrderToDB", func() { _ = saveOrderToDB(view.ProductionOrder) })
+	}
+	return view, nil
+}
+
+// StopOrder kończy trwające zlecenie (orderNo pusty = dowolne trwające).
+func StopOrder(orderNo string) (OrderView, error) {
+	orderCmdMu.Lock()
+	defer orderCmdMu.Unlock()
+	return stopOrder(orderNo)
+}
+
+// stopOrder – StopOrder pod orderCmdMu.
+func stopOrder(orderNo string) (OrderView, error) {
+	calcLock.Lock()
+	if currentOrder == nil {
+		calcLock.Unlock()
+		return OrderView{}, ErrOrderNotRunning
+	}
+	if orderNo != "" && currentOrder.OrderNo != orderNo {
+		running := currentOrder.OrderNo
+		calcLock.Unlock()
+		return OrderView{}, fmt.Errorf("order %q is not running (running: %q)", orderNo, running)
+	}
+	accumulateOrder()
+	now := nowUTC()
+	o := *currentOrder
+	o.Status, o.EndedAt = OrderFinished, &now
+	clearProduct := orderSetProduct && activeProductSKU == o.SKU
+	currentOrder, orderSetProduct = nil, false
+	view := orderView(o, now, currentCycleValue)
+	calcLock.Unlock()
+
+	if clearProduct {
+		_ = SetActiveProduct("")
+	}
+	ordersLog.Info("order finished", "order", o.OrderNo, "pieces", o.Pieces, "planned_qty", o.PlannedQty,
+		"oee", view.OEE)
+
+	pendingOrdersLock.Lock()
+	pendingOrders = append(pendingOrders, o)
+	pendingOrdersLock.Unlock()
+	saveOrderState()
+	if !backgroundDisabled.Load() {
+		utils.Go("SaveOrderToDB", flushOrdersToDB)
+	}
+	return view, nil
+}
+
+// CurrentOrder – trwające zlecenie ze wskaźnikami; false = brak.
+func CurrentOrder() (OrderView, bool) {
+	calcLock.Lock()
+	defer calcLock.Unlock()
+	if currentOrder == nil {
+		return OrderView{}, false
+	}
+	return orderView(*currentOrder, nowUTC(), currentCycleValue), true
+}
+
+// orderView – wskaźniki zlecenia; cycleLPM – cykl bieżącego produktu (ETA przed pierwszą sztuką).
+func orderView(o ProductionOrder, now time.Time, cycleLPM float64) OrderView {
+	v := OrderView{ProductionOrder: o, Jakosc: 1.0}
+	if o.Pieces > 0 && o.Rejects > 0 { // jak calculateJakosc: dobre sztuki / wszystkie
+		v.Jakosc = math.Round(float64(max(o.Pieces-o.Rejects, 0))/float64(o.Pieces)*10000) / 10000
+	}
+	if total := o.RunSeconds + o.DowntimeSeconds + o.ChangeoverSeconds; total > 0 {
+		v.Dostepnosc = math.Round(o.RunSeconds/total*10000) / 10000
+	}
+	if o.ExpectedPieces > 0 {
+		v.Wydajnosc = math.Round(float64(o.Pieces)/o.ExpectedPieces*10000) / 10000
+	}
+	v.OEE = math.Round(v.Dostepnosc*v.Wydajnosc*v.Jakosc*10000) / 10000
+
+	if o.PlannedQty > 0 {
+		v.Remaining = max(o.PlannedQty-o.Pieces, 0)
+	}
+	if o.Status == OrderRunning && v.Remaining > 0 {
+		// średni czas na sztukę od startu zlecenia; bez sztuk – cykl idealny bieżącego produktu
+		perPiece := 0.0
+		if o.Pieces > 0 {
+			perPiece = now.Sub(o.StartedAt).Seconds() / float64(o.Pieces)
+		} else if cycleLPM > 0 {
+			perPiece = 60.0 / cycleLPM
+		}
+		if perPiece > 0 {
+			eta := now.Add(time.Duration(float64(v.Remaining) * perPiece * float64(time.Second))).UTC()
+			v.ETA = &eta
+		}
+	}
+	return v
+}
+
+// --- polecenia MQTT (MQTT_ORDER_TOPIC) ---
+
+// OrderCommand – {"action": "start", "order_no": "...", "sku": "...", "planned_qty": 100}
+// albo {"action": "stop", "order_no": "..."}.
+type OrderCommand struct {
+	Action     string `json:"action"`
+	OrderNo    string `json:"order_no"`
+	SKU        string `json:"sku"`
+	PlannedQty int    `json:"planned_qty"`
+}
+
+// HandleOrderCommand wykonuje polecenie zlecenia z tematu MQTT.
+func HandleOrderCommand(payload []byte) error {
+	var cmd OrderCommand
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		return fmt.Errorf("invalid order command: %w", err)
+	}
+	var err error
+	switch strings.ToLower(cmd.Action) {
+	case "start":
+		_, err = StartOrder(cmd.OrderNo, cmd.SKU, cmd.PlannedQty)
+	case "stop":
+		_, err = StopOrder(cmd.OrderNo)
+	default:
+		err = fmt.Errorf("unknown order action %q (start|stop)", cmd.Action)
+	}
+	return err
+}
+
+// --- trwałość: plik stanu i DB ---
+
+// LoadOrderState przywraca trwające zlecenie po restarcie (po LoadOeeFromJSONFile).
+func LoadOrderState() {
+	raw, err := os.ReadFile(config.OrderFilePath)
+	if err != nil {
+		return
+	}
+	var f orderFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		ordersLog.Warn("order state unreadable", "file", config.OrderFilePath, "error", err)
+		return
+	}
+	calcLock.Lock()
+	currentOrder, orderSetProduct = f.Current, f.SetProduct
+	orderLast, orderLastValid = readOrderCounters(), true
+	calcLock.Unlock()
+
+	pendingOrdersLock.Lock()
+	pendingOrders = f.PendingFinished
+	pendingOrdersLock.Unlock()
+	if f.Current != nil {
+		ordersLog.Info("running order restored", "order", f.Current.OrderNo, "pieces", f.Current.Pieces)
+	}
+}
+
+func saveOrderState() {
+	if backgroundDisabled.Load() {
+		return // replay nie nadpisuje stanu pracującego programu
+	}
+	calcLock.Lock()
+	f := orderFile{SetProduct: orderSetProduct}
+	if currentOrder != nil {
+		o := *currentOrder
+		f.Current = &o
+	}
+	calcLock.Unlock()
+	pendingOrdersLock.Lock()
+	f.PendingFinished = append([]ProductionOrder(nil), pendingOrders...)
+	pendingOrdersLock.Unlock()
+	utils.SaveToJSON(f, config.OrderFilePath)
+}
+
+// StartOrderUpdater – okresowy zapis trwającego zlecenia (plik + DB) i ponawianie zapisu zakończonych.
+func StartOrderUpdater() {
+	utils.SuperviseLoop("ORDERS to DB", config.OrderUpdateInterval, SaveOrders)
+}
+
+// SaveOrders – stan zleceń do pliku, zakończone i trwające zlecenie do DB (także przy zamknięciu).
+func SaveOrders() {
+	saveOrderState()
+	flushOrdersToDB()
+	if o, ok := CurrentOrder(); ok {
+		_ = saveOrderToDB(o.ProductionOrder)
+	}
+}
+
+// flushOrdersToDB – zapis zakończonych zleceń; niezapisane zostają do następnej próby.
+func flushOrdersToDB() {
+	pendingOrdersLock.Lock()
+	pending := append([]ProductionOrder(nil), pendingOrders...)
+	pendingOrdersLock.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	type orderKey struct {
+		no      string
+		started time.Time
+	}
+	saved := map[orderKey]bool{}
+	for _, o := range pending {
+		if saveOrderToDB(o) != nil {
+			break
+		}
+		saved[orderKey{o.OrderNo, o.StartedAt}] = true
+	}
+	if len(saved) == 0 {
+		return
+	}
+	// usuwane tylko zapisane (PK order_no + started_at) – równoległy zapis albo nowe zakończone
+	// zlecenie nie przesuwają kolejki
+	pendingOrdersLock.Lock()
+	kept := pendingOrders[:0]
+	for _, o := range pendingOrders {
+		if !saved[orderKey{o.OrderNo, o.StartedAt}] {
+			kept = append(kept, o)
+		}
+	}
+	pendingOrders = kept
+	pendingOrdersLock.Unlock()
+	saveOrderState()
+}
+
+func saveOrderToDB(o ProductionOrder) error {
+	db, err := getConnection()
+	if err != nil {
+		ordersLog.Limit("db", 10*time.Minute).Warn("order not saved", "order", o.OrderNo, "error", err)
+		return err
+	}
+	defer db.Close()
+
+	v := orderView(o, nowUTC(), 0)
+	var sku sql.NullString
+	if o.SKU != "" {
+		sku = sql.NullString{String: o.SKU, Valid: true}
+	}
+	const q = `
+		INSERT INTO production_orders (
+			order_no, started_at, sku, planned_qty, status, ended_at,
+			pieces, run_seconds, downtime_seconds, changeover_seconds, energy_wh, air_m3, expected_pieces,
+			dostepnosc, wydajnosc, jakosc, oee, rejects, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, now())
+		ON CONFLICT (order_no, started_at) DO UPDATE SET
+			status = EXCLUDED.status, ended_at = EXCLUDED.ended_at,
+			pieces = EXCLUDED.pieces, rejects = EXCLUDED.rejects, run_seconds = EXCLUDED.run_seconds,
+			downtime_seconds = EXCLUDED.downtime_seconds, changeover_seconds = EXCLUDED.changeover_seconds,
+			energy_wh = EXCLUDED.energy_wh, air_m3 = EXCLUDED.air_m3, expected_pieces = EXCLUDED.expected_pieces,
+			dostepnosc = EXCLUDED.dostepnosc, wydajnosc = EXCLUDED.wydajnosc, jakosc = EXCLUDED.jakosc,
+			oee = EXCLUDED.oee, updated_at = now()
+	`
+	_, err = db.Exec(q, o.OrderNo, o.StartedAt, sku, o.PlannedQty, o.Status, o.EndedAt,
+		o.Pieces, o.RunSeconds, o.DowntimeSeconds, o.ChangeoverSeconds, o.EnergyWh, o.AirM3, o.ExpectedPieces,
+		v.Dostepnosc, v.Wydajnosc, v.Jakosc, v.OEE, o.Rejects)
+	if err != nil {
+		ordersLog.Limit("db", 10*time.Minute).Warn("order not saved", "order", o.OrderNo, "error", err)
+		return err
+	}
+	markDBWrite("production_orders")
+	return nil
+}
+
+// LoadOrdersFromDB – ostatnie zlecenia (najnowsze najpierw), opcjonalnie tylko order_no.
+func LoadOrdersFromDB(orderNo string, limit int) ([]OrderView, error) {
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT order_no, started_at, sku, planned_qty, status, ended_at,
+			pieces, run_seconds, downtime_seconds, changeover_seconds, energy_wh, air_m3, expected_pieces,
+			COALESCE(rejects, 0)
+		FROM production_orders
+		WHERE $1 = '' OR order_no = $1
+		ORDER BY started_at DESC
+		LIMIT $2`, orderNo, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := nowUTC()
+	out := []OrderView{}
+	for rows.Next() {
+		var o ProductionOrder
+		var sku sql.NullString
+		var ended sql.NullTime
+		if err := rows.Scan(&o.OrderNo, &o.StartedAt, &sku, &o.PlannedQty, &o.Status, &ended,
+			&o.Pieces, &o.RunSeconds, &o.DowntimeSeconds, &o.ChangeoverSeconds, &o.EnergyWh, &o.AirM3,
+			&o.ExpectedPieces, &o.Rejects); err != nil {
+			return nil, err
+		}
+		o.SKU, o.StartedAt = sku.String, o.StartedAt.UTC()
+		if ended.Valid {
+			t := ended.Time.UTC()
+			o.EndedAt = &t
+		}
+		out = append(out, orderView(o, now, 0))
+	}
+	return out, rows.Err()
+}
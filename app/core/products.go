@@ -0,0 +1,425 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// --- Katalog produktów (tabela products) ---
+//
+// Produkt silnika OEE ustalany jest w kolejności:
+//  1. wybór jawny (SetActiveProduct – API / zlecenie produkcyjne),
+//  2. dopasowanie zmierzonych wymiarów (*_calc) do zakresów produktów z katalogu – przy kilku
+//     pasujących wygrywa najwęższy zakres,
+//  3. reguła CycleTable z konfiguracji (etykieta "cyklN", jak w dawnych podsumowaniach).
+//
+// Katalog odświeżany jest z DB co config.ProductsRefreshInterval; kopia w config.ProductsFilePath
+// pozwala wystartować bez bazy.
+
+// Źródło bieżącego produktu.
+const (
+	ProductSourceManual     = "manual"      // SetActiveProduct
+	ProductSourceDimensions = "dimensions"  // dopasowanie wymiarów do katalogu
+	ProductSourceCycleTable = "cycle_table" // brak produktu w katalogu – reguła CycleTable
+)
+
+// openSpan – szerokość zakresu bez ograniczenia (do wyboru najwęższego dopasowania).
+const openSpan = 1e6
+
+// Product – pozycja katalogu. Zakres wymiaru bez min/max (null) nie ogranicza dopasowania;
+// min = max oznacza dokładny wymiar (± PRODUCT_DIM_TOLERANCE_MM).
+type Product struct {
+	SKU            string    `json:"sku"`
+	Name           string    `json:"name"`
+	Material       string    `json:"material,omitempty"`
+	Family         string    `json:"family,omitempty"`     // rodzina – maksymalny czas przezbrojenia (tuning.max_changeover_by_family)
+	LengthMin      *float64  `json:"length_min,omitempty"` // [mm], jak Dlugosc_calc
+	LengthMax      *float64  `json:"length_max,omitempty"`
+	WidthMin       *float64  `json:"width_min,omitempty"` // [mm], jak Szerokosc_calc
+	WidthMax       *float64  `json:"width_max,omitempty"`
+	HeightMin      *float64  `json:"height_min,omitempty"` // jak Wysokosc_calc
+	HeightMax      *float64  `json:"height_max,omitempty"`
+	IdealCycleS    float64   `json:"ideal_cycle_s"`    // idealny czas cyklu [s/szt.]
+	TargetScrapPct float64   `json:"target_scrap_pct"` // docelowy odsetek braków [%]
+	Active         bool      `json:"active"`           // nieaktywne nie są dopasowywane
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// CycleLPM – idealna wydajność produktu [szt./min] (jednostka cyklu silnika OEE).
+func (p Product) CycleLPM() float64 {
+	if p.IdealCycleS <= 0 {
+		return 0
+	}
+	return 60.0 / p.IdealCycleS
+}
+
+// Validate sprawdza pozycję przed zapisem do katalogu.
+func (p Product) Validate() error {
+	var errs []string
+	if p.SKU == "" || len(p.SKU) > 64 || strings.ContainsAny(p.SKU, " /\t\n") {
+		errs = append(errs, "sku: required, at most 64 characters, no spaces or '/'")
+	}
+	if p.IdealCycleS <= 0 || math.IsNaN(p.IdealCycleS) || math.IsInf(p.IdealCycleS, 0) {
+		errs = append(errs, "ideal_cycle_s: must be > 0")
+	}
+	if len(p.Family) > 64 {
+		errs = append(errs, "family: at most 64 characters")
+	}
+	if p.TargetScrapPct < 0 || p.TargetScrapPct > 100 {
+		errs = append(errs, "target_scrap_pct: must be between 0 and 100")
+	}
+	for _, r := range []struct {
+		name     string
+		min, max *float64
+	}{
+		{"length", p.LengthMin, p.LengthMax},
+		{"width", p.WidthMin, p.WidthMax},
+		{"height", p.HeightMin, p.HeightMax},
+	} {
+		if r.min != nil && r.max != nil && *r.min > *r.max {
+			errs = append(errs, fmt.Sprintf("%s_min: must be <= %s_max", r.name, r.name))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// matches – czy wymiary mieszczą się we wszystkich zakresach produktu. Produkt bez żadnego
+// zakresu nie jest dopasowywany po wymiarach (tylko wybór jawny).
+func (p Product) matches(length, width, height, tol float64) bool {
+	if p.LengthMin == nil && p.LengthMax == nil && p.WidthMin == nil && p.WidthMax == nil &&
+		p.HeightMin == nil && p.HeightMax == nil {
+		return false
+	}
+	return inRange(length, p.LengthMin, p.LengthMax, tol) &&
+		inRange(width, p.WidthMin, p.WidthMax, tol) &&
+		inRange(height, p.HeightMin, p.HeightMax, tol)
+}
+
+func inRange(v float64, min, max *float64, tol float64) bool {
+	if min != nil && v < *min-tol {
+		return false
+	}
+	if max != nil && v > *max+tol {
+		return false
+	}
+	return true
+}
+
+// span – suma szerokości zakresów (mniejsza = dopasowanie bardziej szczegółowe).
+func (p Product) span() float64 {
+	s := 0.0
+	for _, r := range [][2]*float64{{p.LengthMin, p.LengthMax}, {p.WidthMin, p.WidthMax}, {p.HeightMin, p.HeightMax}} {
+		if r[0] == nil || r[1] == nil {
+			s += openSpan
+		} else {
+			s += *r[1] - *r[0]
+		}
+	}
+	return s
+}
+
+type productCatalogue struct {
+	all   []Product          // wg SKU
+	bySKU map[string]Product // także nieaktywne – nazwy w podsumowaniach
+}
+
+var (
+	catalogue   atomic.Pointer[productCatalogue]
+	productsLog = utils.NewLogger("PRODUCTS")
+
+	activeProductSKU     string // wybór jawny; chroniony calcLock
+	currentProductSKU    string // produkt bieżącego okresu cyklu; chroniony calcLock
+	currentProductSource string
+)
+
+func setCatalogue(list []Product) {
+	c := &productCatalogue{all: append([]Product(nil), list...), bySKU: map[string]Product{}}
+	sort.Slice(c.all, func(i, j int) bool { return c.all[i].SKU < c.all[j].SKU })
+	for _, p := range c.all {
+		c.bySKU[p.SKU] = p
+	}
+	catalogue.Store(c)
+}
+
+// Products – cały katalog (także nieaktywne), wg SKU.
+func Products() []Product {
+	c := catalogue.Load()
+	if c == nil {
+		return []Product{}
+	}
+	return append([]Product(nil), c.all...)
+}
+
+// ProductBySKU – pozycja katalogu; false = brak.
+func ProductBySKU(sku string) (Product, bool) {
+	c := catalogue.Load()
+	if c == nil {
+		return Product{}, false
+	}
+	p, ok := c.bySKU[sku]
+	return p, ok
+}
+
+// resolveProduct – produkt dla zmierzonych wymiarów (wymaga calcLock): SKU/etykieta, cykl, źródło.
+func resolveProduct(length, width, height float64) (string, float64, string) {
+	if activeProductSKU != "" {
+		if p, ok := ProductBySKU(activeProductSKU); ok && p.Active {
+			return p.SKU, p.CycleLPM(), ProductSourceManual
+		}
+		productsLog.Limit("inactive-selection", 10*time.Minute).
+			Warn("selected product is not in the catalogue or inactive, matching by dimensions", "sku", activeProductSKU)
+	}
+	if c := catalogue.Load(); c != nil {
+		var best *Product
+		for i := range c.all {
+			p := &c.all[i]
+			if p.Active && p.matches(length, width, height, config.ProductDimTolerance) &&
+				(best == nil || p.span() < best.span()) {
+				best = p
+			}
+		}
+		if best != nil {
+			return best.SKU, best.CycleLPM(), ProductSourceDimensions
+		}
+	}
+	label, lpm := determineCycleRate(length, width)
+	return label, lpm, ProductSourceCycleTable
+}
+
+// SetActiveProduct ustawia produkt jawnie (zlecenie, operator); "" = powrót do dopasowania
+// po wymiarach. Nowy produkt obowiązuje od następnego przeliczenia OEE.
+func SetActiveProduct(sku string) error {
+	if sku != "" {
+		p, ok := ProductBySKU(sku)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrProductNotFound, sku)
+		}
+		if !p.Active {
+			return fmt.Errorf("product %q is inactive", sku)
+		}
+	}
+	calcLock.Lock()
+	prev := activeProductSKU
+	activeProductSKU = sku
+	calcLock.Unlock()
+	if prev != sku {
+		productsLog.Info("active product selected", "sku", sku, "previous", prev)
+	}
+	return nil
+}
+
+// ProductCommand – {"sku": "..."}; "" = powrót do dopasowania po wymiarach.
+type ProductCommand struct {
+	SKU string `json:"sku"`
+}
+
+// HandleProductCommand wykonuje wybór produktu z nagrania (InputTopicProduct).
+func HandleProductCommand(payload []byte) error {
+	var cmd ProductCommand
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		return fmt.Errorf("invalid product command: %w", err)
+	}
+	return SetActiveProduct(cmd.SKU)
+}
+
+// CurrentProductInfo – bieżący produkt silnika OEE.
+type CurrentProductInfo struct {
+	SKU         string  `json:"sku"` // SKU z katalogu albo "cyklN"
+	Name        string  `json:"name,omitempty"`
+	Source      string  `json:"source"`                 // manual | dimensions | cycle_table
+	Selected    string  `json:"selected_sku,omitempty"` // wybór jawny (SetActiveProduct)
+	CycleLPM    float64 `json:"cycle_lpm"`              // [szt./min]
+	IdealCycleS float64 `json:"ideal_cycle_s"`          // [s/szt.]
+	Length      float64 `json:"dlugosc_calc"`
+	Width       float64 `json:"szerokosc_calc"`
+	Height      float64 `json:"wysokosc_calc"`
+	Since       string  `json:"since"` // początek bieżącego okresu cyklu
+	Pieces      int     `json:"pieces"`
+}
+
+// CurrentProduct – stan bieżącego okresu cyklu.
+func CurrentProduct() CurrentProductInfo {
+	calcLock.Lock()
+	defer calcLock.Unlock()
+	info := CurrentProductInfo{
+		SKU:      currentProductSKU,
+		Source:   currentProductSource,
+		Selected: activeProductSKU,
+		CycleLPM: currentCycleValue,
+		Length:   utils.ToFloat(CalculatedData["Dlugosc_calc"]),
+		Width:    utils.ToFloat(CalculatedData["Szerokosc_calc"]),
+		Height:   utils.ToFloat(CalculatedData["Wysokosc_calc"]),
+		Since:    currentCycleStartTime.UTC().Format(time.RFC3339),
+		Pieces:   currentCycleElementCnt,
+	}
+	if currentCycleValue > 0 {
+		info.IdealCycleS = 60.0 / currentCycleValue
+	}
+	if p, ok := ProductBySKU(currentProductSKU); ok {
+		info.Name = p.Name
+	}
+	return info
+}
+
+// StartProductCatalogue wczytuje kopię katalogu z pliku i uruchamia odświeżanie z DB.
+func StartProductCatalogue() {
+	loadCatalogueFile()
+	utils.SuperviseLoop("PRODUCTS refresh", config.ProductsRefreshInterval, func() {
+		_ = refreshProducts()
+	})
+}
+
+// LoadProductCatalogue – jednorazowe wczytanie katalogu bez odświeżania w tle (narzędzia offline):
+// z DB, a bez bazy z kopii w pliku.
+func LoadProductCatalogue() {
+	list, err := LoadProductsFromDB()
+	if err != nil {
+		productsLog.Warn("catalogue not loaded from DB, using file copy", "error", err)
+		loadCatalogueFile()
+		return
+	}
+	setCatalogue(list)
+}
+
+// loadCatalogueFile – katalog z kopii w config.ProductsFilePath (brak pliku = bez zmian).
+func loadCatalogueFile() {
+	raw, err := os.ReadFile(config.ProductsFilePath)
+	if err != nil {
+		return
+	}
+	var list []Product
+	if err := json.Unmarshal(raw, &list); err != nil {
+		productsLog.Warn("catalogue copy unreadable", "file", config.ProductsFilePath, "error", err)
+		return
+	}
+	setCatalogue(list)
+	productsLog.Info("catalogue restored from file", "products", len(list))
+}
+
+// refreshProducts – katalog z DB do pamięci i kopii w pliku; przy błędzie DB zostaje poprzedni.
+func refreshProducts() error {
+	list, err := LoadProductsFromDB()
+	if err != nil {
+		productsLog.Limit("refresh", 10*time.Minute).Warn("catalogue refresh failed, keeping previous", "error", err)
+		return err
+	}
+	if !reflect.DeepEqual(list, Products()) {
+		setCatalogue(list)
+		utils.SaveToJSON(list, config.ProductsFilePath)
+		productsLog.Info("catalogue loaded", "products", len(list))
+	}
+	return nil
+}
+
+const productColumns = `sku, name, material, length_min, length_max, width_min, width_max,
+	height_min, height_max, ideal_cycle_s, target_scrap_pct, active, family, updated_at`
+
+// LoadProductsFromDB – wszystkie pozycje tabeli products.
+func LoadProductsFromDB() ([]Product, error) {
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT ` + productColumns + ` FROM products ORDER BY sku`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Product{}
+	for rows.Next() {
+		var p Product
+		var name, material, family sql.NullString
+		var dims [6]sql.NullFloat64
+		if err := rows.Scan(&p.SKU, &name, &material, &dims[0], &dims[1], &dims[2], &dims[3], &dims[4], &dims[5],
+			&p.IdealCycleS, &p.TargetScrapPct, &p.Active, &family, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		p.Name, p.Material, p.Family = name.String, material.String, family.String
+		ptrs := []**float64{&p.LengthMin, &p.LengthMax, &p.WidthMin, &p.WidthMax, &p.HeightMin, &p.HeightMax}
+		for i, d := range dims {
+			if d.Valid {
+				v := d.Float64
+				*ptrs[i] = &v
+			}
+		}
+		p.UpdatedAt = p.UpdatedAt.UTC()
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// SaveProduct zapisuje (dodaje lub nadpisuje) pozycję katalogu i od razu odświeża katalog.
+func SaveProduct(p Product) (Product, error) {
+	if err := p.Validate(); err != nil {
+		return p, err
+	}
+	db, err := getConnection()
+	if err != nil {
+		return p, err
+	}
+	defer db.Close()
+
+	const q = `
+		INSERT INTO products (` + productColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''), now())
+		ON CONFLICT (sku) DO UPDATE SET
+			name = EXCLUDED.name, material = EXCLUDED.material,
+			length_min = EXCLUDED.length_min, length_max = EXCLUDED.length_max,
+			width_min = EXCLUDED.width_min, width_max = EXCLUDED.width_max,
+			height_min = EXCLUDED.height_min, height_max = EXCLUDED.height_max,
+			ideal_cycle_s = EXCLUDED.ideal_cycle_s, target_scrap_pct = EXCLUDED.target_scrap_pct,
+			active = EXCLUDED.active, family = EXCLUDED.family, updated_at = now()
+		RETURNING updated_at
+	`
+	err = db.QueryRow(q, p.SKU, p.Name, p.Material, p.LengthMin, p.LengthMax, p.WidthMin, p.WidthMax,
+		p.HeightMin, p.HeightMax, p.IdealCycleS, p.TargetScrapPct, p.Active, p.Family).Scan(&p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+	p.UpdatedAt = p.UpdatedAt.UTC()
+	markDBWrite("products")
+	productsLog.Info("product saved", "sku", p.SKU, "ideal_cycle_s", p.IdealCycleS, "active", p.Active)
+	_ = refreshProducts()
+	return p, nil
+}
+
+// DeactivateProduct wyłącza pozycję z dopasowania (wiersz zostaje – nazwy w historii zmian).
+func DeactivateProduct(sku string) error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	res, err := db.Exec(`UPDATE products SET active = FALSE, updated_at = now() WHERE sku = $1`, sku)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrProductNotFound
+	}
+	markDBWrite("products")
+	productsLog.Info("product deactivated", "sku", sku)
+	_ = refreshProducts()
+	return nil
+}
+
+// ErrProductNotFound – brak SKU w tabeli products.
+var ErrProductNotFound = errors.New("product not found")
@@ -0,0 +1,327 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"math"
+	"strings"
+	"time"
+)
+
+// --- Przeliczenie historii OEE (cmd/recompute) ---
+//
+// Zmiana jest odtwarzana z nagrania rejestratora MQTT przez bieżący silnik OEE (bieżące tuning.*
+// i katalog produktów): ramka na każdą wiadomość i co sekundę przerwy – jak pętla „MQTT + OEE” –
+// od granicy do granicy zmiany. Wynik to część podsumowania liczona przez silnik (jak
+// executeShiftSummary) i próbki oee_temp co config.OEEUpdateInterval z pauzami rozliczonymi jak
+// w pracy na żywo (oee_temp.go). Energia i powietrze nie są przeliczane; W_na_szt i M3_na_szt
+// są przeskalowane do nowej liczby sztuk.
+// ApplyRecomputedShift zapisuje poprzedni wiersz shift_summary z produktami, stratami i postojami
+// do shift_summary_revision, nadpisuje je w miejscu i zastępuje próbki oee_temp zmiany.
+
+// ShiftRange – jedna zmiana [Start, End).
+type ShiftRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ShiftRanges – zmiany wg harmonogramu (shiftTimes, Europe/Warsaw) w całości w [from, to).
+func ShiftRanges(from, to time.Time) []ShiftRange {
+	loc := teepLocation()
+	var out []ShiftRange
+	start := findNextShiftTimeUTC(from.Add(-time.Nanosecond).In(loc), loc)
+	for {
+		end := findNextShiftTimeUTC(start.In(loc), loc)
+		if end.After(to) {
+			return out
+		}
+		out = append(out, ShiftRange{Start: start, End: end})
+		start = end
+	}
+}
+
+// recomputeHeartbeat – przeliczenie silnika w przerwie między wiadomościami.
+const recomputeHeartbeat = time.Second
+
+// ShiftRecompute – przebieg jednej zmiany: NewShiftRecompute, Step dla każdej wiadomości
+// z [Start, End) w kolejności czasu, na końcu Finish. Trzyma silnik (Replayer) do Finish.
+type ShiftRecompute struct {
+	ShiftRange
+	r          *Replayer
+	last       ReplayFrame
+	nextSample time.Time
+	messages   int
+	maxGap     time.Duration
+}
+
+// RecomputedShift – wynik przeliczenia zmiany.
+type RecomputedShift struct {
+	ShiftRange
+	Summary  Summary // bez energii, powietrza i analizatorów
+	Samples  []OeeTempSample
+	Messages int
+	MaxGap   time.Duration // najdłuższa przerwa w nagraniu (także od początku i do końca zmiany)
+}
+
+// NewShiftRecompute resetuje silnik na początek zmiany; ports – stan portów MQTT w chwili Start,
+// inputs – wejścia nagrania sprzed Start w kolejności (np. zlecenie trwające przez granicę zmiany).
+func NewShiftRecompute(sr ShiftRange, ports map[string]map[string]interface{}, inputs []ReplayInput) *ShiftRecompute {
+	s := &ShiftRecompute{ShiftRange: sr, r: NewReplayer(sr.Start, ReplayOptions{}), nextSample: sr.Start}
+	s.step(ReplayFrame{Timestamp: sr.Start, Ports: ports, Inputs: inputs})
+	return s
+}
+
+// Step – wiadomość z czasem fr.Timestamp i stanem portów po jej obsłużeniu.
+func (s *ShiftRecompute) Step(fr ReplayFrame) {
+	if fr.Timestamp.Before(s.last.Timestamp) {
+		fr.Timestamp = s.last.Timestamp // nagranie nie zawsze jest ściśle rosnące
+	}
+	s.messages++
+	s.advance(fr.Timestamp)
+	s.step(fr)
+}
+
+// Finish – stan na koniec zmiany i podsumowanie; zwalnia silnik.
+func (s *ShiftRecompute) Finish() RecomputedShift {
+	defer s.r.Close()
+	s.advance(s.End)
+	s.step(ReplayFrame{Timestamp: s.End, Ports: s.last.Ports})
+
+	calcLock.Lock()
+	settlePauseSamples() // pauza trwająca na końcu zmiany = postój, jak przy resecie zmiany
+	samples := takeOeeTempLocked()
+	calcLock.Unlock()
+
+	res := RecomputedShift{ShiftRange: s.ShiftRange, Samples: samples, Messages: s.messages, MaxGap: s.maxGap}
+	var oee map[string]interface{}
+	decodeSection(oeeFlatMap(BuildOeeFlat()), &oee)
+	res.Summary = Summary{
+		DataUtworzenia: s.End.Format(time.RFC3339Nano),
+		StartZmiany:    s.Start.Format(time.RFC3339Nano),
+		KoniecZmiany:   s.End.Format(time.RFC3339Nano),
+		Products:       map[string]ProductShift{},
+	}
+	fillEngineSummary(&res.Summary, oee)
+	return res
+}
+
+// advance – przeliczenia co recomputeHeartbeat z ostatnim stanem portów aż do t.
+func (s *ShiftRecompute) advance(t time.Time) {
+	if gap := t.Sub(s.last.Timestamp); gap > s.maxGap {
+		s.maxGap = gap
+	}
+	for hb := s.last.Timestamp.Add(recomputeHeartbeat); hb.Before(t); hb = hb.Add(recomputeHeartbeat) {
+		s.step(ReplayFrame{Timestamp: hb, Ports: s.last.Ports})
+	}
+}
+
+func (s *ShiftRecompute) step(fr ReplayFrame) {
+	s.r.Step(fr)
+	s.last = fr
+	if fr.Timestamp.Before(s.nextSample) || !fr.Timestamp.Before(s.End) {
+		return
+	}
+	calcLock.Lock()
+	sampleOeeTempLocked(fr.Timestamp)
+	calcLock.Unlock()
+	for !s.nextSample.After(fr.Timestamp) {
+		s.nextSample = s.nextSample.Add(config.OEEUpdateInterval)
+	}
+}
+
+// RecomputeColumns – kolumny shift_summary nadpisywane przeliczeniem (kolejność wydruku różnic).
+var RecomputeColumns = []string{
+	"czas_pomiaru", "czas_pracy", "czas_postoju", "czas_przezbrojenia", "ilosc_elementow",
+	"dostepnosc", "wydajnosc", "jakosc", "oee", "w_na_szt", "m3_na_szt",
+	"mikroprzestoje", "czas_mikroprzestojow",
+	"predkosc_czas", "predkosc_srednia", "predkosc_min", "predkosc_max", "predkosc_nominalna",
+	"czas_ponizej_nominalnej", "strata_predkosci",
+	"liczba_postojow", "liczba_awarii", "najdluzszy_postoj", "mtbf", "mttr",
+}
+
+// StoredShift – wiersz shift_summary zmiany.
+type StoredShift struct {
+	DataUtworzenia time.Time
+	StartZmiany    time.Time
+	KoniecZmiany   time.Time
+	Values         map[string]float64 // RecomputeColumns; NULL = brak klucza
+}
+
+// LoadStoredShift – wiersz shift_summary zmiany kończącej się w end (±1 min); nil = brak.
+func LoadStoredShift(end time.Time) (*StoredShift, error) {
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	row := db.QueryRow(`
+		SELECT data_utworzenia, start_zmiany, koniec_zmiany, `+strings.Join(RecomputeColumns, ", ")+`
+		FROM shift_summary
+		WHERE koniec_zmiany BETWEEN $1::timestamptz - interval '1 minute' AND $1::timestamptz + interval '1 minute'
+		ORDER BY abs(extract(epoch FROM koniec_zmiany - $1::timestamptz)), data_utworzenia
+		LIMIT 1`, end)
+
+	st := &StoredShift{Values: map[string]float64{}}
+	vals := make([]sql.NullFloat64, len(RecomputeColumns))
+	dest := []interface{}{&st.DataUtworzenia, &st.StartZmiany, &st.KoniecZmiany}
+	for i := range vals {
+		dest = append(dest, &vals[i])
+	}
+	if err := row.Scan(dest...); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for i, c := range RecomputeColumns {
+		if vals[i].Valid {
+			st.Values[c] = vals[i].Float64
+		}
+	}
+	return st, nil
+}
+
+// RecomputedValues – nowe wartości RecomputeColumns; energia i powietrze na sztukę przeskalowane
+// z wiersza stored do nowej liczby sztuk.
+func RecomputedValues(rs RecomputedShift, stored *StoredShift) map[string]float64 {
+	s := rs.Summary
+	v := map[string]float64{
+		"czas_pomiaru":            s.OEE.CzasPomiaru,
+		"czas_pracy":              s.OEE.CzasPracy,
+		"czas_postoju":            s.OEE.CzasPostoju,
+		"czas_przezbrojenia":      s.OEE.CzasPrzezbrojenia,
+		"ilosc_elementow":         float64(s.OEE.IloscElementow),
+		"dostepnosc":              s.OEE.Dostepnosc,
+		"wydajnosc":               s.OEE.Wydajnosc,
+		"jakosc":                  s.OEE.Jakosc,
+		"oee":                     s.OEE.OEE,
+		"mikroprzestoje":          float64(s.OEE.Mikroprzestoje),
+		"czas_mikroprzestojow":    s.OEE.CzasMikroprzestojow,
+		"predkosc_czas":           s.Speed.Shift.Seconds,
+		"predkosc_srednia":        s.Speed.Shift.MeanRPM,
+		"predkosc_min":            s.Speed.Shift.MinRPM,
+		"predkosc_max":            s.Speed.Shift.MaxRPM,
+		"predkosc_nominalna":      s.Speed.Shift.NominalRPM,
+		"czas_ponizej_nominalnej": s.Speed.Shift.BelowNominalSeconds,
+		"strata_predkosci":        s.Speed.Shift.SpeedLossSeconds,
+		"liczba_postojow":         float64(s.Reliability.Stops),
+		"liczba_awarii":           float64(s.Reliability.Breakdowns),
+		"najdluzszy_postoj":       s.Reliability.LongestStop,
+		"mtbf":                    s.Reliability.MTBF,
+		"mttr":                    s.Reliability.MTTR,
+	}
+	for _, c := range []string{"w_na_szt", "m3_na_szt"} {
+		v[c] = 0
+		if stored != nil && v["ilosc_elementow"] > 0 {
+			v[c] = stored.Values[c] * stored.Values["ilosc_elementow"] / v["ilosc_elementow"]
+		}
+	}
+	return v
+}
+
+// ColumnChange – różnica jednej kolumny (Old nil = NULL w bazie).
+type ColumnChange struct {
+	Column string
+	Old    *float64
+	New    float64
+}
+
+// DiffShift – kolumny, w których nowa wartość różni się od zapisanej (REAL: tolerancja względna 1e-4).
+func DiffShift(stored *StoredShift, values map[string]float64) []ColumnChange {
+	var out []ColumnChange
+	for _, c := range RecomputeColumns {
+		nv := values[c]
+		old, ok := stored.Values[c]
+		if ok && math.Abs(old-nv) <= 1e-4*math.Max(1, math.Abs(old)) {
+			continue
+		}
+		ch := ColumnChange{Column: c, New: nv}
+		if ok {
+			ch.Old = &old
+		}
+		out = append(out, ch)
+	}
+	return out
+}
+
+// ApplyRecomputedShift – w jednej transakcji: poprzedni stan zmiany do shift_summary_revision,
+// nowe wartości w shift_summary, nowe wiersze produktów, strat i postojów, próbki oee_temp zmiany.
+func ApplyRecomputedShift(stored *StoredShift, rs RecomputedShift, values map[string]float64, reason string) error {
+	db, err := getConnection()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	newValues, err := json.Marshal(map[string]interface{}{
+		"shift_summary": values,
+		"products":      rs.Summary.Products,
+		"losses":        rs.Summary.Losses,
+		"stops":         rs.Summary.Stops,
+	})
+	if err != nil {
+		return err
+	}
+	var details map[string]any
+	decodeSection(rs.Summary, &details)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	key := stored.DataUtworzenia
+	if _, err := tx.Exec(`
+		INSERT INTO shift_summary_revision (data_utworzenia, revised_at, start_zmiany, reason, old_values, new_values)
+		SELECT s.data_utworzenia, now(), s.start_zmiany, $2, jsonb_build_object(
+			'shift_summary', to_jsonb(s),
+			'products', (SELECT COALESCE(jsonb_agg(to_jsonb(p)), '[]'::jsonb) FROM shift_summary_product p WHERE p.data_utworzenia = s.data_utworzenia),
+			'losses', (SELECT COALESCE(jsonb_agg(to_jsonb(l)), '[]'::jsonb) FROM shift_summary_loss l WHERE l.data_utworzenia = s.data_utworzenia),
+			'stops', (SELECT COALESCE(jsonb_agg(to_jsonb(e)), '[]'::jsonb) FROM stop_events e WHERE e.data_utworzenia = s.data_utworzenia)
+		), $3::jsonb
+		FROM shift_summary s
+		WHERE s.data_utworzenia = $1`, key, reason, string(newValues)); err != nil {
+		return fmt.Errorf("shift_summary_revision: %w", err)
+	}
+
+	set := make([]string, len(RecomputeColumns))
+	args := []interface{}{key}
+	for i, c := range RecomputeColumns {
+		set[i] = fmt.Sprintf("%s = $%d", c, i+2)
+		if c == "ilosc_elementow" || c == "mikroprzestoje" || c == "liczba_postojow" || c == "liczba_awarii" {
+			args = append(args, int(values[c]))
+		} else {
+			args = append(args, values[c])
+		}
+	}
+	if _, err := tx.Exec("UPDATE shift_summary SET "+strings.Join(set, ", ")+" WHERE data_utworzenia = $1", args...); err != nil {
+		return fmt.Errorf("shift_summary: %w", err)
+	}
+
+	for _, table := range []string{"shift_summary_product", "shift_summary_loss", "stop_events"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE data_utworzenia = $1", key); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	if err := insertShiftDetails(tx, key, stored.StartZmiany, details); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM oee_temp WHERE timestamp >= $1 AND timestamp < $2`, rs.Start, rs.End); err != nil {
+		return fmt.Errorf("oee_temp: %w", err)
+	}
+	for _, sm := range rs.Samples {
+		if err := insertOeeTemp(tx, sm); err != nil {
+			return fmt.Errorf("oee_temp: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	markDBWrite("shift_summary", "shift_summary_revision", "shift_summary_product", "shift_summary_loss", "stop_events", "oee_temp")
+	return nil
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"math"
+	"time"
+)
+
+// --- Niezawodność: MTBF / MTTR ---
+//
+// Każdy zakończony postój (pauza bez przezbrojenia, także zbyt długie przezbrojenie) trafia do
+// historii postojów zmiany – sekcja stops w oee.json / summary.json i tabela stop_events. Z liczników
+// zmiany (trwający postój liczony wg dotychczasowej długości):
+//   - MTBF = czas pracy / liczba postojów,
+//   - MTTR = czas postojów / liczba postojów,
+//   - najdłuższy postój i liczba awarii (postoje >= tuning.breakdown_threshold_seconds).
+// Bez postojów MTBF i MTTR = 0. Dzień i tydzień: agregacja wierszy shift_summary (LoadReliabilityFromDB).
+
+// maxStopEvents – limit historii postojów zmiany (liczniki liczone dalej).
+const maxStopEvents = 1000
+
+// StopEvent – jeden postój zmiany.
+type StopEvent struct {
+	Start     time.Time  `json:"start"`
+	End       *time.Time `json:"end,omitempty"` // nil = postój trwa
+	Seconds   float64    `json:"seconds"`
+	Breakdown bool       `json:"breakdown"`
+}
+
+// Reliability – wskaźniki niezawodności zmiany, dnia albo tygodnia.
+type Reliability struct {
+	Stops            int     `json:"stops"`
+	Breakdowns       int     `json:"breakdowns"`
+	StopSeconds      float64 `json:"stop_seconds"`
+	LongestStop      float64 `json:"longest_stop_seconds"`
+	OperatingSeconds float64 `json:"operating_seconds"` // czas pracy
+	MTBF             float64 `json:"mtbf_seconds"`
+	MTTR             float64 `json:"mttr_seconds"`
+}
+
+// newReliability – wskaźniki z liczników (zaokrąglone do 0,01 s).
+func newReliability(stops, breakdowns int, stopSeconds, longest, operating float64) Reliability {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	r := Reliability{Stops: stops, Breakdowns: breakdowns, StopSeconds: round(stopSeconds),
+		LongestStop: round(longest), OperatingSeconds: round(operating)}
+	if stops > 0 {
+		r.MTBF = round(operating / float64(stops))
+		r.MTTR = round(stopSeconds / float64(stops))
+	}
+	return r
+}
+
+// StopInternal – liczniki postojów w oee.json (sekcja internal).
+type StopInternal struct {
+	Count      int     `json:"count"`
+	Breakdowns int     `json:"breakdowns"`
+	Seconds    float64 `json:"seconds"`
+	Longest    float64 `json:"longest"`
+}
+
+var (
+	stopCounters StopInternal // zakończone postoje zmiany; chronione calcLock
+	stopEvents   []StopEvent
+)
+
+// recordStop – zakończony postój dur [s] (wołane z classifyStop).
+func recordStop(dur float64, end time.Time, breakdown bool) {
+	stopCounters.Count++
+	stopCounters.Seconds += dur
+	stopCounters.Longest = math.Max(stopCounters.Longest, dur)
+	if breakdown {
+		stopCounters.Breakdowns++
+	}
+	if len(stopEvents) < maxStopEvents {
+		e := end.UTC()
+		stopEvents = append(stopEvents, StopEvent{Start: e.Add(-time.Duration(dur * float64(time.Second))),
+			End: &e, Seconds: math.Round(dur*100) / 100, Breakdown: breakdown})
+	}
+}
+
+// currentStopsLocked – historia postojów z trwającym postojem (wymaga calcLock).
+func currentStopsLocked() []StopEvent {
+	out := append([]StopEvent{}, stopEvents...)
+	if open := openStopSecondsLocked(); open > 0 {
+		start := CzasPomiarowy.StartMeasurement
+		if CzasPomiarowy.PauseStartTime != nil {
+			start = *CzasPomiarowy.PauseStartTime
+		}
+		out = append(out, StopEvent{Start: start.UTC(), Seconds: math.Round(open*100) / 100,
+			Breakdown: open >= config.CurrentTuning().BreakdownThresholdSeconds})
+	}
+	return out
+}
+
+// currentReliabilityLocked – MTBF/MTTR bieżącej zmiany (wymaga calcLock).
+func currentReliabilityLocked() Reliability {
+	c := stopCounters
+	if open := openStopSecondsLocked(); open > 0 {
+		c.Count++
+		c.Seconds += open
+		c.Longest = math.Max(c.Longest, open)
+		if open >= config.CurrentTuning().BreakdownThresholdSeconds {
+			c.Breakdowns++
+		}
+	}
+	return newReliability(c.Count, c.Breakdowns, c.Seconds, c.Longest, utils.ToFloat(CalculatedData["czas_pracy"]))
+}
+
+// loadStops – liczniki (internal.stops) i historia (stops) z oee.json; wymaga calcLock.
+func loadStops(data map[string]interface{}) {
+	resetStops()
+	if in, ok := data["internal"].(map[string]interface{}); ok {
+		decodeSection(in["stops"], &stopCounters)
+	}
+	var events []StopEvent
+	decodeSection(data["stops"], &events)
+	for _, e := range events {
+		if e.End != nil && len(stopEvents) < maxStopEvents {
+			stopEvents = append(stopEvents, e)
+		}
+	}
+}
+
+// resetStops – nowa zmiana; wymaga calcLock.
+func resetStops() {
+	stopCounters = StopInternal{}
+	stopEvents = nil
+}
+
+// ShiftReliability – niezawodność bieżącej zmiany z historią postojów (API).
+type ShiftReliability struct {
+	StartZmiany time.Time   `json:"start_zmiany"`
+	Reliability Reliability `json:"reliability"`
+	Stops       []StopEvent `json:"stops"`
+}
+
+// CurrentShiftReliability – MTBF/MTTR i postoje bieżącej zmiany (od ostatniego resetu OEE).
+func CurrentShiftReliability() ShiftReliability {
+	calcLock.Lock()
+	defer calcLock.Unlock()
+	return ShiftReliability{StartZmiany: CzasPomiarowy.StartMeasurement.UTC(),
+		Reliability: currentReliabilityLocked(), Stops: currentStopsLocked()}
+}
+
+// PeriodReliability – niezawodność zmiany, dnia albo tygodnia z tabeli shift_summary.
+type PeriodReliability struct {
+	Start  time.Time `json:"start"` // początek zmiany / dnia / tygodnia (Europe/Warsaw)
+	Shifts int       `json:"shifts"`
+	Reliability
+}
+
+// ReliabilityPeriods – dozwolone agregacje LoadReliabilityFromDB.
+var ReliabilityPeriods = []string{"shift", "day", "week"}
+
+// LoadReliabilityFromDB – MTBF/MTTR zmian rozpoczętych w [from, to), pogrupowanych wg period
+// (shift | day | week; granice dnia i tygodnia jak harmonogram zmian – Europe/Warsaw, tydzień od
+// poniedziałku). Najnowsze najpierw.
+func LoadReliabilityFromDB(period string, from, to time.Time) ([]PeriodReliability, error) {
+	group := "start_zmiany"
+	switch period {
+	case "shift":
+	case "day", "week":
+		group = fmt.Sprintf("date_trunc('%s', start_zmiany AT TIME ZONE 'Europe/Warsaw') AT TIME ZONE 'Europe/Warsaw'", period)
+	default:
+		return nil, fmt.Errorf("unknown period %q", period)
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT `+group+` AS p, count(*),
+			COALESCE(sum(liczba_postojow), 0), COALESCE(sum(liczba_awarii), 0),
+			COALESCE(sum(czas_postoju), 0), COALESCE(max(najdluzszy_postoj), 0), COALESCE(sum(czas_pracy), 0)
+		FROM shift_summary
+		WHERE start_zmiany >= $1 AND start_zmiany < $2 AND liczba_postojow IS NOT NULL
+		GROUP BY p
+		ORDER BY p DESC`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []PeriodReliability{}
+	for rows.Next() {
+		var start time.Time
+		var shifts, stops, breakdowns int
+		var stopSeconds, longest, operating float64
+		if err := rows.Scan(&start, &shifts, &stops, &breakdowns, &stopSeconds, &longest, &operating); err != nil {
+			return nil, err
+		}
+		out = append(out, PeriodReliability{Start: start.UTC(), Shifts: shifts,
+			Reliability: newReliability(stops, breakdowns, stopSeconds, longest, operating)})
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"go_app/config"
+	"sync"
+	"time"
+)
+
+// ReplayFrame – pojedynczy snapshot portów MQTT (jak z GetMQTTData) z czasem odczytu
+// i wejściami (zlecenia), które przyszły od poprzedniej ramki (inputs.go).
+type ReplayFrame struct {
+	Timestamp time.Time                         `json:"timestamp"`
+	Ports     map[string]map[string]interface{} `json:"ports"`
+	Inputs    []ReplayInput                     `json:"inputs,omitempty"`
+}
+
+// ReplayOptions – parametry przebiegu replay.
+type ReplayOptions struct {
+	// TempInterval – okres symulowanych updaterów *_temp (domyślnie TempUpdaterInterval).
+	TempInterval time.Duration
+	// Every – co ile ramek zapisać stan do osi czasu (domyślnie każda ramka).
+	// Ostatnia ramka trafia do wyniku zawsze.
+	Every int
+}
+
+var replayLock sync.Mutex
+
+// ReplayFrames przepuszcza nagraną sekwencję ramek przez silnik OEE na ręcznym zegarze
+// i zwraca oś czasu OeeFileFlat. Działa na globalnym stanie silnika, więc nie wolno
+// jej wołać w procesie, w którym pracuje pętla „MQTT + OEE” (narzędzia offline, replay, testy).
+// Updatery *_temp są wywoływane synchronicznie co TempInterval czasu ramek, bez gorutyn i bez DB.
+func ReplayFrames(frames []ReplayFrame, opts ReplayOptions) []OeeFileFlat {
+	if len(frames) == 0 {
+		return nil
+	}
+	if opts.Every <= 0 {
+		opts.Every = 1
+	}
+
+	r := NewReplayer(frames[0].Timestamp, opts)
+	defer r.Close()
+
+	timeline := make([]OeeFileFlat, 0, len(frames)/opts.Every+1)
+	for i, fr := range frames {
+		r.Step(fr)
+		if i%opts.Every == 0 || i == len(frames)-1 {
+			timeline = append(timeline, BuildOeeFlat())
+		}
+	}
+	return timeline
+}
+
+// Replayer – przebieg silnika OEE ramka po ramce (długie nagrania bez trzymania ramek w pamięci).
+// Od NewReplayer do Close trzyma replayLock, ręczny zegar i wyłączone tło – jak ReplayFrames.
+type Replayer struct {
+	clk            *ManualClock
+	interval       time.Duration
+	nextTick       time.Time
+	prevClock      Clock
+	prevBackground bool
+}
+
+// NewReplayer resetuje silnik z początkiem pomiaru start.
+func NewReplayer(start time.Time, opts ReplayOptions) *Replayer {
+	if opts.TempInterval <= 0 {
+		opts.TempInterval = TempUpdaterInterval
+	}
+	replayLock.Lock()
+
+	start = start.UTC()
+	r := &Replayer{clk: NewManualClock(start), interval: opts.TempInterval, nextTick: start.Add(opts.TempInterval)}
+	r.prevClock = SetClock(r.clk)
+	r.prevBackground = backgroundDisabled.Swap(true)
+	resetEngineForReplay()
+	return r
+}
+
+// Step – jedno przeliczenie silnika dla ramki; ramki muszą przychodzić w kolejności czasu.
+func (r *Replayer) Step(fr ReplayFrame) {
+	ts := fr.Timestamp.UTC()
+
+	// updatery *_temp „tykają” zanim silnik zobaczy ramkę z późniejszym czasem
+	for !ts.Before(r.nextTick) {
+		r.clk.Set(r.nextTick)
+		stepTempUpdaters(r.interval)
+		r.nextTick = r.nextTick.Add(r.interval)
+	}
+
+	r.clk.Set(ts)
+	for _, in := range fr.Inputs {
+		if err := ApplyInput(in); err != nil {
+			inputsLog.Warn("input rejected", "topic", in.Topic, "time", ts, "error", err)
+		}
+	}
+	CalculateData(fr.Ports)
+}
+
+// Close przywraca zegar i tło sprzed NewReplayer.
+func (r *Replayer) Close() {
+	backgroundDisabled.Store(r.prevBackground)
+	SetClock(r.prevClock)
+	replayLock.Unlock()
+}
+
+// stepTempUpdaters – odpowiednik jednego tyknięcia gorutyn Start*TempUpdater.
+// Źródłem jest stan w pamięci zamiast oee.json.
+func stepTempUpdaters(interval time.Duration) {
+	data := GetCalculatedData()
+	if cycleJustChanged.Load() {
+		cycleJustChanged.Store(false)
+	} else {
+		stepWydajnoscTemp(data, interval)
+	}
+	stepDostepnoscTemp(data)
+}
+
+// resetEngineForReplay – pełny reset stanu silnika (również pól, których ResetOeeState
+// nie rusza między zmianami), tak żeby kolejne przebiegi były od siebie niezależne.
+func resetEngineForReplay() {
+	ResetOeeState()
+
+	pendingOrdersLock.Lock()
+	pendingOrders = nil
+	pendingOrdersLock.Unlock()
+
+	calcLock.Lock()
+	defer calcLock.Unlock()
+
+	now := nowUTC()
+	clearMachineEvents()
+	pendingOeeTemp = nil
+	clearChangeover()
+	currentOrder, orderSetProduct, orderLastValid = nil, false, false
+	activeProductSKU = ""
+	lastImpulse = now
+	prevSignal = false
+	lastCycle = 0.0
+	lastPauseStart, lastPauseEnd = time.Time{}, time.Time{}
+	shouldStoreToDB = false
+	cycleJustChanged.Store(false)
+	CzasPomiarowy.PauseStartTotal = 0
+	CzasPomiarowy.PauseStartChangeoverTemp = 0
+
+	CalculatedData["cykl"] = config.CurrentTuning().ProductionCycleDefault
+	CalculatedData["Predkosc_obrotnica"] = 0.0
+	CalculatedData["status_maszyny"] = false
+	CalculatedData["status_pracy"] = false
+	CalculatedData["Dlugosc_calc"] = 0.0
+	CalculatedData["Szerokosc_calc"] = 0.0
+	CalculatedData["Wysokosc_calc"] = 0.0
+	CalculatedData["dostepnosc_temp"] = 100.0
+	CalculatedData["wydajnosc_temp"] = 100.0
+	CalculatedData["jakosc_temp"] = 100.0
+	CalculatedData["oee_temp"] = 100.0
+	CalculatedData["elements_used"] = 0
+	CalculatedData["energyBaseline_internal"] = 0.0
+	CalculatedData["airBaseline_internal"] = 0.0
+	delete(CalculatedData, "helpers_air")
+	delete(CalculatedData, "helpers_energy")
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// GoldenTolerance – dopuszczalna różnica liczb przy porównaniu osi czasu z plikiem golden.
+const GoldenTolerance = 1e-6
+
+// LoadReplayFrames czyta ramki jako tablicę JSON albo JSON lines (jedna ramka na linię),
+// posortowane po czasie.
+func LoadReplayFrames(path string) ([]ReplayFrame, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var frames []ReplayFrame
+		if err := json.Unmarshal(trimmed, &frames); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return frames, nil
+	}
+
+	var frames []ReplayFrame
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var fr ReplayFrame
+		if err := json.Unmarshal(b, &fr); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if fr.Timestamp.IsZero() {
+			return nil, fmt.Errorf("%s:%d: missing timestamp", path, line)
+		}
+		frames = append(frames, fr)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(frames, func(i, j int) bool {
+		return frames[i].Timestamp.Before(frames[j].Timestamp)
+	})
+	return frames, nil
+}
+
+// DiffGolden porównuje oś czasu (JSON) z plikiem golden; liczby z tolerancją GoldenTolerance.
+// Zwraca różnice jako „ścieżka: want …, got …”.
+func DiffGolden(want, got []byte) ([]string, error) {
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(want, &w); err != nil {
+		return nil, fmt.Errorf("golden: %w", err)
+	}
+	var diffs []string
+	diffJSON("$", w, g, &diffs)
+	return diffs, nil
+}
+
+// diffJSON porównuje zdekodowane drzewa JSON.
+func diffJSON(path string, want, got interface{}, diffs *[]string) {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want object, got %T", path, got))
+			return
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		seen := map[string]bool{}
+		for k := range w {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+		for k := range g {
+			if !seen[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			diffJSON(path+"."+k, w[k], g[k], diffs)
+		}
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want array, got %T", path, got))
+			return
+		}
+		if len(w) != len(g) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want %d items, got %d", path, len(w), len(g)))
+		}
+		for i := 0; i < len(w) && i < len(g); i++ {
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], diffs)
+		}
+	case float64:
+		g, ok := got.(float64)
+		if !ok || math.Abs(w-g) > GoldenTolerance {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want %v, got %v", path, want, got))
+		}
+	default:
+		if fmt.Sprint(want) != fmt.Sprint(got) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: want %v, got %v", path, want, got))
+		}
+	}
+}
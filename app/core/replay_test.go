@@ -0,0 +1,59 @@
+package core
+
+import (
+	"encoding/json"
+	"go_app/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain – log systemowy testów poza drzewem repozytorium.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "core-test")
+	if err != nil {
+		panic(err)
+	}
+	config.SystemLogPath = filepath.Join(dir, "system.log")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// TestReplayGolden – każdy scenariusz testdata/replay/*.jsonl przez silnik OEE wobec pliku
+// .golden.json (jak go run ./cmd/replay -check core/testdata/replay; -update przepisuje pliki).
+func TestReplayGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "replay", "*.jsonl"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no replay scenarios in testdata/replay (%v)", err)
+	}
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".jsonl")
+		t.Run(name, func(t *testing.T) {
+			frames, err := LoadReplayFrames(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(ReplayFrames(frames, ReplayOptions{Every: 20}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(f, ".jsonl") + ".golden.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			diffs, err := DiffGolden(want, got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, d := range diffs {
+				if i == 20 {
+					t.Errorf("... and %d more", len(diffs)-i)
+					break
+				}
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"database/sql"
+	"go_app/db"
+	"go_app/utils"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// --- Tabele i kolumny dodane po pierwszej wersji schematu ---
+//
+// Istniejąca baza nie dostaje nowych tabel ani kolumn ze skryptów init, a bez nich zapis
+// podsumowania zmiany się nie uda. Program stosuje więc db.Migrations (CREATE TABLE IF NOT
+// EXISTS, ALTER TABLE ... ADD COLUMN IF NOT EXISTS) przy pierwszym połączeniu z bazą; po błędzie
+// (baza niedostępna, brak uprawnień) ponawia najwyżej co schemaRetryInterval.
+
+const schemaRetryInterval = time.Minute
+
+var (
+	schemaReady   atomic.Bool
+	schemaMu      sync.Mutex
+	schemaLastTry time.Time
+	schemaLog     = utils.NewLogger("SCHEMA")
+)
+
+// ensureSchema – migracje db.Migrations raz na proces.
+func ensureSchema(conn *sql.DB) {
+	if schemaReady.Load() {
+		return
+	}
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+	if schemaReady.Load() || time.Since(schemaLastTry) < schemaRetryInterval {
+		return
+	}
+	schemaLastTry = time.Now()
+	for _, name := range db.Migrations {
+		script, err := db.Script(name)
+		if err == nil {
+			_, err = conn.Exec(script)
+		}
+		if err != nil {
+			schemaLog.Warn("schema migration failed, retrying later", "script", name, "error", err)
+			return
+		}
+	}
+	schemaReady.Store(true)
+	schemaLog.Info("schema up to date", "migrations", len(db.Migrations))
+}
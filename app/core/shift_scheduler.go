@@ -1,9 +1,11 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"go_app/config"
 	"go_app/utils"
+	"math"
 	"strconv"
 	"sync"
 	"time"
@@ -50,7 +52,11 @@ type Summary struct {
 	StartZmiany      string                        `json:"start_zmiany"`
 	KoniecZmiany     string                        `json:"koniec_zmiany"`
 	OEE              OeeSectionSummary             `json:"oee"`
-	ElementsPerCycle map[string]int                `json:"elements_per_cycle"`
+	Products         map[string]ProductShift       `json:"products"`
+	Losses           SixBigLosses                  `json:"losses"`
+	Speed            SpeedSummary                  `json:"speed"`
+	Reliability      Reliability                   `json:"reliability"`
+	Stops            []StopEvent                   `json:"stops"`
 	Energy           EnergySection                 `json:"energy"`
 	Totaliser        TotaliserSection              `json:"totaliser"`
 	Analizator       map[string]map[string]float64 `json:"analizator"`
@@ -68,6 +74,20 @@ type OeeSectionSummary struct {
 	OEE               float64 `json:"oee"`
 	W_NaSzt            float64 `json:"W_na_szt"`
     M3_NaSzt           float64 `json:"M3_na_szt"`
+	Mikroprzestoje      int     `json:"mikroprzestoje"`
+	CzasMikroprzestojow float64 `json:"czas_mikroprzestojow"`
+}
+
+// ProductShift – produkcja zmiany dla jednego produktu (SKU z katalogu albo "cyklN").
+type ProductShift struct {
+	Name           string  `json:"name,omitempty"`
+	Pieces         int     `json:"pieces"`
+	WorkSeconds    float64 `json:"work_seconds"`
+	IdealCycleS    float64 `json:"ideal_cycle_s"`   // średni ważony czasem pracy
+	ExpectedPieces float64 `json:"expected_pieces"` // czas pracy / cykl idealny
+	Wydajnosc      float64 `json:"wydajnosc"`       // pieces / expected_pieces
+	MicroStops       int     `json:"micro_stops"`
+	MicroStopSeconds float64 `json:"micro_stop_seconds"`
 }
 
 type TotaliserSection struct {
@@ -86,9 +106,10 @@ type EnergySection struct {
 	WhNaSzt     float64            `json:"W_na_szt"`
 }
 
-// StartShiftScheduler – pętla granic zmian
+// StartShiftScheduler – pętla granic zmian (i ponawianie niezapisanych podsumowań)
 func StartShiftScheduler() {
-	utils.Go("SHIFT Scheduler", func() {
+	startShiftSummaryRetry()
+	utils.Supervise("SHIFT Scheduler", func(ctx context.Context) {
 		// Używaj stałej strefy PL niezależnie od ustawień kontenera
 		loc, err := time.LoadLocation("Europe/Warsaw")
 		if err != nil {
@@ -96,7 +117,7 @@ func StartShiftScheduler() {
 			utils.LogMessage("[SHIFT] using time.Local (failed to load Europe/Warsaw): " + err.Error())
 		}
 
-		for {
+		for ctx.Err() == nil {
 			func() {
 				defer utils.Catch("SHIFT iteration")()
 
@@ -116,15 +137,15 @@ func StartShiftScheduler() {
 				} else if until > 26*time.Hour { // ochronnie przy zaburzeniach zegara/DST
 					until = 26 * time.Hour
 				}
-				time.Sleep(until)
-
-				// Domknięcie poprzedniej zmiany
-				if err := executeShiftSummary(prevStartUTC, prevEndUTC, true); err != nil {
-					utils.LogMessage("[SHIFT] Summary write FAILED, OEE NOT reset: " + err.Error())
-				} else {
-					ResetOeeStateAndFile(config.OeeFilePath)
+				utils.Heartbeat(ctx)
+				if !utils.Sleep(ctx, until) {
+					return // zamykanie programu – zmianę domknie kolejne uruchomienie
 				}
 
+				// Domknięcie poprzedniej zmiany (niezapisane w DB czeka w kolejce ponowień)
+				executeShiftSummary(prevStartUTC, prevEndUTC, true)
+				ResetOeeStateAndFile(config.OeeFilePath)
+
 				// Baseline’y na nową zmianę
 				setTotaliserBaselines()
 				setEnergyBaselines()
@@ -154,8 +175,8 @@ func findNextShiftTimeUTC(nowLocal time.Time, loc *time.Location) time.Time {
 	return nextLocal.UTC()
 }
 
-// executeShiftSummary: zapisuje podsumowanie poprzedniej zmiany (JSON + DB).
-func executeShiftSummary(startUTC, endUTC time.Time, isShiftEnd bool) error {
+// executeShiftSummary: zapisuje podsumowanie poprzedniej zmiany (JSON + DB; błąd DB – kolejka ponowień).
+func executeShiftSummary(startUTC, endUTC time.Time, isShiftEnd bool) {
 	s := Summary{
 		DataUtworzenia:   endUTC.Format(time.RFC3339Nano),
 		StartZmiany:      startUTC.Format(time.RFC3339Nano),
@@ -164,7 +185,7 @@ func executeShiftSummary(startUTC, endUTC time.Time, isShiftEnd bool) error {
 		Energy:           EnergySection{PerDeviceWh: map[string]float64{}, Start: map[string]float64{}, Last: map[string]float64{}},
 		Totaliser:        TotaliserSection{PerPort: map[string]float64{}, Start: map[string]float64{}, Last: map[string]float64{}},
 		Analizator:       map[string]map[string]float64{},
-		ElementsPerCycle: map[string]int{},
+		Products:         map[string]ProductShift{},
 	}
 
 	// --- odczyt źródeł ---
@@ -172,17 +193,7 @@ func executeShiftSummary(startUTC, endUTC time.Time, isShiftEnd bool) error {
 	meters := utils.LoadFromJSONMapArray(config.MetersFilePath)
 	flow   := utils.LoadFromJSONMap(config.MqttFlowFilePath)
 
-	fillOeeSectionSummary(&s.OEE, oee)
-
-	// policz elementy per cykl (history + bieżący okres)
-	s.ElementsPerCycle = extractElementsPerCycleFixed(oee)
-
-	// kontrola zgodności sumy z oee.ilosc_elementow
-	total := 0
-	for _, v := range s.ElementsPerCycle { total += v }
-	if s.OEE.IloscElementow > 0 && total != s.OEE.IloscElementow {
-		utils.LogMessage(fmt.Sprintf("[SHIFT_SUMMARY] elements_per_cycle total=%d != ilosc_elementow=%d", total, s.OEE.IloscElementow))
-	}
+	fillEngineSummary(&s, oee)
 
 	// reszta bez zmian
 	fillMeterAnalizator(&s.Analizator, meters)
@@ -191,12 +202,34 @@ func executeShiftSummary(startUTC, endUTC time.Time, isShiftEnd bool) error {
 
 	// --- zapis ---
 	utils.SaveToJSON(s, config.SummaryFilePath)
-	SaveShiftSummaryToDB(config.SummaryFilePath)
-	return nil
+	setLastShiftSummary(s)
+	if err := SaveShiftSummaryToDB(config.SummaryFilePath); err != nil {
+		queueShiftSummary(config.SummaryFilePath, err) // DB ponawiane w tle, licznik OEE i tak zerowany
+	}
 }
 
+// fillEngineSummary – część podsumowania liczona przez silnik OEE (oee.json): wskaźniki,
+// produkty, straty, prędkość, niezawodność. Także dla przeliczeń historii (RecomputeShift).
+func fillEngineSummary(s *Summary, oee map[string]interface{}) {
+	fillOeeSectionSummary(&s.OEE, oee)
+
+	// produkcja per produkt (historia okresów cyklu + bieżący okres)
+	s.Products = extractProducts(oee)
+
+	// Six Big Losses zmiany
+	s.Losses = lossesFromMap(oee["losses"])
+
+	// prędkość obrotnicy: zmiana i okresy cyklu (z wydajnością okresu)
+	decodeSection(oee["speed"], &s.Speed)
+
+	// MTBF / MTTR i historia postojów zmiany
+	decodeSection(oee["reliability"], &s.Reliability)
+	decodeSection(oee["stops"], &s.Stops)
+}
+
+// mapCycleLPMToLabelFixed – etykieta okresu bez SKU (oee.json sprzed katalogu produktów).
 func mapCycleLPMToLabelFixed(lpm float64) string {
-	for i, rule := range config.CycleTable {
+	for i, rule := range config.CurrentTuning().CycleTable {
 		if rule.CycleLPM == lpm {
 			return fmt.Sprintf("cykl%d", i)
 		}
@@ -204,50 +237,75 @@ func mapCycleLPMToLabelFixed(lpm float64) string {
 	return "unknown"
 }
 
-func extractElementsPerCycleFixed(oee map[string]interface{}) map[string]int {
-	out := map[string]int{
-		"cykl0": 0,
-		"cykl1": 0,
-		"cykl2": 0,
-		"cykl3": 0,
-	}
+func extractProducts(oee map[string]interface{}) map[string]ProductShift {
+	out := map[string]ProductShift{}
 
 	internal, _ := oee["internal"].(map[string]interface{})
 	if internal == nil {
 		return out
 	}
 
+	add := func(sku string, lpm float64, cnt int, work float64, micro int, microS float64) {
+		if sku == "" {
+			sku = mapCycleLPMToLabelFixed(lpm)
+		}
+		if cnt <= 0 && work <= 0 {
+			return
+		}
+		ps := out[sku]
+		ps.Pieces += cnt
+		ps.WorkSeconds += work
+		ps.MicroStops += micro
+		ps.MicroStopSeconds += microS
+		if lpm > 0 {
+			ps.ExpectedPieces += work / (60.0 / lpm)
+			if ps.IdealCycleS == 0 {
+				ps.IdealCycleS = 60.0 / lpm
+			}
+		}
+		out[sku] = ps
+	}
+
 	// 1) Historia cykli
 	if rawHist, ok := internal["cycle_history"].([]interface{}); ok {
 		for _, it := range rawHist {
 			if row, ok := it.(map[string]interface{}); ok {
-				lpm := utils.ToFloat(row["CycleLPM"])
-				cnt := utils.ToInt(row["ElementCounter"])
-				if cnt > 0 {
-					out[mapCycleLPMToLabelFixed(lpm)] += cnt
-				}
+				sku, _ := row["SKU"].(string)
+				add(sku, utils.ToFloat(row["CycleLPM"]), utils.ToInt(row["ElementCounter"]), utils.ToFloat(row["WorkSeconds"]),
+					utils.ToInt(row["MicroStops"]), utils.ToFloat(row["MicroStopSeconds"]))
 			}
 		}
 	}
 
 	// 2) Bieżący cykl
-	if cval, ok := internal["current_cycle_value"]; ok {
-		if ccnt, ok2 := internal["current_cycle_element_cnt"]; ok2 {
-			cnt := utils.ToInt(ccnt)
-			if cnt > 0 {
-				out[mapCycleLPMToLabelFixed(utils.ToFloat(cval))] += cnt
-			}
+	sku := ""
+	if prod, ok := oee["product"].(map[string]interface{}); ok {
+		sku, _ = prod["sku"].(string)
+	}
+	add(sku, utils.ToFloat(internal["current_cycle_value"]), utils.ToInt(internal["current_cycle_element_cnt"]),
+		utils.ToFloat(internal["current_cycle_work_seconds"]),
+		utils.ToInt(internal["current_cycle_micro_stops"]), utils.ToFloat(internal["current_cycle_micro_stop_seconds"]))
+
+	total := 0
+	for sku, ps := range out {
+		if p, ok := ProductBySKU(sku); ok {
+			ps.Name = p.Name
+		}
+		if ps.ExpectedPieces > 0 {
+			ps.IdealCycleS = ps.WorkSeconds / ps.ExpectedPieces
+			ps.Wydajnosc = math.Round(float64(ps.Pieces)/ps.ExpectedPieces*10000) / 10000
 		}
+		ps.MicroStopSeconds = math.Round(ps.MicroStopSeconds*100) / 100
+		out[sku] = ps
+		total += ps.Pieces
 	}
 
 	// 3) Log różnicy względem globalnej ilości elementów
-	total := 0
-	for _, v := range out { total += v }
 	if oeeMap, ok := oee["oee"].(map[string]interface{}); ok {
 		target := utils.ToInt(oeeMap["ilosc_elementow"])
 		if target > 0 && total != target {
 			utils.LogMessage(fmt.Sprintf(
-				"[SHIFT_SUMMARY] elements_per_cycle total=%d != oee.ilosc_elementow=%d (różnica %d)",
+				"[SHIFT_SUMMARY] products total=%d != oee.ilosc_elementow=%d (różnica %d)",
 				total, target, target-total))
 		}
 	}
@@ -272,6 +330,8 @@ func fillOeeSectionSummary(dst *OeeSectionSummary, oee map[string]interface{}) {
 	dst.OEE               = utils.ToFloat(section["oee"])
 	dst.W_NaSzt            = utils.ToFloat(section["W_na_szt"])
 	dst.M3_NaSzt           = utils.ToFloat(section["M3_na_szt"])
+	dst.Mikroprzestoje      = utils.ToInt(section["mikroprzestoje"])
+	dst.CzasMikroprzestojow = utils.ToFloat(section["czas_mikroprzestojow"])
 }
 
 func fillMeterAnalizator(dst *map[string]map[string]float64, meters map[string][]map[string]interface{}) {
@@ -308,7 +368,7 @@ func fillFlowTotaliser(dst *TotaliserSection, flow map[string]map[string]interfa
 
 		currentVal := 0.0
 		if f, ok := flow[port]; ok {
-			currentVal = utils.ToFloat(f["totaliser"]) * config.AirFactor
+			currentVal = utils.ToFloat(f["totaliser"]) * config.CurrentTuning().AirFactor
 		}
 
 		if _, ok := totaliserStart[idx]; !ok {
@@ -369,7 +429,7 @@ func fillEnergy(dst *EnergySection, meters map[string][]map[string]interface{},
 	lastSummary := utils.LoadFromJSON(config.SummaryFilePath)
 	totalWh := 0.0
 
-	for i := 1; i <= 3; i++ {
+	for i := 1; i <= len(config.AnalyzerIPs); i++ {
 		k := strconv.Itoa(i)
 		deviceKey := "device_" + k
 
@@ -462,7 +522,7 @@ func setTotaliserBaselines() {
 		idx := i + 1
 		currentVal := 0.0
 		if f, ok := flow[port]; ok {
-			currentVal = utils.ToFloat(f["totaliser"]) * config.AirFactor
+			currentVal = utils.ToFloat(f["totaliser"]) * config.CurrentTuning().AirFactor
 		}
 		totaliserStart[idx] = currentVal
 		totaliserLast[idx]  = currentVal
@@ -484,7 +544,7 @@ func setEnergyBaselines() {
 		}
 	}
 
-	for i := 1; i <= 3; i++ {
+	for i := 1; i <= len(config.AnalyzerIPs); i++ {
 		key := "device_" + strconv.Itoa(i)
 		currentVal := 0.0
 
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"os"
+	"sync"
+	"time"
+)
+
+// --- Ponawianie zapisu podsumowań zmian ---
+//
+// Podsumowanie zmiany trafia do DB w jednej transakcji (saveShiftSummary); jeśli się nie uda
+// (brak bazy, błąd dowolnego wiersza szczegółów), jest odkładane do kolejki w pliku
+// config.SummaryPendingFilePath (przeżywa restart) i ponawiane co config.SummaryRetryInterval
+// w kolejności zmian, aż do skutku. Licznik OEE jest zerowany na granicy zmiany niezależnie od DB.
+
+const maxPendingSummaries = 200 // ~2 miesiące przy 3 zmianach na dobę; najstarsze są odrzucane
+
+var (
+	pendingSummaries     []map[string]interface{}
+	pendingSummariesLock sync.Mutex
+	summaryLog           = utils.NewLogger("SHIFT_SUMMARY")
+)
+
+// queueShiftSummary – podsumowanie z pliku filename do kolejki ponowień.
+func queueShiftSummary(filename string, cause error) {
+	data := utils.LoadFromJSON(filename)
+	if len(data) == 0 {
+		summaryLog.Warn("shift summary not saved and not queued", "error", cause)
+		return
+	}
+	pendingSummariesLock.Lock()
+	pendingSummaries = append(pendingSummaries, data)
+	if n := len(pendingSummaries) - maxPendingSummaries; n > 0 {
+		pendingSummaries = pendingSummaries[n:]
+	}
+	queued := len(pendingSummaries)
+	pendingSummariesLock.Unlock()
+	savePendingSummaries()
+	summaryLog.Warn("shift summary not saved, queued for retry",
+		"start_zmiany", data["start_zmiany"], "queued", queued, "error", cause)
+}
+
+// loadPendingSummaries – kolejka z pliku (start programu).
+func loadPendingSummaries() {
+	raw, err := os.ReadFile(config.SummaryPendingFilePath)
+	if err != nil {
+		return
+	}
+	var pending []map[string]interface{}
+	if err := json.Unmarshal(raw, &pending); err != nil {
+		summaryLog.Warn("pending shift summaries unreadable", "file", config.SummaryPendingFilePath, "error", err)
+		return
+	}
+	pendingSummariesLock.Lock()
+	pendingSummaries = pending
+	pendingSummariesLock.Unlock()
+	if len(pending) > 0 {
+		summaryLog.Info("pending shift summaries restored", "count", len(pending))
+	}
+}
+
+func savePendingSummaries() {
+	pendingSummariesLock.Lock()
+	pending := append([]map[string]interface{}(nil), pendingSummaries...)
+	pendingSummariesLock.Unlock()
+	if len(pending) == 0 {
+		if err := os.Remove(config.SummaryPendingFilePath); err != nil && !os.IsNotExist(err) {
+			summaryLog.Warn("pending shift summaries not removed", "error", err)
+		}
+		return
+	}
+	utils.SaveToJSON(pending, config.SummaryPendingFilePath)
+}
+
+// summaryKey – zmiana podsumowania (jak sprawdzenie w saveShiftSummary: start i koniec).
+func summaryKey(data map[string]interface{}) string {
+	return fmt.Sprint(data["start_zmiany"], "/", data["koniec_zmiany"])
+}
+
+// startShiftSummaryRetry – wczytanie kolejki i pętla ponowień.
+func startShiftSummaryRetry() {
+	loadPendingSummaries()
+	utils.SuperviseLoop("SHIFT SUMMARY retry", config.SummaryRetryInterval, flushShiftSummaries)
+}
+
+// flushShiftSummaries – zapis odłożonych podsumowań od najstarszego; niezapisane zostają do następnej próby.
+func flushShiftSummaries() {
+	pendingSummariesLock.Lock()
+	pending := append([]map[string]interface{}(nil), pendingSummaries...)
+	pendingSummariesLock.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	saved := map[string]bool{}
+	for _, data := range pending {
+		if err := saveShiftSummary(data); err != nil {
+			summaryLog.Limit("retry", 10*time.Minute).Warn("shift summary retry failed",
+				"start_zmiany", data["start_zmiany"], "queued", len(pending)-len(saved), "error", err)
+			break
+		}
+		summaryLog.Info("queued shift summary saved", "start_zmiany", data["start_zmiany"])
+		saved[summaryKey(data)] = true
+	}
+	if len(saved) == 0 {
+		return
+	}
+	// usuwane tylko zapisane – kolejka mogła w tym czasie urosnąć albo stracić początek
+	pendingSummariesLock.Lock()
+	kept := pendingSummaries[:0]
+	for _, data := range pendingSummaries {
+		if !saved[summaryKey(data)] {
+			kept = append(kept, data)
+		}
+	}
+	pendingSummaries = kept
+	pendingSummariesLock.Unlock()
+	savePendingSummaries()
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"encoding/json"
+	"go_app/config"
+	"go_app/utils"
+	"math"
+	"time"
+)
+
+// --- Prędkość obrotnicy ---
+//
+// updateSpeed co sekundę przelicza impulsy Predkosc_sygnal na obr/min. Próbki z czasu pracy
+// (status_pracy) trafiają do statystyk zmiany i bieżącego okresu cyklu: średnia ważona czasem,
+// minimum, maksimum, rozkład względem tuning.nominal_speed_rpm i czas poniżej nominalnej.
+// Strata prędkości = Σ dt·(1 − v/nominalna) dla próbek poniżej nominalnej – czas, który obrotnica
+// straciła przez spowolnienie. Zestawiona z obniżoną prędkością Six Big Losses (zmiana) albo
+// czasem pracy ponad cykl idealny (okres) daje udział spowolnienia w stracie wydajności.
+
+// SpeedBands – przedziały rozkładu prędkości (procent nominalnej).
+var SpeedBands = []string{"<50%", "50-80%", "80-95%", ">=95%"}
+
+// speedMaxSampleSeconds – dłuższa przerwa między próbkami (brak ramek, restart) nie jest liczona.
+const speedMaxSampleSeconds = 5.0
+
+// SpeedStats – statystyki prędkości obrotnicy w czasie pracy (zmiana albo okres cyklu).
+type SpeedStats struct {
+	Seconds             float64            `json:"seconds"` // czas próbek
+	MeanRPM             float64            `json:"mean_rpm"`
+	MinRPM              float64            `json:"min_rpm"`
+	MaxRPM              float64            `json:"max_rpm"`
+	NominalRPM          float64            `json:"nominal_rpm"` // 0 = bez progu
+	BelowNominalSeconds float64            `json:"below_nominal_seconds"`
+	SpeedLossSeconds    float64            `json:"speed_loss_seconds"`
+	Distribution        map[string]float64 `json:"distribution,omitempty"` // [s] w przedziałach SpeedBands
+
+	// zestawienie ze stratą wydajności (tylko w widokach: oee.json speed, summary, API)
+	ReducedSpeedSeconds float64 `json:"reduced_speed_seconds"`
+	ReducedSpeedShare   float64 `json:"reduced_speed_share"` // speed_loss_seconds / reduced_speed_seconds, 0..1
+}
+
+// add – próbka v [obr/min] trwająca dt [s].
+func (s *SpeedStats) add(v, dt, nominal float64) {
+	if s.Seconds == 0 {
+		s.MinRPM, s.MaxRPM = v, v
+	}
+	s.MeanRPM = (s.MeanRPM*s.Seconds + v*dt) / (s.Seconds + dt)
+	s.Seconds += dt
+	s.MinRPM = math.Min(s.MinRPM, v)
+	s.MaxRPM = math.Max(s.MaxRPM, v)
+	s.NominalRPM = nominal
+	if nominal <= 0 {
+		return
+	}
+	ratio := v / nominal
+	if ratio < 1 {
+		s.BelowNominalSeconds += dt
+		s.SpeedLossSeconds += dt * (1 - ratio)
+	}
+	if s.Distribution == nil {
+		s.Distribution = map[string]float64{}
+	}
+	switch {
+	case ratio < 0.5:
+		s.Distribution[SpeedBands[0]] += dt
+	case ratio < 0.8:
+		s.Distribution[SpeedBands[1]] += dt
+	case ratio < 0.95:
+		s.Distribution[SpeedBands[2]] += dt
+	default:
+		s.Distribution[SpeedBands[3]] += dt
+	}
+}
+
+// view – kopia zaokrąglona, z udziałem spowolnienia w stracie wydajności reduced [s].
+func (s SpeedStats) view(reduced float64) SpeedStats {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	v := s
+	v.Seconds, v.MeanRPM, v.MinRPM, v.MaxRPM = round(s.Seconds), round(s.MeanRPM), round(s.MinRPM), round(s.MaxRPM)
+	v.BelowNominalSeconds, v.SpeedLossSeconds = round(s.BelowNominalSeconds), round(s.SpeedLossSeconds)
+	v.Distribution = nil
+	for band, sec := range s.Distribution {
+		if v.Distribution == nil {
+			v.Distribution = map[string]float64{}
+		}
+		v.Distribution[band] = round(sec)
+	}
+	v.ReducedSpeedSeconds, v.ReducedSpeedShare = round(math.Max(reduced, 0)), 0
+	if reduced > 0 {
+		v.ReducedSpeedShare = math.Round(math.Min(s.SpeedLossSeconds/reduced, 1)*10000) / 10000
+	}
+	return v
+}
+
+// PeriodSpeed – prędkość i wydajność jednego okresu cyklu.
+type PeriodSpeed struct {
+	SKU         string     `json:"sku"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end,omitempty"` // nil = bieżący okres
+	CycleLPM    float64    `json:"cycle_lpm"`
+	Pieces      int        `json:"pieces"`
+	WorkSeconds float64    `json:"work_seconds"`
+	Wydajnosc   float64    `json:"wydajnosc"` // sztuki × cykl idealny / czas pracy
+	Speed       SpeedStats `json:"speed"`
+}
+
+// SpeedSummary – prędkość zmiany i okresów cyklu (oee.json speed, summary.json speed).
+type SpeedSummary struct {
+	Shift   SpeedStats    `json:"shift"`
+	Periods []PeriodSpeed `json:"periods,omitempty"`
+}
+
+var (
+	shiftSpeed        SpeedStats // chronione calcLock
+	currentCycleSpeed SpeedStats
+)
+
+// sampleSpeed – próbka z updateSpeed; liczona tylko w czasie pracy.
+func sampleSpeed(rpm, dt float64) {
+	if dt <= 0 || dt > speedMaxSampleSeconds || !utils.ToBool(CalculatedData["status_pracy"]) {
+		return
+	}
+	nominal := config.CurrentTuning().NominalSpeedRPM
+	shiftSpeed.add(rpm, dt, nominal)
+	currentCycleSpeed.add(rpm, dt, nominal)
+}
+
+// periodSpeedView – okres cyklu z wydajnością i udziałem spowolnienia w czasie ponad cykl idealny.
+func periodSpeedView(p CyclePeriod, current bool) PeriodSpeed {
+	ps := PeriodSpeed{SKU: p.SKU, Start: p.StartTime.UTC(), CycleLPM: p.CycleLPM, Pieces: p.ElementCounter,
+		WorkSeconds: math.Round(p.WorkSeconds*100) / 100}
+	if !current {
+		end := p.EndTime.UTC()
+		ps.End = &end
+	}
+	var reduced float64
+	if p.CycleLPM > 0 && p.WorkSeconds > 0 {
+		ideal := float64(p.ElementCounter) * 60.0 / p.CycleLPM
+		ps.Wydajnosc = math.Round(ideal/p.WorkSeconds*10000) / 10000
+		reduced = p.WorkSeconds - ideal - p.MicroStopSeconds
+	}
+	ps.Speed = p.Speed.view(reduced)
+	return ps
+}
+
+// currentSpeedLocked – prędkość bieżącej zmiany (wymaga calcLock).
+func currentSpeedLocked() SpeedSummary {
+	out := SpeedSummary{Shift: shiftSpeed.view(currentLossesLocked().ReducedSpeed)}
+	for _, p := range cycleHistory {
+		if p.Speed.Seconds > 0 || p.ElementCounter > 0 {
+			out.Periods = append(out.Periods, periodSpeedView(p, false))
+		}
+	}
+	out.Periods = append(out.Periods, periodSpeedView(CyclePeriod{
+		StartTime: currentCycleStartTime, CycleLPM: currentCycleValue, ElementCounter: currentCycleElementCnt,
+		WorkSeconds: currentCycleWorkSeconds, SKU: currentProductSKU,
+		MicroStopSeconds: currentCycleMicroStopSeconds, Speed: currentCycleSpeed,
+	}, true))
+	return out
+}
+
+// decodeSection – sekcja oee.json / summary.json (mapa z LoadFromJSON) do struktury dst
+// wg tagów json; brak sekcji lub zły format = dst bez zmian.
+func decodeSection(raw interface{}, dst interface{}) {
+	if raw == nil {
+		return
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(b, dst)
+}
+
+// loadSpeed – statystyki zmiany i bieżącego okresu z oee.json (sekcja internal); wymaga calcLock.
+func loadSpeed(data map[string]interface{}) {
+	resetSpeed()
+	if in, ok := data["internal"].(map[string]interface{}); ok {
+		decodeSection(in["speed"], &shiftSpeed)
+		decodeSection(in["current_cycle_speed"], &currentCycleSpeed)
+	}
+}
+
+// resetSpeed – nowa zmiana; wymaga calcLock.
+func resetSpeed() {
+	shiftSpeed = SpeedStats{}
+	currentCycleSpeed = SpeedStats{}
+}
+
+// ShiftSpeed – prędkość obrotnicy jednej zmiany (API).
+type ShiftSpeed struct {
+	StartZmiany    time.Time  `json:"start_zmiany"`
+	DataUtworzenia *time.Time `json:"data_utworzenia,omitempty"` // nil = bieżąca zmiana
+	SpeedSummary
+}
+
+// CurrentShiftSpeed – prędkość bieżącej zmiany (od ostatniego resetu OEE) z podziałem na okresy cyklu.
+func CurrentShiftSpeed() ShiftSpeed {
+	calcLock.Lock()
+	defer calcLock.Unlock()
+	return ShiftSpeed{StartZmiany: CzasPomiarowy.StartMeasurement.UTC(), SpeedSummary: currentSpeedLocked()}
+}
+
+// LoadShiftSpeedFromDB – prędkość zmian zakończonych w [from, to) z tabeli shift_summary, obniżona
+// prędkość z shift_summary_loss (najnowsze najpierw; bez rozkładu i okresów cyklu).
+func LoadShiftSpeedFromDB(from, to time.Time) ([]ShiftSpeed, error) {
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT s.data_utworzenia, s.start_zmiany,
+			s.predkosc_czas, COALESCE(s.predkosc_srednia, 0), COALESCE(s.predkosc_min, 0),
+			COALESCE(s.predkosc_max, 0), COALESCE(s.predkosc_nominalna, 0),
+			COALESCE(s.czas_ponizej_nominalnej, 0), COALESCE(s.strata_predkosci, 0), COALESCE(l.seconds, 0)
+		FROM shift_summary s
+		LEFT JOIN shift_summary_loss l
+			ON l.data_utworzenia = s.data_utworzenia AND l.category = 'reduced_speed'
+		WHERE s.data_utworzenia >= $1 AND s.data_utworzenia < $2 AND s.predkosc_czas IS NOT NULL
+		ORDER BY s.data_utworzenia DESC`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []ShiftSpeed{}
+	for rows.Next() {
+		var created, start time.Time
+		var s SpeedStats
+		var reduced float64
+		if err := rows.Scan(&created, &start, &s.Seconds, &s.MeanRPM, &s.MinRPM, &s.MaxRPM, &s.NominalRPM,
+			&s.BelowNominalSeconds, &s.SpeedLossSeconds, &reduced); err != nil {
+			return nil, err
+		}
+		c := created.UTC()
+		out = append(out, ShiftSpeed{StartZmiany: start.UTC(), DataUtworzenia: &c, SpeedSummary: SpeedSummary{Shift: s.view(reduced)}})
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"fmt"
+	"go_app/config"
+	"go_app/utils"
+	"math"
+	"time"
+)
+
+// --- TEEP i wykorzystanie czasu kalendarzowego ---
+//
+// OEE liczy się tylko w czasie pomiaru zmiany. Dla dnia, tygodnia i miesiąca (kalendarz
+// Europe/Warsaw jak harmonogram zmian, tydzień od poniedziałku):
+//   - czas kalendarzowy – cały okres (bieżący okres: do teraz), także bez zaplanowanych zmian,
+//   - czas planowany – długość zmian harmonogramu (shiftTimes) rozpoczętych w dniach
+//     tuning.planned_weekdays, także zmian bez wiersza shift_summary (program nie działał)
+//     i bez produkcji; zmiana z produkcją w dniu spoza planu to nadgodziny – też planowana,
+//   - czas w pełni produktywny – OEE zmiany × jej czas pomiaru (shift_summary),
+//   - wykorzystanie = planowany / kalendarzowy, OEE = produktywny / planowany,
+//     TEEP = produktywny / kalendarzowy = OEE × wykorzystanie.
+// Zmiana przechodząca przez północ jest dzielona proporcjonalnie do czasu w każdym okresie.
+// Bieżąca zmiana (jeszcze bez wiersza shift_summary) jest liczona ze stanu silnika.
+
+// TeepPeriods – dozwolone agregacje LoadTeepFromDB.
+var TeepPeriods = []string{"day", "week", "month"}
+
+// TeepPeriod – TEEP jednego dnia, tygodnia albo miesiąca.
+type TeepPeriod struct {
+	Start             time.Time `json:"start"`
+	End               time.Time `json:"end"`
+	Shifts            int       `json:"shifts"` // planowane zmiany rozpoczęte w okresie
+	CalendarSeconds   float64   `json:"calendar_seconds"`
+	PlannedSeconds    float64   `json:"planned_seconds"`
+	ProductiveSeconds float64   `json:"productive_seconds"` // OEE × czas planowany
+	OEE               float64   `json:"oee"`
+	Utilisation       float64   `json:"utilisation"` // planowany / kalendarzowy
+	TEEP              float64   `json:"teep"`
+}
+
+// teepLocation – strefa granic dni (jak StartShiftScheduler).
+func teepLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// periodStart – początek dnia / tygodnia / miesiąca zawierającego t.
+func periodStart(t time.Time, period string, loc *time.Location) time.Time {
+	l := t.In(loc)
+	switch period {
+	case "week":
+		d := time.Date(l.Year(), l.Month(), l.Day(), 0, 0, 0, 0, loc)
+		return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(l.Year(), l.Month(), 1, 0, 0, 0, 0, loc)
+	}
+	return time.Date(l.Year(), l.Month(), l.Day(), 0, 0, 0, 0, loc)
+}
+
+func periodNext(start time.Time, period string) time.Time {
+	switch period {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// TeepDefaultFrom – domyślny początek zakresu: 7 dni, 4 tygodnie albo 3 miesiące przed to,
+// wyrównany do początku okresu.
+func TeepDefaultFrom(period string, to time.Time) time.Time {
+	from := to.AddDate(0, 0, -6)
+	switch period {
+	case "week":
+		from = to.AddDate(0, 0, -21)
+	case "month":
+		from = to.AddDate(0, -2, 0)
+	}
+	return periodStart(from, period, teepLocation()).UTC()
+}
+
+// overlapSeconds – część wspólna [a1, a2) i [b1, b2) [s].
+func overlapSeconds(a1, a2, b1, b2 time.Time) float64 {
+	if b1.After(a1) {
+		a1 = b1
+	}
+	if b2.Before(a2) {
+		a2 = b2
+	}
+	return math.Max(a2.Sub(a1).Seconds(), 0)
+}
+
+// teepShift – jedna zmiana (wiersz shift_summary albo bieżąca).
+type teepShift struct {
+	start, end  time.Time
+	czasPomiaru float64
+	oee         float64
+	elements    int
+}
+
+// LoadTeepFromDB – TEEP i wykorzystanie w okresach period (day | week | month) nachodzących
+// na [from, to); czas kalendarzowy przycięty do [from, min(to, teraz)). Najnowsze najpierw.
+func LoadTeepFromDB(period string, from, to time.Time) ([]TeepPeriod, error) {
+	switch period {
+	case "day", "week", "month":
+	default:
+		return nil, fmt.Errorf("unknown period %q", period)
+	}
+	now := nowUTC()
+	calendarEnd := to
+	if now.Before(calendarEnd) {
+		calendarEnd = now
+	}
+
+	db, err := getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT start_zmiany, koniec_zmiany, COALESCE(czas_pomiaru, 0), COALESCE(oee, 0), COALESCE(ilosc_elementow, 0)
+		FROM shift_summary
+		WHERE koniec_zmiany > $1 AND start_zmiany < $2
+		ORDER BY start_zmiany`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shifts []teepShift
+	lastEnd := time.Time{}
+	for rows.Next() {
+		var s teepShift
+		if err := rows.Scan(&s.start, &s.end, &s.czasPomiaru, &s.oee, &s.elements); err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, s)
+		if s.end.After(lastEnd) {
+			lastEnd = s.end
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// bieżąca zmiana – jeszcze bez wiersza shift_summary
+	calcLock.Lock()
+	cur := teepShift{start: CzasPomiarowy.StartMeasurement, end: nowUTC(),
+		czasPomiaru: utils.ToFloat(CalculatedData["czas_pomiaru"]), oee: utils.ToFloat(CalculatedData["oee"]),
+		elements: utils.ToInt(CalculatedData["ilosc_elementow"])}
+	calcLock.Unlock()
+	if !cur.start.IsZero() && !cur.start.Before(lastEnd) && cur.end.After(from) && cur.start.Before(to) {
+		shifts = append(shifts, cur)
+	}
+
+	planned := plannedShifts(from, calendarEnd, shifts, config.CurrentTuning())
+	return teepBuckets(period, from, to, calendarEnd, planned, shifts), nil
+}
+
+// plannedShifts – zmiany harmonogramu nachodzące na [from, to) w dniach planu oraz zmiany
+// z produkcją (nadgodziny) w pozostałe dni.
+func plannedShifts(from, to time.Time, shifts []teepShift, t config.Tuning) []ShiftRange {
+	loc := teepLocation()
+	worked := map[time.Time]bool{}
+	for _, s := range shifts {
+		if s.elements > 0 {
+			worked[s.start.UTC()] = true
+		}
+	}
+	var out []ShiftRange
+	for _, r := range ShiftRanges(from.Add(-24*time.Hour), to.Add(24*time.Hour)) {
+		if r.End.After(from) && r.Start.Before(to) && (t.PlannedOn(r.Start.In(loc).Weekday()) || worked[r.Start]) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// teepBuckets – okresy nachodzące na [from, to) z czasem kalendarzowym do calendarEnd:
+// czas planowany z planned, produktywny z shifts.
+func teepBuckets(period string, from, to, calendarEnd time.Time, planned []ShiftRange, shifts []teepShift) []TeepPeriod {
+	loc := teepLocation()
+	out := []TeepPeriod{}
+	for ps := periodStart(from, period, loc); ps.Before(to) && ps.Before(calendarEnd); ps = periodNext(ps, period) {
+		pe := periodNext(ps, period)
+		p := TeepPeriod{Start: ps.UTC(), End: pe.UTC(), CalendarSeconds: overlapSeconds(ps, pe, from, calendarEnd)}
+		w1, w2 := ps, pe // część okresu w zakresie
+		if from.After(w1) {
+			w1 = from
+		}
+		if calendarEnd.Before(w2) {
+			w2 = calendarEnd
+		}
+		for _, r := range planned {
+			p.PlannedSeconds += overlapSeconds(r.Start, r.End, w1, w2)
+			if !r.Start.Before(w1) && r.Start.Before(w2) {
+				p.Shifts++
+			}
+		}
+		for _, s := range shifts {
+			length := s.end.Sub(s.start).Seconds()
+			if length <= 0 {
+				continue
+			}
+			p.ProductiveSeconds += s.czasPomiaru * s.oee * overlapSeconds(s.start, s.end, w1, w2) / length
+		}
+		out = append([]TeepPeriod{teepRatios(p)}, out...)
+	}
+	return out
+}
+
+// teepRatios – wskaźniki okresu (zaokrąglone jak OEE zmiany).
+func teepRatios(p TeepPeriod) TeepPeriod {
+	ratio := func(a, b float64) float64 {
+		if b <= 0 {
+			return 0
+		}
+		return math.Round(math.Min(a/b, 1)*10000) / 10000
+	}
+	p.OEE = ratio(p.ProductiveSeconds, p.PlannedSeconds)
+	p.Utilisation = ratio(p.PlannedSeconds, p.CalendarSeconds)
+	p.TEEP = ratio(p.ProductiveSeconds, p.CalendarSeconds)
+	p.CalendarSeconds = math.Round(p.CalendarSeconds*100) / 100
+	p.PlannedSeconds = math.Round(p.PlannedSeconds*100) / 100
+	p.ProductiveSeconds = math.Round(p.ProductiveSeconds*100) / 100
+	return p
+}
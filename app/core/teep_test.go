@@ -0,0 +1,44 @@
+package core
+
+import (
+	"go_app/config"
+	"testing"
+	"time"
+)
+
+// TestTeepPlannedFromSchedule – czas planowany to zmiany harmonogramu w dniach planu, także
+// bez produkcji; w dzień spoza planu tylko zmiany z produkcją (nadgodziny).
+func TestTeepPlannedFromSchedule(t *testing.T) {
+	loc := teepLocation()
+	at := func(day, hour int) time.Time { return time.Date(2026, 3, day, hour, 0, 0, 0, loc).UTC() }
+	shift := func(day, hour, elements int, oee float64) teepShift {
+		return teepShift{start: at(day, hour), end: at(day, hour+8), czasPomiaru: 8 * 3600, oee: oee, elements: elements}
+	}
+	shifts := []teepShift{
+		shift(6, 6, 0, 0), // piątek – awaria przez całą zmianę, nadal w planie
+		shift(6, 14, 100, 0.5),
+		shift(7, 6, 50, 0.5), // sobota – nadgodziny
+		shift(7, 14, 0, 0),   // sobota bez produkcji – poza planem
+	}
+	tuning := config.Tuning{PlannedWeekdays: []string{"mon", "tue", "wed", "thu", "fri"}}
+	from, to := at(6, 0), at(8, 0)
+
+	got := teepBuckets("day", from, to, to, plannedShifts(from, to, shifts, tuning), shifts)
+	if len(got) != 2 {
+		t.Fatalf("got %d periods, want 2", len(got))
+	}
+	sat, fri := got[0], got[1]
+	// piątek: 22:00 czw. (6 h) + 06:00 + 14:00 + 22:00 (2 h)
+	if fri.PlannedSeconds != 24*3600 || fri.Shifts != 3 || fri.ProductiveSeconds != 4*3600 || fri.OEE != 0.1667 {
+		t.Errorf("friday = %+v", fri)
+	}
+	// sobota: 22:00 pt. (6 h) + nadgodziny 06:00
+	if sat.PlannedSeconds != 14*3600 || sat.Shifts != 1 || sat.ProductiveSeconds != 4*3600 || sat.Utilisation != 0.5833 {
+		t.Errorf("saturday = %+v", sat)
+	}
+
+	all := plannedShifts(from, to, shifts, config.Tuning{})
+	if got := teepBuckets("day", from, to, to, all, shifts); got[0].PlannedSeconds != 24*3600 {
+		t.Errorf("empty planned_weekdays: saturday planned %g s, want 24 h", got[0].PlannedSeconds)
+	}
+}
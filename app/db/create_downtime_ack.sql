@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS downtime_ack (
+    timestamp      TIMESTAMPTZ      NOT NULL,
+    reason         SMALLINT         NOT NULL,
+    source         TEXT,
+    pause_start    TIMESTAMPTZ,
+    pause_end      TIMESTAMPTZ,
+    pause_seconds  REAL,
+    PRIMARY KEY (timestamp)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('downtime_ack', 'timestamp', if_not_exists => TRUE);
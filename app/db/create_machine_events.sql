@@ -0,0 +1,21 @@
+-- Dziennik zdarzeń maszyny (tylko dopisywanie): stany run / stop / changeover / machine_off
+-- jako przedziały i chwilowe cycle_change – osie czasu w Grafanie, ponowne przeliczenia OEE.
+CREATE TABLE IF NOT EXISTS machine_events (
+    start_time            TIMESTAMPTZ      NOT NULL,
+    type                  TEXT             NOT NULL,   -- run | stop | changeover | machine_off | cycle_change
+    end_time              TIMESTAMPTZ      NOT NULL,   -- cycle_change: = start_time
+    seconds               REAL,
+    cycle                 REAL,                        -- cykl idealny [szt/min]
+    sku                   TEXT,                        -- produkt z katalogu albo "cyklN"
+    from_sku              TEXT,                        -- changeover: produkt przed pauzą
+    to_sku                TEXT,                        -- changeover: produkt po pauzie
+    reason                TEXT,                        -- changeover: signal | order | dimensions
+
+    PRIMARY KEY (start_time, type)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('machine_events', 'start_time', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_machine_events_type ON machine_events(type, start_time DESC);
@@ -0,0 +1,29 @@
+-- Zlecenia produkcyjne (start/stop przez API /api/orders albo temat MQTT_ORDER_TOPIC).
+-- Produkcja, czasy i media przypisane od startu do zakończenia zlecenia; trwające zlecenie
+-- aktualizowane co 30 s. Ponowny start tego samego numeru = nowy wiersz (inny started_at).
+CREATE TABLE IF NOT EXISTS production_orders (
+    order_no              TEXT             NOT NULL,
+    started_at            TIMESTAMPTZ      NOT NULL,
+    sku                   TEXT,                        -- NULL = produkt wg wymiarów
+    planned_qty           INTEGER          NOT NULL DEFAULT 0,   -- 0 = bez planu
+    status                TEXT             NOT NULL,   -- running | finished
+    ended_at              TIMESTAMPTZ,
+    pieces                INTEGER,                     -- wyprodukowane sztuki
+    rejects               INTEGER,                     -- odrzuty (jakosc = (pieces - rejects) / pieces)
+    run_seconds           REAL,                        -- czas pracy [s]
+    downtime_seconds      REAL,                        -- czas postoju [s]
+    changeover_seconds    REAL,                        -- czas przezbrojenia [s]
+    energy_wh             REAL,                        -- energia [Wh]
+    air_m3                REAL,                        -- powietrze [m3]
+    expected_pieces       REAL,                        -- run_seconds / cykl idealny
+    dostepnosc            REAL,
+    wydajnosc             REAL,
+    jakosc                REAL,
+    oee                   REAL,
+    updated_at            TIMESTAMPTZ      NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (order_no, started_at)
+);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_production_orders_started ON production_orders(started_at DESC);
@@ -0,0 +1,21 @@
+-- Katalog produktów (dane podstawowe do cyklu idealnego). Edycja: API /api/products.
+-- Zakres wymiaru bez min/max (NULL) nie ogranicza dopasowania; min = max – dokładny wymiar
+-- (± PRODUCT_DIM_TOLERANCE_MM). Wymiary w jednostkach *_calc silnika OEE.
+CREATE TABLE IF NOT EXISTS products (
+    sku                   TEXT             NOT NULL,
+    name                  TEXT,
+    material              TEXT,
+    length_min            REAL,
+    length_max            REAL,
+    width_min             REAL,
+    width_max             REAL,
+    height_min            REAL,
+    height_max            REAL,
+    ideal_cycle_s         REAL             NOT NULL CHECK (ideal_cycle_s > 0),   -- [s/szt.]
+    target_scrap_pct      REAL             NOT NULL DEFAULT 0,                   -- [%]
+    active                BOOLEAN          NOT NULL DEFAULT TRUE,                -- FALSE = poza dopasowaniem
+    family                TEXT,                                                  -- rodzina: max czas przezbrojenia
+    updated_at            TIMESTAMPTZ      NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (sku)
+);
@@ -12,44 +12,31 @@ CREATE TABLE IF NOT EXISTS shift_summary (
     jakosc                REAL,
     oee                   REAL,
 
-    -- dane z 5 analizatorów energii
-    analizator_1_ea_pos     REAL,
-    analizator_1_ea_neg     REAL,
-    analizator_1_er_pos     REAL,
-    analizator_1_er_neg     REAL,
-    analizator_1_es         REAL,
-    analizator_1_er         REAL,
-    analizator_2_ea_pos     REAL,
-    analizator_2_ea_neg     REAL,
-    analizator_2_er_pos     REAL,
-    analizator_2_er_neg     REAL,
-    analizator_2_es         REAL,
-    analizator_2_er         REAL,
-    analizator_3_ea_pos     REAL,
-    analizator_3_ea_neg     REAL,
-    analizator_3_er_pos     REAL,
-    analizator_3_er_neg     REAL,
-    analizator_3_es         REAL,
-    analizator_3_er         REAL,
-    analizator_4_ea_pos     REAL,
-    analizator_4_ea_neg     REAL,
-    analizator_4_er_pos     REAL,
-    analizator_4_er_neg     REAL,
-    analizator_4_es         REAL,
-    analizator_4_er         REAL,
-    analizator_5_ea_pos     REAL,
-    analizator_5_ea_neg     REAL,
-    analizator_5_er_pos     REAL,
-    analizator_5_er_neg     REAL,
-    analizator_5_es         REAL,
-    analizator_5_er         REAL,
-
-    -- dane z przepływomierzy powietrza
-    totaliser_1               REAL,
-    totaliser_2               REAL,
-    totaliser_3               REAL,
-    totaliser_4               REAL,
-    totaliser_5               REAL,
+    -- zużycie jednostkowe (dane per analizator / przepływomierz: shift_summary_device)
+    w_na_szt              REAL,
+    m3_na_szt             REAL,
+
+    -- mikroprzestoje (przerwy między elementami > micro_stop_factor × cykl idealny, poniżej idle timeout)
+    mikroprzestoje        INTEGER,
+    czas_mikroprzestojow  REAL,                        -- [s] ponad cykl idealny
+
+    -- prędkość obrotnicy w czasie pracy [obr/min] (tuning.nominal_speed_rpm)
+    predkosc_czas            REAL,                     -- [s] próbek
+    predkosc_srednia         REAL,
+    predkosc_min             REAL,
+    predkosc_max             REAL,
+    predkosc_nominalna       REAL,
+    czas_ponizej_nominalnej  REAL,                     -- [s]
+    strata_predkosci         REAL,                     -- [s] Σ dt·(1 − v/nominalna)
+
+    -- niezawodność (postoje bez przezbrojeń; historia: stop_events)
+    liczba_postojow          INTEGER,
+    liczba_awarii            INTEGER,
+    najdluzszy_postoj        REAL,                     -- [s]
+    mtbf                     REAL,                     -- [s] czas pracy / liczba postojów
+    mttr                     REAL,                     -- [s] czas postoju / liczba postojów
+
+    -- produkcja per produkt: shift_summary_product
 
     PRIMARY KEY (data_utworzenia)
 );
@@ -0,0 +1,29 @@
+-- Dane zmiany per urządzenie (dowolna liczba analizatorów i przepływomierzy).
+-- data_utworzenia = shift_summary.data_utworzenia (zapis w tej samej transakcji).
+CREATE TABLE IF NOT EXISTS shift_summary_device (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ      NOT NULL,
+    device_type           TEXT             NOT NULL,   -- 'analyzer' | 'flow'
+    device_id             SMALLINT         NOT NULL,   -- ANALYZER_IPnn / pozycja w FLOW_PORTS
+    source                TEXT,                        -- adres analizatora / port IO-Link
+    consumption           REAL,                        -- zużycie w zmianie
+    unit                  TEXT,                        -- 'Wh' | 'm3'
+    counter_start         REAL,                        -- stan licznika na początku zmiany
+    counter_end           REAL,                        -- stan licznika na końcu zmiany
+
+    -- liczniki analizatora (NULL dla przepływomierzy)
+    ea_pos                REAL,
+    ea_neg                REAL,
+    er_pos                REAL,
+    er_neg                REAL,
+    es                    REAL,
+    er                    REAL,
+
+    PRIMARY KEY (data_utworzenia, device_type, device_id)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('shift_summary_device', 'data_utworzenia', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_shift_summary_device_device ON shift_summary_device(device_type, device_id, data_utworzenia DESC);
@@ -0,0 +1,15 @@
+-- Six Big Losses zmiany (TPM): jeden wiersz na kategorię – breakdowns, setup, minor_stops,
+-- reduced_speed, startup_rejects, production_rejects.
+-- data_utworzenia = shift_summary.data_utworzenia (zapis w tej samej transakcji).
+CREATE TABLE IF NOT EXISTS shift_summary_loss (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ      NOT NULL,
+    category              TEXT             NOT NULL,
+    seconds               REAL,                        -- czas utracony [s]
+    pieces                INTEGER,                     -- odrzuty [szt.] (tylko kategorie *_rejects)
+
+    PRIMARY KEY (data_utworzenia, category)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('shift_summary_loss', 'data_utworzenia', if_not_exists => TRUE);
@@ -0,0 +1,23 @@
+-- Produkcja zmiany per produkt (SKU z katalogu products albo 'cyklN' – reguła CycleTable).
+-- data_utworzenia = shift_summary.data_utworzenia (zapis w tej samej transakcji).
+CREATE TABLE IF NOT EXISTS shift_summary_product (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ      NOT NULL,
+    sku                   TEXT             NOT NULL,
+    name                  TEXT,                        -- nazwa z katalogu (NULL dla 'cyklN')
+    pieces                INTEGER,                     -- wyprodukowane sztuki
+    work_seconds          REAL,                        -- czas pracy na produkcie [s]
+    ideal_cycle_s         REAL,                        -- cykl idealny [s/szt.]
+    expected_pieces       REAL,                        -- work_seconds / ideal_cycle_s
+    wydajnosc             REAL,                        -- pieces / expected_pieces
+    micro_stops           INTEGER,                     -- mikroprzestoje na produkcie
+    micro_stop_seconds    REAL,                        -- czas mikroprzestojów [s]
+
+    PRIMARY KEY (data_utworzenia, sku)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('shift_summary_product', 'data_utworzenia', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_shift_summary_product_sku ON shift_summary_product(sku, data_utworzenia DESC);
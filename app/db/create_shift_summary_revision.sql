@@ -0,0 +1,18 @@
+-- Poprzednie wersje zmian nadpisanych przez cmd/recompute -apply: wiersz shift_summary
+-- z produktami, stratami i postojami sprzed przeliczenia oraz nowe wartości.
+CREATE TABLE IF NOT EXISTS shift_summary_revision (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,   -- klucz zmiany w shift_summary
+    revised_at            TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ,
+    reason                TEXT,
+    old_values            JSONB,                       -- shift_summary, products, losses, stops
+    new_values            JSONB,
+
+    PRIMARY KEY (data_utworzenia, revised_at)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('shift_summary_revision', 'data_utworzenia', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_shift_summary_revision_revised ON shift_summary_revision(revised_at DESC);
@@ -0,0 +1,18 @@
+-- Historia postojów zmiany (pauzy bez przezbrojenia) – podstawa MTBF / MTTR.
+-- data_utworzenia = shift_summary.data_utworzenia (zapis w tej samej transakcji).
+CREATE TABLE IF NOT EXISTS stop_events (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ      NOT NULL,
+    start                 TIMESTAMPTZ      NOT NULL,   -- początek postoju
+    koniec                TIMESTAMPTZ,                 -- NULL = trwał na koniec zmiany
+    seconds               REAL,                        -- czas postoju w zmianie [s]
+    breakdown             BOOLEAN,                     -- >= tuning.breakdown_threshold_seconds
+
+    PRIMARY KEY (data_utworzenia, start)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('stop_events', 'data_utworzenia', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_stop_events_start ON stop_events(start DESC);
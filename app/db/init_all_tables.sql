@@ -66,48 +66,31 @@ CREATE TABLE IF NOT EXISTS shift_summary (
     jakosc                REAL,
     oee                   REAL,
 
-    -- dane z 5 analizatorów energii
-    analizator_1_t_ea_pos     REAL,
-    analizator_1_t_ea_neg     REAL,
-    analizator_1_t_er_pos     REAL,
-    analizator_1_t_er_neg     REAL,
-    analizator_1_t_es         REAL,
-    analizator_1_t_er         REAL,
-
-    analizator_2_t_ea_pos     REAL,
-    analizator_2_t_ea_neg     REAL,
-    analizator_2_t_er_pos     REAL,
-    analizator_2_t_er_neg     REAL,
-    analizator_2_t_es         REAL,
-    analizator_2_t_er         REAL,
-
-    analizator_3_t_ea_pos     REAL,
-    analizator_3_t_ea_neg     REAL,
-    analizator_3_t_er_pos     REAL,
-    analizator_3_t_er_neg     REAL,
-    analizator_3_t_es         REAL,
-    analizator_3_t_er         REAL,
-
-    analizator_4_t_ea_pos     REAL,
-    analizator_4_t_ea_neg     REAL,
-    analizator_4_t_er_pos     REAL,
-    analizator_4_t_er_neg     REAL,
-    analizator_4_t_es         REAL,
-    analizator_4_t_er         REAL,
-
-    analizator_5_t_ea_pos     REAL,
-    analizator_5_t_ea_neg     REAL,
-    analizator_5_t_er_pos     REAL,
-    analizator_5_t_er_neg     REAL,
-    analizator_5_t_es         REAL,
-    analizator_5_t_er         REAL,
-
-    -- dane z przepływomierzy powietrza
-    totaliser_1               REAL,
-    totaliser_2               REAL,
-    totaliser_3               REAL,
-    totaliser_4               REAL,
-    totaliser_5               REAL,
+    -- zużycie jednostkowe (dane per analizator / przepływomierz: shift_summary_device)
+    w_na_szt              REAL,
+    m3_na_szt             REAL,
+
+    -- mikroprzestoje (przerwy między elementami > micro_stop_factor × cykl idealny, poniżej idle timeout)
+    mikroprzestoje        INTEGER,
+    czas_mikroprzestojow  REAL,                        -- [s] ponad cykl idealny
+
+    -- prędkość obrotnicy w czasie pracy [obr/min] (tuning.nominal_speed_rpm)
+    predkosc_czas            REAL,                     -- [s] próbek
+    predkosc_srednia         REAL,
+    predkosc_min             REAL,
+    predkosc_max             REAL,
+    predkosc_nominalna       REAL,
+    czas_ponizej_nominalnej  REAL,                     -- [s]
+    strata_predkosci         REAL,                     -- [s] Σ dt·(1 − v/nominalna)
+
+    -- niezawodność (postoje bez przezbrojeń; historia: stop_events)
+    liczba_postojow          INTEGER,
+    liczba_awarii            INTEGER,
+    najdluzszy_postoj        REAL,                     -- [s]
+    mtbf                     REAL,                     -- [s] czas pracy / liczba postojów
+    mttr                     REAL,                     -- [s] czas postoju / liczba postojów
+
+    -- produkcja per produkt: shift_summary_product
 
     PRIMARY KEY (data_utworzenia)
 );
@@ -119,7 +102,6 @@ SELECT create_hypertable('shift_summary', 'data_utworzenia', if_not_exists => TR
 CREATE INDEX IF NOT EXISTS idx_shift_summary_start_zmiany ON shift_summary(start_zmiany);
 CREATE INDEX IF NOT EXISTS idx_shift_summary_koniec_zmiany ON shift_summary(koniec_zmiany);
 
-
 -- START: create_oee_temp.sql --
 CREATE TABLE IF NOT EXISTS oee_temp (
     timestamp            TIMESTAMPTZ      NOT NULL,
@@ -241,3 +223,209 @@ CREATE TABLE IF NOT EXISTS meters_t4_temp (
 SELECT create_hypertable('meters_t4_temp', 'timestamp', if_not_exists => TRUE);
 
 
+
+
+-- START: create_downtime_ack.sql --
+CREATE TABLE IF NOT EXISTS downtime_ack (
+    timestamp      TIMESTAMPTZ      NOT NULL,
+    reason         SMALLINT         NOT NULL,
+    source         TEXT,
+    pause_start    TIMESTAMPTZ,
+    pause_end      TIMESTAMPTZ,
+    pause_seconds  REAL,
+    PRIMARY KEY (timestamp)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('downtime_ack', 'timestamp', if_not_exists => TRUE);
+
+
+-- START: create_shift_summary_device.sql --
+-- Dane zmiany per urządzenie (dowolna liczba analizatorów i przepływomierzy).
+-- data_utworzenia = shift_summary.data_utworzenia (zapis w tej samej transakcji).
+CREATE TABLE IF NOT EXISTS shift_summary_device (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ      NOT NULL,
+    device_type           TEXT             NOT NULL,   -- 'analyzer' | 'flow'
+    device_id             SMALLINT         NOT NULL,   -- ANALYZER_IPnn / pozycja w FLOW_PORTS
+    source                TEXT,                        -- adres analizatora / port IO-Link
+    consumption           REAL,                        -- zużycie w zmianie
+    unit                  TEXT,                        -- 'Wh' | 'm3'
+    counter_start         REAL,                        -- stan licznika na początku zmiany
+    counter_end           REAL,                        -- stan licznika na końcu zmiany
+
+    -- liczniki analizatora (NULL dla przepływomierzy)
+    ea_pos                REAL,
+    ea_neg                REAL,
+    er_pos                REAL,
+    er_neg                REAL,
+    es                    REAL,
+    er                    REAL,
+
+    PRIMARY KEY (data_utworzenia, device_type, device_id)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('shift_summary_device', 'data_utworzenia', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_shift_summary_device_device ON shift_summary_device(device_type, device_id, data_utworzenia DESC);
+
+-- START: create_products.sql --
+-- Katalog produktów (dane podstawowe do cyklu idealnego). Edycja: API /api/products.
+-- Zakres wymiaru bez min/max (NULL) nie ogranicza dopasowania; min = max – dokładny wymiar
+-- (± PRODUCT_DIM_TOLERANCE_MM). Wymiary w jednostkach *_calc silnika OEE.
+CREATE TABLE IF NOT EXISTS products (
+    sku                   TEXT             NOT NULL,
+    name                  TEXT,
+    material              TEXT,
+    length_min            REAL,
+    length_max            REAL,
+    width_min             REAL,
+    width_max             REAL,
+    height_min            REAL,
+    height_max            REAL,
+    ideal_cycle_s         REAL             NOT NULL CHECK (ideal_cycle_s > 0),   -- [s/szt.]
+    target_scrap_pct      REAL             NOT NULL DEFAULT 0,                   -- [%]
+    active                BOOLEAN          NOT NULL DEFAULT TRUE,                -- FALSE = poza dopasowaniem
+    family                TEXT,                                                  -- rodzina: max czas przezbrojenia
+    updated_at            TIMESTAMPTZ      NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (sku)
+);
+
+-- START: create_shift_summary_product.sql --
+-- Produkcja zmiany per produkt (SKU z katalogu products albo 'cyklN' – reguła CycleTable).
+-- data_utworzenia = shift_summary.data_utworzenia (zapis w tej samej transakcji).
+CREATE TABLE IF NOT EXISTS shift_summary_product (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ      NOT NULL,
+    sku                   TEXT             NOT NULL,
+    name                  TEXT,                        -- nazwa z katalogu (NULL dla 'cyklN')
+    pieces                INTEGER,                     -- wyprodukowane sztuki
+    work_seconds          REAL,                        -- czas pracy na produkcie [s]
+    ideal_cycle_s         REAL,                        -- cykl idealny [s/szt.]
+    expected_pieces       REAL,                        -- work_seconds / ideal_cycle_s
+    wydajnosc             REAL,                        -- pieces / expected_pieces
+    micro_stops           INTEGER,                     -- mikroprzestoje na produkcie
+    micro_stop_seconds    REAL,                        -- czas mikroprzestojów [s]
+
+    PRIMARY KEY (data_utworzenia, sku)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('shift_summary_product', 'data_utworzenia', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_shift_summary_product_sku ON shift_summary_product(sku, data_utworzenia DESC);
+
+-- START: create_production_orders.sql --
+-- Zlecenia produkcyjne (start/stop przez API /api/orders albo temat MQTT_ORDER_TOPIC).
+-- Produkcja, czasy i media przypisane od startu do zakończenia zlecenia; trwające zlecenie
+-- aktualizowane co 30 s. Ponowny start tego samego numeru = nowy wiersz (inny started_at).
+CREATE TABLE IF NOT EXISTS production_orders (
+    order_no              TEXT             NOT NULL,
+    started_at            TIMESTAMPTZ      NOT NULL,
+    sku                   TEXT,                        -- NULL = produkt wg wymiarów
+    planned_qty           INTEGER          NOT NULL DEFAULT 0,   -- 0 = bez planu
+    status                TEXT             NOT NULL,   -- running | finished
+    ended_at              TIMESTAMPTZ,
+    pieces                INTEGER,                     -- wyprodukowane sztuki
+    rejects               INTEGER,                     -- odrzuty (jakosc = (pieces - rejects) / pieces)
+    run_seconds           REAL,                        -- czas pracy [s]
+    downtime_seconds      REAL,                        -- czas postoju [s]
+    changeover_seconds    REAL,                        -- czas przezbrojenia [s]
+    energy_wh             REAL,                        -- energia [Wh]
+    air_m3                REAL,                        -- powietrze [m3]
+    expected_pieces       REAL,                        -- run_seconds / cykl idealny
+    dostepnosc            REAL,
+    wydajnosc             REAL,
+    jakosc                REAL,
+    oee                   REAL,
+    updated_at            TIMESTAMPTZ      NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (order_no, started_at)
+);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_production_orders_started ON production_orders(started_at DESC);
+
+-- START: create_shift_summary_loss.sql --
+-- Six Big Losses zmiany (TPM): jeden wiersz na kategorię – breakdowns, setup, minor_stops,
+-- reduced_speed, startup_rejects, production_rejects.
+-- data_utworzenia = shift_summary.data_utworzenia (zapis w tej samej transakcji).
+CREATE TABLE IF NOT EXISTS shift_summary_loss (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ      NOT NULL,
+    category              TEXT             NOT NULL,
+    seconds               REAL,                        -- czas utracony [s]
+    pieces                INTEGER,                     -- odrzuty [szt.] (tylko kategorie *_rejects)
+
+    PRIMARY KEY (data_utworzenia, category)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('shift_summary_loss', 'data_utworzenia', if_not_exists => TRUE);
+
+-- START: create_stop_events.sql --
+-- Historia postojów zmiany (pauzy bez przezbrojenia) – podstawa MTBF / MTTR.
+-- data_utworzenia = shift_summary.data_utworzenia (zapis w tej samej transakcji).
+CREATE TABLE IF NOT EXISTS stop_events (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ      NOT NULL,
+    start                 TIMESTAMPTZ      NOT NULL,   -- początek postoju
+    koniec                TIMESTAMPTZ,                 -- NULL = trwał na koniec zmiany
+    seconds               REAL,                        -- czas postoju w zmianie [s]
+    breakdown             BOOLEAN,                     -- >= tuning.breakdown_threshold_seconds
+
+    PRIMARY KEY (data_utworzenia, start)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('stop_events', 'data_utworzenia', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_stop_events_start ON stop_events(start DESC);
+
+-- START: create_machine_events.sql --
+-- Dziennik zdarzeń maszyny (tylko dopisywanie): stany run / stop / changeover / machine_off
+-- jako przedziały i chwilowe cycle_change – osie czasu w Grafanie, ponowne przeliczenia OEE.
+CREATE TABLE IF NOT EXISTS machine_events (
+    start_time            TIMESTAMPTZ      NOT NULL,
+    type                  TEXT             NOT NULL,   -- run | stop | changeover | machine_off | cycle_change
+    end_time              TIMESTAMPTZ      NOT NULL,   -- cycle_change: = start_time
+    seconds               REAL,
+    cycle                 REAL,                        -- cykl idealny [szt/min]
+    sku                   TEXT,                        -- produkt z katalogu albo "cyklN"
+    from_sku              TEXT,                        -- changeover: produkt przed pauzą
+    to_sku                TEXT,                        -- changeover: produkt po pauzie
+    reason                TEXT,                        -- changeover: signal | order | dimensions
+
+    PRIMARY KEY (start_time, type)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('machine_events', 'start_time', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_machine_events_type ON machine_events(type, start_time DESC);
+
+-- START: create_shift_summary_revision.sql --
+-- Poprzednie wersje zmian nadpisanych przez cmd/recompute -apply: wiersz shift_summary
+-- z produktami, stratami i postojami sprzed przeliczenia oraz nowe wartości.
+CREATE TABLE IF NOT EXISTS shift_summary_revision (
+    data_utworzenia       TIMESTAMPTZ      NOT NULL,   -- klucz zmiany w shift_summary
+    revised_at            TIMESTAMPTZ      NOT NULL,
+    start_zmiany          TIMESTAMPTZ,
+    reason                TEXT,
+    old_values            JSONB,                       -- shift_summary, products, losses, stops
+    new_values            JSONB,
+
+    PRIMARY KEY (data_utworzenia, revised_at)
+);
+
+-- Konwersja na hypertable
+SELECT create_hypertable('shift_summary_revision', 'data_utworzenia', if_not_exists => TRUE);
+
+-- Indeksy pomocnicze
+CREATE INDEX IF NOT EXISTS idx_shift_summary_revision_revised ON shift_summary_revision(revised_at DESC);
@@ -0,0 +1,9 @@
+-- Migracja istniejącej bazy: przezbrojenie rozpoznawane po zmianie wymiarów, sygnale albo zleceniu.
+-- Rodzina produktu wybiera maksymalny czas przezbrojenia (tuning.max_changeover_by_family);
+-- zdarzenia changeover zapisują produkt przed i po oraz powód. Starsze wiersze pozostają z NULL.
+-- Po create_products.sql i create_machine_events.sql (db.Migrations).
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS family TEXT;
+ALTER TABLE machine_events ADD COLUMN IF NOT EXISTS from_sku TEXT;
+ALTER TABLE machine_events ADD COLUMN IF NOT EXISTS to_sku TEXT;
+ALTER TABLE machine_events ADD COLUMN IF NOT EXISTS reason TEXT;
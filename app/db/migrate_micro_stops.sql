@@ -0,0 +1,8 @@
+-- Migracja istniejącej bazy: liczba i czas mikroprzestojów per zmiana i per produkt.
+-- Starsze zmiany pozostają z NULL (mikroprzestoje nie były liczone).
+
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS mikroprzestoje INTEGER;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS czas_mikroprzestojow REAL;
+
+ALTER TABLE shift_summary_product ADD COLUMN IF NOT EXISTS micro_stops INTEGER;
+ALTER TABLE shift_summary_product ADD COLUMN IF NOT EXISTS micro_stop_seconds REAL;
@@ -0,0 +1,4 @@
+-- Migracja istniejącej bazy: odrzuty zlecenia produkcyjnego (jakość zlecenia).
+-- Starsze zlecenia pozostają z NULL (liczone jako bez odrzutów).
+
+ALTER TABLE production_orders ADD COLUMN IF NOT EXISTS rejects INTEGER;
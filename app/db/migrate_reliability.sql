@@ -0,0 +1,8 @@
+-- Migracja istniejącej bazy: wskaźniki niezawodności per zmiana (historia postojów:
+-- create_stop_events.sql). Starsze zmiany pozostają z NULL (pomijane w /api/reliability/history).
+
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS liczba_postojow INTEGER;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS liczba_awarii INTEGER;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS najdluzszy_postoj REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS mtbf REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS mttr REAL;
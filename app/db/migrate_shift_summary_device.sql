@@ -0,0 +1,41 @@
+-- Migracja istniejącej bazy: kolumny analizator_N_* / totaliser_N z shift_summary
+-- przeniesione do shift_summary_device (po create_shift_summary_device.sql). Idempotentna:
+-- baza bez starych kolumn nic nie kopiuje, ponowne kopiowanie pomija istniejące wiersze.
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'shift_summary' AND column_name = 'analizator_1_ea_pos') THEN
+        INSERT INTO shift_summary_device (
+            data_utworzenia, start_zmiany, device_type, device_id,
+            ea_pos, ea_neg, er_pos, er_neg, es, er
+        )
+        SELECT s.data_utworzenia, s.start_zmiany, 'analyzer', a.id,
+               a.ea_pos, a.ea_neg, a.er_pos, a.er_neg, a.es, a.er
+        FROM shift_summary s
+        CROSS JOIN LATERAL (VALUES
+            (1, s.analizator_1_ea_pos, s.analizator_1_ea_neg, s.analizator_1_er_pos, s.analizator_1_er_neg, s.analizator_1_es, s.analizator_1_er),
+            (2, s.analizator_2_ea_pos, s.analizator_2_ea_neg, s.analizator_2_er_pos, s.analizator_2_er_neg, s.analizator_2_es, s.analizator_2_er),
+            (3, s.analizator_3_ea_pos, s.analizator_3_ea_neg, s.analizator_3_er_pos, s.analizator_3_er_neg, s.analizator_3_es, s.analizator_3_er)
+        ) AS a(id, ea_pos, ea_neg, er_pos, er_neg, es, er)
+        ON CONFLICT DO NOTHING;
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'shift_summary' AND column_name = 'totaliser_1') THEN
+        INSERT INTO shift_summary_device (data_utworzenia, start_zmiany, device_type, device_id, consumption, unit)
+        SELECT s.data_utworzenia, s.start_zmiany, 'flow', f.id, f.consumption, 'm3'
+        FROM shift_summary s
+        CROSS JOIN LATERAL (VALUES
+            (1, s.totaliser_1), (2, s.totaliser_2), (3, s.totaliser_3), (4, s.totaliser_4), (5, s.totaliser_5)
+        ) AS f(id, consumption)
+        ON CONFLICT DO NOTHING;
+    END IF;
+END $$;
+
+-- Kolumny nie są już zapisywane; po sprawdzeniu danych można je usunąć:
+-- ALTER TABLE shift_summary
+--     DROP COLUMN analizator_1_ea_pos, ... , DROP COLUMN totaliser_5;
+-- Nowe kolumny zapisywane przez aplikację (jeśli tabela powstała ze starego skryptu):
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS w_na_szt  REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS m3_na_szt REAL;
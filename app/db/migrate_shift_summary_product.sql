@@ -0,0 +1,22 @@
+-- Migracja istniejącej bazy: kolumny cykl0..cykl3 z shift_summary przeniesione do
+-- shift_summary_product (sku 'cykl0'..'cykl3'; bez czasu pracy – nie był zapisywany).
+-- Po create_shift_summary_product.sql; baza bez starych kolumn nic nie kopiuje.
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'shift_summary' AND column_name = 'cykl0') THEN
+        INSERT INTO shift_summary_product (data_utworzenia, start_zmiany, sku, pieces)
+        SELECT s.data_utworzenia, s.start_zmiany, c.sku, c.pieces::INTEGER
+        FROM shift_summary s
+        CROSS JOIN LATERAL (VALUES
+            ('cykl0', s.cykl0), ('cykl1', s.cykl1), ('cykl2', s.cykl2), ('cykl3', s.cykl3)
+        ) AS c(sku, pieces)
+        WHERE c.pieces > 0
+        ON CONFLICT DO NOTHING;
+    END IF;
+END $$;
+
+-- Kolumny nie są już zapisywane; po sprawdzeniu danych można je usunąć:
+-- ALTER TABLE shift_summary
+--     DROP COLUMN cykl0, DROP COLUMN cykl1, DROP COLUMN cykl2, DROP COLUMN cykl3;
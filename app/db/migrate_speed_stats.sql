@@ -0,0 +1,10 @@
+-- Migracja istniejącej bazy: statystyki prędkości obrotnicy per zmiana.
+-- Starsze zmiany pozostają z NULL (nie pokazywane w /api/speed/shifts).
+
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS predkosc_czas REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS predkosc_srednia REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS predkosc_min REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS predkosc_max REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS predkosc_nominalna REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS czas_ponizej_nominalnej REAL;
+ALTER TABLE shift_summary ADD COLUMN IF NOT EXISTS strata_predkosci REAL;
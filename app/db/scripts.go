@@ -0,0 +1,40 @@
+// Package db – skrypty SQL schematu. Skrypty init (go_init.sql, init_all_tables.sql) działają
+// tylko przy tworzeniu bazy; migracje z Migrations są idempotentne i program stosuje je sam
+// przy pierwszym połączeniu z bazą (core/schema.go).
+package db
+
+import "embed"
+
+//go:embed create_shift_summary_device.sql migrate_shift_summary_device.sql
+//go:embed create_shift_summary_product.sql migrate_shift_summary_product.sql create_shift_summary_loss.sql
+//go:embed migrate_micro_stops.sql migrate_speed_stats.sql create_stop_events.sql migrate_reliability.sql migrate_changeover.sql
+//go:embed create_products.sql
+//go:embed create_production_orders.sql migrate_order_rejects.sql
+//go:embed create_machine_events.sql
+//go:embed create_shift_summary_revision.sql
+var scripts embed.FS
+
+// Migrations – skrypty stosowane przy starcie, w kolejności.
+var Migrations = []string{
+	"create_shift_summary_device.sql",
+	"migrate_shift_summary_device.sql",
+	"create_shift_summary_product.sql",
+	"migrate_shift_summary_product.sql",
+	"create_shift_summary_loss.sql",
+	"create_products.sql",
+	"create_production_orders.sql",
+	"migrate_order_rejects.sql",
+	"create_machine_events.sql",
+	"create_shift_summary_revision.sql",
+	"migrate_micro_stops.sql",
+	"migrate_speed_stats.sql",
+	"create_stop_events.sql",
+	"migrate_reliability.sql",
+	"migrate_changeover.sql",
+}
+
+// Script – treść skryptu name.
+func Script(name string) (string, error) {
+	b, err := scripts.ReadFile(name)
+	return string(b), err
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"regexp"
+	"testing"
+)
+
+var (
+	createRe = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS (\w+)`)
+	alterRe  = regexp.MustCompile(`(?im)^\s*(?:ALTER TABLE|INSERT INTO) (\w+)`)
+)
+
+// TestMigrationsOrder – każda migracja zmienia tylko tabele z pierwszej wersji schematu albo
+// utworzone przez wcześniejszą migrację (na istniejącej bazie skrypty init już nie działają).
+func TestMigrationsOrder(t *testing.T) {
+	created := map[string]bool{"shift_summary": true}
+	for _, name := range Migrations {
+		script, err := Script(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range alterRe.FindAllStringSubmatch(script, -1) {
+			if !created[m[1]] {
+				t.Errorf("%s: table %s is not created by an earlier migration", name, m[1])
+			}
+		}
+		for _, m := range createRe.FindAllStringSubmatch(script, -1) {
+			created[m[1]] = true
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"fmt"
+	"go_app/api"
 	"go_app/communication"
 	"go_app/config"
 	"go_app/core"
+	"go_app/plc"
 	"go_app/utils"
 	"os"
 	"os/signal"
 	"runtime/debug"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -18,121 +22,174 @@ func main() {
 			utils.LogMessage("[FATAL] PANIC in main thread: " + utils.RecoverToString(r) + "\n" + string(debug.Stack()))
 		}
 	}()
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		utils.LogMessage("[ERROR] [CONFIG] " + err.Error())
+		utils.CloseLog()
+		os.Exit(2)
+	}
 	utils.LogMessage("[SYSTEM] Program started")
+	if config.ConfigFile != "" {
+		utils.LogMessage("[CONFIG] Loaded " + config.ConfigFile)
+	}
+	core.StartProductCatalogue() // katalog produktów przed pierwszym przeliczeniem OEE
 	core.LoadOeeFromJSONFile(config.OeeFilePath)
-	communication.RunMQTT()
-	communication.RunRestCommunication()
+	core.LoadOrderState() // trwające zlecenie produkcyjne (po liczniku OEE)
+	if config.MqttOrderTopic != "" {
+		communication.HandleCommand(config.MqttOrderTopic, core.HandleOrderCommand)
+	}
+	// zlecenia z API w nagraniu MQTT i ich odtworzenie przy replay (MQTT_REPLAY_PATH)
+	core.InputRecorder = communication.RecordInput
+	communication.HandleInput(core.InputTopicOrder, core.HandleOrderCommand)
+	communication.HandleInput(core.InputTopicProduct, core.HandleProductCommand)
+	communication.HandleInput(core.InputTopicChangeover, core.HandleChangeoverCommand)
+	communication.Start() // broker/REST, replay nagrania albo symulacja (SIMULATION=1)
 	core.StartShiftScheduler()
+	core.StartOrderUpdater()
+	core.StartMachineEventWriter()
+	plc.StartModbusServer() // MODBUS_SERVER_ADDR – wartości OEE dla PLC
+	plc.StartOpcUaServer()  // OPCUA_ENDPOINT – model OEE dla klientów OPC UA
+	api.Start()             // HTTP_ADDR – /healthz, /readyz, /api/...
 
 	// --- REST + METERS Fetcher ---
-	utils.Go("REST+METERS Fetcher", func() {
-		for {
-			func() {
-				defer utils.Catch("REST+METERS Fetcher")()
-				restData := communication.GetRestData()
-				metersData := communication.GetMetersData()
-				utils.SaveToJSON(restData, config.MeasurementFilePath)
-				utils.SaveToJSON(metersData, config.MetersFilePath)
-			}()
-			time.Sleep(500 * time.Millisecond)
-		}
-	})
+	utils.SuperviseLoop("REST+METERS Fetcher", 500*time.Millisecond, saveAnalyzerData)
 
 	// --- MQTT + OEE ---
-	utils.Go("MQTT + OEE", func() {
-		for {
-			func() {
-				defer utils.Catch("MQTT + OEE")()
-
-				if core.IsResetScheduled() {
-					core.ResetOeeStateAndFile(config.OeeFilePath)
-					core.ClearResetFlag()
-				}
-
-				mqttData := communication.GetMQTTData()
-
-				mqttOEE := map[string]map[string]interface{}{
-					"master1/port1": mqttData["master1/port1"],
-					"master1/port2": mqttData["master1/port2"],
-				}
-				mqttFlow := map[string]map[string]interface{}{
-					"master1/port3": mqttData["master1/port3"],
-					"master1/port4": mqttData["master1/port4"],
-					"master2/port0": mqttData["master2/port0"],
-					"master2/port1": mqttData["master2/port1"],
-					"master2/port2": mqttData["master2/port2"],
-				}
-				utils.SaveToJSON(mqttOEE, config.MqttOeeFilePath)
-				utils.SaveToJSON(mqttFlow, config.MqttFlowFilePath)
-
-				// --- wyliczanie OEE ---
-				core.CalculateData(mqttData)
-
-				// --- zapis OEE w nowej strukturze ---
-				core.SaveOeeFlat(config.OeeFilePath)
-			}()
-			time.Sleep(config.IntervalMQTTData)
-		}
-	})
+	utils.SuperviseLoop("MQTT + OEE", config.IntervalMQTTData, updateOee)
 
 	// --- MEASUREMENTS to DB ---
-	utils.Go("MEASUREMENTS to DB", func() {
-		for {
-			func() {
-				defer utils.Catch("MEASUREMENTS to DB")()
-				core.SaveMeasurementsToDB(config.MeasurementFilePath)
-			}()
-			time.Sleep(config.MeasurementUpdateInterval)
-		}
+	utils.SuperviseLoop("MEASUREMENTS to DB", config.MeasurementUpdateInterval, func() {
+		core.SaveMeasurementsToDB(config.MeasurementFilePath)
 	})
 
 	// --- METERS to DB ---
-	utils.Go("METERS to DB", func() {
-		for {
-			func() {
-				defer utils.Catch("METERS to DB")()
-				core.SaveMetersToDB(config.MetersFilePath)
-			}()
-			time.Sleep(config.MetersUpdateInterval)
-		}
+	utils.SuperviseLoop("METERS to DB", config.MetersUpdateInterval, func() {
+		core.SaveMetersToDB(config.MetersFilePath)
 	})
 
 	// --- FLOW to DB ---
-	utils.Go("FLOW to DB", func() {
-		for {
-			func() {
-				defer utils.Catch("FLOW to DB")()
-				core.SaveFlowDataToDB(config.MqttFlowFilePath)
-			}()
-			time.Sleep(config.FlowUpdateInterval)
-		}
+	utils.SuperviseLoop("FLOW to DB", config.FlowUpdateInterval, func() {
+		core.SaveFlowDataToDB(config.MqttFlowFilePath)
 	})
 
 	// --- OEE to DB ---
-	utils.Go("OEE to DB", func() {
-		for {
-			func() {
-				defer utils.Catch("OEE to DB")()
-				core.SaveOeeTempToDB(config.OeeFilePath)
-			}()
-			time.Sleep(config.OEEUpdateInterval)
-		}
+	utils.SuperviseLoop("OEE to DB", config.OEEUpdateInterval, func() {
+		core.SaveOeeTempToDB()
 	})
 
 	// --- ALIVE Logger ---
-	utils.Go("ALIVE Logger", func() {
-		for {
-			func() {
-				defer utils.Catch("ALIVE Logger")()
-				utils.LogMessage("[SYSTEM] App is alive")
-			}()
-			time.Sleep(30 * time.Minute)
-		}
+	utils.SuperviseLoop("ALIVE Logger", 30*time.Minute, func() {
+		utils.LogMessage("[SYSTEM] App is alive")
 	})
 
-	// --- sygnał stop (graceful w Dockerze) ---
+	// --- CONFIG_FILE: przeładowanie po zmianie pliku ---
+	if config.ConfigFile != "" {
+		utils.SuperviseLoop("CONFIG watcher", config.ConfigWatchInterval, func() {
+			if config.ConfigFileChanged() {
+				reloadConfig("file changed")
+			}
+		})
+	}
+
+	// --- sygnał stop (graceful w Dockerze), SIGHUP = przeładowanie CONFIG_FILE ---
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-	<-sig
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for s := range sig {
+		if s == syscall.SIGHUP {
+			reloadConfig("SIGHUP")
+			continue
+		}
+		break
+	}
 	utils.LogMessage("[SYSTEM] Stop signal received – shutting down.")
+	shutdown()
+}
+
+// reloadConfig – ponowny odczyt CONFIG_FILE; błędny plik nie zmienia bieżących parametrów.
+func reloadConfig(reason string) {
+	res, err := config.Reload()
+	if err != nil {
+		utils.LogMessage(fmt.Sprintf("[WARNING] [CONFIG] Reload (%s) rejected, keeping current settings: %v", reason, err))
+		return
+	}
+	if len(res.Changed) == 0 && len(res.RestartRequired) == 0 {
+		utils.LogMessage(fmt.Sprintf("[CONFIG] Reload (%s): no changes", reason))
+		return
+	}
+	if len(res.Changed) > 0 {
+		utils.LogMessage(fmt.Sprintf("[CONFIG] Reload (%s) applied: %s", reason, strings.Join(res.Changed, ", ")))
+	}
+	if len(res.RestartRequired) > 0 {
+		utils.LogMessage(fmt.Sprintf("[WARNING] [CONFIG] Changed settings take effect after restart: %s (all pending: %s)",
+			strings.Join(res.RestartRequired, ", "), strings.Join(res.PendingRestart, ", ")))
+	}
+}
+
+// shutdown – kolejność: zatrzymanie pętli (źródła, serwery PLC, zapisy DB kończą bieżącą
+// iterację, zadania jednorazowe – np. potwierdzenia postojów – dobiegają końca), końcowy zapis
+// oee_temp i zapis kolejki niezapisanych próbek, ostatnie przeliczenie OEE z danymi odebranymi
+// po ostatniej iteracji i zapis oee.json, końcowy zapis zleceń produkcyjnych i zdarzeń maszyny
+// do DB (i kolejki niezapisanych zdarzeń), zamknięcie logu.
+func shutdown() {
+	if !utils.Shutdown(config.ShutdownTimeout) {
+		utils.LogMessage("[SYSTEM] Not all workers stopped in time – saving state anyway")
+	}
+	func() {
+		defer utils.Catch("shutdown: final OEE to DB")()
+		core.SaveOeeTempToDB()
+		core.SaveOeeTempPending()
+	}()
+	func() {
+		defer utils.Catch("shutdown: final OEE update")()
+		updateOee()
+	}()
+	func() {
+		defer utils.Catch("shutdown: final orders save")()
+		core.SaveOrders()
+	}()
+	func() {
+		defer utils.Catch("shutdown: final machine events save")()
+		core.SaveMachineEventsToDB()
+		core.SaveMachineEventsPending()
+	}()
+	utils.LogMessage("[SYSTEM] Shutdown complete")
+	utils.CloseLog()
+}
+
+// saveAnalyzerData – bieżące dane analizatorów do measurements.json, meters.json i analyzer_*.json.
+func saveAnalyzerData() {
+	restData := communication.GetRestData()
+	metersData := communication.GetMetersData()
+	utils.SaveToJSON(restData, config.MeasurementFilePath)
+	utils.SaveToJSON(metersData, config.MetersFilePath)
+	for name, data := range communication.GetAnalyzerEndpointData() {
+		utils.SaveToJSON(data, fmt.Sprintf(config.AnalyzerEndpointFileFmt, name))
+	}
+}
+
+// updateOee – jedna iteracja MQTT + OEE: zapis surowych danych portów, wyliczenie OEE, zapis oee.json.
+func updateOee() {
+	if core.IsResetScheduled() {
+		core.ResetOeeStateAndFile(config.OeeFilePath)
+		core.ClearResetFlag()
+	}
+
+	mqttData := communication.GetMQTTData()
+
+	mqttOEE := map[string]map[string]interface{}{}
+	for _, port := range config.OeePorts {
+		mqttOEE[port] = mqttData[port]
+	}
+	mqttFlow := map[string]map[string]interface{}{}
+	for _, port := range config.FlowPorts {
+		mqttFlow[port] = mqttData[port]
+	}
+	utils.SaveToJSON(mqttOEE, config.MqttOeeFilePath)
+	utils.SaveToJSON(mqttFlow, config.MqttFlowFilePath)
+
+	// --- wyliczanie OEE ---
+	core.CalculateData(mqttData)
+
+	// --- zapis OEE w nowej strukturze ---
+	core.SaveOeeFlat(config.OeeFilePath)
 }
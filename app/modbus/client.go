@@ -0,0 +1,163 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client – klient Modbus TCP z jednym połączeniem, odnawianym po błędzie.
+// Bezpieczny dla wielu gorutyn (żądania są serializowane).
+type Client struct {
+	Address string
+	UnitID  byte
+	Timeout time.Duration
+
+	mu          sync.Mutex
+	conn        net.Conn
+	transaction uint16
+}
+
+// NewClient tworzy klienta; połączenie nawiązywane jest przy pierwszym żądaniu.
+func NewClient(address string, unitID byte, timeout time.Duration) *Client {
+	return &Client{Address: address, UnitID: unitID, Timeout: timeout}
+}
+
+// Close zamyka połączenie (następne żądanie połączy się ponownie).
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeLocked()
+}
+
+func (c *Client) closeLocked() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// ReadHoldingRegisters – funkcja 0x03.
+func (c *Client) ReadHoldingRegisters(address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(FuncReadHoldingRegisters, address, quantity)
+}
+
+// ReadInputRegisters – funkcja 0x04.
+func (c *Client) ReadInputRegisters(address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(FuncReadInputRegisters, address, quantity)
+}
+
+// ReadCoils – funkcja 0x01.
+func (c *Client) ReadCoils(address, quantity uint16) ([]bool, error) {
+	if quantity == 0 || quantity > maxReadCoils {
+		return nil, fmt.Errorf("modbus: invalid coil quantity %d", quantity)
+	}
+	resp, err := c.do(FuncReadCoils, addrQty(address, quantity))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 1 || int(resp[0]) != len(resp)-1 || len(resp)-1 < (int(quantity)+7)/8 {
+		return nil, fmt.Errorf("modbus: short coil response")
+	}
+	return unpackBits(resp[1:], int(quantity)), nil
+}
+
+// WriteSingleCoil – funkcja 0x05.
+func (c *Client) WriteSingleCoil(address uint16, value bool) error {
+	v := uint16(0x0000)
+	if value {
+		v = 0xFF00
+	}
+	_, err := c.do(FuncWriteSingleCoil, addrQty(address, v))
+	return err
+}
+
+// WriteSingleRegister – funkcja 0x06.
+func (c *Client) WriteSingleRegister(address, value uint16) error {
+	_, err := c.do(FuncWriteSingleRegister, addrQty(address, value))
+	return err
+}
+
+// WriteMultipleRegisters – funkcja 0x10.
+func (c *Client) WriteMultipleRegisters(address uint16, values []uint16) error {
+	if len(values) == 0 || len(values) > 123 {
+		return fmt.Errorf("modbus: invalid register quantity %d", len(values))
+	}
+	data := append(addrQty(address, uint16(len(values))), byte(2*len(values)))
+	data = append(data, putUint16s(values)...)
+	_, err := c.do(FuncWriteMultipleRegisters, data)
+	return err
+}
+
+func (c *Client) readRegisters(function byte, address, quantity uint16) ([]uint16, error) {
+	if quantity == 0 || quantity > maxReadRegisters {
+		return nil, fmt.Errorf("modbus: invalid register quantity %d", quantity)
+	}
+	resp, err := c.do(function, addrQty(address, quantity))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 1 || int(resp[0]) != len(resp)-1 || int(resp[0]) != 2*int(quantity) {
+		return nil, fmt.Errorf("modbus: unexpected response length")
+	}
+	return getUint16s(resp[1:]), nil
+}
+
+func addrQty(a, b uint16) []byte {
+	out := make([]byte, 4)
+	binary.BigEndian.PutUint16(out[0:], a)
+	binary.BigEndian.PutUint16(out[2:], b)
+	return out
+}
+
+// do wysyła PDU i zwraca dane odpowiedzi. Błąd sieci zamyka połączenie,
+// wyjątek Modbus – nie (urządzenie odpowiada poprawnie).
+func (c *Client) do(function byte, data []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.Address, c.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+
+	c.transaction++
+	req := frame{transaction: c.transaction, unit: c.UnitID, function: function, data: data}
+	if c.Timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(c.Timeout))
+	}
+	if _, err := c.conn.Write(req.encode()); err != nil {
+		_ = c.closeLocked()
+		return nil, err
+	}
+
+	for {
+		resp, err := readFrame(c.conn)
+		if err != nil {
+			_ = c.closeLocked()
+			return nil, err
+		}
+		if resp.transaction != req.transaction {
+			continue // spóźniona odpowiedź na wcześniejsze (przeterminowane) żądanie
+		}
+		if resp.function == function|0x80 {
+			code := byte(0)
+			if len(resp.data) > 0 {
+				code = resp.data[0]
+			}
+			return nil, &ExceptionError{Function: function, Code: code}
+		}
+		if resp.function != function {
+			_ = c.closeLocked()
+			return nil, fmt.Errorf("modbus: unexpected function 0x%02x in response", resp.function)
+		}
+		return resp.data, nil
+	}
+}
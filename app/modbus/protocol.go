@@ -0,0 +1,120 @@
+// Package modbus – minimalna implementacja Modbus TCP (klient i serwer) dla analizatorów
+// energii i dla udostępniania wartości OEE sterownikom PLC.
+// Obsługiwane funkcje: 0x01, 0x03, 0x04, 0x05, 0x06, 0x0F, 0x10.
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Kody funkcji.
+const (
+	FuncReadCoils              byte = 0x01
+	FuncReadHoldingRegisters   byte = 0x03
+	FuncReadInputRegisters     byte = 0x04
+	FuncWriteSingleCoil        byte = 0x05
+	FuncWriteSingleRegister    byte = 0x06
+	FuncWriteMultipleCoils     byte = 0x0F
+	FuncWriteMultipleRegisters byte = 0x10
+)
+
+// Kody wyjątków.
+const (
+	ExceptionIllegalFunction    byte = 0x01
+	ExceptionIllegalDataAddress byte = 0x02
+	ExceptionIllegalDataValue   byte = 0x03
+	ExceptionDeviceFailure      byte = 0x04
+)
+
+const (
+	maxReadRegisters = 125
+	maxReadCoils     = 2000
+	mbapHeaderLen    = 7
+	maxADU           = 260
+)
+
+// ExceptionError – odpowiedź wyjątku od urządzenia.
+type ExceptionError struct {
+	Function byte
+	Code     byte
+}
+
+func (e *ExceptionError) Error() string {
+	return fmt.Sprintf("modbus exception 0x%02x (function 0x%02x)", e.Code, e.Function)
+}
+
+// frame – ramka ADU Modbus TCP (nagłówek MBAP + PDU).
+type frame struct {
+	transaction uint16
+	unit        byte
+	function    byte
+	data        []byte
+}
+
+func (f frame) encode() []byte {
+	out := make([]byte, mbapHeaderLen+1+len(f.data))
+	binary.BigEndian.PutUint16(out[0:], f.transaction)
+	binary.BigEndian.PutUint16(out[2:], 0) // protokół Modbus
+	binary.BigEndian.PutUint16(out[4:], uint16(2+len(f.data)))
+	out[6] = f.unit
+	out[7] = f.function
+	copy(out[8:], f.data)
+	return out
+}
+
+func readFrame(r io.Reader) (frame, error) {
+	var hdr [mbapHeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return frame{}, err
+	}
+	length := int(binary.BigEndian.Uint16(hdr[4:]))
+	if binary.BigEndian.Uint16(hdr[2:]) != 0 || length < 2 || mbapHeaderLen+length-1 > maxADU {
+		return frame{}, fmt.Errorf("modbus: invalid MBAP header")
+	}
+	body := make([]byte, length-1)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return frame{}, err
+	}
+	return frame{
+		transaction: binary.BigEndian.Uint16(hdr[0:]),
+		unit:        hdr[6],
+		function:    body[0],
+		data:        body[1:],
+	}, nil
+}
+
+func putUint16s(values []uint16) []byte {
+	out := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(out[2*i:], v)
+	}
+	return out
+}
+
+func getUint16s(b []byte) []uint16 {
+	out := make([]uint16, len(b)/2)
+	for i := range out {
+		out[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return out
+}
+
+func packBits(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+func unpackBits(b []byte, n int) []bool {
+	out := make([]bool, n)
+	for i := range out {
+		out[i] = b[i/8]&(1<<(i%8)) != 0
+	}
+	return out
+}
@@ -0,0 +1,295 @@
+package modbus
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rodzaje rekordów – odpowiedniki /api/v1/measurements i /api/v1/meters.
+const (
+	KindMeasurement = "measurement"
+	KindMeter       = "meter"
+)
+
+// Obszary rejestrów.
+const (
+	AreaInput   = "input"
+	AreaHolding = "holding"
+)
+
+// RegisterDef – opis jednej wartości w mapie rejestrów urządzenia.
+type RegisterDef struct {
+	ID        string  `yaml:"id"`         // id rekordu jak w REST (u1, ea_pos_total, ...)
+	Address   uint16  `yaml:"address"`    // adres pierwszego rejestru (od 0)
+	Type      string  `yaml:"type"`       // uint16, int16, uint32, int32, float32, uint64, float64
+	Scale     float64 `yaml:"scale"`      // mnożnik wartości surowej (0 = 1)
+	WordOrder string  `yaml:"word_order"` // "big" – starsze słowo pierwsze (domyślnie), "little"
+	Unit      string  `yaml:"unit"`
+	Kind      string  `yaml:"kind"` // measurement (domyślnie) albo meter
+	Area      string  `yaml:"area"` // input (domyślnie) albo holding
+}
+
+// RegisterMap – mapa rejestrów analizatora.
+type RegisterMap struct {
+	Name      string        `yaml:"name"`
+	Registers []RegisterDef `yaml:"registers"`
+}
+
+// Value – odczytana i przeskalowana wartość.
+type Value struct {
+	ID    string
+	Value float64
+	Unit  string
+	Kind  string
+}
+
+// LoadRegisterMap czyta mapę rejestrów z pliku YAML (JSON też jest poprawnym YAML-em).
+func LoadRegisterMap(path string) (*RegisterMap, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m RegisterMap
+	if err := yaml.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := m.normalize(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func (m *RegisterMap) normalize() error {
+	if len(m.Registers) == 0 {
+		return fmt.Errorf("register map has no registers")
+	}
+	seen := map[string]bool{}
+	for i := range m.Registers {
+		d := &m.Registers[i]
+		if d.ID == "" {
+			return fmt.Errorf("register %d: id is required", i+1)
+		}
+		if seen[d.ID] {
+			return fmt.Errorf("register %s: duplicate id", d.ID)
+		}
+		seen[d.ID] = true
+		if d.Words() == 0 {
+			return fmt.Errorf("register %s: unknown type %q", d.ID, d.Type)
+		}
+		if d.Scale == 0 {
+			d.Scale = 1
+		}
+		if d.WordOrder == "" {
+			d.WordOrder = "big"
+		}
+		if d.WordOrder != "big" && d.WordOrder != "little" {
+			return fmt.Errorf("register %s: word_order must be big or little", d.ID)
+		}
+		if d.Kind == "" {
+			d.Kind = KindMeasurement
+		}
+		if d.Kind != KindMeasurement && d.Kind != KindMeter {
+			return fmt.Errorf("register %s: kind must be measurement or meter", d.ID)
+		}
+		if d.Area == "" {
+			d.Area = AreaInput
+		}
+		if d.Area != AreaInput && d.Area != AreaHolding {
+			return fmt.Errorf("register %s: area must be input or holding", d.ID)
+		}
+		if int(d.Address)+d.Words() > 0x10000 {
+			return fmt.Errorf("register %s: address out of range", d.ID)
+		}
+	}
+	return nil
+}
+
+// Words – liczba rejestrów 16-bit zajmowanych przez typ (0 = nieznany typ).
+func (d RegisterDef) Words() int {
+	switch d.Type {
+	case "uint16", "int16":
+		return 1
+	case "uint32", "int32", "float32":
+		return 2
+	case "uint64", "float64":
+		return 4
+	}
+	return 0
+}
+
+// Decode zamienia rejestry (len == Words()) na wartość po skalowaniu.
+func (d RegisterDef) Decode(regs []uint16) float64 {
+	var raw uint64
+	n := d.Words()
+	for i := 0; i < n; i++ {
+		w := regs[i]
+		if d.WordOrder == "little" {
+			w = regs[n-1-i]
+		}
+		raw = raw<<16 | uint64(w)
+	}
+	var v float64
+	switch d.Type {
+	case "uint16":
+		v = float64(uint16(raw))
+	case "int16":
+		v = float64(int16(raw))
+	case "uint32":
+		v = float64(uint32(raw))
+	case "int32":
+		v = float64(int32(raw))
+	case "float32":
+		v = float64(math.Float32frombits(uint32(raw)))
+	case "uint64":
+		v = float64(raw)
+	case "float64":
+		v = math.Float64frombits(raw)
+	}
+	return v * d.Scale
+}
+
+// Encode – odwrotność Decode (symulatory, serwer OEE).
+func (d RegisterDef) Encode(value float64) []uint16 {
+	scale := d.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	v := value / scale
+	var raw uint64
+	switch d.Type {
+	case "uint16":
+		raw = uint64(uint16(clampRound(v, 0, math.MaxUint16)))
+	case "int16":
+		raw = uint64(uint16(int16(clampRound(v, math.MinInt16, math.MaxInt16))))
+	case "uint32":
+		raw = uint64(uint32(clampRound(v, 0, math.MaxUint32)))
+	case "int32":
+		raw = uint64(uint32(int32(clampRound(v, math.MinInt32, math.MaxInt32))))
+	case "float32":
+		raw = uint64(math.Float32bits(float32(v)))
+	case "uint64":
+		raw = uint64(clampRound(v, 0, math.MaxUint64))
+	case "float64":
+		raw = math.Float64bits(v)
+	}
+	n := d.Words()
+	out := make([]uint16, n)
+	for i := 0; i < n; i++ {
+		w := uint16(raw >> (16 * uint(n-1-i)))
+		if d.WordOrder == "little" {
+			out[n-1-i] = w
+		} else {
+			out[i] = w
+		}
+	}
+	return out
+}
+
+func clampRound(v, min, max float64) float64 {
+	v = math.Round(v)
+	if math.IsNaN(v) || v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// readBlock – ciągły zakres rejestrów czytany jednym żądaniem.
+type readBlock struct {
+	area     string
+	address  uint16
+	quantity uint16
+	defs     []RegisterDef
+}
+
+// maxBlockGap – ile nieużywanych rejestrów może być w środku bloku, zanim opłaca się nowe żądanie.
+const maxBlockGap = 8
+
+// blocks grupuje definicje w jak najmniej odczytów (max 125 rejestrów na żądanie).
+func (m *RegisterMap) blocks() []readBlock {
+	defs := append([]RegisterDef{}, m.Registers...)
+	sort.SliceStable(defs, func(i, j int) bool {
+		if defs[i].Area != defs[j].Area {
+			return defs[i].Area < defs[j].Area
+		}
+		return defs[i].Address < defs[j].Address
+	})
+
+	var out []readBlock
+	for _, d := range defs {
+		end := int(d.Address) + d.Words()
+		if n := len(out); n > 0 {
+			b := &out[n-1]
+			bEnd := int(b.address) + int(b.quantity)
+			if b.area == d.Area && int(d.Address) <= bEnd+maxBlockGap && end-int(b.address) <= maxReadRegisters {
+				if end > bEnd {
+					b.quantity = uint16(end - int(b.address))
+				}
+				b.defs = append(b.defs, d)
+				continue
+			}
+		}
+		out = append(out, readBlock{area: d.Area, address: d.Address, quantity: uint16(d.Words()), defs: []RegisterDef{d}})
+	}
+	return out
+}
+
+// ReadAll odczytuje wszystkie wartości mapy. Błąd dowolnego bloku przerywa odczyt
+// (urządzenie traktujemy wtedy jak offline, tak jak przy błędzie REST).
+func (m *RegisterMap) ReadAll(c *Client) ([]Value, error) {
+	out := make([]Value, 0, len(m.Registers))
+	for _, b := range m.blocks() {
+		var regs []uint16
+		var err error
+		if b.area == AreaHolding {
+			regs, err = c.ReadHoldingRegisters(b.address, b.quantity)
+		} else {
+			regs, err = c.ReadInputRegisters(b.address, b.quantity)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %d..%d: %w", b.area, b.address, int(b.address)+int(b.quantity)-1, err)
+		}
+		for _, d := range b.defs {
+			off := int(d.Address - b.address)
+			out = append(out, Value{ID: d.ID, Value: d.Decode(regs[off : off+d.Words()]), Unit: d.Unit, Kind: d.Kind})
+		}
+	}
+	return out, nil
+}
+
+// DefaultAnalyzerMap – mapa używana, gdy analizator nie ma własnego pliku:
+// pomiary jako float32 od input 0, liczniki jako uint32 ×0.01 od input 100.
+func DefaultAnalyzerMap() *RegisterMap {
+	m := &RegisterMap{Name: "default"}
+	measurements := []struct{ id, unit string }{
+		{"f", "Hz"},
+		{"u1", "V"}, {"u2", "V"}, {"u3", "V"}, {"u12", "V"}, {"u23", "V"}, {"u31", "V"},
+		{"i1", "A"}, {"i2", "A"}, {"i3", "A"}, {"in", "A"},
+		{"p1", "W"}, {"p2", "W"}, {"p3", "W"},
+		{"q1", "var"}, {"q2", "var"}, {"q3", "var"},
+		{"s1", "VA"}, {"s2", "VA"}, {"s3", "VA"},
+		{"pf1", ""}, {"pf2", ""}, {"pf3", ""},
+		{"p", "W"}, {"q", "var"}, {"s", "VA"}, {"pf", ""},
+	}
+	for i, r := range measurements {
+		m.Registers = append(m.Registers, RegisterDef{ID: r.id, Address: uint16(2 * i), Type: "float32", Unit: r.unit})
+	}
+	meters := []struct{ id, unit string }{
+		{"ea_pos_total", "kWh"}, {"ea_neg_total", "kWh"}, {"er_pos_total", "kvarh"}, {"er_neg_total", "kvarh"},
+		{"es_total", "kVAh"}, {"er_total", "kvarh"},
+		{"ea_pos", "kWh"}, {"ea_neg", "kWh"}, {"er_pos", "kvarh"}, {"er_neg", "kvarh"}, {"es", "kVAh"}, {"er", "kvarh"},
+		{"t1_ea_pos", "kWh"}, {"t1_es", "kVAh"}, {"t2_ea_pos", "kWh"}, {"t2_es", "kVAh"},
+		{"t3_ea_pos", "kWh"}, {"t3_es", "kVAh"}, {"t4_ea_pos", "kWh"}, {"t4_es", "kVAh"},
+	}
+	for i, r := range meters {
+		m.Registers = append(m.Registers, RegisterDef{ID: r.id, Address: uint16(100 + 2*i), Type: "uint32", Scale: 0.01, Unit: r.unit, Kind: KindMeter})
+	}
+	_ = m.normalize()
+	return m
+}
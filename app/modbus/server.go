@@ -0,0 +1,292 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Bank – obszary danych serwera: holding/input registers i coils.
+// Zapisy z sieci trafiają do banku, a potem do OnCoilWrite/OnRegisterWrite
+// (np. potwierdzenie przyczyny postoju przez PLC).
+type Bank struct {
+	mu      sync.RWMutex
+	holding []uint16
+	input   []uint16
+	coils   []bool
+
+	// OnCoilWrite – wołane po zapisie coila (0x05/0x0F), poza lockiem banku.
+	OnCoilWrite func(address uint16, value bool)
+	// OnRegisterWrite – wołane po zapisie holding registers (0x06/0x10), poza lockiem banku.
+	OnRegisterWrite func(address uint16, values []uint16)
+	// ReadOnlyHolding – holding registers 0..ReadOnlyHolding-1 tylko do odczytu: zapis obejmujący
+	// którykolwiek z nich kończy się wyjątkiem ILLEGAL DATA ADDRESS.
+	ReadOnlyHolding int
+}
+
+// NewBank tworzy bank o zadanych rozmiarach obszarów.
+func NewBank(holding, input, coils int) *Bank {
+	return &Bank{
+		holding: make([]uint16, holding),
+		input:   make([]uint16, input),
+		coils:   make([]bool, coils),
+	}
+}
+
+// SetHolding ustawia holding registers od adresu address (poza zakresem – ignorowane).
+func (b *Bank) SetHolding(address uint16, values []uint16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setRange(b.holding, address, values)
+}
+
+// SetInput ustawia input registers od adresu address (poza zakresem – ignorowane).
+func (b *Bank) SetInput(address uint16, values []uint16) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setRange(b.input, address, values)
+}
+
+// SetCoil ustawia coil (poza zakresem – ignorowane).
+func (b *Bank) SetCoil(address uint16, value bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if int(address) < len(b.coils) {
+		b.coils[address] = value
+	}
+}
+
+// Coil zwraca stan coila.
+func (b *Bank) Coil(address uint16) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return int(address) < len(b.coils) && b.coils[address]
+}
+
+// Holding zwraca kopię quantity holding registers od address.
+func (b *Bank) Holding(address, quantity uint16) []uint16 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if int(address)+int(quantity) > len(b.holding) {
+		return nil
+	}
+	return append([]uint16{}, b.holding[address:int(address)+int(quantity)]...)
+}
+
+func setRange(dst []uint16, address uint16, values []uint16) {
+	if int(address)+len(values) > len(dst) {
+		return
+	}
+	copy(dst[address:], values)
+}
+
+// Server – serwer (slave) Modbus TCP obsługujący jeden Bank dla dowolnego unit id.
+type Server struct {
+	Bank *Bank
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+// NewServer tworzy serwer dla banku.
+func NewServer(bank *Bank) *Server {
+	return &Server{Bank: bank, conns: map[net.Conn]struct{}{}}
+}
+
+// ListenAndServe nasłuchuje na address i obsługuje połączenia aż do Close.
+func (s *Server) ListenAndServe(address string) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve obsługuje połączenia z gotowego listenera.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close zamyka listener i wszystkie połączenia.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	for {
+		req, err := readFrame(conn)
+		if err != nil {
+			return // EOF albo uszkodzona ramka – klient połączy się ponownie
+		}
+		data, exc := s.handle(req.function, req.data)
+		resp := frame{transaction: req.transaction, unit: req.unit, function: req.function, data: data}
+		if exc != 0 {
+			resp.function |= 0x80
+			resp.data = []byte{exc}
+		}
+		if _, err := conn.Write(resp.encode()); err != nil {
+			return
+		}
+	}
+}
+
+// handle wykonuje PDU; zwraca dane odpowiedzi albo kod wyjątku.
+func (s *Server) handle(function byte, data []byte) ([]byte, byte) {
+	b := s.Bank
+	switch function {
+	case FuncReadHoldingRegisters, FuncReadInputRegisters:
+		if len(data) != 4 {
+			return nil, ExceptionIllegalDataValue
+		}
+		addr, qty := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if qty == 0 || qty > maxReadRegisters {
+			return nil, ExceptionIllegalDataValue
+		}
+		b.mu.RLock()
+		area := b.holding
+		if function == FuncReadInputRegisters {
+			area = b.input
+		}
+		if int(addr)+int(qty) > len(area) {
+			b.mu.RUnlock()
+			return nil, ExceptionIllegalDataAddress
+		}
+		out := append([]byte{byte(2 * qty)}, putUint16s(area[addr:int(addr)+int(qty)])...)
+		b.mu.RUnlock()
+		return out, 0
+
+	case FuncReadCoils:
+		if len(data) != 4 {
+			return nil, ExceptionIllegalDataValue
+		}
+		addr, qty := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if qty == 0 || qty > maxReadCoils {
+			return nil, ExceptionIllegalDataValue
+		}
+		b.mu.RLock()
+		if int(addr)+int(qty) > len(b.coils) {
+			b.mu.RUnlock()
+			return nil, ExceptionIllegalDataAddress
+		}
+		bits := packBits(b.coils[addr : int(addr)+int(qty)])
+		b.mu.RUnlock()
+		return append([]byte{byte(len(bits))}, bits...), 0
+
+	case FuncWriteSingleCoil:
+		if len(data) != 4 {
+			return nil, ExceptionIllegalDataValue
+		}
+		addr, v := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if v != 0xFF00 && v != 0x0000 {
+			return nil, ExceptionIllegalDataValue
+		}
+		if !s.writeCoils(addr, []bool{v == 0xFF00}) {
+			return nil, ExceptionIllegalDataAddress
+		}
+		return data, 0
+
+	case FuncWriteMultipleCoils:
+		if len(data) < 5 {
+			return nil, ExceptionIllegalDataValue
+		}
+		addr, qty := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if qty == 0 || int(data[4]) != (int(qty)+7)/8 || len(data) != 5+int(data[4]) {
+			return nil, ExceptionIllegalDataValue
+		}
+		if !s.writeCoils(addr, unpackBits(data[5:], int(qty))) {
+			return nil, ExceptionIllegalDataAddress
+		}
+		return data[:4], 0
+
+	case FuncWriteSingleRegister:
+		if len(data) != 4 {
+			return nil, ExceptionIllegalDataValue
+		}
+		addr := binary.BigEndian.Uint16(data)
+		if !s.writeRegisters(addr, getUint16s(data[2:])) {
+			return nil, ExceptionIllegalDataAddress
+		}
+		return data, 0
+
+	case FuncWriteMultipleRegisters:
+		if len(data) < 5 {
+			return nil, ExceptionIllegalDataValue
+		}
+		addr, qty := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:])
+		if qty == 0 || int(data[4]) != 2*int(qty) || len(data) != 5+int(data[4]) {
+			return nil, ExceptionIllegalDataValue
+		}
+		if !s.writeRegisters(addr, getUint16s(data[5:])) {
+			return nil, ExceptionIllegalDataAddress
+		}
+		return data[:4], 0
+	}
+	return nil, ExceptionIllegalFunction
+}
+
+func (s *Server) writeCoils(addr uint16, values []bool) bool {
+	b := s.Bank
+	b.mu.Lock()
+	if int(addr)+len(values) > len(b.coils) {
+		b.mu.Unlock()
+		return false
+	}
+	copy(b.coils[addr:], values)
+	hook := b.OnCoilWrite
+	b.mu.Unlock()
+	if hook != nil {
+		for i, v := range values {
+			hook(addr+uint16(i), v)
+		}
+	}
+	return true
+}
+
+func (s *Server) writeRegisters(addr uint16, values []uint16) bool {
+	b := s.Bank
+	b.mu.Lock()
+	if int(addr)+len(values) > len(b.holding) || int(addr) < b.ReadOnlyHolding {
+		b.mu.Unlock()
+		return false
+	}
+	copy(b.holding[addr:], values)
+	hook := b.OnRegisterWrite
+	b.mu.Unlock()
+	if hook != nil {
+		hook(addr, values)
+	}
+	return true
+}
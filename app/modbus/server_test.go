@@ -0,0 +1,54 @@
+package modbus
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func startTestServer(t *testing.T, bank *Bank) *Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(bank)
+	go srv.Serve(ln)
+	c := NewClient(ln.Addr().String(), 1, 2*time.Second)
+	t.Cleanup(func() {
+		c.Close()
+		srv.Close()
+	})
+	return c
+}
+
+// TestReadOnlyHolding – zapis do rejestrów tylko do odczytu kończy się wyjątkiem 0x02 i nie
+// zmienia banku; rejestry powyżej zakresu przyjmują zapis.
+func TestReadOnlyHolding(t *testing.T) {
+	bank := NewBank(110, 42, 3)
+	bank.ReadOnlyHolding = 42
+	bank.SetHolding(0, []uint16{7})
+	c := startTestServer(t, bank)
+
+	for name, write := range map[string]func() error{
+		"FC06":          func() error { return c.WriteSingleRegister(0, 1) },
+		"FC16":          func() error { return c.WriteMultipleRegisters(40, []uint16{1, 2, 3}) },
+		"FC16 crossing": func() error { return c.WriteMultipleRegisters(41, []uint16{1, 2}) },
+	} {
+		var exc *ExceptionError
+		if err := write(); !errors.As(err, &exc) || exc.Code != ExceptionIllegalDataAddress {
+			t.Errorf("%s: want illegal data address exception, got %v", name, err)
+		}
+	}
+	if got := bank.Holding(0, 1); got[0] != 7 {
+		t.Errorf("read-only register changed: %d", got[0])
+	}
+
+	if err := c.WriteSingleRegister(100, 5); err != nil {
+		t.Fatalf("writable register: %v", err)
+	}
+	if got := bank.Holding(100, 1); got[0] != 5 {
+		t.Errorf("holding 100 = %d, want 5", got[0])
+	}
+}
@@ -0,0 +1,496 @@
+package opcua
+
+import (
+	"sync"
+	"time"
+)
+
+// NodeClass – klasa węzła.
+type NodeClass uint32
+
+const (
+	NodeClassObject       NodeClass = 1
+	NodeClassVariable     NodeClass = 2
+	NodeClassObjectType   NodeClass = 8
+	NodeClassVariableType NodeClass = 16
+)
+
+// Atrybuty węzłów.
+const (
+	AttrNodeID                  uint32 = 1
+	AttrNodeClass               uint32 = 2
+	AttrBrowseName              uint32 = 3
+	AttrDisplayName             uint32 = 4
+	AttrDescription             uint32 = 5
+	AttrWriteMask               uint32 = 6
+	AttrUserWriteMask           uint32 = 7
+	AttrIsAbstract              uint32 = 8
+	AttrEventNotifier           uint32 = 12
+	AttrValue                   uint32 = 13
+	AttrDataType                uint32 = 14
+	AttrValueRank               uint32 = 15
+	AttrArrayDimensions         uint32 = 16
+	AttrAccessLevel             uint32 = 17
+	AttrUserAccessLevel         uint32 = 18
+	AttrMinimumSamplingInterval uint32 = 19
+	AttrHistorizing             uint32 = 20
+)
+
+// Węzły standardowe (ns=0).
+var (
+	RootFolder    = NewNumericNodeID(0, 84)
+	ObjectsFolder = NewNumericNodeID(0, 85)
+	TypesFolder   = NewNumericNodeID(0, 86)
+	ViewsFolder   = NewNumericNodeID(0, 87)
+
+	serverNode            = NewNumericNodeID(0, 2253)
+	serverArrayNode       = NewNumericNodeID(0, 2254)
+	namespaceArrayNode    = NewNumericNodeID(0, 2255)
+	serverStatusNode      = NewNumericNodeID(0, 2256)
+	serverStartTimeNode   = NewNumericNodeID(0, 2257)
+	serverCurrentTimeNode = NewNumericNodeID(0, 2258)
+	serverStateNode       = NewNumericNodeID(0, 2259)
+
+	baseObjectType       = NewNumericNodeID(0, 58)
+	folderType           = NewNumericNodeID(0, 61)
+	baseDataVariableType = NewNumericNodeID(0, 63)
+	propertyType         = NewNumericNodeID(0, 68)
+	serverType           = NewNumericNodeID(0, 2004)
+	serverStatusType     = NewNumericNodeID(0, 2138)
+
+	refReferences    = NewNumericNodeID(0, 31)
+	refHierarchical  = NewNumericNodeID(0, 33)
+	refHasChild      = NewNumericNodeID(0, 34)
+	refOrganizes     = NewNumericNodeID(0, 35)
+	refHasTypeDef    = NewNumericNodeID(0, 40)
+	refAggregates    = NewNumericNodeID(0, 44)
+	refHasProperty   = NewNumericNodeID(0, 46)
+	refHasComponent  = NewNumericNodeID(0, 47)
+	refNonHierarchic = NewNumericNodeID(0, 32)
+)
+
+// referenceParent – hierarchia typów referencji (do IncludeSubtypes w Browse).
+var referenceParent = map[NodeID]NodeID{
+	refHierarchical:  refReferences,
+	refNonHierarchic: refReferences,
+	refHasChild:      refHierarchical,
+	refOrganizes:     refHierarchical,
+	refAggregates:    refHasChild,
+	refHasComponent:  refAggregates,
+	refHasProperty:   refAggregates,
+	refHasTypeDef:    refNonHierarchic,
+}
+
+func isSubtype(ref, base NodeID) bool {
+	for {
+		if ref == base {
+			return true
+		}
+		p, ok := referenceParent[ref]
+		if !ok {
+			return false
+		}
+		ref = p
+	}
+}
+
+// Reference – krawędź grafu przestrzeni adresowej.
+type Reference struct {
+	Type    NodeID
+	Target  NodeID
+	Forward bool
+}
+
+// Node – węzeł przestrzeni adresowej (obiekt, zmienna albo typ).
+type Node struct {
+	ID          NodeID
+	Class       NodeClass
+	BrowseName  QualifiedName
+	DisplayName LocalizedText
+	Description LocalizedText
+	TypeDef     NodeID
+	DataType    NodeID
+	ValueRank   int32
+
+	refs  []Reference
+	value DataValue
+	read  func() *Variant // wartość liczona przy odczycie (np. CurrentTime)
+}
+
+// AddressSpace – węzły serwera; przestrzeń nazw 1 to model aplikacji.
+type AddressSpace struct {
+	mu         sync.RWMutex
+	nodes      map[NodeID]*Node
+	namespaces []string
+}
+
+// NewAddressSpace tworzy przestrzeń z węzłami standardowymi i przestrzenią nazw namespaceURI (ns=1).
+func NewAddressSpace(namespaceURI string) *AddressSpace {
+	a := &AddressSpace{
+		nodes:      map[NodeID]*Node{},
+		namespaces: []string{"http://opcfoundation.org/UA/", namespaceURI},
+	}
+	for _, t := range []struct {
+		id    NodeID
+		class NodeClass
+		name  string
+	}{
+		{baseObjectType, NodeClassObjectType, "BaseObjectType"},
+		{folderType, NodeClassObjectType, "FolderType"},
+		{serverType, NodeClassObjectType, "ServerType"},
+		{baseDataVariableType, NodeClassVariableType, "BaseDataVariableType"},
+		{propertyType, NodeClassVariableType, "PropertyType"},
+		{serverStatusType, NodeClassVariableType, "ServerStatusType"},
+	} {
+		a.nodes[t.id] = &Node{ID: t.id, Class: t.class, BrowseName: QualifiedName{Name: t.name}, DisplayName: LocalizedText{Text: t.name}}
+	}
+
+	a.addNode(&Node{ID: RootFolder, Class: NodeClassObject, TypeDef: folderType}, NodeID{}, NodeID{}, "Root")
+	a.addNode(&Node{ID: ObjectsFolder, Class: NodeClassObject, TypeDef: folderType}, RootFolder, refOrganizes, "Objects")
+	a.addNode(&Node{ID: TypesFolder, Class: NodeClassObject, TypeDef: folderType}, RootFolder, refOrganizes, "Types")
+	a.addNode(&Node{ID: ViewsFolder, Class: NodeClassObject, TypeDef: folderType}, RootFolder, refOrganizes, "Views")
+	a.addNode(&Node{ID: serverNode, Class: NodeClassObject, TypeDef: serverType}, ObjectsFolder, refOrganizes, "Server")
+
+	a.addNode(&Node{ID: namespaceArrayNode, Class: NodeClassVariable, TypeDef: propertyType, DataType: NewNumericNodeID(0, uint32(TypeString)), ValueRank: 1,
+		read: func() *Variant { return &Variant{Type: TypeString, Value: append([]string{}, a.namespaces...)} }},
+		serverNode, refHasProperty, "NamespaceArray")
+	a.addNode(&Node{ID: serverArrayNode, Class: NodeClassVariable, TypeDef: propertyType, DataType: NewNumericNodeID(0, uint32(TypeString)), ValueRank: 1},
+		serverNode, refHasProperty, "ServerArray")
+
+	a.addNode(&Node{ID: serverStatusNode, Class: NodeClassVariable, TypeDef: serverStatusType, DataType: NewNumericNodeID(0, 862), ValueRank: -1},
+		serverNode, refHasComponent, "ServerStatus")
+	a.addNode(&Node{ID: serverStartTimeNode, Class: NodeClassVariable, TypeDef: baseDataVariableType, DataType: NewNumericNodeID(0, uint32(TypeDateTime)), ValueRank: -1},
+		serverStatusNode, refHasComponent, "StartTime")
+	a.addNode(&Node{ID: serverCurrentTimeNode, Class: NodeClassVariable, TypeDef: baseDataVariableType, DataType: NewNumericNodeID(0, uint32(TypeDateTime)), ValueRank: -1,
+		read: func() *Variant { return &Variant{Type: TypeDateTime, Value: time.Now().UTC()} }},
+		serverStatusNode, refHasComponent, "CurrentTime")
+	a.addNode(&Node{ID: serverStateNode, Class: NodeClassVariable, TypeDef: baseDataVariableType, DataType: NewNumericNodeID(0, 852), ValueRank: -1},
+		serverStatusNode, refHasComponent, "State")
+	a.nodes[serverStateNode].value = DataValue{Value: &Variant{Type: TypeInt32, Value: int32(0)}} // Running
+	return a
+}
+
+// addNode dodaje węzeł z referencją od rodzica (i odwrotną) oraz HasTypeDefinition.
+func (a *AddressSpace) addNode(n *Node, parent, refType NodeID, name string) {
+	ns := n.ID.Namespace
+	n.BrowseName = QualifiedName{Namespace: ns, Name: name}
+	n.DisplayName = LocalizedText{Text: name}
+	if n.ValueRank == 0 && n.Class == NodeClassVariable {
+		n.ValueRank = -1
+	}
+	a.nodes[n.ID] = n
+	if !parent.IsNull() {
+		if p, ok := a.nodes[parent]; ok {
+			p.refs = append(p.refs, Reference{Type: refType, Target: n.ID, Forward: true})
+		}
+		n.refs = append(n.refs, Reference{Type: refType, Target: parent, Forward: false})
+	}
+	if !n.TypeDef.IsNull() {
+		n.refs = append(n.refs, Reference{Type: refHasTypeDef, Target: n.TypeDef, Forward: true})
+	}
+}
+
+// NamespaceIndex – indeks przestrzeni nazw aplikacji.
+func (a *AddressSpace) NamespaceIndex() uint16 { return 1 }
+
+// AddFolder dodaje folder (FolderType, Organizes) pod węzłem parent.
+func (a *AddressSpace) AddFolder(parent NodeID, id NodeID, name string) NodeID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.addNode(&Node{ID: id, Class: NodeClassObject, TypeDef: folderType}, parent, refOrganizes, name)
+	return id
+}
+
+// AddObject dodaje obiekt (BaseObjectType); pod folderem przez Organizes, pod obiektem przez HasComponent.
+func (a *AddressSpace) AddObject(parent NodeID, id NodeID, name, description string) NodeID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ref := refHasComponent
+	if p, ok := a.nodes[parent]; ok && p.TypeDef == folderType {
+		ref = refOrganizes
+	}
+	a.addNode(&Node{ID: id, Class: NodeClassObject, TypeDef: baseObjectType, Description: LocalizedText{Text: description}}, parent, ref, name)
+	return id
+}
+
+// AddVariable dodaje zmienną tylko do odczytu typu dataType (TypeDouble, TypeInt32, ...) z wartością początkową.
+func (a *AddressSpace) AddVariable(parent NodeID, id NodeID, name string, dataType byte, initial interface{}, description string) NodeID {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := &Node{
+		ID:          id,
+		Class:       NodeClassVariable,
+		TypeDef:     baseDataVariableType,
+		DataType:    NewNumericNodeID(0, uint32(dataType)),
+		Description: LocalizedText{Text: description},
+	}
+	a.addNode(n, parent, refHasComponent, name)
+	n.value = DataValue{Value: &Variant{Type: dataType, Value: convertValue(dataType, initial)}, SourceTimestamp: time.Now().UTC()}
+	return id
+}
+
+// SetValue ustawia wartość zmiennej (konwersja do typu zmiennej); zmiana jest widoczna dla subskrypcji.
+func (a *AddressSpace) SetValue(id NodeID, v interface{}, ts time.Time) {
+	a.SetValueStatus(id, v, StatusGood, ts)
+}
+
+// SetValueStatus – jak SetValue, z jakością (np. brak danych źródłowych).
+func (a *AddressSpace) SetValueStatus(id NodeID, v interface{}, status uint32, ts time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n, ok := a.nodes[id]
+	if !ok || n.Class != NodeClassVariable {
+		return
+	}
+	t := byte(n.DataType.ID)
+	nv := &Variant{Type: t, Value: convertValue(t, v)}
+	if n.value.Value != nil && variantEqual(n.value.Value, nv) && n.value.Status == status {
+		return
+	}
+	n.value = DataValue{Value: nv, Status: status, SourceTimestamp: ts.UTC()}
+}
+
+func (a *AddressSpace) setStartTime(t time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nodes[serverStartTimeNode].value = DataValue{Value: &Variant{Type: TypeDateTime, Value: t.UTC()}}
+}
+
+// convertValue – zamiana typów Go na typ wbudowany zmiennej.
+func convertValue(t byte, v interface{}) interface{} {
+	var f float64
+	switch x := v.(type) {
+	case float64:
+		f = x
+	case float32:
+		f = float64(x)
+	case int:
+		f = float64(x)
+	case int32:
+		f = float64(x)
+	case int64:
+		f = float64(x)
+	case uint32:
+		f = float64(x)
+	case bool:
+		if t == TypeBoolean {
+			return x
+		}
+		if x {
+			f = 1
+		}
+	case string:
+		if t == TypeString {
+			return x
+		}
+	case time.Time:
+		if t == TypeDateTime {
+			return x.UTC()
+		}
+	}
+	switch t {
+	case TypeBoolean:
+		return f != 0
+	case TypeInt32:
+		return int32(f)
+	case TypeUInt32:
+		return uint32(f)
+	case TypeInt64:
+		return int64(f)
+	case TypeFloat:
+		return float32(f)
+	case TypeDouble:
+		return f
+	case TypeString:
+		return ""
+	case TypeDateTime:
+		return time.Time{}
+	}
+	return v
+}
+
+func variantEqual(a, b *Variant) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Type != b.Type {
+		return false
+	}
+	if as, ok := a.Value.([]string); ok {
+		bs, ok := b.Value.([]string)
+		if !ok || len(as) != len(bs) {
+			return false
+		}
+		for i := range as {
+			if as[i] != bs[i] {
+				return false
+			}
+		}
+		return true
+	}
+	if at, ok := a.Value.(time.Time); ok {
+		bt, ok := b.Value.(time.Time)
+		return ok && at.Equal(bt)
+	}
+	if _, ok := a.Value.([]byte); ok {
+		return false
+	}
+	return a.Value == b.Value
+}
+
+// readAttribute – odczyt atrybutu węzła (pod blokadą do odczytu).
+func (a *AddressSpace) readAttribute(id NodeID, attr uint32) DataValue {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	n, ok := a.nodes[id]
+	if !ok {
+		return DataValue{Status: StatusBadNodeIdUnknown}
+	}
+	val := func(t byte, v interface{}) DataValue { return DataValue{Value: &Variant{Type: t, Value: v}} }
+	isVar := n.Class == NodeClassVariable
+	switch attr {
+	case AttrNodeID:
+		return val(TypeNodeID, n.ID)
+	case AttrNodeClass:
+		return val(TypeInt32, int32(n.Class))
+	case AttrBrowseName:
+		return val(TypeQualified, n.BrowseName)
+	case AttrDisplayName:
+		return val(TypeLocalized, n.DisplayName)
+	case AttrDescription:
+		return val(TypeLocalized, n.Description)
+	case AttrWriteMask, AttrUserWriteMask:
+		return val(TypeUInt32, uint32(0))
+	case AttrIsAbstract:
+		if n.Class == NodeClassObjectType || n.Class == NodeClassVariableType {
+			return val(TypeBoolean, n.ID == baseObjectType || n.ID == baseDataVariableType)
+		}
+	case AttrEventNotifier:
+		if n.Class == NodeClassObject {
+			return val(TypeByte, byte(0))
+		}
+	case AttrValue:
+		if isVar {
+			if n.read != nil {
+				return DataValue{Value: n.read(), SourceTimestamp: time.Now().UTC()}
+			}
+			if n.ID == serverStatusNode {
+				return DataValue{Value: a.serverStatusLocked(), SourceTimestamp: time.Now().UTC()}
+			}
+			dv := n.value
+			if dv.Value == nil && n.ValueRank == 1 {
+				dv.Value = &Variant{Type: TypeString, Value: []string{}}
+			}
+			return dv
+		}
+	case AttrDataType:
+		if isVar {
+			return val(TypeNodeID, n.DataType)
+		}
+	case AttrValueRank:
+		if isVar {
+			return val(TypeInt32, n.ValueRank)
+		}
+	case AttrArrayDimensions:
+		if isVar {
+			return DataValue{}
+		}
+	case AttrAccessLevel, AttrUserAccessLevel:
+		if isVar {
+			return val(TypeByte, byte(1)) // CurrentRead
+		}
+	case AttrMinimumSamplingInterval:
+		if isVar {
+			return val(TypeDouble, float64(minSamplingInterval/time.Millisecond))
+		}
+	case AttrHistorizing:
+		if isVar {
+			return val(TypeBoolean, false)
+		}
+	}
+	return DataValue{Status: StatusBadAttributeIdInvalid}
+}
+
+// serverStatusLocked – wartość ServerStatus (ServerStatusDataType).
+func (a *AddressSpace) serverStatusLocked() *Variant {
+	e := &encoder{}
+	start, _ := a.nodes[serverStartTimeNode].value.Value.Value.(time.Time)
+	e.dateTime(start)
+	e.dateTime(time.Now().UTC())
+	e.uint32(0) // Running
+	e.string(productURI)
+	e.string("OEE Monitoring")
+	e.string("go_app OPC UA server")
+	e.string("1.0")
+	e.string("1")
+	e.dateTime(start)
+	e.uint32(0)
+	e.localizedText(LocalizedText{})
+	return &Variant{Type: TypeExtensionObject, Value: ExtensionObject{TypeID: NewNumericNodeID(0, 864), Body: e.bytes()}}
+}
+
+// browse – referencje węzła zgodne z filtrem Browse (direction: 0 do przodu, 1 wstecz, 2 oba).
+func (a *AddressSpace) browse(id NodeID, direction uint32, refType NodeID, subtypes bool, classMask uint32) ([]ReferenceDescription, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	n, ok := a.nodes[id]
+	if !ok {
+		return nil, false
+	}
+	out := []ReferenceDescription{}
+	for _, r := range n.refs {
+		if (direction == 0 && !r.Forward) || (direction == 1 && r.Forward) {
+			continue
+		}
+		if !refType.IsNull() && r.Type != refType && !(subtypes && isSubtype(r.Type, refType)) {
+			continue
+		}
+		t := a.nodes[r.Target]
+		if t == nil {
+			continue
+		}
+		if classMask != 0 && uint32(t.Class)&classMask == 0 {
+			continue
+		}
+		out = append(out, ReferenceDescription{
+			ReferenceType: r.Type,
+			IsForward:     r.Forward,
+			Target:        t.ID,
+			BrowseName:    t.BrowseName,
+			DisplayName:   t.DisplayName,
+			Class:         t.Class,
+			TypeDef:       t.TypeDef,
+		})
+	}
+	return out, true
+}
+
+// childByName – cel referencji hierarchicznej o nazwie name (TranslateBrowsePaths).
+func (a *AddressSpace) childByName(id NodeID, name QualifiedName) (NodeID, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	n, ok := a.nodes[id]
+	if !ok {
+		return NodeID{}, false
+	}
+	for _, r := range n.refs {
+		if !r.Forward || !isSubtype(r.Type, refHierarchical) {
+			continue
+		}
+		if t := a.nodes[r.Target]; t != nil && t.BrowseName == name {
+			return t.ID, true
+		}
+	}
+	return NodeID{}, false
+}
+
+// ReferenceDescription – wynik Browse.
+type ReferenceDescription struct {
+	ReferenceType NodeID
+	IsForward     bool
+	Target        NodeID
+	BrowseName    QualifiedName
+	DisplayName   LocalizedText
+	Class         NodeClass
+	TypeDef       NodeID
+}
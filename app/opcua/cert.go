@@ -0,0 +1,186 @@
+package opcua
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LoadCertificate wczytuje certyfikat (PEM albo DER) i klucz prywatny RSA (PEM PKCS#1/PKCS#8 albo DER).
+func LoadCertificate(certFile, keyFile string) ([]byte, *rsa.PrivateKey, error) {
+	certRaw, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b, _ := pem.Decode(certRaw); b != nil {
+		certRaw = b.Bytes
+	}
+	if _, err := x509.ParseCertificate(certRaw); err != nil {
+		return nil, nil, err
+	}
+
+	keyRaw, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b, _ := pem.Decode(keyRaw); b != nil {
+		keyRaw = b.Bytes
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(keyRaw); err == nil {
+		return certRaw, k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(keyRaw)
+	if err != nil {
+		return nil, nil, err
+	}
+	rsaKey, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.New("opcua: private key is not RSA")
+	}
+	return certRaw, rsaKey, nil
+}
+
+// GenerateCertificate tworzy samopodpisany certyfikat aplikacji OPC UA (URI aplikacji w SAN)
+// i zwraca certyfikat oraz klucz w PEM.
+func GenerateCertificate(appURI string, hosts []string, bits int, validity time.Duration) (certPEM, keyPEM []byte, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	uri, err := url.Parse(appURI)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := "go_app OPC UA"
+	if len(hosts) > 0 {
+		name += " @ " + hosts[0]
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"OEE Monitoring"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageKeyEncipherment | x509.KeyUsageDataEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		URIs:                  []*url.URL{uri},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM, nil
+}
+
+// ApplicationURI – URI aplikacji z rozszerzenia SAN certyfikatu ("" gdy brak).
+func ApplicationURI(certDER []byte) string {
+	cert, err := x509.ParseCertificate(firstCertificate(certDER))
+	if err != nil || len(cert.URIs) == 0 {
+		return ""
+	}
+	return cert.URIs[0].String()
+}
+
+// firstCertificate – pierwszy certyfikat z łańcucha DER (nadawca może przesłać cały łańcuch).
+func firstCertificate(der []byte) []byte {
+	certs, err := x509.ParseCertificates(der)
+	if err != nil || len(certs) == 0 {
+		return der
+	}
+	return certs[0].Raw
+}
+
+func publicKeyFromCert(der []byte) (*rsa.PublicKey, error) {
+	certs, err := x509.ParseCertificates(der)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("empty certificate")
+	}
+	cert := certs[0]
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, errors.New("certificate expired or not yet valid")
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("certificate key is not RSA")
+	}
+	if key.Size() < 256 || key.Size() > 512 {
+		return nil, errors.New("RSA key must be 2048..4096 bits for Basic256Sha256")
+	}
+	return key, nil
+}
+
+// TrustList – zaufane certyfikaty klientów (pliki .der/.pem/.crt w katalogu); pusta lista nie ufa nikomu.
+type TrustList struct {
+	thumbprints map[[sha1.Size]byte]bool
+}
+
+// LoadTrustList wczytuje certyfikaty z katalogu dir ("" = brak listy).
+func LoadTrustList(dir string) (*TrustList, error) {
+	t := &TrustList{thumbprints: map[[sha1.Size]byte]bool{}}
+	if dir == "" {
+		return t, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".der" && ext != ".pem" && ext != ".crt") {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for len(raw) > 0 {
+			b, rest := pem.Decode(raw)
+			if b == nil {
+				t.thumbprints[sha1.Sum(raw)] = true
+				break
+			}
+			t.thumbprints[sha1.Sum(b.Bytes)] = true
+			raw = rest
+		}
+	}
+	return t, nil
+}
+
+// Empty – lista nie zawiera żadnych certyfikatów.
+func (t *TrustList) Empty() bool { return t == nil || len(t.thumbprints) == 0 }
+
+// Trusted – czy certyfikat (DER) jest na liście.
+func (t *TrustList) Trusted(der []byte) bool {
+	if t.Empty() {
+		return false
+	}
+	return t.thumbprints[sha1.Sum(firstCertificate(der))]
+}
@@ -0,0 +1,631 @@
+package opcua
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ClientConfig – parametry połączenia klienta diagnostycznego.
+type ClientConfig struct {
+	Endpoint string // opc.tcp://host:4840
+	Policy   string // PolicyNone (domyślnie) albo PolicyBasic256Sha256
+	Mode     MessageSecurityMode
+	// Certificate / PrivateKey – certyfikat klienta (wymagany poza polityką None).
+	Certificate []byte
+	PrivateKey  *rsa.PrivateKey
+	Timeout     time.Duration
+}
+
+// Endpoint – opis endpointu zwrócony przez GetEndpoints.
+type Endpoint struct {
+	URL               string
+	ApplicationURI    string
+	ApplicationName   string
+	ServerCertificate []byte
+	Mode              MessageSecurityMode
+	Policy            string
+	SecurityLevel     byte
+}
+
+// Client – minimalny klient OPC UA (Browse, Read, subskrypcje) do diagnostyki serwera.
+type Client struct {
+	cfg ClientConfig
+	ch  *secureChannel
+
+	mu       sync.Mutex
+	nextReq  uint32
+	handle   uint32
+	waiting  map[uint32]chan *message
+	token    NodeID
+	readErr  error
+	closed   chan struct{}
+	closeMux sync.Once
+}
+
+// GetEndpoints pobiera endpointy serwera przez kanał None.
+func GetEndpoints(endpoint string, timeout time.Duration) ([]Endpoint, error) {
+	c, err := dialChannel(ClientConfig{Endpoint: endpoint, Policy: PolicyNone, Mode: SecurityModeNone, Timeout: timeout}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.getEndpoints()
+}
+
+// Dial łączy się z serwerem, otwiera kanał wg polityki i aktywuje sesję anonimową.
+func Dial(cfg ClientConfig) (*Client, error) {
+	if cfg.Policy == "" {
+		cfg.Policy = PolicyNone
+	}
+	if cfg.Mode == 0 {
+		cfg.Mode = SecurityModeNone
+		if cfg.Policy != PolicyNone {
+			cfg.Mode = SecurityModeSignAndEncrypt
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	var serverCert []byte
+	if cfg.Policy != PolicyNone {
+		if cfg.PrivateKey == nil {
+			return nil, errors.New("opcua: client certificate required for " + policyName(cfg.Policy))
+		}
+		eps, err := GetEndpoints(cfg.Endpoint, cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		for _, ep := range eps {
+			if ep.Policy == cfg.Policy && ep.Mode == cfg.Mode {
+				serverCert = ep.ServerCertificate
+			}
+		}
+		if serverCert == nil {
+			return nil, errors.New("opcua: server has no endpoint " + policyName(cfg.Policy) + "/" + cfg.Mode.String())
+		}
+	}
+
+	c, err := dialChannel(cfg, serverCert)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.createSession(serverCert); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func dialChannel(cfg ClientConfig, serverCert []byte) (*Client, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Scheme != "opc.tcp" {
+		return nil, &StatusError{Code: StatusBadTcpEndpointUrlInvalid, Reason: cfg.Endpoint}
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4840")
+	}
+	conn, err := net.DialTimeout("tcp", host, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	ch := newSecureChannel(conn)
+	ch.policy, ch.mode = cfg.Policy, cfg.Mode
+	if cfg.Policy != PolicyNone {
+		ch.localCert, ch.localKey = cfg.Certificate, cfg.PrivateKey
+		ch.remoteCert = firstCertificate(serverCert)
+		if ch.remoteKey, err = publicKeyFromCert(serverCert); err != nil {
+			conn.Close()
+			return nil, &StatusError{Code: StatusBadCertificateInvalid, Reason: err.Error()}
+		}
+	}
+	c := &Client{cfg: cfg, ch: ch, waiting: map[uint32]chan *message{}, closed: make(chan struct{})}
+
+	// HEL / ACK
+	e := &encoder{b: make([]byte, headerSize)}
+	e.uint32(protocolVersion)
+	e.uint32(defaultBufferSize)
+	e.uint32(defaultBufferSize)
+	e.uint32(maxMessageSize)
+	e.uint32(maxChunkCount)
+	e.string(cfg.Endpoint)
+	hello := e.bytes()
+	putHeader(hello, "HEL", 'F')
+	_ = conn.SetDeadline(time.Now().Add(cfg.Timeout))
+	if err := ch.writeRaw(hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ack, err := ch.readChunk()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ack.msgType == "ERR" {
+		d := &decoder{b: ack.data[headerSize:]}
+		code := d.uint32()
+		conn.Close()
+		return nil, &StatusError{Code: code, Reason: d.string()}
+	}
+	d := &decoder{b: ack.data[headerSize:]}
+	d.uint32()
+	if recvBuf := int(d.uint32()); recvBuf >= 8192 && recvBuf < ch.sendBuf {
+		ch.sendBuf = recvBuf
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	go c.readLoop()
+	if err := c.openChannel(false); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// readLoop rozdziela odpowiedzi wg requestID.
+func (c *Client) readLoop() {
+	for {
+		msg, err := c.ch.readMessage()
+		c.mu.Lock()
+		if err != nil {
+			c.readErr = err
+			for id, w := range c.waiting {
+				close(w)
+				delete(c.waiting, id)
+			}
+			c.mu.Unlock()
+			c.Close()
+			return
+		}
+		w, ok := c.waiting[msg.requestID]
+		delete(c.waiting, msg.requestID)
+		c.mu.Unlock()
+		if ok {
+			w <- msg
+		}
+	}
+}
+
+// Close zamyka sesję (bez czekania na odpowiedź) i połączenie.
+func (c *Client) Close() error {
+	c.closeMux.Do(func() {
+		close(c.closed)
+		if !c.token.IsNull() {
+			e := c.request(idCloseSessionRequest)
+			e.bool(true)
+			_, _ = c.send("MSG", e.bytes())
+		}
+		_ = c.ch.conn.Close()
+	})
+	return nil
+}
+
+func (c *Client) request(typeID uint32) *encoder {
+	c.mu.Lock()
+	c.handle++
+	handle := c.handle
+	token := c.token
+	c.mu.Unlock()
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, typeID))
+	e.nodeID(token)
+	e.dateTime(time.Now().UTC())
+	e.uint32(handle)
+	e.uint32(0)
+	e.nullString()
+	e.uint32(uint32(c.cfg.Timeout / time.Millisecond))
+	e.extensionObject(ExtensionObject{})
+	return e
+}
+
+func (c *Client) send(msgType string, body []byte) (chan *message, error) {
+	c.mu.Lock()
+	c.nextReq++
+	id := c.nextReq
+	w := make(chan *message, 1)
+	c.waiting[id] = w
+	c.mu.Unlock()
+	var err error
+	if msgType == "OPN" {
+		err = c.ch.sendOPN(id, body)
+	} else {
+		err = c.ch.sendSymmetric(msgType, id, body)
+	}
+	if err != nil {
+		c.mu.Lock()
+		delete(c.waiting, id)
+		c.mu.Unlock()
+		return nil, err
+	}
+	return w, nil
+}
+
+// call wysyła żądanie i zwraca dekoder ustawiony za nagłówkiem odpowiedzi.
+func (c *Client) call(msgType string, req *encoder, wantType uint32, timeout time.Duration) (*decoder, error) {
+	w, err := c.send(msgType, req.bytes())
+	if err != nil {
+		return nil, err
+	}
+	var msg *message
+	select {
+	case msg = <-w:
+	case <-time.After(timeout):
+		return nil, &StatusError{Code: StatusBadTimeout}
+	}
+	if msg == nil {
+		c.mu.Lock()
+		err := c.readErr
+		c.mu.Unlock()
+		if err == nil {
+			err = &StatusError{Code: StatusBadSecureChannelClosed}
+		}
+		return nil, err
+	}
+	d := &decoder{b: msg.body}
+	typeID := d.nodeID()
+	d.dateTime()
+	d.uint32()
+	result := d.uint32()
+	d.diagnosticInfo()
+	d.stringArray()
+	d.extensionObject()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if typeID.ID == idServiceFault || result&0x80000000 != 0 {
+		return nil, &StatusError{Code: result}
+	}
+	if typeID != NewNumericNodeID(0, wantType) {
+		return nil, &StatusError{Code: StatusBadDecodingError, Reason: "unexpected response " + typeID.String()}
+	}
+	return d, nil
+}
+
+// openChannel – OpenSecureChannel issue / renew.
+func (c *Client) openChannel(renew bool) error {
+	nonce := []byte(nil)
+	if c.cfg.Policy != PolicyNone {
+		nonce = randomNonce()
+	}
+	req := c.request(idOpenChannelRequest)
+	req.uint32(protocolVersion)
+	if renew {
+		req.uint32(1)
+	} else {
+		req.uint32(0)
+	}
+	req.uint32(uint32(c.cfg.Mode))
+	req.byteString(nonce)
+	req.uint32(uint32(tokenLifetime / time.Millisecond))
+	d, err := c.call("OPN", req, idOpenChannelResponse, c.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	d.uint32() // ServerProtocolVersion
+	channelID := d.uint32()
+	tokenID := d.uint32()
+	d.dateTime()
+	lifetime := time.Duration(d.uint32()) * time.Millisecond
+	serverNonce := d.byteString()
+	if d.err != nil {
+		return d.err
+	}
+	c.ch.id = channelID
+	c.ch.installToken(tokenID, nonce, serverNonce)
+	if !renew && lifetime > 0 {
+		go c.renewLoop(lifetime)
+	}
+	return nil
+}
+
+// renewLoop odnawia token kanału po 75% czasu życia.
+func (c *Client) renewLoop(lifetime time.Duration) {
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-time.After(lifetime * 3 / 4):
+			if err := c.openChannel(true); err != nil {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+func decodeEndpoint(d *decoder) Endpoint {
+	var ep Endpoint
+	ep.URL = d.string()
+	ep.ApplicationURI = d.string()
+	d.string()
+	ep.ApplicationName = d.localizedText().Text
+	d.uint32()
+	d.string()
+	d.string()
+	d.stringArray()
+	ep.ServerCertificate = d.byteString()
+	ep.Mode = MessageSecurityMode(d.uint32())
+	ep.Policy = d.string()
+	n := d.arrayLen()
+	for i := 0; i < n && d.err == nil; i++ {
+		d.string()
+		d.uint32()
+		d.string()
+		d.string()
+		d.string()
+	}
+	d.string()
+	ep.SecurityLevel = d.byte()
+	return ep
+}
+
+func (c *Client) getEndpoints() ([]Endpoint, error) {
+	req := c.request(idGetEndpointsRequest)
+	req.string(c.cfg.Endpoint)
+	req.int32(-1)
+	req.int32(-1)
+	d, err := c.call("MSG", req, idGetEndpointsResponse, c.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	n := d.arrayLen()
+	eps := make([]Endpoint, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		eps = append(eps, decodeEndpoint(d))
+	}
+	return eps, d.err
+}
+
+func (c *Client) createSession(serverCert []byte) error {
+	clientNonce := randomNonce()
+	req := c.request(idCreateSessionRequest)
+	appURI := ApplicationURI(c.cfg.Certificate)
+	if appURI == "" {
+		appURI = "urn:oee-monitoring:opcua-client"
+	}
+	req.string(appURI)
+	req.string(productURI)
+	req.localizedText(LocalizedText{Text: "opcua-client"})
+	req.uint32(1) // Client
+	req.nullString()
+	req.nullString()
+	req.int32(-1)
+	req.nullString()
+	req.string(c.cfg.Endpoint)
+	req.string("opcua-client")
+	req.byteString(clientNonce)
+	req.byteString(c.cfg.Certificate)
+	req.double(float64(5 * time.Minute / time.Millisecond))
+	req.uint32(maxMessageSize)
+	d, err := c.call("MSG", req, idCreateSessionResponse, c.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	d.nodeID() // SessionId
+	token := d.nodeID()
+	d.double()
+	serverNonce := d.byteString()
+	sessionCert := d.byteString()
+	n := d.arrayLen()
+	for i := 0; i < n && d.err == nil; i++ {
+		decodeEndpoint(d)
+	}
+	n = d.arrayLen()
+	for i := 0; i < n && d.err == nil; i++ {
+		d.byteString()
+		d.byteString()
+	}
+	d.string()
+	serverSig := d.byteString()
+	if d.err != nil {
+		return d.err
+	}
+
+	var clientSig []byte
+	if c.cfg.Policy != PolicyNone {
+		if !bytes.Equal(firstCertificate(sessionCert), firstCertificate(serverCert)) {
+			return &StatusError{Code: StatusBadCertificateInvalid, Reason: "session certificate differs from endpoint"}
+		}
+		h := sha256.Sum256(append(append([]byte{}, c.cfg.Certificate...), clientNonce...))
+		if rsa.VerifyPKCS1v15(c.ch.remoteKey, crypto.SHA256, h[:], serverSig) != nil {
+			return &StatusError{Code: StatusBadSecurityChecksFailed, Reason: "invalid server signature"}
+		}
+		h = sha256.Sum256(append(append([]byte{}, sessionCert...), serverNonce...))
+		if clientSig, err = rsa.SignPKCS1v15(nil, c.cfg.PrivateKey, crypto.SHA256, h[:]); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+
+	req = c.request(idActivateSessionRequest)
+	if clientSig != nil {
+		req.string(algorithmRsaSha256)
+	} else {
+		req.nullString()
+	}
+	req.byteString(clientSig)
+	req.int32(-1)
+	req.int32(-1)
+	anon := &encoder{}
+	anon.string("anonymous")
+	req.extensionObject(ExtensionObject{TypeID: NewNumericNodeID(0, idAnonymousToken), Body: anon.bytes()})
+	req.nullString()
+	req.byteString(nil)
+	_, err = c.call("MSG", req, idActivateSessionResponse, c.cfg.Timeout)
+	return err
+}
+
+// Browse – referencje hierarchiczne do przodu węzła id.
+func (c *Client) Browse(id NodeID) ([]ReferenceDescription, error) {
+	req := c.request(idBrowseRequest)
+	req.nodeID(NodeID{})
+	req.dateTime(time.Time{})
+	req.uint32(0)
+	req.uint32(0)
+	req.int32(1)
+	req.nodeID(id)
+	req.uint32(0)
+	req.nodeID(refHierarchical)
+	req.bool(true)
+	req.uint32(0)
+	req.uint32(0x3F)
+	d, err := c.call("MSG", req, idBrowseResponse, c.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if n := d.arrayLen(); n != 1 {
+		return nil, errDecode
+	}
+	if status := d.uint32(); status != StatusGood {
+		return nil, &StatusError{Code: status}
+	}
+	d.byteString()
+	n := d.arrayLen()
+	out := make([]ReferenceDescription, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		var r ReferenceDescription
+		r.ReferenceType = d.nodeID()
+		r.IsForward = d.bool()
+		r.Target = d.expandedNodeID()
+		r.BrowseName = d.qualifiedName()
+		r.DisplayName = d.localizedText()
+		r.Class = NodeClass(d.uint32())
+		r.TypeDef = d.expandedNodeID()
+		out = append(out, r)
+	}
+	return out, d.err
+}
+
+// Read – atrybut Value węzłów.
+func (c *Client) Read(ids ...NodeID) ([]DataValue, error) {
+	req := c.request(idReadRequest)
+	req.double(0)
+	req.uint32(2) // Both
+	req.int32(int32(len(ids)))
+	for _, id := range ids {
+		req.nodeID(id)
+		req.uint32(AttrValue)
+		req.nullString()
+		req.qualifiedName(QualifiedName{})
+	}
+	d, err := c.call("MSG", req, idReadResponse, c.cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	n := d.arrayLen()
+	out := make([]DataValue, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, d.dataValue())
+	}
+	return out, d.err
+}
+
+// Subscribe tworzy subskrypcję z elementami ids i wywołuje fn dla każdej zmiany wartości
+// aż do zamknięcia klienta albo błędu.
+func (c *Client) Subscribe(interval time.Duration, ids []NodeID, fn func(id NodeID, dv DataValue)) error {
+	req := c.request(idCreateSubRequest)
+	req.double(float64(interval / time.Millisecond))
+	req.uint32(60)
+	req.uint32(10)
+	req.uint32(0)
+	req.bool(true)
+	req.byte(0)
+	d, err := c.call("MSG", req, idCreateSubResponse, c.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	subID := d.uint32()
+	revised := time.Duration(d.double() * float64(time.Millisecond))
+	d.uint32()
+	keepAlive := d.uint32()
+
+	req = c.request(idCreateItemsRequest)
+	req.uint32(subID)
+	req.uint32(0) // Source
+	req.int32(int32(len(ids)))
+	for i, id := range ids {
+		req.nodeID(id)
+		req.uint32(AttrValue)
+		req.nullString()
+		req.qualifiedName(QualifiedName{})
+		req.uint32(2) // Reporting
+		req.uint32(uint32(i))
+		req.double(float64(interval / time.Millisecond))
+		req.extensionObject(ExtensionObject{})
+		req.uint32(1)
+		req.bool(true)
+	}
+	if d, err = c.call("MSG", req, idCreateItemsResponse, c.cfg.Timeout); err != nil {
+		return err
+	}
+	n := d.arrayLen()
+	for i := 0; i < n && d.err == nil; i++ {
+		if status := d.uint32(); status != StatusGood {
+			return &StatusError{Code: status, Reason: ids[i].String()}
+		}
+		d.uint32()
+		d.double()
+		d.uint32()
+		d.extensionObject()
+	}
+
+	var acks []uint32 // numery sekwencji do potwierdzenia
+	wait := revised*time.Duration(keepAlive) + c.cfg.Timeout
+	for {
+		select {
+		case <-c.closed:
+			return nil
+		default:
+		}
+		req = c.request(idPublishRequest)
+		req.int32(int32(len(acks)))
+		for _, seq := range acks {
+			req.uint32(subID)
+			req.uint32(seq)
+		}
+		acks = acks[:0]
+		d, err := c.call("MSG", req, idPublishResponse, wait)
+		if err != nil {
+			return err
+		}
+		d.uint32() // SubscriptionId
+		d.uint32Array()
+		d.bool()
+		seq := d.uint32()
+		d.dateTime()
+		notifications := d.arrayLen()
+		for i := 0; i < notifications && d.err == nil; i++ {
+			x := d.extensionObject()
+			if x.TypeID != NewNumericNodeID(0, idDataChangeNotification) {
+				continue
+			}
+			acks = append(acks, seq)
+			nd := &decoder{b: x.Body}
+			items := nd.arrayLen()
+			for j := 0; j < items && nd.err == nil; j++ {
+				handle := nd.uint32()
+				dv := nd.dataValue()
+				if int(handle) < len(ids) {
+					fn(ids[handle], dv)
+				}
+			}
+		}
+		if d.err != nil {
+			return d.err
+		}
+	}
+}
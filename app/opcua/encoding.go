@@ -0,0 +1,647 @@
+// Package opcua – minimalna implementacja OPC UA Binary (opc.tcp) bez zależności zewnętrznych:
+// serwer z przestrzenią adresową tylko do odczytu (Browse, Read, subskrypcje zmian wartości)
+// i prosty klient do diagnostyki. Polityki bezpieczeństwa: None (dev) i Basic256Sha256
+// z trybem Sign / SignAndEncrypt (produkcja, certyfikaty X.509).
+package opcua
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"time"
+)
+
+var errDecode = errors.New("opcua: decoding error")
+
+// Identyfikatory typów wbudowanych (Variant, DataType ns=0).
+const (
+	TypeBoolean         byte = 1
+	TypeByte            byte = 3
+	TypeInt32           byte = 6
+	TypeUInt32          byte = 7
+	TypeInt64           byte = 8
+	TypeFloat           byte = 10
+	TypeDouble          byte = 11
+	TypeString          byte = 12
+	TypeDateTime        byte = 13
+	TypeByteString      byte = 15
+	TypeNodeID          byte = 17
+	TypeStatusCode      byte = 19
+	TypeQualified       byte = 20
+	TypeLocalized       byte = 21
+	TypeExtensionObject byte = 22
+)
+
+// NodeID – identyfikator węzła. Kind: 0 liczbowy (ID), 's' tekstowy, 'b' ByteString, 'g' GUID
+// (dla 'b' i 'g' surowe bajty w Str – porównywalne, więc NodeID może być kluczem mapy).
+type NodeID struct {
+	Namespace uint16
+	ID        uint32
+	Str       string
+	Kind      byte
+}
+
+// NewNumericNodeID / NewStringNodeID – skróty konstrukcji.
+func NewNumericNodeID(ns uint16, id uint32) NodeID { return NodeID{Namespace: ns, ID: id} }
+func NewStringNodeID(ns uint16, s string) NodeID   { return NodeID{Namespace: ns, Str: s, Kind: 's'} }
+
+// IsNull – NodeID ns=0;i=0.
+func (n NodeID) IsNull() bool { return n.Namespace == 0 && n.ID == 0 && n.Kind == 0 }
+
+func (n NodeID) String() string {
+	ns := ""
+	if n.Namespace != 0 {
+		ns = "ns=" + itoa(int(n.Namespace)) + ";"
+	}
+	switch n.Kind {
+	case 's':
+		return ns + "s=" + n.Str
+	case 'b':
+		return ns + "b=" + base64.StdEncoding.EncodeToString([]byte(n.Str))
+	case 'g':
+		return ns + "g=" + hex.EncodeToString([]byte(n.Str))
+	}
+	return ns + "i=" + itoa(int(n.ID))
+}
+
+// ParseNodeID – "i=85", "ns=1;s=Line1.OEE.OEE".
+func ParseNodeID(s string) (NodeID, error) {
+	var n NodeID
+	if len(s) > 3 && s[:3] == "ns=" {
+		i := 3
+		ns := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			ns = ns*10 + int(s[i]-'0')
+			i++
+		}
+		if i == 3 || i >= len(s) || s[i] != ';' || ns > math.MaxUint16 {
+			return n, errors.New("opcua: invalid node id " + s)
+		}
+		n.Namespace = uint16(ns)
+		s = s[i+1:]
+	}
+	switch {
+	case len(s) > 2 && s[:2] == "s=":
+		n.Str, n.Kind = s[2:], 's'
+	case len(s) > 2 && s[:2] == "i=":
+		id := 0
+		for _, c := range s[2:] {
+			if c < '0' || c > '9' {
+				return n, errors.New("opcua: invalid node id " + s)
+			}
+			id = id*10 + int(c-'0')
+		}
+		n.ID = uint32(id)
+	default:
+		return n, errors.New("opcua: invalid node id " + s)
+	}
+	return n, nil
+}
+
+func itoa(v int) string {
+	if v == 0 {
+		return "0"
+	}
+	var b [20]byte
+	i := len(b)
+	for v > 0 {
+		i--
+		b[i] = byte('0' + v%10)
+		v /= 10
+	}
+	return string(b[i:])
+}
+
+// QualifiedName / LocalizedText – nazwy węzłów.
+type QualifiedName struct {
+	Namespace uint16
+	Name      string
+}
+
+type LocalizedText struct {
+	Locale string
+	Text   string
+}
+
+// Variant – wartość skalarna jednego z typów wbudowanych albo tablica stringów (NamespaceArray).
+type Variant struct {
+	Type  byte
+	Value interface{}
+}
+
+// DataValue – wartość z jakością i znacznikami czasu.
+type DataValue struct {
+	Value           *Variant
+	Status          uint32
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+}
+
+// ExtensionObject – zakodowana struktura z identyfikatorem typu (DefaultBinary).
+type ExtensionObject struct {
+	TypeID NodeID
+	Body   []byte
+}
+
+// --- encoder ---
+
+type encoder struct{ b []byte }
+
+func (e *encoder) bytes() []byte { return e.b }
+
+func (e *encoder) byte(v byte) { e.b = append(e.b, v) }
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.byte(1)
+	} else {
+		e.byte(0)
+	}
+}
+
+func (e *encoder) uint16(v uint16) { e.b = binary.LittleEndian.AppendUint16(e.b, v) }
+func (e *encoder) uint32(v uint32) { e.b = binary.LittleEndian.AppendUint32(e.b, v) }
+func (e *encoder) int32(v int32)   { e.uint32(uint32(v)) }
+func (e *encoder) int64(v int64)   { e.b = binary.LittleEndian.AppendUint64(e.b, uint64(v)) }
+func (e *encoder) float(v float32) { e.uint32(math.Float32bits(v)) }
+func (e *encoder) double(v float64) {
+	e.b = binary.LittleEndian.AppendUint64(e.b, math.Float64bits(v))
+}
+
+func (e *encoder) string(s string) {
+	e.int32(int32(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// nullString – String o długości -1 (brak wartości).
+func (e *encoder) nullString() { e.int32(-1) }
+
+func (e *encoder) byteString(v []byte) {
+	if v == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *encoder) stringArray(v []string) {
+	if v == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(v)))
+	for _, s := range v {
+		e.string(s)
+	}
+}
+
+func (e *encoder) uint32Array(v []uint32) {
+	e.int32(int32(len(v)))
+	for _, x := range v {
+		e.uint32(x)
+	}
+}
+
+// dateTime – liczba interwałów 100 ns od 1601-01-01 UTC (0 = brak).
+func (e *encoder) dateTime(t time.Time) {
+	if t.IsZero() {
+		e.int64(0)
+		return
+	}
+	e.int64(t.UnixNano()/100 + epochOffset100ns)
+}
+
+const epochOffset100ns = 116444736000000000
+
+func (e *encoder) nodeID(n NodeID) {
+	switch {
+	case n.Kind == 's':
+		e.byte(0x03)
+		e.uint16(n.Namespace)
+		e.string(n.Str)
+	case n.Kind == 'g':
+		e.byte(0x04)
+		e.uint16(n.Namespace)
+		e.b = append(e.b, n.Str...)
+	case n.Kind == 'b':
+		e.byte(0x05)
+		e.uint16(n.Namespace)
+		e.string(n.Str)
+	case n.Namespace == 0 && n.ID <= 0xFF:
+		e.byte(0x00)
+		e.byte(byte(n.ID))
+	case n.Namespace <= 0xFF && n.ID <= 0xFFFF:
+		e.byte(0x01)
+		e.byte(byte(n.Namespace))
+		e.uint16(uint16(n.ID))
+	default:
+		e.byte(0x02)
+		e.uint16(n.Namespace)
+		e.uint32(n.ID)
+	}
+}
+
+// expandedNodeID – bez NamespaceUri i ServerIndex.
+func (e *encoder) expandedNodeID(n NodeID) { e.nodeID(n) }
+
+func (e *encoder) qualifiedName(q QualifiedName) {
+	e.uint16(q.Namespace)
+	e.string(q.Name)
+}
+
+func (e *encoder) localizedText(t LocalizedText) {
+	var mask byte
+	if t.Locale != "" {
+		mask |= 0x01
+	}
+	if t.Text != "" {
+		mask |= 0x02
+	}
+	e.byte(mask)
+	if t.Locale != "" {
+		e.string(t.Locale)
+	}
+	if t.Text != "" {
+		e.string(t.Text)
+	}
+}
+
+func (e *encoder) extensionObject(x ExtensionObject) {
+	e.nodeID(x.TypeID)
+	if x.Body == nil {
+		e.byte(0x00)
+		return
+	}
+	e.byte(0x01)
+	e.byteString(x.Body)
+}
+
+func (e *encoder) variant(v *Variant) {
+	if v == nil {
+		e.byte(0)
+		return
+	}
+	if ss, ok := v.Value.([]string); ok {
+		e.byte(TypeString | 0x80)
+		e.stringArray(ss)
+		return
+	}
+	e.byte(v.Type)
+	switch v.Type {
+	case TypeBoolean:
+		e.bool(v.Value.(bool))
+	case TypeByte:
+		e.byte(v.Value.(byte))
+	case TypeInt32:
+		e.int32(v.Value.(int32))
+	case TypeUInt32, TypeStatusCode:
+		e.uint32(v.Value.(uint32))
+	case TypeInt64:
+		e.int64(v.Value.(int64))
+	case TypeFloat:
+		e.float(v.Value.(float32))
+	case TypeDouble:
+		e.double(v.Value.(float64))
+	case TypeString:
+		e.string(v.Value.(string))
+	case TypeDateTime:
+		e.dateTime(v.Value.(time.Time))
+	case TypeByteString:
+		e.byteString(v.Value.([]byte))
+	case TypeNodeID:
+		e.nodeID(v.Value.(NodeID))
+	case TypeQualified:
+		e.qualifiedName(v.Value.(QualifiedName))
+	case TypeLocalized:
+		e.localizedText(v.Value.(LocalizedText))
+	case TypeExtensionObject:
+		e.extensionObject(v.Value.(ExtensionObject))
+	}
+}
+
+func (e *encoder) dataValue(dv DataValue) {
+	var mask byte
+	if dv.Value != nil {
+		mask |= 0x01
+	}
+	if dv.Status != 0 {
+		mask |= 0x02
+	}
+	if !dv.SourceTimestamp.IsZero() {
+		mask |= 0x04
+	}
+	if !dv.ServerTimestamp.IsZero() {
+		mask |= 0x08
+	}
+	e.byte(mask)
+	if dv.Value != nil {
+		e.variant(dv.Value)
+	}
+	if dv.Status != 0 {
+		e.uint32(dv.Status)
+	}
+	if !dv.SourceTimestamp.IsZero() {
+		e.dateTime(dv.SourceTimestamp)
+	}
+	if !dv.ServerTimestamp.IsZero() {
+		e.dateTime(dv.ServerTimestamp)
+	}
+}
+
+// --- decoder ---
+
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.b) {
+		d.err = errDecode
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if v := d.take(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool { return d.byte() != 0 }
+
+func (d *decoder) uint16() uint16 {
+	if v := d.take(2); v != nil {
+		return binary.LittleEndian.Uint16(v)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if v := d.take(4); v != nil {
+		return binary.LittleEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 { return int32(d.uint32()) }
+
+func (d *decoder) int64() int64 {
+	if v := d.take(8); v != nil {
+		return int64(binary.LittleEndian.Uint64(v))
+	}
+	return 0
+}
+
+func (d *decoder) float() float32  { return math.Float32frombits(d.uint32()) }
+func (d *decoder) double() float64 { return math.Float64frombits(uint64(d.int64())) }
+
+func (d *decoder) byteString() []byte {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return nil
+	}
+	v := d.take(int(n))
+	if v == nil {
+		return nil
+	}
+	out := make([]byte, len(v))
+	copy(out, v)
+	return out
+}
+
+func (d *decoder) string() string { return string(d.byteString()) }
+
+// arrayLen – długość tablicy (-1 = null → 0), z ochroną przed absurdalnymi wartościami.
+func (d *decoder) arrayLen() int {
+	n := d.int32()
+	if n < 0 {
+		return 0
+	}
+	if int(n) > len(d.b) {
+		d.err = errDecode
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) stringArray() []string {
+	n := d.arrayLen()
+	out := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, d.string())
+	}
+	return out
+}
+
+func (d *decoder) uint32Array() []uint32 {
+	n := d.arrayLen()
+	out := make([]uint32, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, d.uint32())
+	}
+	return out
+}
+
+func (d *decoder) dateTime() time.Time {
+	v := d.int64()
+	if v <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, (v-epochOffset100ns)*100).UTC()
+}
+
+func (d *decoder) nodeID() NodeID {
+	switch enc := d.byte(); enc & 0x0F {
+	case 0x00:
+		return NodeID{ID: uint32(d.byte())}
+	case 0x01:
+		ns := d.byte()
+		return NodeID{Namespace: uint16(ns), ID: uint32(d.uint16())}
+	case 0x02:
+		ns := d.uint16()
+		return NodeID{Namespace: ns, ID: d.uint32()}
+	case 0x03:
+		ns := d.uint16()
+		return NodeID{Namespace: ns, Str: d.string(), Kind: 's'}
+	case 0x04:
+		ns := d.uint16()
+		return NodeID{Namespace: ns, Str: string(d.take(16)), Kind: 'g'}
+	case 0x05:
+		ns := d.uint16()
+		return NodeID{Namespace: ns, Str: d.string(), Kind: 'b'}
+	default:
+		d.err = errDecode
+		return NodeID{}
+	}
+}
+
+func (d *decoder) expandedNodeID() NodeID {
+	flags := 0
+	if len(d.b) > 0 {
+		flags = int(d.b[0])
+	}
+	n := d.nodeID()
+	if flags&0x80 != 0 {
+		d.string()
+	}
+	if flags&0x40 != 0 {
+		d.uint32()
+	}
+	return n
+}
+
+func (d *decoder) qualifiedName() QualifiedName {
+	ns := d.uint16()
+	return QualifiedName{Namespace: ns, Name: d.string()}
+}
+
+func (d *decoder) localizedText() LocalizedText {
+	var t LocalizedText
+	mask := d.byte()
+	if mask&0x01 != 0 {
+		t.Locale = d.string()
+	}
+	if mask&0x02 != 0 {
+		t.Text = d.string()
+	}
+	return t
+}
+
+func (d *decoder) extensionObject() ExtensionObject {
+	x := ExtensionObject{TypeID: d.nodeID()}
+	switch d.byte() {
+	case 0x00:
+	case 0x01, 0x02:
+		x.Body = d.byteString()
+	default:
+		d.err = errDecode
+	}
+	return x
+}
+
+// diagnosticInfo – pomijane (serwer ich nie zwraca, klient ich nie używa).
+func (d *decoder) diagnosticInfo() {
+	mask := d.byte()
+	for _, bit := range []byte{0x01, 0x02, 0x04, 0x08} {
+		if mask&bit != 0 {
+			d.int32()
+		}
+	}
+	if mask&0x10 != 0 {
+		d.string()
+	}
+	if mask&0x20 != 0 {
+		d.uint32()
+	}
+	if mask&0x40 != 0 {
+		d.diagnosticInfo()
+	}
+}
+
+func (d *decoder) diagnosticInfos() {
+	n := d.arrayLen()
+	for i := 0; i < n && d.err == nil; i++ {
+		d.diagnosticInfo()
+	}
+}
+
+func (d *decoder) variant() *Variant {
+	enc := d.byte()
+	if enc == 0 {
+		return nil
+	}
+	t := enc & 0x3F
+	if enc&0x80 != 0 {
+		n := d.arrayLen()
+		vals := make([]interface{}, 0, n)
+		for i := 0; i < n && d.err == nil; i++ {
+			vals = append(vals, d.scalar(t))
+		}
+		if enc&0x40 != 0 {
+			d.uint32Array()
+		}
+		if t == TypeString {
+			ss := make([]string, len(vals))
+			for i, v := range vals {
+				ss[i], _ = v.(string)
+			}
+			return &Variant{Type: t, Value: ss}
+		}
+		return &Variant{Type: t, Value: vals}
+	}
+	return &Variant{Type: t, Value: d.scalar(t)}
+}
+
+func (d *decoder) scalar(t byte) interface{} {
+	switch t {
+	case TypeBoolean:
+		return d.bool()
+	case 2, 3: // SByte, Byte
+		return d.byte()
+	case 4, 5: // Int16, UInt16
+		return d.uint16()
+	case TypeInt32:
+		return d.int32()
+	case TypeUInt32, TypeStatusCode:
+		return d.uint32()
+	case TypeInt64, 9:
+		return d.int64()
+	case TypeFloat:
+		return d.float()
+	case TypeDouble:
+		return d.double()
+	case TypeString:
+		return d.string()
+	case TypeDateTime:
+		return d.dateTime()
+	case 14: // Guid
+		return d.take(16)
+	case TypeByteString, 16:
+		return d.byteString()
+	case TypeNodeID:
+		return d.nodeID()
+	case 18:
+		return d.expandedNodeID()
+	case TypeQualified:
+		return d.qualifiedName()
+	case TypeLocalized:
+		return d.localizedText()
+	case 22:
+		return d.extensionObject()
+	default:
+		d.err = errDecode
+		return nil
+	}
+}
+
+func (d *decoder) dataValue() DataValue {
+	var dv DataValue
+	mask := d.byte()
+	if mask&0x01 != 0 {
+		dv.Value = d.variant()
+	}
+	if mask&0x02 != 0 {
+		dv.Status = d.uint32()
+	}
+	if mask&0x04 != 0 {
+		dv.SourceTimestamp = d.dateTime()
+	}
+	if mask&0x10 != 0 {
+		d.uint16()
+	}
+	if mask&0x08 != 0 {
+		dv.ServerTimestamp = d.dateTime()
+	}
+	if mask&0x20 != 0 {
+		d.uint16()
+	}
+	return dv
+}
@@ -0,0 +1,136 @@
+package opcua
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Przykłady kodowania z OPC UA Part 6 (5.2.2) – bajty takie, jakie wysyłają inne stosy.
+func TestNodeIDEncodingVectors(t *testing.T) {
+	cases := []struct {
+		id  NodeID
+		hex string
+	}{
+		{NewNumericNodeID(0, 72), "0048"},                               // TwoByte
+		{NewNumericNodeID(5, 1025), "01050104"},                         // FourByte
+		{NewNumericNodeID(1, 70000), "020100" + "70110100"},             // Numeric
+		{NewStringNodeID(1, "Hot水"), "030100" + "06000000486f74e6b0b4"}, // String
+	}
+	for _, c := range cases {
+		var e encoder
+		e.nodeID(c.id)
+		if got := hex.EncodeToString(e.bytes()); got != c.hex {
+			t.Errorf("%s: encoded %s, want %s", c.id, got, c.hex)
+		}
+		d := decoder{b: e.bytes()}
+		if got := d.nodeID(); got != c.id || d.err != nil || len(d.b) != 0 {
+			t.Errorf("%s: decoded %v (err %v, %d bytes left)", c.id, got, d.err, len(d.b))
+		}
+	}
+}
+
+func TestParseNodeID(t *testing.T) {
+	for _, s := range []string{"i=85", "ns=1;s=Line1.KPI.oee", "ns=2;i=70000", "ns=1;s=a;b"} {
+		n, err := ParseNodeID(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if n.String() != s {
+			t.Errorf("%s: String() = %s", s, n.String())
+		}
+	}
+	for _, s := range []string{"", "x=1", "ns=;i=1", "ns=70000;i=1", "i=1a"} {
+		if _, err := ParseNodeID(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestDateTimeEncoding(t *testing.T) {
+	var e encoder
+	e.dateTime(time.Unix(0, 0))
+	e.dateTime(time.Time{})
+	want := "00803ed5deb19d01" + "0000000000000000" // 1970-01-01 = 116444736000000000 × 100 ns od 1601
+	if got := hex.EncodeToString(e.bytes()); got != want {
+		t.Fatalf("encoded %s, want %s", got, want)
+	}
+	ts := time.Date(2026, 3, 14, 6, 0, 1, 123456700, time.UTC)
+	e = encoder{}
+	e.dateTime(ts)
+	d := decoder{b: e.bytes()}
+	if got := d.dateTime(); !got.Equal(ts) {
+		t.Errorf("decoded %v, want %v", got, ts)
+	}
+}
+
+func TestVariantRoundTrip(t *testing.T) {
+	values := []*Variant{
+		nil,
+		{Type: TypeBoolean, Value: true},
+		{Type: TypeByte, Value: byte(7)},
+		{Type: TypeInt32, Value: int32(-42)},
+		{Type: TypeUInt32, Value: uint32(1 << 31)},
+		{Type: TypeInt64, Value: int64(-1 << 40)},
+		{Type: TypeFloat, Value: float32(1.5)},
+		{Type: TypeDouble, Value: 0.4293},
+		{Type: TypeDouble, Value: math.Inf(-1)},
+		{Type: TypeString, Value: "Dostępność"},
+		{Type: TypeString, Value: ""},
+		{Type: TypeDateTime, Value: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Type: TypeByteString, Value: []byte{0, 1, 2}},
+		{Type: TypeNodeID, Value: NewStringNodeID(1, "Line1.KPI")},
+		{Type: TypeStatusCode, Value: StatusBadNodeIdUnknown},
+		{Type: TypeQualified, Value: QualifiedName{Namespace: 1, Name: "oee"}},
+		{Type: TypeLocalized, Value: LocalizedText{Locale: "pl", Text: "OEE"}},
+		{Type: TypeLocalized, Value: LocalizedText{Text: "OEE"}},
+		{Type: TypeExtensionObject, Value: ExtensionObject{TypeID: NewNumericNodeID(0, 864), Body: []byte{1, 2}}},
+		{Type: TypeString, Value: []string{"http://opcfoundation.org/UA/", "urn:oee-monitoring:Line1"}},
+	}
+	for _, v := range values {
+		var e encoder
+		e.variant(v)
+		d := decoder{b: e.bytes()}
+		got := d.variant()
+		if d.err != nil || len(d.b) != 0 {
+			t.Errorf("%+v: err %v, %d bytes left", v, d.err, len(d.b))
+			continue
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("round trip %+v -> %+v", v, got)
+		}
+	}
+}
+
+func TestDataValueRoundTrip(t *testing.T) {
+	ts := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, dv := range []DataValue{
+		{},
+		{Value: &Variant{Type: TypeDouble, Value: 0.85}, SourceTimestamp: ts, ServerTimestamp: ts.Add(time.Millisecond)},
+		{Status: StatusBadWaitingForInitialData, ServerTimestamp: ts},
+	} {
+		var e encoder
+		e.dataValue(dv)
+		d := decoder{b: e.bytes()}
+		if got := d.dataValue(); !reflect.DeepEqual(got, dv) || d.err != nil {
+			t.Errorf("round trip %+v -> %+v (err %v)", dv, got, d.err)
+		}
+	}
+}
+
+// Obcięte wiadomości nie mogą panikować ani zwracać częściowych wartości bez błędu.
+func TestDecoderTruncated(t *testing.T) {
+	var e encoder
+	e.dataValue(DataValue{Value: &Variant{Type: TypeString, Value: "Line1"}, SourceTimestamp: time.Now()})
+	full := e.bytes()
+	for n := 0; n < len(full); n++ {
+		d := decoder{b: bytes.Clone(full[:n])}
+		d.dataValue()
+		if d.err == nil && n > 0 {
+			t.Errorf("no error decoding %d of %d bytes", n, len(full))
+		}
+	}
+}
@@ -0,0 +1,541 @@
+package opcua
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Polityki bezpieczeństwa.
+const (
+	PolicyNone           = "http://opcfoundation.org/UA/SecurityPolicy#None"
+	PolicyBasic256Sha256 = "http://opcfoundation.org/UA/SecurityPolicy#Basic256Sha256"
+
+	algorithmRsaSha256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+)
+
+// MessageSecurityMode – tryb zabezpieczenia wiadomości.
+type MessageSecurityMode uint32
+
+const (
+	SecurityModeNone           MessageSecurityMode = 1
+	SecurityModeSign           MessageSecurityMode = 2
+	SecurityModeSignAndEncrypt MessageSecurityMode = 3
+)
+
+func (m MessageSecurityMode) String() string {
+	switch m {
+	case SecurityModeNone:
+		return "None"
+	case SecurityModeSign:
+		return "Sign"
+	case SecurityModeSignAndEncrypt:
+		return "SignAndEncrypt"
+	}
+	return fmt.Sprintf("MessageSecurityMode(%d)", uint32(m))
+}
+
+// ParseSecurityMode – "None", "Sign", "SignAndEncrypt" (wielkość liter bez znaczenia).
+func ParseSecurityMode(s string) (MessageSecurityMode, error) {
+	for _, m := range []MessageSecurityMode{SecurityModeNone, SecurityModeSign, SecurityModeSignAndEncrypt} {
+		if strings.EqualFold(s, m.String()) {
+			return m, nil
+		}
+	}
+	return 0, errors.New("opcua: unknown security mode " + s)
+}
+
+const (
+	protocolVersion    = 0
+	defaultBufferSize  = 65536
+	maxMessageSize     = 16 << 20
+	maxChunkCount      = 512
+	headerSize         = 8
+	sequenceHeaderSize = 8
+
+	symKeyLen     = 32 // Basic256Sha256: klucz podpisu = klucz szyfrowania = 32 B
+	symBlockSize  = aes.BlockSize
+	symSigLen     = sha256.Size
+	nonceLen      = 32
+	oaepSha1Extra = 2*sha1.Size + 2
+)
+
+var errSecurityChecks = &StatusError{Code: StatusBadSecurityChecksFailed}
+
+// symKeys – klucze pochodne z nonce (P_SHA256) dla jednego kierunku.
+type symKeys struct {
+	sign, encrypt, iv []byte
+}
+
+// deriveKeys: P_SHA256(secret, seed) → klucz podpisu | klucz szyfrowania | IV.
+func deriveKeys(secret, seed []byte) symKeys {
+	need := 2*symKeyLen + symBlockSize
+	out := make([]byte, 0, need+sha256.Size)
+	a := seed
+	for len(out) < need {
+		m := hmac.New(sha256.New, secret)
+		m.Write(a)
+		a = m.Sum(nil)
+		m = hmac.New(sha256.New, secret)
+		m.Write(a)
+		m.Write(seed)
+		out = m.Sum(out)
+	}
+	return symKeys{sign: out[:symKeyLen], encrypt: out[symKeyLen : 2*symKeyLen], iv: out[2*symKeyLen : need]}
+}
+
+// secureChannel – kanał opc.tcp po handshake HEL/ACK; wspólny dla serwera i klienta.
+type secureChannel struct {
+	conn net.Conn
+	r    *bufio.Reader
+	wmu  sync.Mutex
+
+	id      uint32
+	policy  string
+	mode    MessageSecurityMode
+	seq     uint32
+	sendBuf int // bufor odbiorczy drugiej strony
+
+	localCert  []byte
+	localKey   *rsa.PrivateKey
+	remoteCert []byte
+	remoteKey  *rsa.PublicKey
+
+	kmu      sync.Mutex
+	tokenID  uint32
+	sendKeys symKeys
+	recvKeys map[uint32]symKeys // bieżący i poprzedni token (odnowienie kanału)
+}
+
+func newSecureChannel(conn net.Conn) *secureChannel {
+	return &secureChannel{
+		conn:     conn,
+		r:        bufio.NewReaderSize(conn, defaultBufferSize),
+		policy:   PolicyNone,
+		mode:     SecurityModeNone,
+		sendBuf:  defaultBufferSize,
+		recvKeys: map[uint32]symKeys{},
+	}
+}
+
+func (c *secureChannel) secured() bool { return c.policy != PolicyNone }
+
+// installToken ustawia klucze nowego tokenu (OPN issue/renew); poprzedni token pozostaje ważny
+// dla wiadomości przychodzących, dopóki druga strona nie przejdzie na nowy.
+func (c *secureChannel) installToken(tokenID uint32, localNonce, remoteNonce []byte) {
+	c.kmu.Lock()
+	defer c.kmu.Unlock()
+	prev := c.tokenID
+	c.tokenID = tokenID
+	for id := range c.recvKeys {
+		if id != prev {
+			delete(c.recvKeys, id)
+		}
+	}
+	if c.mode == SecurityModeNone {
+		c.recvKeys[tokenID] = symKeys{}
+		return
+	}
+	c.sendKeys = deriveKeys(remoteNonce, localNonce)
+	c.recvKeys[tokenID] = deriveKeys(localNonce, remoteNonce)
+}
+
+// --- surowe ramki ---
+
+type rawChunk struct {
+	msgType string
+	final   byte
+	data    []byte // całość łącznie z nagłówkiem 8 B
+}
+
+func (c *secureChannel) readChunk() (rawChunk, error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return rawChunk{}, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[4:])
+	if size < headerSize || size > defaultBufferSize*4 {
+		return rawChunk{}, &StatusError{Code: StatusBadTcpMessageTooLarge}
+	}
+	data := make([]byte, size)
+	copy(data, hdr[:])
+	if _, err := io.ReadFull(c.r, data[headerSize:]); err != nil {
+		return rawChunk{}, err
+	}
+	return rawChunk{msgType: string(hdr[:3]), final: hdr[3], data: data}, nil
+}
+
+func (c *secureChannel) writeRaw(b []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(b)
+	return err
+}
+
+func putHeader(b []byte, msgType string, final byte) {
+	copy(b, msgType)
+	b[3] = final
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+}
+
+// writeError – komunikat ERR przed zamknięciem połączenia.
+func (c *secureChannel) writeError(code uint32, reason string) {
+	e := &encoder{b: make([]byte, headerSize)}
+	e.uint32(code)
+	e.string(reason)
+	b := e.bytes()
+	putHeader(b, "ERR", 'F')
+	_ = c.writeRaw(b)
+}
+
+// --- wysyłanie ---
+
+// asymmetricHeader – nagłówek OPN: polityka, certyfikat nadawcy, odcisk certyfikatu odbiorcy.
+func (c *secureChannel) asymmetricHeader() []byte {
+	e := &encoder{}
+	e.string(c.policy)
+	if c.secured() {
+		e.byteString(c.localCert)
+		tp := sha1.Sum(c.remoteCert)
+		e.byteString(tp[:])
+	} else {
+		e.byteString(nil)
+		e.byteString(nil)
+	}
+	return e.bytes()
+}
+
+func (c *secureChannel) nextSeq() uint32 {
+	c.seq++
+	return c.seq
+}
+
+// sendOPN wysyła OpenSecureChannel (zawsze jedna ramka, szyfrowanie asymetryczne poza polityką None).
+func (c *secureChannel) sendOPN(requestID uint32, body []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	msg := make([]byte, headerSize, 256+len(body))
+	msg = binary.LittleEndian.AppendUint32(msg, c.id)
+	msg = append(msg, c.asymmetricHeader()...)
+	plainStart := len(msg)
+	msg = binary.LittleEndian.AppendUint32(msg, c.nextSeq())
+	msg = binary.LittleEndian.AppendUint32(msg, requestID)
+	msg = append(msg, body...)
+
+	if !c.secured() {
+		putHeader(msg, "OPN", 'F')
+		return c.writeLocked(msg)
+	}
+
+	remoteBytes := c.remoteKey.Size()
+	plainBlock := remoteBytes - oaepSha1Extra
+	sigLen := c.localKey.Size()
+	extra := 0
+	if remoteBytes > 256 {
+		extra = 1
+	}
+	n := len(msg) - plainStart + 1 + extra + sigLen
+	pad := (plainBlock - n%plainBlock) % plainBlock
+	for i := 0; i <= pad; i++ {
+		msg = append(msg, byte(pad))
+	}
+	if extra == 1 {
+		msg = append(msg, byte(pad>>8))
+	}
+	encLen := (len(msg) - plainStart + sigLen) / plainBlock * remoteBytes
+	binary.LittleEndian.PutUint32(msg[4:], uint32(plainStart+encLen))
+	copy(msg, "OPNF")
+
+	h := sha256.Sum256(msg)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.localKey, crypto.SHA256, h[:])
+	if err != nil {
+		return err
+	}
+	plain := append(msg[plainStart:len(msg):len(msg)], sig...)
+	out := make([]byte, plainStart, plainStart+encLen)
+	copy(out, msg[:plainStart])
+	for i := 0; i < len(plain); i += plainBlock {
+		block, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, c.remoteKey, plain[i:i+plainBlock], nil)
+		if err != nil {
+			return err
+		}
+		out = append(out, block...)
+	}
+	return c.writeLocked(out)
+}
+
+func (c *secureChannel) writeLocked(b []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// sendSymmetric wysyła MSG/CLO, w razie potrzeby dzieląc na ramki wg bufora drugiej strony.
+func (c *secureChannel) sendSymmetric(msgType string, requestID uint32, body []byte) error {
+	c.kmu.Lock()
+	tokenID, keys := c.tokenID, c.sendKeys
+	c.kmu.Unlock()
+
+	maxBody := c.sendBuf - headerSize - 8 - sequenceHeaderSize
+	switch c.mode {
+	case SecurityModeSign:
+		maxBody -= symSigLen
+	case SecurityModeSignAndEncrypt:
+		maxBody = (c.sendBuf-headerSize-8)/symBlockSize*symBlockSize - sequenceHeaderSize - 1 - symSigLen
+	}
+	if maxBody < 1024 {
+		return errors.New("opcua: send buffer too small")
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	for {
+		part := body
+		final := byte('F')
+		if len(part) > maxBody {
+			part, final = body[:maxBody], 'C'
+		}
+		body = body[len(part):]
+
+		msg := make([]byte, headerSize, headerSize+8+sequenceHeaderSize+len(part)+symBlockSize+symSigLen)
+		msg = binary.LittleEndian.AppendUint32(msg, c.id)
+		msg = binary.LittleEndian.AppendUint32(msg, tokenID)
+		msg = binary.LittleEndian.AppendUint32(msg, c.nextSeq())
+		msg = binary.LittleEndian.AppendUint32(msg, requestID)
+		msg = append(msg, part...)
+
+		switch c.mode {
+		case SecurityModeSign:
+			binary.LittleEndian.PutUint32(msg[4:], uint32(len(msg)+symSigLen))
+			copy(msg, msgType)
+			msg[3] = final
+			msg = appendHMAC(msg, keys.sign)
+		case SecurityModeSignAndEncrypt:
+			n := len(msg) - 16 + 1 + symSigLen
+			pad := (symBlockSize - n%symBlockSize) % symBlockSize
+			for i := 0; i <= pad; i++ {
+				msg = append(msg, byte(pad))
+			}
+			binary.LittleEndian.PutUint32(msg[4:], uint32(len(msg)+symSigLen))
+			copy(msg, msgType)
+			msg[3] = final
+			msg = appendHMAC(msg, keys.sign)
+			block, _ := aes.NewCipher(keys.encrypt)
+			cipher.NewCBCEncrypter(block, keys.iv).CryptBlocks(msg[16:], msg[16:])
+		default:
+			putHeader(msg, msgType, final)
+		}
+		if err := c.writeLocked(msg); err != nil {
+			return err
+		}
+		if final == 'F' {
+			return nil
+		}
+	}
+}
+
+func appendHMAC(msg, key []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(msg)
+	return m.Sum(msg)
+}
+
+// --- odbiór ---
+
+// message – złożona wiadomość usługi (wszystkie ramki C + F).
+type message struct {
+	msgType   string
+	requestID uint32
+	body      []byte
+	// OPN: dane nagłówka asymetrycznego
+	policy     string
+	senderCert []byte
+}
+
+// readMessage czyta kolejne ramki aż do ramki końcowej; ramki przerwane (A) są odrzucane.
+func (c *secureChannel) readMessage() (*message, error) {
+	var body []byte
+	chunks := 0
+	for {
+		ch, err := c.readChunk()
+		if err != nil {
+			return nil, err
+		}
+		switch ch.msgType {
+		case "OPN":
+			return c.openOPN(ch)
+		case "MSG", "CLO":
+		case "ERR":
+			d := &decoder{b: ch.data[headerSize:]}
+			code := d.uint32()
+			return nil, &StatusError{Code: code, Reason: d.string()}
+		default:
+			return nil, &StatusError{Code: StatusBadTcpMessageTypeInvalid}
+		}
+		requestID, part, err := c.openSymmetric(ch)
+		if err != nil {
+			return nil, err
+		}
+		switch ch.final {
+		case 'A':
+			body, chunks = nil, 0
+			continue
+		case 'C':
+			chunks++
+			if chunks > maxChunkCount || len(body)+len(part) > maxMessageSize {
+				return nil, &StatusError{Code: StatusBadTcpMessageTooLarge}
+			}
+			body = append(body, part...)
+			continue
+		}
+		return &message{msgType: ch.msgType, requestID: requestID, body: append(body, part...)}, nil
+	}
+}
+
+// openSymmetric sprawdza podpis / deszyfruje ramkę MSG/CLO i zwraca requestID oraz treść.
+func (c *secureChannel) openSymmetric(ch rawChunk) (uint32, []byte, error) {
+	data := ch.data
+	if len(data) < headerSize+8+sequenceHeaderSize {
+		return 0, nil, errDecode
+	}
+	if binary.LittleEndian.Uint32(data[headerSize:]) != c.id {
+		return 0, nil, &StatusError{Code: StatusBadSecureChannelIdInvalid}
+	}
+	tokenID := binary.LittleEndian.Uint32(data[headerSize+4:])
+	c.kmu.Lock()
+	keys, ok := c.recvKeys[tokenID]
+	c.kmu.Unlock()
+	if !ok {
+		return 0, nil, &StatusError{Code: StatusBadSecureChannelTokenUnknown}
+	}
+
+	switch c.mode {
+	case SecurityModeSignAndEncrypt:
+		enc := data[16:]
+		if len(enc)%symBlockSize != 0 || len(enc) < symBlockSize+symSigLen {
+			return 0, nil, errSecurityChecks
+		}
+		block, _ := aes.NewCipher(keys.encrypt)
+		cipher.NewCBCDecrypter(block, keys.iv).CryptBlocks(enc, enc)
+		fallthrough
+	case SecurityModeSign:
+		if len(data) < 16+sequenceHeaderSize+symSigLen {
+			return 0, nil, errSecurityChecks
+		}
+		signed := data[:len(data)-symSigLen]
+		m := hmac.New(sha256.New, keys.sign)
+		m.Write(signed)
+		if subtle.ConstantTimeCompare(m.Sum(nil), data[len(data)-symSigLen:]) != 1 {
+			return 0, nil, errSecurityChecks
+		}
+		data = signed
+		if c.mode == SecurityModeSignAndEncrypt {
+			pad := int(data[len(data)-1])
+			if len(data)-pad-1 < 16+sequenceHeaderSize {
+				return 0, nil, errSecurityChecks
+			}
+			data = data[:len(data)-pad-1]
+		}
+	}
+	requestID := binary.LittleEndian.Uint32(data[20:])
+	return requestID, data[24:], nil
+}
+
+// openOPN dekoduje nagłówek asymetryczny i – dla polityki z bezpieczeństwem – deszyfruje
+// wiadomość kluczem prywatnym i weryfikuje podpis certyfikatem nadawcy.
+func (c *secureChannel) openOPN(ch rawChunk) (*message, error) {
+	if ch.final != 'F' {
+		return nil, &StatusError{Code: StatusBadTcpMessageTooLarge}
+	}
+	d := &decoder{b: ch.data[headerSize:]}
+	channelID := d.uint32()
+	msg := &message{msgType: "OPN", policy: d.string(), senderCert: d.byteString()}
+	thumbprint := d.byteString()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if c.id != 0 && channelID != c.id {
+		return nil, &StatusError{Code: StatusBadSecureChannelIdInvalid}
+	}
+	plainStart := len(ch.data) - len(d.b)
+	rest := d.b
+
+	if msg.policy != PolicyNone {
+		if msg.policy != PolicyBasic256Sha256 {
+			return nil, &StatusError{Code: StatusBadSecurityPolicyRejected}
+		}
+		if c.localKey == nil {
+			return nil, &StatusError{Code: StatusBadSecurityPolicyRejected, Reason: "no server certificate"}
+		}
+		tp := sha1.Sum(c.localCert)
+		if !bytes.Equal(thumbprint, tp[:]) {
+			return nil, &StatusError{Code: StatusBadCertificateInvalid, Reason: "receiver thumbprint mismatch"}
+		}
+		remoteKey, err := publicKeyFromCert(msg.senderCert)
+		if err != nil {
+			return nil, &StatusError{Code: StatusBadCertificateInvalid, Reason: err.Error()}
+		}
+		localBytes := c.localKey.Size()
+		if len(rest)%localBytes != 0 {
+			return nil, errSecurityChecks
+		}
+		plain := make([]byte, 0, len(rest))
+		for i := 0; i < len(rest); i += localBytes {
+			block, err := rsa.DecryptOAEP(sha1.New(), nil, c.localKey, rest[i:i+localBytes], nil)
+			if err != nil {
+				return nil, errSecurityChecks
+			}
+			plain = append(plain, block...)
+		}
+		sigLen := remoteKey.Size()
+		if len(plain) < sequenceHeaderSize+sigLen+1 {
+			return nil, errSecurityChecks
+		}
+		signed := append(append([]byte{}, ch.data[:plainStart]...), plain[:len(plain)-sigLen]...)
+		h := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(remoteKey, crypto.SHA256, h[:], plain[len(plain)-sigLen:]) != nil {
+			return nil, errSecurityChecks
+		}
+		plain = plain[:len(plain)-sigLen]
+		padTotal := int(plain[len(plain)-1]) + 1
+		if localBytes > 256 {
+			padTotal = (int(plain[len(plain)-1])<<8 | int(plain[len(plain)-2])) + 2
+		}
+		if len(plain)-padTotal < sequenceHeaderSize {
+			return nil, errSecurityChecks
+		}
+		rest = plain[:len(plain)-padTotal]
+		c.remoteKey = remoteKey
+		c.remoteCert = firstCertificate(msg.senderCert)
+	}
+	if len(rest) < sequenceHeaderSize {
+		return nil, errDecode
+	}
+	msg.requestID = binary.LittleEndian.Uint32(rest[4:])
+	msg.body = rest[sequenceHeaderSize:]
+	return msg, nil
+}
+
+func randomNonce() []byte {
+	b := make([]byte, nonceLen)
+	_, _ = rand.Read(b)
+	return b
+}
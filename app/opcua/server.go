@@ -0,0 +1,1070 @@
+package opcua
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	productURI = "urn:oee-monitoring:go_app"
+
+	minSamplingInterval   = 100 * time.Millisecond
+	minPublishingInterval = 100 * time.Millisecond
+	maxSessionTimeout     = time.Hour
+	maxSessions           = 50
+	maxPublishQueue       = 10
+	maxOperations         = 1000
+	tokenLifetime         = time.Hour
+)
+
+// Identyfikatory kodowania (DefaultBinary) obsługiwanych usług.
+const (
+	idServiceFault            = 397
+	idFindServersRequest      = 422
+	idFindServersResponse     = 425
+	idGetEndpointsRequest     = 428
+	idGetEndpointsResponse    = 431
+	idOpenChannelRequest      = 446
+	idOpenChannelResponse     = 449
+	idCloseChannelRequest     = 452
+	idCreateSessionRequest    = 461
+	idCreateSessionResponse   = 464
+	idActivateSessionRequest  = 467
+	idActivateSessionResponse = 470
+	idCloseSessionRequest     = 473
+	idCloseSessionResponse    = 476
+	idBrowseRequest           = 527
+	idBrowseResponse          = 530
+	idBrowseNextRequest       = 533
+	idBrowseNextResponse      = 536
+	idTranslateRequest        = 554
+	idTranslateResponse       = 557
+	idRegisterNodesRequest    = 560
+	idRegisterNodesResponse   = 563
+	idUnregisterNodesRequest  = 566
+	idUnregisterNodesResponse = 569
+	idReadRequest             = 631
+	idReadResponse            = 634
+	idWriteRequest            = 673
+	idWriteResponse           = 676
+	idCreateItemsRequest      = 751
+	idCreateItemsResponse     = 754
+	idDeleteItemsRequest      = 781
+	idDeleteItemsResponse     = 784
+	idCreateSubRequest        = 787
+	idCreateSubResponse       = 790
+	idModifySubRequest        = 793
+	idModifySubResponse       = 796
+	idSetPublishingRequest    = 799
+	idSetPublishingResponse   = 802
+	idPublishRequest          = 826
+	idPublishResponse         = 829
+	idRepublishRequest        = 832
+	idDeleteSubsRequest       = 847
+	idDeleteSubsResponse      = 850
+	idAnonymousToken          = 321
+	idDataChangeNotification  = 811
+
+	statusBadContinuationPointInvalid uint32 = 0x804A0000
+	statusBadMessageNotAvailable      uint32 = 0x807B0000
+	statusBadBrowseNameInvalid        uint32 = 0x80600000
+	statusBadNoMatch                  uint32 = 0x806F0000
+)
+
+// ServerConfig – konfiguracja serwera OPC UA.
+type ServerConfig struct {
+	// EndpointURL – adres ogłaszany klientom, np. opc.tcp://oee-host:4840.
+	EndpointURL string
+	// ListenAddr – adres nasłuchu (domyślnie port z EndpointURL na wszystkich interfejsach).
+	ListenAddr      string
+	ApplicationName string
+	// Certificate / PrivateKey – certyfikat aplikacji; bez niego dostępny jest tylko endpoint None.
+	Certificate []byte
+	PrivateKey  *rsa.PrivateKey
+	// SecurityModes – tryby oferowane z Basic256Sha256 (domyślnie Sign i SignAndEncrypt).
+	SecurityModes []MessageSecurityMode
+	// AllowNone – dodatkowo endpoint bez zabezpieczeń (zawsze, gdy brak certyfikatu).
+	AllowNone bool
+	// TrustList – zaufane certyfikaty klientów; pusta lista odrzuca każdy kanał Sign/SignAndEncrypt.
+	TrustList *TrustList
+	// TrustAll – akceptuj każdy poprawny certyfikat klienta bez listy (tylko jawnie, np. dev).
+	TrustAll bool
+	// Logf – logowanie zdarzeń połączeń (opcjonalne).
+	Logf func(format string, args ...interface{})
+}
+
+// Server – serwer OPC UA (opc.tcp) udostępniający przestrzeń adresową tylko do odczytu.
+type Server struct {
+	cfg    ServerConfig
+	space  *AddressSpace
+	appURI string
+	start  time.Time
+
+	mu        sync.Mutex
+	ln        net.Listener
+	conns     map[net.Conn]struct{}
+	sessions  map[NodeID]*session // klucz: authentication token
+	nextID    uint32
+	closed    bool
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewServer tworzy serwer dla przestrzeni adresowej space.
+func NewServer(cfg ServerConfig, space *AddressSpace) *Server {
+	if cfg.PrivateKey == nil {
+		cfg.Certificate = nil
+		cfg.AllowNone = true
+	}
+	if len(cfg.SecurityModes) == 0 {
+		cfg.SecurityModes = []MessageSecurityMode{SecurityModeSign, SecurityModeSignAndEncrypt}
+	}
+	if cfg.ApplicationName == "" {
+		cfg.ApplicationName = "go_app OPC UA"
+	}
+	appURI := productURI
+	if uri := ApplicationURI(cfg.Certificate); uri != "" {
+		appURI = uri
+	}
+	s := &Server{
+		cfg:      cfg,
+		space:    space,
+		appURI:   appURI,
+		start:    time.Now().UTC(),
+		conns:    map[net.Conn]struct{}{},
+		sessions: map[NodeID]*session{},
+		nextID:   uint32(rand.Int31n(1 << 20)),
+		done:     make(chan struct{}),
+	}
+	space.setStartTime(s.start)
+	return s
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.cfg.Logf != nil {
+		s.cfg.Logf(format, args...)
+	}
+}
+
+func (s *Server) newID() uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	return s.nextID
+}
+
+// ListenAndServe nasłuchuje na ListenAddr (albo porcie z EndpointURL) i obsługuje połączenia do Close.
+func (s *Server) ListenAndServe() error {
+	addr := s.cfg.ListenAddr
+	if addr == "" {
+		u, err := url.Parse(s.cfg.EndpointURL)
+		if err != nil || u.Scheme != "opc.tcp" {
+			return fmt.Errorf("opcua: invalid endpoint URL %q", s.cfg.EndpointURL)
+		}
+		port := u.Port()
+		if port == "" {
+			port = "4840"
+		}
+		addr = ":" + port
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve obsługuje połączenia z listenera.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return net.ErrClosed
+	}
+	s.ln = ln
+	s.mu.Unlock()
+
+	go s.publishLoop()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close zamyka listener i wszystkie połączenia.
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		if s.ln != nil {
+			err = s.ln.Close()
+		}
+		for c := range s.conns {
+			_ = c.Close()
+		}
+		s.mu.Unlock()
+		close(s.done)
+	})
+	return err
+}
+
+// --- połączenie ---
+
+type serverChannel struct {
+	*secureChannel
+	srv    *Server
+	opened bool
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	sc := &serverChannel{secureChannel: newSecureChannel(conn), srv: s}
+	sc.localCert, sc.localKey = s.cfg.Certificate, s.cfg.PrivateKey
+	defer s.detachChannel(sc)
+
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if err := sc.hello(); err != nil {
+		s.logf("[OPCUA] %s handshake failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(sc.idleTimeout()))
+		msg, err := sc.readMessage()
+		if err != nil {
+			var se *StatusError
+			if errors.As(err, &se) {
+				sc.writeError(se.Code, se.Reason)
+				s.logf("[OPCUA] %s channel error: %v", conn.RemoteAddr(), err)
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.logf("[OPCUA] %s connection closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		switch msg.msgType {
+		case "OPN":
+			if err := sc.handleOpen(msg); err != nil {
+				var se *StatusError
+				if errors.As(err, &se) {
+					sc.writeError(se.Code, se.Reason)
+				}
+				s.logf("[OPCUA] %s open secure channel rejected: %v", conn.RemoteAddr(), err)
+				return
+			}
+		case "CLO":
+			return
+		case "MSG":
+			if !sc.opened {
+				sc.writeError(StatusBadTcpSecureChannelUnknown, "")
+				return
+			}
+			if err := sc.handleService(msg); err != nil {
+				s.logf("[OPCUA] %s send failed: %v", conn.RemoteAddr(), err)
+				return
+			}
+		}
+	}
+}
+
+// idleTimeout – limit ciszy na kanale: przed otwarciem krótki, potem czas życia tokenu.
+func (sc *serverChannel) idleTimeout() time.Duration {
+	if !sc.opened {
+		return 10 * time.Second
+	}
+	return tokenLifetime + time.Minute
+}
+
+// hello – HEL/ACK; ustala rozmiar bufora, którym ograniczane są wysyłane ramki.
+func (sc *serverChannel) hello() error {
+	ch, err := sc.readChunk()
+	if err != nil {
+		return err
+	}
+	if ch.msgType != "HEL" {
+		sc.writeError(StatusBadTcpMessageTypeInvalid, "expected HEL")
+		return &StatusError{Code: StatusBadTcpMessageTypeInvalid}
+	}
+	d := &decoder{b: ch.data[headerSize:]}
+	d.uint32() // ProtocolVersion
+	recvBuf := d.uint32()
+	d.uint32() // SendBufferSize
+	d.uint32() // MaxMessageSize
+	d.uint32() // MaxChunkCount
+	d.string() // EndpointUrl
+	if d.err != nil || recvBuf < 8192 {
+		sc.writeError(StatusBadDecodingError, "invalid HEL")
+		return errDecode
+	}
+	if int(recvBuf) < sc.sendBuf {
+		sc.sendBuf = int(recvBuf)
+	}
+
+	e := &encoder{b: make([]byte, headerSize)}
+	e.uint32(protocolVersion)
+	e.uint32(defaultBufferSize)
+	e.uint32(uint32(sc.sendBuf))
+	e.uint32(maxMessageSize)
+	e.uint32(maxChunkCount)
+	b := e.bytes()
+	putHeader(b, "ACK", 'F')
+	return sc.writeRaw(b)
+}
+
+// requestHeader – pola RequestHeader potrzebne serwerowi.
+type requestHeader struct {
+	authToken NodeID
+	handle    uint32
+	timeout   time.Duration
+}
+
+func decodeRequestHeader(d *decoder) requestHeader {
+	var h requestHeader
+	h.authToken = d.nodeID()
+	d.dateTime()
+	h.handle = d.uint32()
+	d.uint32() // ReturnDiagnostics
+	d.string() // AuditEntryId
+	h.timeout = time.Duration(d.uint32()) * time.Millisecond
+	d.extensionObject()
+	return h
+}
+
+func encodeResponseHeader(e *encoder, handle, result uint32) {
+	e.dateTime(time.Now().UTC())
+	e.uint32(handle)
+	e.uint32(result)
+	e.byte(0)   // ServiceDiagnostics
+	e.int32(-1) // StringTable
+	e.extensionObject(ExtensionObject{})
+}
+
+// handleOpen – OpenSecureChannel (issue/renew).
+func (sc *serverChannel) handleOpen(msg *message) error {
+	d := &decoder{b: msg.body}
+	if typeID := d.nodeID(); typeID != NewNumericNodeID(0, idOpenChannelRequest) {
+		return &StatusError{Code: StatusBadTcpMessageTypeInvalid}
+	}
+	hdr := decodeRequestHeader(d)
+	d.uint32() // ClientProtocolVersion
+	requestType := d.uint32()
+	mode := MessageSecurityMode(d.uint32())
+	clientNonce := d.byteString()
+	lifetime := time.Duration(d.uint32()) * time.Millisecond
+	if d.err != nil {
+		return &StatusError{Code: StatusBadDecodingError}
+	}
+
+	renew := requestType == 1
+	if renew != sc.opened {
+		return &StatusError{Code: StatusBadSecurityChecksFailed, Reason: "unexpected request type"}
+	}
+	if renew && (msg.policy != sc.policy || mode != sc.mode) {
+		return &StatusError{Code: StatusBadSecurityChecksFailed, Reason: "security changed on renew"}
+	}
+	if !renew {
+		if err := sc.srv.checkSecurity(msg.policy, mode, msg.senderCert); err != nil {
+			return err
+		}
+		sc.policy, sc.mode = msg.policy, mode
+		sc.id = sc.srv.newID()
+	}
+
+	var serverNonce []byte
+	if sc.secured() {
+		if len(clientNonce) != nonceLen {
+			return &StatusError{Code: StatusBadSecurityChecksFailed, Reason: "invalid client nonce"}
+		}
+		serverNonce = randomNonce()
+	}
+	if lifetime <= 0 || lifetime > tokenLifetime {
+		lifetime = tokenLifetime
+	}
+	tokenID := sc.srv.newID()
+
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idOpenChannelResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.uint32(protocolVersion)
+	e.uint32(sc.id)
+	e.uint32(tokenID)
+	e.dateTime(time.Now().UTC())
+	e.uint32(uint32(lifetime / time.Millisecond))
+	e.byteString(serverNonce)
+	if err := sc.sendOPN(msg.requestID, e.bytes()); err != nil {
+		return err
+	}
+	sc.installToken(tokenID, serverNonce, clientNonce)
+	if !sc.opened {
+		sc.srv.logf("[OPCUA] %s secure channel %d opened (%s, %s)", sc.conn.RemoteAddr(), sc.id, policyName(sc.policy), sc.mode)
+	}
+	sc.opened = true
+	return nil
+}
+
+func policyName(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Fragment != "" {
+		return u.Fragment
+	}
+	return uri
+}
+
+// checkSecurity – czy serwer oferuje daną politykę / tryb i ufa certyfikatowi klienta.
+func (s *Server) checkSecurity(policy string, mode MessageSecurityMode, clientCert []byte) error {
+	if policy == PolicyNone {
+		if mode != SecurityModeNone {
+			return &StatusError{Code: StatusBadSecurityModeRejected}
+		}
+		return nil // bez endpointu None kanał obsługuje tylko discovery
+	}
+	if policy != PolicyBasic256Sha256 || s.cfg.PrivateKey == nil {
+		return &StatusError{Code: StatusBadSecurityPolicyRejected}
+	}
+	allowed := false
+	for _, m := range s.cfg.SecurityModes {
+		allowed = allowed || m == mode
+	}
+	if !allowed {
+		return &StatusError{Code: StatusBadSecurityModeRejected}
+	}
+	if !s.cfg.TrustAll && !s.cfg.TrustList.Trusted(clientCert) {
+		s.logf("[OPCUA] untrusted client certificate (application %s)", ApplicationURI(clientCert))
+		return &StatusError{Code: StatusBadCertificateUntrusted}
+	}
+	return nil
+}
+
+// endpoints – opisy endpointów oferowanych przez serwer.
+func (s *Server) endpoints(endpointURL string) []endpointDescription {
+	if endpointURL == "" {
+		endpointURL = s.cfg.EndpointURL
+	}
+	var out []endpointDescription
+	if s.cfg.PrivateKey != nil {
+		for _, m := range s.cfg.SecurityModes {
+			level := byte(1)
+			if m == SecurityModeSignAndEncrypt {
+				level = 3
+			}
+			out = append(out, endpointDescription{url: endpointURL, mode: m, policy: PolicyBasic256Sha256, level: level})
+		}
+	}
+	if s.cfg.AllowNone {
+		out = append(out, endpointDescription{url: endpointURL, mode: SecurityModeNone, policy: PolicyNone})
+	}
+	return out
+}
+
+type endpointDescription struct {
+	url    string
+	mode   MessageSecurityMode
+	policy string
+	level  byte
+}
+
+func (s *Server) encodeApplication(e *encoder, discoveryURL string) {
+	e.string(s.appURI)
+	e.string(productURI)
+	e.localizedText(LocalizedText{Text: s.cfg.ApplicationName})
+	e.uint32(0) // Server
+	e.nullString()
+	e.nullString()
+	e.stringArray([]string{discoveryURL})
+}
+
+func (s *Server) encodeEndpoint(e *encoder, ep endpointDescription) {
+	e.string(ep.url)
+	s.encodeApplication(e, ep.url)
+	e.byteString(s.cfg.Certificate)
+	e.uint32(uint32(ep.mode))
+	e.string(ep.policy)
+	e.int32(1) // UserIdentityTokens: anonimowy
+	e.string("anonymous")
+	e.uint32(0)
+	e.nullString()
+	e.nullString()
+	e.nullString()
+	e.string("http://opcfoundation.org/UA-Profile/Transport/uatcp-uasc-uabinary")
+	e.byte(ep.level)
+}
+
+// --- usługi ---
+
+// serviceFault – odpowiedź błędu całej usługi.
+func serviceFault(handle, code uint32) []byte {
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idServiceFault))
+	encodeResponseHeader(e, handle, code)
+	return e.bytes()
+}
+
+func (sc *serverChannel) handleService(msg *message) error {
+	d := &decoder{b: msg.body}
+	typeID := d.nodeID()
+	hdr := decodeRequestHeader(d)
+	if d.err != nil {
+		return sc.sendSymmetric("MSG", msg.requestID, serviceFault(hdr.handle, StatusBadDecodingError))
+	}
+
+	// kanał None bez endpointu None służy wyłącznie do discovery (GetEndpoints przed kanałem z certyfikatem)
+	discovery := typeID == NewNumericNodeID(0, idGetEndpointsRequest) || typeID == NewNumericNodeID(0, idFindServersRequest)
+	if !sc.secured() && !sc.srv.cfg.AllowNone && !discovery && typeID != NewNumericNodeID(0, idCloseChannelRequest) {
+		return sc.sendSymmetric("MSG", msg.requestID, serviceFault(hdr.handle, StatusBadSecurityPolicyRejected))
+	}
+
+	var resp []byte
+	switch typeID {
+	case NewNumericNodeID(0, idCloseChannelRequest):
+		return io.EOF
+	case NewNumericNodeID(0, idGetEndpointsRequest):
+		resp = sc.getEndpoints(d, hdr)
+	case NewNumericNodeID(0, idFindServersRequest):
+		resp = sc.findServers(d, hdr)
+	case NewNumericNodeID(0, idCreateSessionRequest):
+		resp = sc.createSession(d, hdr)
+	case NewNumericNodeID(0, idActivateSessionRequest):
+		resp = sc.activateSession(d, hdr)
+	default:
+		sess, code := sc.srv.lookupSession(hdr.authToken, sc)
+		if code != StatusGood {
+			resp = serviceFault(hdr.handle, code)
+			break
+		}
+		if typeID == NewNumericNodeID(0, idPublishRequest) {
+			resp = sc.srv.publish(sess, sc, d, hdr, msg.requestID)
+			if resp == nil {
+				return nil // odpowiedź później – z pętli subskrypcji
+			}
+			break
+		}
+		resp = sc.sessionService(sess, typeID, d, hdr)
+	}
+	if d.err != nil {
+		resp = serviceFault(hdr.handle, StatusBadDecodingError)
+	}
+	return sc.sendSymmetric("MSG", msg.requestID, resp)
+}
+
+func (sc *serverChannel) sessionService(sess *session, typeID NodeID, d *decoder, hdr requestHeader) []byte {
+	switch typeID.ID {
+	case idCloseSessionRequest:
+		deleteSubs := d.bool()
+		sc.srv.closeSession(sess, deleteSubs)
+		e := &encoder{}
+		e.nodeID(NewNumericNodeID(0, idCloseSessionResponse))
+		encodeResponseHeader(e, hdr.handle, StatusGood)
+		return e.bytes()
+	case idBrowseRequest:
+		return sc.srv.browse(d, hdr)
+	case idBrowseNextRequest:
+		return browseNext(d, hdr)
+	case idTranslateRequest:
+		return sc.srv.translate(d, hdr)
+	case idRegisterNodesRequest, idUnregisterNodesRequest:
+		return registerNodes(typeID.ID, d, hdr)
+	case idReadRequest:
+		return sc.srv.read(d, hdr)
+	case idWriteRequest:
+		return write(d, hdr)
+	case idCreateSubRequest:
+		return sc.srv.createSubscription(sess, d, hdr)
+	case idModifySubRequest:
+		return sc.srv.modifySubscription(sess, d, hdr)
+	case idSetPublishingRequest:
+		return sc.srv.setPublishingMode(sess, d, hdr)
+	case idDeleteSubsRequest:
+		return sc.srv.deleteSubscriptions(sess, d, hdr)
+	case idCreateItemsRequest:
+		return sc.srv.createMonitoredItems(sess, d, hdr)
+	case idDeleteItemsRequest:
+		return sc.srv.deleteMonitoredItems(sess, d, hdr)
+	case idRepublishRequest:
+		return serviceFault(hdr.handle, statusBadMessageNotAvailable)
+	}
+	return serviceFault(hdr.handle, StatusBadServiceUnsupported)
+}
+
+func (sc *serverChannel) getEndpoints(d *decoder, hdr requestHeader) []byte {
+	endpointURL := d.string()
+	d.stringArray() // LocaleIds
+	d.stringArray() // ProfileUris
+	eps := sc.srv.endpoints(endpointURL)
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idGetEndpointsResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(len(eps)))
+	for _, ep := range eps {
+		sc.srv.encodeEndpoint(e, ep)
+	}
+	return e.bytes()
+}
+
+func (sc *serverChannel) findServers(d *decoder, hdr requestHeader) []byte {
+	endpointURL := d.string()
+	if endpointURL == "" {
+		endpointURL = sc.srv.cfg.EndpointURL
+	}
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idFindServersResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(1)
+	sc.srv.encodeApplication(e, endpointURL)
+	return e.bytes()
+}
+
+// --- sesje ---
+
+type session struct {
+	id, token   NodeID
+	name        string
+	channel     *serverChannel
+	activated   bool
+	nonce       []byte
+	clientCert  []byte
+	timeout     time.Duration
+	lastSeen    time.Time
+	subs        map[uint32]*subscription
+	publishQ    []pendingPublish
+	lastPublish uint32 // ostatnia obsłużona subskrypcja (round-robin)
+}
+
+func (sc *serverChannel) createSession(d *decoder, hdr requestHeader) []byte {
+	// ClientDescription
+	d.string()
+	d.string()
+	d.localizedText()
+	d.uint32()
+	d.string()
+	d.string()
+	d.stringArray()
+	d.string() // ServerUri
+	endpointURL := d.string()
+	name := d.string()
+	clientNonce := d.byteString()
+	clientCert := d.byteString()
+	timeout := time.Duration(d.double()) * time.Millisecond
+	d.uint32() // MaxResponseMessageSize
+	if d.err != nil {
+		return serviceFault(hdr.handle, StatusBadDecodingError)
+	}
+	if sc.secured() && len(clientNonce) < nonceLen {
+		return serviceFault(hdr.handle, StatusBadSecurityChecksFailed)
+	}
+	if timeout < 10*time.Second {
+		timeout = 10 * time.Second
+	} else if timeout > maxSessionTimeout {
+		timeout = maxSessionTimeout
+	}
+
+	s := sc.srv
+	s.mu.Lock()
+	if len(s.sessions) >= maxSessions {
+		s.mu.Unlock()
+		return serviceFault(hdr.handle, StatusBadTooManyOperations)
+	}
+	s.mu.Unlock()
+
+	sess := &session{
+		id:         NewNumericNodeID(1, s.newID()),
+		token:      NodeID{Namespace: 0, Str: string(randomNonce()), Kind: 'b'},
+		name:       name,
+		channel:    sc,
+		nonce:      randomNonce(),
+		clientCert: clientCert,
+		timeout:    timeout,
+		lastSeen:   time.Now(),
+		subs:       map[uint32]*subscription{},
+	}
+	s.mu.Lock()
+	s.sessions[sess.token] = sess
+	s.mu.Unlock()
+
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idCreateSessionResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.nodeID(sess.id)
+	e.nodeID(sess.token)
+	e.double(float64(timeout / time.Millisecond))
+	e.byteString(sess.nonce)
+	e.byteString(s.cfg.Certificate)
+	eps := s.endpoints(endpointURL)
+	e.int32(int32(len(eps)))
+	for _, ep := range eps {
+		s.encodeEndpoint(e, ep)
+	}
+	e.int32(0) // ServerSoftwareCertificates
+	if sc.secured() {
+		h := sha256.Sum256(append(append([]byte{}, clientCert...), clientNonce...))
+		sig, err := rsa.SignPKCS1v15(nil, s.cfg.PrivateKey, crypto.SHA256, h[:])
+		if err != nil {
+			return serviceFault(hdr.handle, StatusBadInternalError)
+		}
+		e.string(algorithmRsaSha256)
+		e.byteString(sig)
+	} else {
+		e.nullString()
+		e.byteString(nil)
+	}
+	e.uint32(maxMessageSize)
+	s.logf("[OPCUA] session %q created (%s)", name, sess.id)
+	return e.bytes()
+}
+
+func (sc *serverChannel) activateSession(d *decoder, hdr requestHeader) []byte {
+	s := sc.srv
+	s.mu.Lock()
+	sess, ok := s.sessions[hdr.authToken]
+	s.mu.Unlock()
+	if !ok {
+		return serviceFault(hdr.handle, StatusBadSessionIdInvalid)
+	}
+
+	sigAlg := d.string()
+	sig := d.byteString()
+	n := d.arrayLen() // ClientSoftwareCertificates
+	for i := 0; i < n && d.err == nil; i++ {
+		d.byteString()
+		d.byteString()
+	}
+	d.stringArray() // LocaleIds
+	token := d.extensionObject()
+	d.string() // UserTokenSignature
+	d.byteString()
+	if d.err != nil {
+		return serviceFault(hdr.handle, StatusBadDecodingError)
+	}
+
+	if sc.secured() {
+		// sesja może przejść na nowy kanał tylko z tym samym certyfikatem klienta
+		if sc.policy != sess.channel.policy || !bytes.Equal(firstCertificate(sess.clientCert), sc.remoteCert) {
+			return serviceFault(hdr.handle, StatusBadSecurityChecksFailed)
+		}
+		h := sha256.Sum256(append(append([]byte{}, s.cfg.Certificate...), sess.nonce...))
+		if sigAlg != algorithmRsaSha256 || rsa.VerifyPKCS1v15(sc.remoteKey, crypto.SHA256, h[:], sig) != nil {
+			return serviceFault(hdr.handle, StatusBadSecurityChecksFailed)
+		}
+	} else if sess.channel != sc && sess.channel.secured() {
+		return serviceFault(hdr.handle, StatusBadSecurityChecksFailed)
+	}
+	if token.TypeID != NewNumericNodeID(0, idAnonymousToken) && !token.TypeID.IsNull() {
+		return serviceFault(hdr.handle, StatusBadIdentityTokenRejected)
+	}
+
+	s.mu.Lock()
+	if sess.channel != sc {
+		sess.publishQ = nil // oczekujące Publish należały do starego kanału
+	}
+	sess.channel = sc
+	sess.activated = true
+	sess.lastSeen = time.Now()
+	sess.nonce = randomNonce()
+	nonce := sess.nonce
+	s.mu.Unlock()
+
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idActivateSessionResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.byteString(nonce)
+	e.int32(0) // Results
+	e.int32(0) // DiagnosticInfos
+	return e.bytes()
+}
+
+// lookupSession – aktywna sesja z tokenem z nagłówka, przypisana do tego kanału.
+func (s *Server) lookupSession(token NodeID, sc *serverChannel) (*session, uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok {
+		return nil, StatusBadSessionIdInvalid
+	}
+	if !sess.activated || sess.channel != sc {
+		return nil, StatusBadSessionNotActivated
+	}
+	sess.lastSeen = time.Now()
+	return sess, StatusGood
+}
+
+func (s *Server) closeSession(sess *session, _ bool) {
+	s.mu.Lock()
+	delete(s.sessions, sess.token)
+	sess.subs = map[uint32]*subscription{}
+	s.mu.Unlock()
+	s.logf("[OPCUA] session %q closed", sess.name)
+}
+
+// detachChannel – kanał zamknięty: oczekujące Publish przepadają, sesja czeka na reaktywację do timeoutu.
+func (s *Server) detachChannel(sc *serverChannel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.sessions {
+		if sess.channel == sc {
+			sess.publishQ = nil
+		}
+	}
+}
+
+// --- Browse / Read / Write ---
+
+func (s *Server) browse(d *decoder, hdr requestHeader) []byte {
+	// View
+	d.nodeID()
+	d.dateTime()
+	d.uint32()
+	d.uint32() // RequestedMaxReferencesPerNode – bez punktów kontynuacji
+	n := d.arrayLen()
+	if n == 0 {
+		return serviceFault(hdr.handle, StatusBadNothingToDo)
+	}
+	if n > maxOperations {
+		return serviceFault(hdr.handle, StatusBadTooManyOperations)
+	}
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idBrowseResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(n))
+	for i := 0; i < n && d.err == nil; i++ {
+		node := d.nodeID()
+		direction := d.uint32()
+		refType := d.nodeID()
+		subtypes := d.bool()
+		classMask := d.uint32()
+		resultMask := d.uint32()
+
+		refs, ok := s.space.browse(node, direction, refType, subtypes, classMask)
+		if !ok {
+			e.uint32(StatusBadNodeIdUnknown)
+			e.byteString(nil)
+			e.int32(0)
+			continue
+		}
+		e.uint32(StatusGood)
+		e.byteString(nil)
+		e.int32(int32(len(refs)))
+		for _, r := range refs {
+			encodeReference(e, r, resultMask)
+		}
+	}
+	e.int32(0) // DiagnosticInfos
+	return e.bytes()
+}
+
+// encodeReference – pola poza ResultMask mają wartości puste.
+func encodeReference(e *encoder, r ReferenceDescription, mask uint32) {
+	if mask&0x01 != 0 {
+		e.nodeID(r.ReferenceType)
+	} else {
+		e.nodeID(NodeID{})
+	}
+	e.bool(mask&0x02 != 0 && r.IsForward)
+	e.expandedNodeID(r.Target)
+	if mask&0x08 != 0 {
+		e.qualifiedName(r.BrowseName)
+	} else {
+		e.qualifiedName(QualifiedName{})
+	}
+	if mask&0x10 != 0 {
+		e.localizedText(r.DisplayName)
+	} else {
+		e.localizedText(LocalizedText{})
+	}
+	if mask&0x04 != 0 {
+		e.uint32(uint32(r.Class))
+	} else {
+		e.uint32(0)
+	}
+	if mask&0x20 != 0 {
+		e.expandedNodeID(r.TypeDef)
+	} else {
+		e.expandedNodeID(NodeID{})
+	}
+}
+
+func browseNext(d *decoder, hdr requestHeader) []byte {
+	d.bool()
+	n := d.arrayLen()
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idBrowseNextResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(n))
+	for i := 0; i < n && d.err == nil; i++ {
+		d.byteString()
+		e.uint32(statusBadContinuationPointInvalid)
+		e.byteString(nil)
+		e.int32(0)
+	}
+	e.int32(0)
+	return e.bytes()
+}
+
+// translate – TranslateBrowsePathsToNodeIds po referencjach hierarchicznych (nazwy BrowseName).
+func (s *Server) translate(d *decoder, hdr requestHeader) []byte {
+	n := d.arrayLen()
+	if n == 0 {
+		return serviceFault(hdr.handle, StatusBadNothingToDo)
+	}
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idTranslateResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(n))
+	for i := 0; i < n && d.err == nil; i++ {
+		node := d.nodeID()
+		elems := d.arrayLen()
+		status := StatusGood
+		if elems == 0 {
+			status = StatusBadNothingToDo
+		}
+		for j := 0; j < elems && d.err == nil; j++ {
+			d.nodeID() // ReferenceTypeId
+			d.bool()   // IsInverse
+			d.bool()   // IncludeSubtypes
+			name := d.qualifiedName()
+			if status != StatusGood {
+				continue
+			}
+			if name.Name == "" {
+				status = statusBadBrowseNameInvalid
+				continue
+			}
+			next, ok := s.space.childByName(node, name)
+			if !ok {
+				status = statusBadNoMatch
+				continue
+			}
+			node = next
+		}
+		e.uint32(status)
+		if status != StatusGood {
+			e.int32(0)
+			continue
+		}
+		e.int32(1)
+		e.expandedNodeID(node)
+		e.uint32(0xFFFFFFFF) // RemainingPathIndex
+	}
+	e.int32(0)
+	return e.bytes()
+}
+
+func registerNodes(id uint32, d *decoder, hdr requestHeader) []byte {
+	n := d.arrayLen()
+	ids := make([]NodeID, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		ids = append(ids, d.nodeID())
+	}
+	e := &encoder{}
+	if id == idUnregisterNodesRequest {
+		e.nodeID(NewNumericNodeID(0, idUnregisterNodesResponse))
+		encodeResponseHeader(e, hdr.handle, StatusGood)
+		return e.bytes()
+	}
+	e.nodeID(NewNumericNodeID(0, idRegisterNodesResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(len(ids)))
+	for _, x := range ids {
+		e.nodeID(x)
+	}
+	return e.bytes()
+}
+
+type readValueID struct {
+	node NodeID
+	attr uint32
+}
+
+func decodeReadValueID(d *decoder) readValueID {
+	r := readValueID{node: d.nodeID(), attr: d.uint32()}
+	d.string()        // IndexRange
+	d.qualifiedName() // DataEncoding
+	return r
+}
+
+// applyTimestamps – TimestampsToReturn: 0 Source, 1 Server, 2 Both, 3 Neither.
+func applyTimestamps(dv DataValue, which uint32, attr uint32) DataValue {
+	now := time.Now().UTC()
+	if attr != AttrValue {
+		dv.SourceTimestamp = time.Time{}
+	}
+	switch which {
+	case 0:
+		dv.ServerTimestamp = time.Time{}
+	case 1:
+		dv.SourceTimestamp = time.Time{}
+		dv.ServerTimestamp = now
+	case 2:
+		dv.ServerTimestamp = now
+	default:
+		dv.SourceTimestamp, dv.ServerTimestamp = time.Time{}, time.Time{}
+	}
+	return dv
+}
+
+func (s *Server) read(d *decoder, hdr requestHeader) []byte {
+	d.double() // MaxAge
+	which := d.uint32()
+	n := d.arrayLen()
+	if n == 0 {
+		return serviceFault(hdr.handle, StatusBadNothingToDo)
+	}
+	if n > maxOperations {
+		return serviceFault(hdr.handle, StatusBadTooManyOperations)
+	}
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idReadResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(n))
+	for i := 0; i < n && d.err == nil; i++ {
+		r := decodeReadValueID(d)
+		e.dataValue(applyTimestamps(s.space.readAttribute(r.node, r.attr), which, r.attr))
+	}
+	e.int32(0)
+	return e.bytes()
+}
+
+// write – przestrzeń adresowa jest tylko do odczytu.
+func write(d *decoder, hdr requestHeader) []byte {
+	n := d.arrayLen()
+	for i := 0; i < n && d.err == nil; i++ {
+		d.nodeID()
+		d.uint32()
+		d.string()
+		d.dataValue()
+	}
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idWriteResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(n))
+	for i := 0; i < n; i++ {
+		e.uint32(StatusBadNotWritable)
+	}
+	e.int32(0)
+	return e.bytes()
+}
@@ -0,0 +1,162 @@
+package opcua
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert – certyfikat aplikacji (DER + klucz) wygenerowany jak opcua-client -gen-cert.
+func testCert(t *testing.T, dir, name, uri string) ([]byte, *rsa.PrivateKey) {
+	t.Helper()
+	certPEM, keyPEM, err := GenerateCertificate(uri, []string{"127.0.0.1"}, 2048, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, key, err := LoadCertificate(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// startTestServer – serwer z jedną zmienną ns=1;s=Line1.KPI.oee na losowym porcie.
+func startTestServer(t *testing.T, cfg ServerConfig) (string, *AddressSpace, NodeID) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.EndpointURL = "opc.tcp://" + ln.Addr().String()
+	space := NewAddressSpace("urn:oee-monitoring:test")
+	machine := space.AddObject(NewNumericNodeID(0, 85), NewStringNodeID(1, "Line1"), "Line1", "")
+	oee := space.AddVariable(machine, NewStringNodeID(1, "Line1.KPI.oee"), "oee", TypeDouble, 0.0, "OEE [0..1]")
+	srv := NewServer(cfg, space)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return cfg.EndpointURL, space, oee
+}
+
+func TestClientServerNone(t *testing.T) {
+	endpoint, space, oee := startTestServer(t, ServerConfig{})
+	space.SetValue(oee, 0.4293, time.Now())
+
+	c, err := Dial(ClientConfig{Endpoint: endpoint, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	refs, err := c.Browse(NewStringNodeID(1, "Line1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, r := range refs {
+		found = found || r.Target == oee
+	}
+	if !found {
+		t.Errorf("Browse(Line1) = %+v, want %s", refs, oee)
+	}
+	dvs, err := c.Read(oee, NewStringNodeID(1, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dvs) != 2 || dvs[0].Value == nil || dvs[0].Value.Value != 0.4293 {
+		t.Fatalf("Read = %+v", dvs)
+	}
+	if dvs[1].Status != StatusBadNodeIdUnknown {
+		t.Errorf("Read(missing) status = %#x", dvs[1].Status)
+	}
+}
+
+func TestClientServerSecure(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := testCert(t, dir, "server", "urn:oee-monitoring:test")
+	trustedDir := filepath.Join(dir, "trusted")
+	if err := os.Mkdir(trustedDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	clientCert, clientKey := testCert(t, trustedDir, "client", "urn:oee-monitoring:client")
+	os.Remove(filepath.Join(trustedDir, "client.key"))
+	trust, err := LoadTrustList(trustedDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, space, oee := startTestServer(t, ServerConfig{Certificate: serverCert, PrivateKey: serverKey, TrustList: trust})
+
+	for _, mode := range []MessageSecurityMode{SecurityModeSign, SecurityModeSignAndEncrypt} {
+		t.Run(mode.String(), func(t *testing.T) {
+			c, err := Dial(ClientConfig{Endpoint: endpoint, Policy: PolicyBasic256Sha256, Mode: mode,
+				Certificate: clientCert, PrivateKey: clientKey, Timeout: 5 * time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			changes := make(chan float64, 8)
+			go c.Subscribe(50*time.Millisecond, []NodeID{oee}, func(id NodeID, dv DataValue) {
+				if v, ok := dv.Value.Value.(float64); ok && id == oee {
+					changes <- v
+				}
+			})
+			space.SetValue(oee, 0.85, time.Now())
+			deadline := time.After(5 * time.Second)
+			for {
+				select {
+				case v := <-changes:
+					if v == 0.85 {
+						return
+					}
+				case <-deadline:
+					t.Fatal("no data change notification for 0.85")
+				}
+			}
+		})
+	}
+
+	t.Run("None rejected", func(t *testing.T) {
+		if _, err := Dial(ClientConfig{Endpoint: endpoint, Timeout: 5 * time.Second}); err == nil {
+			t.Error("session over SecurityPolicy None accepted without OPCUA_ALLOW_NONE")
+		}
+	})
+}
+
+func TestUntrustedClientRejected(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := testCert(t, dir, "server", "urn:oee-monitoring:test")
+	clientCert, clientKey := testCert(t, dir, "client", "urn:oee-monitoring:client")
+	empty, err := LoadTrustList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dial := func(endpoint string) error {
+		c, err := Dial(ClientConfig{Endpoint: endpoint, Policy: PolicyBasic256Sha256, Mode: SecurityModeSignAndEncrypt,
+			Certificate: clientCert, PrivateKey: clientKey, Timeout: 5 * time.Second})
+		if err == nil {
+			c.Close()
+		}
+		return err
+	}
+
+	endpoint, _, _ := startTestServer(t, ServerConfig{Certificate: serverCert, PrivateKey: serverKey, TrustList: empty})
+	var se *StatusError
+	if err := dial(endpoint); !errors.As(err, &se) || se.Code != StatusBadCertificateUntrusted {
+		t.Errorf("empty trust list: err = %v, want BadCertificateUntrusted", err)
+	}
+
+	endpoint, _, _ = startTestServer(t, ServerConfig{Certificate: serverCert, PrivateKey: serverKey, TrustList: empty, TrustAll: true})
+	if err := dial(endpoint); err != nil {
+		t.Errorf("TrustAll: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package opcua
+
+import "fmt"
+
+// Kody statusu OPC UA używane przez serwer i klienta.
+const (
+	StatusGood                         uint32 = 0x00000000
+	StatusBadInternalError             uint32 = 0x80020000
+	StatusBadDecodingError             uint32 = 0x80070000
+	StatusBadTimeout                   uint32 = 0x800A0000
+	StatusBadServiceUnsupported        uint32 = 0x800B0000
+	StatusBadNothingToDo               uint32 = 0x800F0000
+	StatusBadTooManyOperations         uint32 = 0x80100000
+	StatusBadCertificateInvalid        uint32 = 0x80120000
+	StatusBadSecurityChecksFailed      uint32 = 0x80130000
+	StatusBadCertificateUntrusted      uint32 = 0x801A0000
+	StatusBadIdentityTokenInvalid      uint32 = 0x80200000
+	StatusBadIdentityTokenRejected     uint32 = 0x80210000
+	StatusBadSecureChannelIdInvalid    uint32 = 0x80220000
+	StatusBadSessionIdInvalid          uint32 = 0x80250000
+	StatusBadSessionClosed             uint32 = 0x80260000
+	StatusBadSessionNotActivated       uint32 = 0x80270000
+	StatusBadSubscriptionIdInvalid     uint32 = 0x80280000
+	StatusBadWaitingForInitialData     uint32 = 0x80320000
+	StatusBadNodeIdUnknown             uint32 = 0x80340000
+	StatusBadAttributeIdInvalid        uint32 = 0x80350000
+	StatusBadNotWritable               uint32 = 0x803B0000
+	StatusBadMonitoredItemIdInvalid    uint32 = 0x80420000
+	StatusBadSecurityModeRejected      uint32 = 0x80540000
+	StatusBadSecurityPolicyRejected    uint32 = 0x80550000
+	StatusBadNoSubscription            uint32 = 0x80790000
+	StatusBadTooManyPublishRequests    uint32 = 0x80780000
+	StatusBadTcpMessageTypeInvalid     uint32 = 0x807E0000
+	StatusBadTcpSecureChannelUnknown   uint32 = 0x807F0000
+	StatusBadTcpMessageTooLarge        uint32 = 0x80800000
+	StatusBadTcpEndpointUrlInvalid     uint32 = 0x80830000
+	StatusBadSecureChannelTokenUnknown uint32 = 0x80870000
+	StatusBadSecureChannelClosed       uint32 = 0x80860000
+)
+
+var statusNames = map[uint32]string{
+	StatusBadInternalError:             "BadInternalError",
+	StatusBadDecodingError:             "BadDecodingError",
+	StatusBadTimeout:                   "BadTimeout",
+	StatusBadServiceUnsupported:        "BadServiceUnsupported",
+	StatusBadNothingToDo:               "BadNothingToDo",
+	StatusBadTooManyOperations:         "BadTooManyOperations",
+	StatusBadCertificateInvalid:        "BadCertificateInvalid",
+	StatusBadSecurityChecksFailed:      "BadSecurityChecksFailed",
+	StatusBadCertificateUntrusted:      "BadCertificateUntrusted",
+	StatusBadIdentityTokenInvalid:      "BadIdentityTokenInvalid",
+	StatusBadIdentityTokenRejected:     "BadIdentityTokenRejected",
+	StatusBadSecureChannelIdInvalid:    "BadSecureChannelIdInvalid",
+	StatusBadSessionIdInvalid:          "BadSessionIdInvalid",
+	StatusBadSessionClosed:             "BadSessionClosed",
+	StatusBadSessionNotActivated:       "BadSessionNotActivated",
+	StatusBadSubscriptionIdInvalid:     "BadSubscriptionIdInvalid",
+	StatusBadWaitingForInitialData:     "BadWaitingForInitialData",
+	StatusBadNodeIdUnknown:             "BadNodeIdUnknown",
+	StatusBadAttributeIdInvalid:        "BadAttributeIdInvalid",
+	StatusBadNotWritable:               "BadNotWritable",
+	StatusBadMonitoredItemIdInvalid:    "BadMonitoredItemIdInvalid",
+	StatusBadSecurityModeRejected:      "BadSecurityModeRejected",
+	StatusBadSecurityPolicyRejected:    "BadSecurityPolicyRejected",
+	StatusBadNoSubscription:            "BadNoSubscription",
+	StatusBadTooManyPublishRequests:    "BadTooManyPublishRequests",
+	StatusBadTcpMessageTypeInvalid:     "BadTcpMessageTypeInvalid",
+	StatusBadTcpSecureChannelUnknown:   "BadTcpSecureChannelUnknown",
+	StatusBadTcpMessageTooLarge:        "BadTcpMessageTooLarge",
+	StatusBadTcpEndpointUrlInvalid:     "BadTcpEndpointUrlInvalid",
+	StatusBadSecureChannelTokenUnknown: "BadSecureChannelTokenUnknown",
+	StatusBadSecureChannelClosed:       "BadSecureChannelClosed",
+}
+
+// StatusName – nazwa kodu statusu (albo zapis szesnastkowy).
+func StatusName(code uint32) string {
+	if code == StatusGood {
+		return "Good"
+	}
+	if n, ok := statusNames[code]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%08X", code)
+}
+
+// StatusError – błąd usługi albo kanału z kodem statusu OPC UA.
+type StatusError struct {
+	Code   uint32
+	Reason string
+}
+
+func (e *StatusError) Error() string {
+	if e.Reason != "" {
+		return "opcua: " + StatusName(e.Code) + ": " + e.Reason
+	}
+	return "opcua: " + StatusName(e.Code)
+}
@@ -0,0 +1,448 @@
+package opcua
+
+import "time"
+
+// subscription – subskrypcja sesji; wartości próbkowane co PublishingInterval,
+// do klienta trafia tylko najnowsza zmiana każdego elementu (kolejka 1).
+type subscription struct {
+	id               uint32
+	interval         time.Duration
+	lifetimeCount    uint32
+	keepAliveCount   uint32
+	maxNotifications uint32
+	enabled          bool
+
+	items     map[uint32]*monitoredItem
+	seq       uint32
+	next      time.Time
+	keepAlive uint32 // interwały od ostatniej wiadomości
+	lifetime  uint32 // interwały bez dostępnego PublishRequest
+	pending   []itemNotification
+}
+
+type monitoredItem struct {
+	id      uint32
+	handle  uint32
+	rv      readValueID
+	which   uint32 // TimestampsToReturn
+	mode    uint32 // 0 Disabled, 1 Sampling, 2 Reporting
+	last    DataValue
+	sampled bool
+}
+
+type itemNotification struct {
+	itemID uint32
+	handle uint32
+	value  DataValue
+}
+
+type pendingPublish struct {
+	requestID uint32
+	handle    uint32
+	acks      []uint32
+	expires   time.Time
+}
+
+type outgoing struct {
+	sc        *serverChannel
+	requestID uint32
+	body      []byte
+}
+
+// reviseSubscription – granice parametrów subskrypcji.
+func reviseSubscription(intervalMs float64, lifetime, keepAlive uint32) (time.Duration, uint32, uint32) {
+	interval := time.Duration(intervalMs * float64(time.Millisecond))
+	if interval < minPublishingInterval {
+		interval = minPublishingInterval
+	} else if interval > time.Hour {
+		interval = time.Hour
+	}
+	if keepAlive == 0 {
+		keepAlive = 10
+	} else if keepAlive > 10000 {
+		keepAlive = 10000
+	}
+	if lifetime < 3*keepAlive {
+		lifetime = 3 * keepAlive
+	}
+	return interval, lifetime, keepAlive
+}
+
+func (s *Server) createSubscription(sess *session, d *decoder, hdr requestHeader) []byte {
+	intervalMs := d.double()
+	lifetime := d.uint32()
+	keepAlive := d.uint32()
+	maxNotif := d.uint32()
+	enabled := d.bool()
+	d.byte() // Priority
+	if d.err != nil {
+		return serviceFault(hdr.handle, StatusBadDecodingError)
+	}
+	interval, lifetime, keepAlive := reviseSubscription(intervalMs, lifetime, keepAlive)
+	sub := &subscription{
+		id:               s.newID(),
+		interval:         interval,
+		lifetimeCount:    lifetime,
+		keepAliveCount:   keepAlive,
+		maxNotifications: maxNotif,
+		enabled:          enabled,
+		items:            map[uint32]*monitoredItem{},
+		next:             time.Now().Add(interval),
+	}
+	s.mu.Lock()
+	sess.subs[sub.id] = sub
+	s.mu.Unlock()
+
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idCreateSubResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.uint32(sub.id)
+	e.double(float64(interval) / float64(time.Millisecond))
+	e.uint32(lifetime)
+	e.uint32(keepAlive)
+	return e.bytes()
+}
+
+func (s *Server) modifySubscription(sess *session, d *decoder, hdr requestHeader) []byte {
+	id := d.uint32()
+	intervalMs := d.double()
+	lifetime := d.uint32()
+	keepAlive := d.uint32()
+	maxNotif := d.uint32()
+	d.byte()
+	interval, lifetime, keepAlive := reviseSubscription(intervalMs, lifetime, keepAlive)
+
+	s.mu.Lock()
+	sub, ok := sess.subs[id]
+	if ok {
+		sub.interval, sub.lifetimeCount, sub.keepAliveCount, sub.maxNotifications = interval, lifetime, keepAlive, maxNotif
+	}
+	s.mu.Unlock()
+	if !ok {
+		return serviceFault(hdr.handle, StatusBadSubscriptionIdInvalid)
+	}
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idModifySubResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.double(float64(interval) / float64(time.Millisecond))
+	e.uint32(lifetime)
+	e.uint32(keepAlive)
+	return e.bytes()
+}
+
+// subscriptionResults – wspólny wzorzec usług operujących na liście identyfikatorów subskrypcji.
+func (s *Server) subscriptionResults(sess *session, ids []uint32, respID uint32, handle uint32, apply func(*subscription) bool) []byte {
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, respID))
+	encodeResponseHeader(e, handle, StatusGood)
+	e.int32(int32(len(ids)))
+	s.mu.Lock()
+	for _, id := range ids {
+		sub, ok := sess.subs[id]
+		if ok && apply(sub) {
+			e.uint32(StatusGood)
+		} else {
+			e.uint32(StatusBadSubscriptionIdInvalid)
+		}
+	}
+	s.mu.Unlock()
+	e.int32(0)
+	return e.bytes()
+}
+
+func (s *Server) setPublishingMode(sess *session, d *decoder, hdr requestHeader) []byte {
+	enabled := d.bool()
+	ids := d.uint32Array()
+	return s.subscriptionResults(sess, ids, idSetPublishingResponse, hdr.handle, func(sub *subscription) bool {
+		sub.enabled = enabled
+		return true
+	})
+}
+
+func (s *Server) deleteSubscriptions(sess *session, d *decoder, hdr requestHeader) []byte {
+	ids := d.uint32Array()
+	return s.subscriptionResults(sess, ids, idDeleteSubsResponse, hdr.handle, func(sub *subscription) bool {
+		delete(sess.subs, sub.id)
+		return true
+	})
+}
+
+func (s *Server) createMonitoredItems(sess *session, d *decoder, hdr requestHeader) []byte {
+	subID := d.uint32()
+	which := d.uint32()
+	n := d.arrayLen()
+	if n > maxOperations {
+		return serviceFault(hdr.handle, StatusBadTooManyOperations)
+	}
+	type created struct {
+		status   uint32
+		item     *monitoredItem
+		interval float64
+	}
+	results := make([]created, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		rv := decodeReadValueID(d)
+		mode := d.uint32()
+		handle := d.uint32()
+		sampling := d.double()
+		d.extensionObject() // Filter – deadband nieobsługiwany
+		d.uint32()          // QueueSize
+		d.bool()            // DiscardOldest
+
+		status := s.space.readAttribute(rv.node, rv.attr).Status
+		if status == StatusBadNodeIdUnknown || status == StatusBadAttributeIdInvalid {
+			results = append(results, created{status: status})
+			continue
+		}
+		if sampling < float64(minSamplingInterval/time.Millisecond) {
+			sampling = float64(minSamplingInterval / time.Millisecond)
+		}
+		results = append(results, created{
+			item:     &monitoredItem{id: s.newID(), handle: handle, rv: rv, which: which, mode: mode},
+			interval: sampling,
+		})
+	}
+	if d.err != nil {
+		return serviceFault(hdr.handle, StatusBadDecodingError)
+	}
+
+	s.mu.Lock()
+	sub, ok := sess.subs[subID]
+	if ok {
+		for _, r := range results {
+			if r.item != nil {
+				sub.items[r.item.id] = r.item
+			}
+		}
+	}
+	s.mu.Unlock()
+	if !ok {
+		return serviceFault(hdr.handle, StatusBadSubscriptionIdInvalid)
+	}
+
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idCreateItemsResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(len(results)))
+	for _, r := range results {
+		if r.item == nil {
+			e.uint32(r.status)
+			e.uint32(0)
+			e.double(0)
+			e.uint32(0)
+		} else {
+			e.uint32(StatusGood)
+			e.uint32(r.item.id)
+			e.double(r.interval)
+			e.uint32(1)
+		}
+		e.extensionObject(ExtensionObject{})
+	}
+	e.int32(0)
+	return e.bytes()
+}
+
+func (s *Server) deleteMonitoredItems(sess *session, d *decoder, hdr requestHeader) []byte {
+	subID := d.uint32()
+	ids := d.uint32Array()
+	s.mu.Lock()
+	sub, ok := sess.subs[subID]
+	results := make([]uint32, len(ids))
+	for i, id := range ids {
+		if ok {
+			if _, found := sub.items[id]; found {
+				delete(sub.items, id)
+				continue
+			}
+		}
+		results[i] = StatusBadMonitoredItemIdInvalid
+	}
+	s.mu.Unlock()
+	if !ok {
+		return serviceFault(hdr.handle, StatusBadSubscriptionIdInvalid)
+	}
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idDeleteItemsResponse))
+	encodeResponseHeader(e, hdr.handle, StatusGood)
+	e.int32(int32(len(results)))
+	for _, r := range results {
+		e.uint32(r)
+	}
+	e.int32(0)
+	return e.bytes()
+}
+
+// publish kolejkuje PublishRequest; odpowiedź wysyła pętla subskrypcji (nil) albo od razu błąd.
+func (s *Server) publish(sess *session, sc *serverChannel, d *decoder, hdr requestHeader, requestID uint32) []byte {
+	n := d.arrayLen()
+	s.mu.Lock()
+	acks := make([]uint32, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		subID := d.uint32()
+		d.uint32() // SequenceNumber – brak kolejki retransmisji, potwierdzenie zawsze przyjęte
+		if _, ok := sess.subs[subID]; ok {
+			acks = append(acks, StatusGood)
+		} else {
+			acks = append(acks, StatusBadSubscriptionIdInvalid)
+		}
+	}
+	if d.err != nil {
+		s.mu.Unlock()
+		return serviceFault(hdr.handle, StatusBadDecodingError)
+	}
+	if len(sess.subs) == 0 {
+		s.mu.Unlock()
+		return serviceFault(hdr.handle, StatusBadNoSubscription)
+	}
+	var expires time.Time
+	if hdr.timeout > 0 {
+		expires = time.Now().Add(hdr.timeout)
+	}
+	sess.publishQ = append(sess.publishQ, pendingPublish{requestID: requestID, handle: hdr.handle, acks: acks, expires: expires})
+	var overflow *pendingPublish
+	if len(sess.publishQ) > maxPublishQueue {
+		p := sess.publishQ[0]
+		overflow = &p
+		sess.publishQ = sess.publishQ[1:]
+	}
+	s.mu.Unlock()
+	if overflow != nil {
+		_ = sc.sendSymmetric("MSG", overflow.requestID, serviceFault(overflow.handle, StatusBadTooManyPublishRequests))
+	}
+	return nil
+}
+
+// publishLoop – próbkowanie elementów, odpowiedzi na PublishRequest, keep-alive i wygaszanie sesji.
+func (s *Server) publishLoop() {
+	t := time.NewTicker(minSamplingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-t.C:
+			for _, o := range s.publishTick(now) {
+				if err := o.sc.sendSymmetric("MSG", o.requestID, o.body); err != nil {
+					_ = o.sc.conn.Close()
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) publishTick(now time.Time) []outgoing {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []outgoing
+	for token, sess := range s.sessions {
+		if now.Sub(sess.lastSeen) > sess.timeout {
+			delete(s.sessions, token)
+			s.logf("[OPCUA] session %q timed out", sess.name)
+			continue
+		}
+		// przeterminowane PublishRequest (klient już nie czeka)
+		q := sess.publishQ[:0]
+		for _, p := range sess.publishQ {
+			if p.expires.IsZero() || now.Before(p.expires) {
+				q = append(q, p)
+			} else {
+				out = append(out, outgoing{sess.channel, p.requestID, serviceFault(p.handle, StatusBadTimeout)})
+			}
+		}
+		sess.publishQ = q
+
+		for id, sub := range sess.subs {
+			if now.Before(sub.next) {
+				continue
+			}
+			sub.next = now.Add(sub.interval)
+			s.sample(sub)
+
+			ready := sub.enabled && len(sub.pending) > 0
+			sub.keepAlive++
+			if !ready && sub.keepAlive < sub.keepAliveCount {
+				continue
+			}
+			if len(sess.publishQ) == 0 {
+				sub.lifetime++
+				if sub.lifetime >= sub.lifetimeCount {
+					delete(sess.subs, id)
+					s.logf("[OPCUA] subscription %d of session %q expired", id, sess.name)
+				}
+				continue
+			}
+			p := sess.publishQ[0]
+			sess.publishQ = sess.publishQ[1:]
+			sub.keepAlive, sub.lifetime = 0, 0
+			out = append(out, outgoing{sess.channel, p.requestID, sub.publishResponse(p, ready)})
+		}
+	}
+	return out
+}
+
+// sample – nowe wartości elementów w trybie Reporting trafiają do kolejki (ostatnia wartość wygrywa).
+func (s *Server) sample(sub *subscription) {
+	for _, it := range sub.items {
+		if it.mode != 2 {
+			continue
+		}
+		dv := s.space.readAttribute(it.rv.node, it.rv.attr)
+		if it.sampled && dv.Status == it.last.Status && variantEqual(dv.Value, it.last.Value) {
+			continue
+		}
+		it.last, it.sampled = dv, true
+		n := itemNotification{itemID: it.id, handle: it.handle, value: applyTimestamps(dv, it.which, it.rv.attr)}
+		replaced := false
+		for i := range sub.pending {
+			if sub.pending[i].itemID == it.id {
+				sub.pending[i], replaced = n, true
+			}
+		}
+		if !replaced {
+			sub.pending = append(sub.pending, n)
+		}
+	}
+}
+
+// publishResponse – DataChangeNotification albo keep-alive (bez danych, numer następnej wiadomości).
+func (sub *subscription) publishResponse(p pendingPublish, data bool) []byte {
+	var batch []itemNotification
+	more := false
+	if data {
+		batch = sub.pending
+		if sub.maxNotifications > 0 && uint32(len(batch)) > sub.maxNotifications {
+			batch, more = batch[:sub.maxNotifications], true
+		}
+		sub.pending = append([]itemNotification(nil), sub.pending[len(batch):]...)
+		sub.seq++
+	}
+
+	e := &encoder{}
+	e.nodeID(NewNumericNodeID(0, idPublishResponse))
+	encodeResponseHeader(e, p.handle, StatusGood)
+	e.uint32(sub.id)
+	e.int32(0) // AvailableSequenceNumbers – brak retransmisji
+	e.bool(more)
+	if data {
+		e.uint32(sub.seq)
+	} else {
+		e.uint32(sub.seq + 1)
+	}
+	e.dateTime(time.Now().UTC())
+	if data {
+		body := &encoder{}
+		body.int32(int32(len(batch)))
+		for _, n := range batch {
+			body.uint32(n.handle)
+			body.dataValue(n.value)
+		}
+		body.int32(0)
+		e.int32(1)
+		e.extensionObject(ExtensionObject{TypeID: NewNumericNodeID(0, idDataChangeNotification), Body: body.bytes()})
+	} else {
+		e.int32(0)
+	}
+	e.uint32Array(p.acks)
+	e.int32(0)
+	return e.bytes()
+}
@@ -0,0 +1,160 @@
+// Package plc udostępnia bieżące wartości OEE sterownikom linii (Modbus TCP slave).
+package plc
+
+import (
+	"context"
+	"go_app/config"
+	"go_app/core"
+	"go_app/modbus"
+	"go_app/utils"
+)
+
+// Mapa rejestrów (input registers FC04, te same adresy lustrzanie w holding FC03).
+// float32 / uint32 zajmują dwa rejestry, starsze słowo pierwsze (big endian). Wskaźniki OEE silnik
+// liczy jako ułamek 0..1, PLC dostaje je w procentach (×100). Holding 0..41 są tylko do odczytu
+// (zapis FC06/FC16 = wyjątek 0x02), inaczej wartość PLC znikałaby przy odświeżeniu.
+//
+//	adr  typ      pole
+//	 0   float32  oee [%]
+//	 2   float32  dostepnosc [%]
+//	 4   float32  wydajnosc [%]
+//	 6   float32  jakosc [%]
+//	 8   uint32   ilosc_elementow
+//	10   float32  czas_pomiaru [s]
+//	12   float32  czas_pracy [s]
+//	14   float32  czas_postoju [s]
+//	16   float32  czas_przezbrojenia [s]
+//	18   float32  czas_przezbrojenia_temp [s]
+//	20   float32  cykl [szt./min]
+//	22   float32  predkosc_obrotnica [obr./min]
+//	24   float32  energia_W
+//	26   float32  powietrze_L
+//	28   float32  W_na_szt
+//	30   float32  M3_na_szt
+//	32   float32  dlugosc_calc [mm]
+//	34   float32  szerokosc_calc [mm]
+//	36   float32  wysokosc_calc [mm]
+//	38   uint16   status: bit0 status_maszyny, bit1 status_pracy, bit2 trwa pauza
+//	39   uint16   heartbeat (rośnie przy każdym odświeżeniu)
+//	40   uint16   przyczyna ostatniego potwierdzonego postoju
+//	41   uint16   licznik potwierdzeń postoju
+//
+// Zapis przez PLC:
+//
+//	holding 100  uint16  kod przyczyny postoju (przed ustawieniem coila 0)
+//	coil 0       1 = potwierdź przyczynę z holding 100 (kasuje się sam)
+//	coil 1       nieużywany (zapis ignorowany; reset liczników tylko na granicy zmiany)
+//	coil 2       1 = przezbrojenie: trwająca (albo najbliższa) pauza to przezbrojenie (kasuje się sam)
+const (
+	RegReasonCode   = 100
+	CoilAckDowntime = 0
+	CoilChangeover  = 2
+
+	regStatus    = 38
+	regHeartbeat = 39
+	regLastAck   = 40
+	regAckCount  = 41
+	regLiveCount = 42
+)
+
+type liveRegister struct {
+	def   modbus.RegisterDef
+	value func(core.OeeFileFlat) float64
+}
+
+var liveRegisters = []liveRegister{
+	{f32(0), func(o core.OeeFileFlat) float64 { return o.OEE.OEE * 100 }},
+	{f32(2), func(o core.OeeFileFlat) float64 { return o.OEE.Dostepnosc * 100 }},
+	{f32(4), func(o core.OeeFileFlat) float64 { return o.OEE.Wydajnosc * 100 }},
+	{f32(6), func(o core.OeeFileFlat) float64 { return o.OEE.Jakosc * 100 }},
+	{modbus.RegisterDef{Address: 8, Type: "uint32", Scale: 1, WordOrder: "big"}, func(o core.OeeFileFlat) float64 { return float64(o.OEE.IloscElementow) }},
+	{f32(10), func(o core.OeeFileFlat) float64 { return o.OEE.CzasPomiaru }},
+	{f32(12), func(o core.OeeFileFlat) float64 { return o.OEE.CzasPracy }},
+	{f32(14), func(o core.OeeFileFlat) float64 { return o.OEE.CzasPostoju }},
+	{f32(16), func(o core.OeeFileFlat) float64 { return o.OEE.CzasPrzezbrojenia }},
+	{f32(18), func(o core.OeeFileFlat) float64 { return o.OEE.CzasPrzezbrojeniaTemp }},
+	{f32(20), func(o core.OeeFileFlat) float64 { return o.Product.Cykl }},
+	{f32(22), func(o core.OeeFileFlat) float64 { return o.OEE.PredkoscObrotnica }},
+	{f32(24), func(o core.OeeFileFlat) float64 { return o.OEE.EnergyW }},
+	{f32(26), func(o core.OeeFileFlat) float64 { return o.OEE.PowietrzeL }},
+	{f32(28), func(o core.OeeFileFlat) float64 { return o.OEE.WNaSzt }},
+	{f32(30), func(o core.OeeFileFlat) float64 { return o.OEE.M3naSzt }},
+	{f32(32), func(o core.OeeFileFlat) float64 { return o.Product.DlugoscCalc }},
+	{f32(34), func(o core.OeeFileFlat) float64 { return o.Product.SzerokoscCalc }},
+	{f32(36), func(o core.OeeFileFlat) float64 { return o.Product.WysokoscCalc }},
+}
+
+func f32(addr uint16) modbus.RegisterDef {
+	return modbus.RegisterDef{Address: addr, Type: "float32", Scale: 1, WordOrder: "big"}
+}
+
+// StartModbusServer uruchamia serwer, jeśli ustawiono MODBUS_SERVER_ADDR.
+func StartModbusServer() {
+	if config.ModbusServerAddr == "" {
+		return
+	}
+	bank := modbus.NewBank(RegReasonCode+1, regLiveCount, 3)
+	bank.ReadOnlyHolding = regLiveCount
+	bank.OnCoilWrite = func(address uint16, value bool) {
+		if !value {
+			return
+		}
+		switch address {
+		case CoilAckDowntime:
+			reason := 0
+			if regs := bank.Holding(RegReasonCode, 1); len(regs) == 1 {
+				reason = int(regs[0])
+			}
+			core.AcknowledgeDowntime(reason, "modbus")
+		case CoilChangeover:
+			core.SignalChangeover("modbus")
+			core.RecordInput(core.InputTopicChangeover, core.ChangeoverCommand{Source: "modbus"})
+		}
+		bank.SetCoil(address, false)
+	}
+
+	var heartbeat uint16
+	utils.SuperviseLoop("PLC Modbus refresh", config.ModbusServerRefresh, func() {
+		heartbeat++
+		regs := buildRegisters(core.BuildOeeFlat(), heartbeat)
+		bank.SetInput(0, regs)
+		bank.SetHolding(0, regs)
+	})
+
+	srv := modbus.NewServer(bank)
+	utils.Supervise("PLC Modbus server", func(ctx context.Context) {
+		utils.LogMessage("[PLC] Modbus TCP server listening on " + config.ModbusServerAddr)
+		stop := context.AfterFunc(ctx, func() { _ = srv.Close() })
+		defer stop()
+		if err := srv.ListenAndServe(config.ModbusServerAddr); err != nil && ctx.Err() == nil {
+			utils.LogMessage("[PLC] Modbus TCP server error: " + err.Error())
+		}
+	})
+}
+
+// buildRegisters – obraz rejestrów 0..41 dla bieżącego stanu OEE.
+func buildRegisters(o core.OeeFileFlat, heartbeat uint16) []uint16 {
+	regs := make([]uint16, regLiveCount)
+	for _, r := range liveRegisters {
+		copy(regs[r.def.Address:], r.def.Encode(r.value(o)))
+	}
+
+	var status uint16
+	if o.OEE.StatusMaszyny {
+		status |= 1
+	}
+	if o.OEE.StatusPracy {
+		status |= 1 << 1
+	}
+	if o.Internal.PauseStartTime != nil {
+		status |= 1 << 2
+	}
+	regs[regStatus] = status
+	regs[regHeartbeat] = heartbeat
+
+	if ack, count := core.LastDowntimeAck(); ack != nil {
+		regs[regLastAck] = uint16(ack.Reason)
+		regs[regAckCount] = uint16(count)
+	}
+	return regs
+}
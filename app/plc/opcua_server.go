@@ -0,0 +1,207 @@
+package plc
+
+import (
+	"context"
+	"fmt"
+	"go_app/config"
+	"go_app/core"
+	"go_app/opcua"
+	"go_app/utils"
+	"time"
+)
+
+// Model informacyjny OPC UA (przestrzeń nazw urn:oee-monitoring:<MACHINE_NAME>, ns=1):
+//
+//	Objects/Machines/<MACHINE_NAME>/
+//	  KPI/        oee, dostepnosc, wydajnosc, jakosc, *_temp, czasy [s], ilosc_elementow, energia, powietrze
+//	  Status/     status_maszyny, status_pracy, pauza, pause_start, last_downtime_reason
+//	  Product/    dlugosc_calc, szerokosc_calc, wysokosc_calc, cykl
+//	  Cycle/      bieżący cykl, last_cycle, predkosc_obrotnica, element_last_time
+//	  LastShift/  podsumowanie ostatniej zamkniętej zmiany
+//
+// Identyfikatory węzłów: ns=1;s=<MACHINE_NAME>.<Folder>.<nazwa>, np. ns=1;s=Line1.KPI.oee.
+// Wszystkie zmienne są tylko do odczytu.
+
+type opcUaVariable struct {
+	folder string
+	name   string
+	typ    byte
+	desc   string
+	value  func(o core.OeeFileFlat) interface{}
+}
+
+type opcUaShiftVariable struct {
+	name  string
+	typ   byte
+	desc  string
+	value func(s core.Summary) interface{}
+}
+
+var opcUaVariables = []opcUaVariable{
+	{"KPI", "oee", opcua.TypeDouble, "OEE [0..1]", func(o core.OeeFileFlat) interface{} { return o.OEE.OEE }},
+	{"KPI", "dostepnosc", opcua.TypeDouble, "Dostępność [0..1]", func(o core.OeeFileFlat) interface{} { return o.OEE.Dostepnosc }},
+	{"KPI", "wydajnosc", opcua.TypeDouble, "Wydajność [0..1]", func(o core.OeeFileFlat) interface{} { return o.OEE.Wydajnosc }},
+	{"KPI", "jakosc", opcua.TypeDouble, "Jakość [0..1]", func(o core.OeeFileFlat) interface{} { return o.OEE.Jakosc }},
+	{"KPI", "oee_temp", opcua.TypeDouble, "OEE bieżącego cyklu [0..1]", func(o core.OeeFileFlat) interface{} { return o.Internal.OeeTemp }},
+	{"KPI", "dostepnosc_temp", opcua.TypeDouble, "Dostępność bieżącego cyklu [0..1]", func(o core.OeeFileFlat) interface{} { return o.Internal.DostepnoscTemp }},
+	{"KPI", "wydajnosc_temp", opcua.TypeDouble, "Wydajność bieżącego cyklu [0..1]", func(o core.OeeFileFlat) interface{} { return o.Internal.WydajnoscTemp }},
+	{"KPI", "czas_pomiaru", opcua.TypeDouble, "Czas pomiaru [s]", func(o core.OeeFileFlat) interface{} { return o.OEE.CzasPomiaru }},
+	{"KPI", "czas_pracy", opcua.TypeDouble, "Czas pracy [s]", func(o core.OeeFileFlat) interface{} { return o.OEE.CzasPracy }},
+	{"KPI", "czas_postoju", opcua.TypeDouble, "Czas postoju [s]", func(o core.OeeFileFlat) interface{} { return o.OEE.CzasPostoju }},
+	{"KPI", "czas_przezbrojenia", opcua.TypeDouble, "Czas przezbrojenia [s]", func(o core.OeeFileFlat) interface{} { return o.OEE.CzasPrzezbrojenia }},
+	{"KPI", "czas_przezbrojenia_temp", opcua.TypeDouble, "Trwające przezbrojenie [s]", func(o core.OeeFileFlat) interface{} { return o.OEE.CzasPrzezbrojeniaTemp }},
+	{"KPI", "ilosc_elementow", opcua.TypeInt32, "Liczba elementów", func(o core.OeeFileFlat) interface{} { return o.OEE.IloscElementow }},
+	{"KPI", "energia_W", opcua.TypeDouble, "Energia od początku pomiaru", func(o core.OeeFileFlat) interface{} { return o.OEE.EnergyW }},
+	{"KPI", "powietrze_L", opcua.TypeDouble, "Powietrze od początku pomiaru", func(o core.OeeFileFlat) interface{} { return o.OEE.PowietrzeL }},
+	{"KPI", "W_na_szt", opcua.TypeDouble, "Energia na sztukę", func(o core.OeeFileFlat) interface{} { return o.OEE.WNaSzt }},
+	{"KPI", "M3_na_szt", opcua.TypeDouble, "Powietrze na sztukę", func(o core.OeeFileFlat) interface{} { return o.OEE.M3naSzt }},
+
+	{"Status", "status_maszyny", opcua.TypeBoolean, "Maszyna załączona", func(o core.OeeFileFlat) interface{} { return o.OEE.StatusMaszyny }},
+	{"Status", "status_pracy", opcua.TypeBoolean, "Maszyna pracuje", func(o core.OeeFileFlat) interface{} { return o.OEE.StatusPracy }},
+	{"Status", "pauza", opcua.TypeBoolean, "Trwa pauza", func(o core.OeeFileFlat) interface{} { return o.Internal.PauseStartTime != nil }},
+	{"Status", "pause_start", opcua.TypeDateTime, "Początek trwającej pauzy", func(o core.OeeFileFlat) interface{} { return parseOpcUaTime(o.Internal.PauseStartTime) }},
+	{"Status", "last_downtime_reason", opcua.TypeInt32, "Przyczyna ostatniego potwierdzonego postoju", func(core.OeeFileFlat) interface{} {
+		if ack, _ := core.LastDowntimeAck(); ack != nil {
+			return ack.Reason
+		}
+		return 0
+	}},
+
+	{"Product", "dlugosc_calc", opcua.TypeDouble, "Długość elementu [mm]", func(o core.OeeFileFlat) interface{} { return o.Product.DlugoscCalc }},
+	{"Product", "szerokosc_calc", opcua.TypeDouble, "Szerokość elementu [mm]", func(o core.OeeFileFlat) interface{} { return o.Product.SzerokoscCalc }},
+	{"Product", "wysokosc_calc", opcua.TypeDouble, "Wysokość elementu [mm]", func(o core.OeeFileFlat) interface{} { return o.Product.WysokoscCalc }},
+	{"Product", "cykl", opcua.TypeDouble, "Cykl [szt./min]", func(o core.OeeFileFlat) interface{} { return o.Product.Cykl }},
+
+	{"Cycle", "current_cycle_value", opcua.TypeDouble, "Cykl bieżącego okresu [szt./min]", func(o core.OeeFileFlat) interface{} { return o.Internal.CurrentCycleValue }},
+	{"Cycle", "current_cycle_start", opcua.TypeDateTime, "Początek bieżącego okresu cyklu", func(o core.OeeFileFlat) interface{} { return parseOpcUaTime(&o.Internal.CurrentCycleStart) }},
+	{"Cycle", "current_cycle_element_cnt", opcua.TypeInt32, "Elementy w bieżącym okresie cyklu", func(o core.OeeFileFlat) interface{} { return o.Internal.CurrentCycleElementCnt }},
+	{"Cycle", "current_cycle_work_seconds", opcua.TypeDouble, "Czas pracy w bieżącym okresie cyklu [s]", func(o core.OeeFileFlat) interface{} { return o.Internal.CurrentCycleWorkSeconds }},
+	{"Cycle", "last_cycle", opcua.TypeDouble, "Poprzedni cykl [szt./min]", func(o core.OeeFileFlat) interface{} { return o.Internal.LastCycle }},
+	{"Cycle", "predkosc_obrotnica", opcua.TypeDouble, "Prędkość obrotnicy [obr./min]", func(o core.OeeFileFlat) interface{} { return o.OEE.PredkoscObrotnica }},
+	{"Cycle", "element_last_time", opcua.TypeDateTime, "Czas ostatniego elementu", func(o core.OeeFileFlat) interface{} { return parseOpcUaTime(&o.Internal.ElementLastTime) }},
+}
+
+var opcUaShiftVariables = []opcUaShiftVariable{
+	{"start_zmiany", opcua.TypeDateTime, "Początek zmiany", func(s core.Summary) interface{} { return parseOpcUaTime(&s.StartZmiany) }},
+	{"koniec_zmiany", opcua.TypeDateTime, "Koniec zmiany", func(s core.Summary) interface{} { return parseOpcUaTime(&s.KoniecZmiany) }},
+	{"oee", opcua.TypeDouble, "OEE zmiany [0..1]", func(s core.Summary) interface{} { return s.OEE.OEE }},
+	{"dostepnosc", opcua.TypeDouble, "Dostępność zmiany [0..1]", func(s core.Summary) interface{} { return s.OEE.Dostepnosc }},
+	{"wydajnosc", opcua.TypeDouble, "Wydajność zmiany [0..1]", func(s core.Summary) interface{} { return s.OEE.Wydajnosc }},
+	{"jakosc", opcua.TypeDouble, "Jakość zmiany [0..1]", func(s core.Summary) interface{} { return s.OEE.Jakosc }},
+	{"czas_pracy", opcua.TypeDouble, "Czas pracy [s]", func(s core.Summary) interface{} { return s.OEE.CzasPracy }},
+	{"czas_postoju", opcua.TypeDouble, "Czas postoju [s]", func(s core.Summary) interface{} { return s.OEE.CzasPostoju }},
+	{"czas_przezbrojenia", opcua.TypeDouble, "Czas przezbrojenia [s]", func(s core.Summary) interface{} { return s.OEE.CzasPrzezbrojenia }},
+	{"ilosc_elementow", opcua.TypeInt32, "Liczba elementów", func(s core.Summary) interface{} { return s.OEE.IloscElementow }},
+	{"energia_W", opcua.TypeDouble, "Energia zmiany", func(s core.Summary) interface{} { return s.Energy.SumWh }},
+	{"powietrze_M3", opcua.TypeDouble, "Powietrze zmiany [m3]", func(s core.Summary) interface{} { return s.Totaliser.SumM3 }},
+	{"W_na_szt", opcua.TypeDouble, "Energia na sztukę", func(s core.Summary) interface{} { return s.OEE.W_NaSzt }},
+	{"M3_na_szt", opcua.TypeDouble, "Powietrze na sztukę", func(s core.Summary) interface{} { return s.OEE.M3_NaSzt }},
+}
+
+var opcUaFolders = []string{"KPI", "Status", "Product", "Cycle", "LastShift"}
+
+// StartOpcUaServer uruchamia serwer OPC UA, jeśli ustawiono OPCUA_ENDPOINT.
+func StartOpcUaServer() {
+	if config.OpcUaEndpoint == "" {
+		return
+	}
+
+	cfg := opcua.ServerConfig{
+		EndpointURL:     config.OpcUaEndpoint,
+		ListenAddr:      config.OpcUaListenAddr,
+		ApplicationName: "OEE Monitoring " + config.MachineName,
+		AllowNone:       config.OpcUaAllowNone,
+		Logf: func(format string, args ...interface{}) {
+			utils.LogMessage(fmt.Sprintf(format, args...))
+		},
+	}
+	if config.OpcUaCertFile != "" || config.OpcUaKeyFile != "" {
+		cert, key, err := opcua.LoadCertificate(config.OpcUaCertFile, config.OpcUaKeyFile)
+		if err != nil {
+			utils.LogMessage("[OPCUA] Certificate load error, server not started: " + err.Error())
+			return
+		}
+		cfg.Certificate, cfg.PrivateKey = cert, key
+	} else {
+		utils.LogMessage("[OPCUA] No certificate configured – only SecurityPolicy None is offered (dev only)")
+	}
+	trust, err := opcua.LoadTrustList(config.OpcUaTrustedDir)
+	if err != nil {
+		utils.LogMessage("[OPCUA] Trust list load error, server not started: " + err.Error())
+		return
+	}
+	cfg.TrustList, cfg.TrustAll = trust, config.OpcUaTrustAll
+	if cfg.PrivateKey != nil && trust.Empty() {
+		if cfg.TrustAll {
+			utils.LogMessage("[OPCUA] OPCUA_TRUST_ALL=1 – any valid client certificate is accepted (dev only)")
+		} else {
+			utils.LogMessage("[OPCUA] Trust list is empty – secure channels are rejected until client certificates are placed in OPCUA_TRUSTED_DIR")
+		}
+	}
+
+	space, ids, shiftIDs := buildOpcUaModel()
+	srv := opcua.NewServer(cfg, space)
+
+	utils.SuperviseLoop("PLC OPC UA refresh", config.OpcUaRefresh, func() {
+		now := time.Now()
+		o := core.BuildOeeFlat()
+		for i, v := range opcUaVariables {
+			space.SetValue(ids[i], v.value(o), now)
+		}
+		s, ok := core.LastShiftSummary()
+		status := opcua.StatusGood
+		if !ok {
+			status = opcua.StatusBadWaitingForInitialData
+		}
+		for i, v := range opcUaShiftVariables {
+			space.SetValueStatus(shiftIDs[i], v.value(s), status, now)
+		}
+	})
+
+	utils.Supervise("PLC OPC UA server", func(ctx context.Context) {
+		utils.LogMessage("[OPCUA] OPC UA server listening on " + config.OpcUaEndpoint)
+		stop := context.AfterFunc(ctx, func() { _ = srv.Close() })
+		defer stop()
+		if err := srv.ListenAndServe(); err != nil && ctx.Err() == nil {
+			utils.LogMessage("[OPCUA] OPC UA server error: " + err.Error())
+		}
+	})
+}
+
+// buildOpcUaModel tworzy drzewo węzłów maszyny; zwraca identyfikatory zmiennych
+// w kolejności opcUaVariables i opcUaShiftVariables.
+func buildOpcUaModel() (*opcua.AddressSpace, []opcua.NodeID, []opcua.NodeID) {
+	space := opcua.NewAddressSpace("urn:oee-monitoring:" + config.MachineName)
+	ns := space.NamespaceIndex()
+	machines := space.AddFolder(opcua.ObjectsFolder, opcua.NewStringNodeID(ns, "Machines"), "Machines")
+	machine := space.AddObject(machines, opcua.NewStringNodeID(ns, config.MachineName), config.MachineName, "Linia produkcyjna monitorowana przez go_app")
+
+	folders := map[string]opcua.NodeID{}
+	for _, f := range opcUaFolders {
+		folders[f] = space.AddFolder(machine, opcua.NewStringNodeID(ns, config.MachineName+"."+f), f)
+	}
+
+	ids := make([]opcua.NodeID, len(opcUaVariables))
+	for i, v := range opcUaVariables {
+		id := opcua.NewStringNodeID(ns, config.MachineName+"."+v.folder+"."+v.name)
+		ids[i] = space.AddVariable(folders[v.folder], id, v.name, v.typ, nil, v.desc)
+	}
+	shiftIDs := make([]opcua.NodeID, len(opcUaShiftVariables))
+	for i, v := range opcUaShiftVariables {
+		id := opcua.NewStringNodeID(ns, config.MachineName+".LastShift."+v.name)
+		shiftIDs[i] = space.AddVariable(folders["LastShift"], id, v.name, v.typ, nil, v.desc)
+	}
+	return space, ids, shiftIDs
+}
+
+// parseOpcUaTime – znacznik RFC3339 z pliku OEE jako DateTime (nil/pusty = wartość zerowa).
+func parseOpcUaTime(s *string) time.Time {
+	if s == nil || *s == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, *s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
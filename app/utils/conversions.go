@@ -7,25 +7,17 @@ import (
 	"math"
 	"fmt"
     "runtime"
-    "sync"
     "time"
 	"reflect"
 )
 
-var warnGate sync.Map
+var convLog = NewLogger("CONV")
 
 func warnToFloat(msg string) {
 	// throttling per-caller: 1 wpis / 30s
 	_, file, line, _ := runtime.Caller(2)
-	key := fmt.Sprintf("%s:%d", file, line)
-	now := time.Now()
-	if lastRaw, ok := warnGate.Load(key); ok {
-		if now.Sub(lastRaw.(time.Time)) < 30*time.Second {
-			return
-		}
-	}
-	warnGate.Store(key, now)
-	LogMessage(fmt.Sprintf("[WARNING] ToFloat @ %s:%d: %s", file, line, msg))
+	caller := fmt.Sprintf("%s:%d", file, line)
+	convLog.Limit(caller, 30*time.Second).Warn("ToFloat: "+msg, "caller", caller)
 }
 
 // --- Konwersja z rozróżnieniem ok/fail ---
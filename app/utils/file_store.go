@@ -10,19 +10,20 @@ import (
 	"path/filepath"
 	"runtime/debug"
 	"sync"
-	"time"
 )
 
 // --- Goroutine helpers ---
 
 // Go uruchamia fn w gorutinie z automatycznym recoverem + stacktrace.
-// Go uruchamia fn w gorutinie z automatycznym recoverem + stacktrace.
+// Przeznaczone dla zadań jednorazowych (Shutdown czeka na ich zakończenie); pętle – Supervise.
 func Go(context string, fn func()) {
 	if fn == nil {
 		LogMessage(fmt.Sprintf("[WARN] utils.Go called with nil fn (%s) — skipping", context))
 		return
 	}
+	tasks.Add(1)
 	go func() {
+		defer tasks.Done()
 		defer func() {
 			if r := recover(); r != nil {
 				LogMessage(fmt.Sprintf("[ERROR] PANIC in goroutine %s: %v\n%s", context, r, string(debug.Stack())))
@@ -69,23 +70,6 @@ func getLockForFile(filename string) *sync.Mutex {
 	return fileLocks[filename]
 }
 
-// --- Logging ---
-
-func LogMessage(message string) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	fullMessage := fmt.Sprintf("[%s] %s\n", timestamp, message)
-
-	_ = os.MkdirAll(filepath.Dir(config.SystemLogPath), os.ModePerm)
-	f, err := os.OpenFile(config.SystemLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Println("[ERROR] Cannot open log file:", err)
-		return
-	}
-	defer f.Close()
-
-	_, _ = f.WriteString(fullMessage)
-}
-
 // --- Save JSON to file (atomowo, z opcjonalną kopią .bak i fsync) ---
 
 func SaveToJSON(data interface{}, filename string) {
@@ -0,0 +1,449 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"go_app/config"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Logging ---
+//
+// Log systemowy: poziomy, pola klucz/wartość (component, device, topic, error, ...),
+// format text albo json (LOG_FORMAT), rotacja pliku po rozmiarze/wieku z kompresją gzip
+// i opcjonalne kopiowanie na stdout (LOG_STDOUT=1, `docker logs`).
+//
+//	var log = utils.NewLogger("MQTT")
+//	log.Warn("connection lost", "broker", addr, "error", err)
+//	log.With("device", "device_2").Limit("timeout", time.Minute).Error("read failed", "error", err)
+
+// Level – poziom wpisu w logu.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel – "debug", "info", "warn"/"warning", "error" (wielkość liter bez znaczenia).
+func ParseLevel(s string) (Level, bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return LevelDebug, true
+	case "INFO", "":
+		return LevelInfo, true
+	case "WARN", "WARNING":
+		return LevelWarn, true
+	case "ERROR":
+		return LevelError, true
+	}
+	return LevelInfo, false
+}
+
+// Logger – logger komponentu z polami dołączanymi do każdego wpisu. Wartość zerowa loguje bez komponentu.
+type Logger struct {
+	component  string
+	fields     []interface{}
+	limitKey   string
+	limitEvery time.Duration
+}
+
+// NewLogger – logger komponentu (tag, np. "MQTT", "DB", "OPCUA").
+func NewLogger(component string) Logger {
+	return Logger{component: component}
+}
+
+// With – kopia loggera z dodatkowymi polami klucz/wartość.
+func (l Logger) With(kv ...interface{}) Logger {
+	l.fields = append(append([]interface{}{}, l.fields...), kv...)
+	return l
+}
+
+// Limit – kopia loggera zapisująca wpisy o kluczu key najwyżej raz na every; liczba
+// pominiętych wpisów trafia do pola "suppressed" następnego zapisanego.
+func (l Logger) Limit(key string, every time.Duration) Logger {
+	l.limitKey, l.limitEvery = key, every
+	return l
+}
+
+func (l Logger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+func (l Logger) Info(msg string, kv ...interface{})  { l.log(LevelInfo, msg, kv) }
+func (l Logger) Warn(msg string, kv ...interface{})  { l.log(LevelWarn, msg, kv) }
+func (l Logger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+// Log – wpis o zadanym poziomie.
+func (l Logger) Log(level Level, msg string, kv ...interface{}) { l.log(level, msg, kv) }
+
+func (l Logger) log(level Level, msg string, kv []interface{}) {
+	sink := logOutput()
+	if level < sink.level {
+		return
+	}
+	fields := l.fields
+	if len(kv) > 0 {
+		fields = append(append([]interface{}{}, l.fields...), kv...)
+	}
+	if l.limitEvery > 0 {
+		suppressed, ok := rateGate(l.component+"|"+l.limitKey, l.limitEvery)
+		if !ok {
+			return
+		}
+		if suppressed > 0 {
+			fields = append(append([]interface{}{}, fields...), "suppressed", suppressed)
+		}
+	}
+	sink.write(logEntry{time: time.Now(), level: level, component: l.component, msg: msg, fields: fields})
+}
+
+// LogMessage – zapis w starym stylu "[TAG] treść": wiodące [ERROR]/[WARNING]/[WARN]/[INFO]/[DEBUG]
+// wyznaczają poziom ([PANIC]/[FATAL] – ERROR, tag zostaje w treści), pierwszy inny tag to komponent.
+func LogMessage(message string) {
+	level, component, msg := parseTaggedMessage(message)
+	NewLogger(component).log(level, msg, nil)
+}
+
+func parseTaggedMessage(message string) (Level, string, string) {
+	level, component, msg := LevelInfo, "", message
+	levelSet := false
+	for i := 0; i < 2 && strings.HasPrefix(msg, "["); i++ {
+		end := strings.IndexByte(msg, ']')
+		if end < 0 {
+			break
+		}
+		tag := msg[1:end]
+		rest := strings.TrimLeft(msg[end+1:], " ")
+		switch tag {
+		case "ERROR", "WARNING", "WARN", "INFO", "DEBUG":
+			if levelSet {
+				return level, component, msg
+			}
+			level, _ = ParseLevel(tag)
+			levelSet = true
+		case "PANIC", "FATAL":
+			level = LevelError
+			return level, component, msg
+		default:
+			if component != "" {
+				return level, component, msg
+			}
+			component = tag
+		}
+		msg = rest
+	}
+	return level, component, msg
+}
+
+// --- throttling ---
+
+type rateState struct {
+	last       time.Time
+	suppressed int
+}
+
+var (
+	rateMu    sync.Mutex
+	rateGates = map[string]*rateState{}
+)
+
+// rateGate – czy wpis o kluczu key może zostać zapisany (i ile wcześniej pominięto).
+func rateGate(key string, every time.Duration) (int, bool) {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	now := time.Now()
+	st := rateGates[key]
+	if st == nil {
+		rateGates[key] = &rateState{last: now}
+		return 0, true
+	}
+	if now.Sub(st.last) < every {
+		st.suppressed++
+		return 0, false
+	}
+	n := st.suppressed
+	st.last, st.suppressed = now, 0
+	return n, true
+}
+
+// --- format wpisu ---
+
+type logEntry struct {
+	time      time.Time
+	level     Level
+	component string
+	msg       string
+	fields    []interface{}
+}
+
+// text: [2006-01-02 15:04:05] WARN [MQTT] connection lost broker=10.10.22.10:1883 error="EOF"
+func (e logEntry) appendText(b *bytes.Buffer) {
+	fmt.Fprintf(b, "[%s] %s ", e.time.Format("2006-01-02 15:04:05"), e.level)
+	if e.component != "" {
+		fmt.Fprintf(b, "[%s] ", e.component)
+	}
+	b.WriteString(e.msg)
+	e.eachField(func(k string, v interface{}) {
+		s := fmt.Sprint(v)
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = fmt.Sprintf("%q", s)
+		}
+		fmt.Fprintf(b, " %s=%s", k, s)
+	})
+	b.WriteByte('\n')
+}
+
+// json: {"time":"...","level":"WARN","component":"MQTT","msg":"connection lost","broker":"...","error":"EOF"}
+func (e logEntry) appendJSON(b *bytes.Buffer) {
+	b.WriteString(`{"time":`)
+	writeJSONValue(b, e.time.Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(b, e.level.String())
+	if e.component != "" {
+		b.WriteString(`,"component":`)
+		writeJSONValue(b, e.component)
+	}
+	b.WriteString(`,"msg":`)
+	writeJSONValue(b, e.msg)
+	e.eachField(func(k string, v interface{}) {
+		b.WriteByte(',')
+		writeJSONValue(b, k)
+		b.WriteByte(':')
+		writeJSONValue(b, v)
+	})
+	b.WriteString("}\n")
+}
+
+// eachField – pary klucz/wartość; nieparzysty ostatni element trafia pod klucz "!extra", błędy jako tekst.
+func (e logEntry) eachField(fn func(string, interface{})) {
+	for i := 0; i < len(e.fields); i += 2 {
+		key, ok := e.fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(e.fields[i])
+		}
+		if i+1 >= len(e.fields) {
+			fn("!extra", key)
+			return
+		}
+		v := e.fields[i+1]
+		switch t := v.(type) {
+		case error:
+			v = t.Error()
+		case fmt.Stringer:
+			v = t.String()
+		}
+		fn(key, v)
+	}
+}
+
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		raw, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(raw)
+}
+
+// --- wyjście logu ---
+
+type logSink struct {
+	mu     sync.Mutex
+	level  Level
+	json   bool
+	stdout bool
+	file   *rotatingFile
+	buf    bytes.Buffer
+}
+
+var (
+	sinkOnce sync.Once
+	sink     *logSink
+)
+
+func logOutput() *logSink {
+	sinkOnce.Do(func() {
+		level, ok := ParseLevel(config.LogLevel)
+		sink = &logSink{
+			level:  level,
+			json:   strings.EqualFold(config.LogFormat, "json"),
+			stdout: config.LogStdout,
+			file: &rotatingFile{
+				path:     config.SystemLogPath,
+				maxBytes: config.LogMaxBytes,
+				maxAge:   config.LogMaxAge,
+				keep:     config.LogKeep,
+				compress: config.LogCompress,
+			},
+		}
+		if !ok {
+			fmt.Printf("[WARNING] Unknown LOG_LEVEL %q – using INFO\n", config.LogLevel)
+		}
+	})
+	return sink
+}
+
+func (s *logSink) write(e logEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.Reset()
+	if s.json {
+		e.appendJSON(&s.buf)
+	} else {
+		e.appendText(&s.buf)
+	}
+	if err := s.file.write(s.buf.Bytes()); err != nil {
+		fmt.Println("[ERROR] Cannot write log file:", err)
+	}
+	if s.stdout {
+		_, _ = os.Stdout.Write(s.buf.Bytes())
+	}
+}
+
+// CloseLog zamyka plik logu (przy zamykaniu programu; kolejny wpis otworzy go ponownie).
+func CloseLog() {
+	s := logOutput()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file.close()
+}
+
+// --- rotacja pliku ---
+
+// rotatingFile – plik logu otwarty na stałe; po przekroczeniu maxBytes albo maxAge
+// przenoszony do <nazwa>-YYYYMMDD-HHMMSS.mmm<ext> (gzip w tle), zostaje keep najnowszych.
+type rotatingFile struct {
+	path     string
+	maxBytes int64
+	maxAge   time.Duration
+	keep     int
+	compress bool
+
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func (r *rotatingFile) write(p []byte) error {
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if (r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes) ||
+		(r.maxAge > 0 && time.Since(r.opened) >= r.maxAge) {
+		r.rotate()
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	r.f, r.size, r.opened = f, 0, time.Now()
+	if st, err := f.Stat(); err == nil {
+		r.size = st.Size()
+		// istniejący plik z poprzedniego uruchomienia – wiek liczony od ostatniej modyfikacji
+		if r.size > 0 && st.ModTime().Before(r.opened) {
+			r.opened = st.ModTime()
+		}
+	}
+	return nil
+}
+
+func (r *rotatingFile) close() {
+	if r.f != nil {
+		_ = r.f.Close()
+		r.f = nil
+	}
+}
+
+func (r *rotatingFile) rotate() {
+	r.close()
+	ext := filepath.Ext(r.path)
+	rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, ext), time.Now().Format("20060102-150405.000"), ext)
+	if err := os.Rename(r.path, rotated); err != nil {
+		fmt.Println("[ERROR] Cannot rotate log file:", err)
+		return
+	}
+	go func() {
+		if r.compress {
+			if err := gzipFile(rotated); err != nil {
+				fmt.Println("[ERROR] Cannot compress rotated log:", err)
+			}
+		}
+		pruneRotatedLogs(r.path, r.keep)
+	}()
+}
+
+// gzipFile zastępuje plik path plikiem path.gz.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// pruneRotatedLogs usuwa najstarsze zrotowane pliki logu path ponad keep (keep <= 0 = bez limitu).
+func pruneRotatedLogs(path string, keep int) {
+	if keep <= 0 {
+		return
+	}
+	ext := filepath.Ext(path)
+	files, err := filepath.Glob(strings.TrimSuffix(path, ext) + "-*" + ext + "*")
+	if err != nil {
+		return
+	}
+	// nazwa zawiera czas rotacji, więc kolejność alfabetyczna = chronologiczna (.log przed .log.gz)
+	sort.Strings(files)
+	if len(files) <= keep {
+		return
+	}
+	for _, f := range files[:len(files)-keep] {
+		_ = os.Remove(f)
+	}
+}
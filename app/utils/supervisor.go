@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// --- Supervisor ---
+//
+// Długotrwałe pętle programu działają jako workery supervisora: panika, która wyjdzie poza
+// wewnętrzny Catch, restartuje workera z rosnącą przerwą (1s, 2s, 4s, ... do 1 min), stan
+// każdego workera jest dostępny przez Workers(), a Shutdown anuluje wspólny kontekst i czeka,
+// aż pętle skończą bieżącą iterację, a zadania jednorazowe z Go (np. zapisy do DB) się zakończą.
+
+// Stany workera.
+const (
+	WorkerRunning    = "running"
+	WorkerRestarting = "restarting" // po panice, czeka na restart
+	WorkerStopped    = "stopped"    // zatrzymany przez Shutdown
+	WorkerFinished   = "finished"   // funkcja zakończyła się sama (np. koniec replay)
+)
+
+const (
+	restartBackoffMin  = time.Second
+	restartBackoffMax  = time.Minute
+	restartStableAfter = time.Minute // worker działał dłużej przed paniką – przerwa liczona od nowa
+)
+
+// WorkerStatus – stan workera supervisora.
+type WorkerStatus struct {
+	Name        string     `json:"name"`
+	State       string     `json:"state"`
+	Started     time.Time  `json:"started"`   // ostatni (re)start
+	LastBeat    time.Time  `json:"last_beat"` // ostatnia zakończona iteracja / Heartbeat
+	Restarts    int        `json:"restarts"`
+	LastPanic   string     `json:"last_panic,omitempty"`
+	LastPanicAt *time.Time `json:"last_panic_at,omitempty"`
+}
+
+type worker struct {
+	mu     sync.Mutex
+	status WorkerStatus
+}
+
+func (w *worker) update(fn func(*WorkerStatus)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fn(&w.status)
+}
+
+type workerKey struct{}
+
+var (
+	rootCtx, rootCancel = context.WithCancel(context.Background())
+
+	workersMu sync.Mutex
+	workers   []*worker
+	loops     sync.WaitGroup // workery Supervise
+	tasks     sync.WaitGroup // zadania jednorazowe z Go
+
+	supervisorLog = NewLogger("SUPERVISOR")
+)
+
+// Context – kontekst programu, anulowany przez Shutdown.
+func Context() context.Context { return rootCtx }
+
+// Supervise uruchamia fn jako workera name. fn powinna kończyć się po anulowaniu ctx;
+// panika restartuje ją z backoffem, normalny powrót kończy workera (stan finished).
+func Supervise(name string, fn func(ctx context.Context)) {
+	w := &worker{status: WorkerStatus{Name: name, State: WorkerRunning}}
+	workersMu.Lock()
+	workers = append(workers, w)
+	workersMu.Unlock()
+
+	ctx := context.WithValue(rootCtx, workerKey{}, w)
+	loops.Add(1)
+	go func() {
+		defer loops.Done()
+		backoff := restartBackoffMin
+		for {
+			started := time.Now()
+			w.update(func(s *WorkerStatus) { s.State, s.Started, s.LastBeat = WorkerRunning, started, started })
+
+			panicked := runWorker(ctx, w, fn)
+			if ctx.Err() != nil {
+				w.update(func(s *WorkerStatus) { s.State = WorkerStopped })
+				return
+			}
+			if !panicked {
+				w.update(func(s *WorkerStatus) { s.State = WorkerFinished })
+				supervisorLog.Info("worker finished", "worker", name)
+				return
+			}
+
+			if time.Since(started) >= restartStableAfter {
+				backoff = restartBackoffMin
+			}
+			w.update(func(s *WorkerStatus) { s.State = WorkerRestarting })
+			supervisorLog.Warn("restarting worker", "worker", name, "in", backoff)
+			if !Sleep(ctx, backoff) {
+				w.update(func(s *WorkerStatus) { s.State = WorkerStopped })
+				return
+			}
+			w.update(func(s *WorkerStatus) { s.Restarts++ })
+			if backoff *= 2; backoff > restartBackoffMax {
+				backoff = restartBackoffMax
+			}
+		}
+	}()
+}
+
+func runWorker(ctx context.Context, w *worker, fn func(ctx context.Context)) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			now := time.Now()
+			msg := RecoverToString(r)
+			w.update(func(s *WorkerStatus) { s.LastPanic, s.LastPanicAt = msg, &now })
+			supervisorLog.Error(fmt.Sprintf("PANIC in worker: %s\n%s", msg, debug.Stack()), "worker", w.status.Name)
+		}
+	}()
+	fn(ctx)
+	return false
+}
+
+// SuperviseLoop – worker wołający fn co interval (panika w iteracji jest logowana jak w Catch,
+// pętla działa dalej); każda zakończona iteracja to Heartbeat.
+func SuperviseLoop(name string, interval time.Duration, fn func()) {
+	Supervise(name, func(ctx context.Context) {
+		for {
+			func() {
+				defer Catch(name)()
+				fn()
+			}()
+			Heartbeat(ctx)
+			if !Sleep(ctx, interval) {
+				return
+			}
+		}
+	})
+}
+
+// Heartbeat – znacznik życia workera, którego kontekstem jest ctx (dla pętli bez SuperviseLoop).
+func Heartbeat(ctx context.Context) {
+	if w, ok := ctx.Value(workerKey{}).(*worker); ok {
+		now := time.Now()
+		w.update(func(s *WorkerStatus) { s.LastBeat = now })
+	}
+}
+
+// Sleep czeka d albo do anulowania ctx; false = ctx anulowany.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Workers – stan wszystkich workerów w kolejności uruchomienia.
+func Workers() []WorkerStatus {
+	workersMu.Lock()
+	defer workersMu.Unlock()
+	out := make([]WorkerStatus, 0, len(workers))
+	for _, w := range workers {
+		w.mu.Lock()
+		out = append(out, w.status)
+		w.mu.Unlock()
+	}
+	return out
+}
+
+// Shutdown anuluje Context() i czeka na workery, a potem na zadania z Go, najdłużej timeout.
+// false = nie wszystko zakończyło się w czasie (niezakończone workery trafiają do logu).
+func Shutdown(timeout time.Duration) bool {
+	rootCancel()
+	deadline := time.Now().Add(timeout)
+	if !waitTimeout(&loops, time.Until(deadline)) {
+		for _, s := range Workers() {
+			if s.State == WorkerRunning || s.State == WorkerRestarting {
+				supervisorLog.Error("worker did not stop in time", "worker", s.Name)
+			}
+		}
+		return false
+	}
+	if !waitTimeout(&tasks, time.Until(deadline)) {
+		supervisorLog.Error("pending tasks did not finish in time")
+		return false
+	}
+	return true
+}
+
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
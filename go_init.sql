@@ -136,7 +136,7 @@ CREATE TABLE IF NOT EXISTS public.oee_temp (
 );
 SELECT create_hypertable('public.oee_temp','timestamp', if_not_exists => true);
 
--- 9) shift_summary (PK data_utworzenia)
+-- 9) shift_summary (PK data_utworzenia; dane per urządzenie w shift_summary_device, per produkt w shift_summary_product)
 CREATE TABLE IF NOT EXISTS public.shift_summary (
     data_utworzenia    TIMESTAMPTZ NOT NULL DEFAULT now(),
     start_zmiany       TIMESTAMPTZ NOT NULL,
@@ -150,37 +150,171 @@ CREATE TABLE IF NOT EXISTS public.shift_summary (
     wydajnosc          REAL,
     jakosc             REAL,
     oee                REAL,
-
-    analizator_1_ea_pos REAL,
-    analizator_1_ea_neg REAL,
-    analizator_1_er_pos REAL,
-    analizator_1_er_neg REAL,
-    analizator_1_es     REAL,
-    analizator_1_er     REAL,
-    analizator_2_ea_pos REAL,
-    analizator_2_ea_neg REAL,
-    analizator_2_er_pos REAL,
-    analizator_2_er_neg REAL,
-    analizator_2_es     REAL,
-    analizator_2_er     REAL,
-    analizator_3_ea_pos REAL,
-    analizator_3_ea_neg REAL,
-    analizator_3_er_pos REAL,
-    analizator_3_er_neg REAL,
-    analizator_3_es     REAL,
-    analizator_3_er     REAL,
-    totaliser_1 REAL,
-    totaliser_2 REAL,
-    totaliser_3 REAL,
-    totaliser_4 REAL,
-    totaliser_5 REAL,
-    # OEE / jednostkowe
+    -- OEE / jednostkowe
     w_na_szt           REAL,
-    l_na_szt           REAL,
-    cykl0              REAL,
-    cykl1              REAL,
-    cykl2              REAL,
-    cykl3              REAL,
+    m3_na_szt          REAL,
+    -- mikroprzestoje
+    mikroprzestoje       INTEGER,
+    czas_mikroprzestojow REAL,
+    -- prędkość obrotnicy
+    predkosc_czas           REAL,
+    predkosc_srednia        REAL,
+    predkosc_min            REAL,
+    predkosc_max            REAL,
+    predkosc_nominalna      REAL,
+    czas_ponizej_nominalnej REAL,
+    strata_predkosci        REAL,
+    -- niezawodność
+    liczba_postojow         INTEGER,
+    liczba_awarii           INTEGER,
+    najdluzszy_postoj       REAL,
+    mtbf                    REAL,
+    mttr                    REAL,
     PRIMARY KEY (data_utworzenia)
 );
-SELECT create_hypertable('public.shift_summary','data_utworzenia', if_not_exists => true);
\ No newline at end of file
+SELECT create_hypertable('public.shift_summary','data_utworzenia', if_not_exists => true);
+
+-- 10) downtime_ack (potwierdzenia przyczyn postoju, PK timestamp)
+CREATE TABLE IF NOT EXISTS public.downtime_ack (
+    timestamp      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    reason         SMALLINT    NOT NULL,
+    source         TEXT,
+    pause_start    TIMESTAMPTZ,
+    pause_end      TIMESTAMPTZ,
+    pause_seconds  REAL,
+    PRIMARY KEY (timestamp)
+);
+SELECT create_hypertable('public.downtime_ack','timestamp', if_not_exists => true);
+
+-- 11) shift_summary_device (dane zmiany per analizator / przepływomierz, PK data_utworzenia + urządzenie)
+CREATE TABLE IF NOT EXISTS public.shift_summary_device (
+    data_utworzenia TIMESTAMPTZ NOT NULL,
+    start_zmiany    TIMESTAMPTZ NOT NULL,
+    device_type     TEXT        NOT NULL,
+    device_id       SMALLINT    NOT NULL,
+    source          TEXT,
+    consumption     REAL,
+    unit            TEXT,
+    counter_start   REAL,
+    counter_end     REAL,
+    ea_pos          REAL,
+    ea_neg          REAL,
+    er_pos          REAL,
+    er_neg          REAL,
+    es              REAL,
+    er              REAL,
+    PRIMARY KEY (data_utworzenia, device_type, device_id)
+);
+SELECT create_hypertable('public.shift_summary_device','data_utworzenia', if_not_exists => true);
+
+-- 12) products (katalog produktów: zakresy wymiarów, idealny cykl, materiał, docelowe braki; PK sku)
+CREATE TABLE IF NOT EXISTS public.products (
+    sku              TEXT        NOT NULL,
+    name             TEXT,
+    material         TEXT,
+    length_min       REAL,
+    length_max       REAL,
+    width_min        REAL,
+    width_max        REAL,
+    height_min       REAL,
+    height_max       REAL,
+    ideal_cycle_s    REAL        NOT NULL CHECK (ideal_cycle_s > 0),
+    target_scrap_pct REAL        NOT NULL DEFAULT 0,
+    active           BOOLEAN     NOT NULL DEFAULT TRUE,
+    family           TEXT,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (sku)
+);
+
+-- 13) shift_summary_product (produkcja zmiany per produkt, PK data_utworzenia + sku)
+CREATE TABLE IF NOT EXISTS public.shift_summary_product (
+    data_utworzenia TIMESTAMPTZ NOT NULL,
+    start_zmiany    TIMESTAMPTZ NOT NULL,
+    sku             TEXT        NOT NULL,
+    name            TEXT,
+    pieces          INTEGER,
+    work_seconds    REAL,
+    ideal_cycle_s   REAL,
+    expected_pieces REAL,
+    wydajnosc       REAL,
+    micro_stops        INTEGER,
+    micro_stop_seconds REAL,
+    PRIMARY KEY (data_utworzenia, sku)
+);
+SELECT create_hypertable('public.shift_summary_product','data_utworzenia', if_not_exists => true);
+
+-- 14) production_orders (zlecenia produkcyjne: produkcja, czasy, media i OEE per zlecenie; PK order_no + started_at)
+CREATE TABLE IF NOT EXISTS public.production_orders (
+    order_no           TEXT        NOT NULL,
+    started_at         TIMESTAMPTZ NOT NULL,
+    sku                TEXT,
+    planned_qty        INTEGER     NOT NULL DEFAULT 0,
+    status             TEXT        NOT NULL,
+    ended_at           TIMESTAMPTZ,
+    pieces             INTEGER,
+    run_seconds        REAL,
+    downtime_seconds   REAL,
+    changeover_seconds REAL,
+    energy_wh          REAL,
+    air_m3             REAL,
+    expected_pieces    REAL,
+    dostepnosc         REAL,
+    wydajnosc          REAL,
+    jakosc             REAL,
+    oee                REAL,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (order_no, started_at)
+);
+CREATE INDEX IF NOT EXISTS idx_production_orders_started ON public.production_orders(started_at DESC);
+
+-- 15) shift_summary_loss (Six Big Losses zmiany: czas utracony i odrzuty per kategoria, PK data_utworzenia + category)
+CREATE TABLE IF NOT EXISTS public.shift_summary_loss (
+    data_utworzenia TIMESTAMPTZ NOT NULL,
+    start_zmiany    TIMESTAMPTZ NOT NULL,
+    category        TEXT        NOT NULL,
+    seconds         REAL,
+    pieces          INTEGER,
+    PRIMARY KEY (data_utworzenia, category)
+);
+SELECT create_hypertable('public.shift_summary_loss','data_utworzenia', if_not_exists => true);
+
+-- 16) stop_events (historia postojów zmiany dla MTBF / MTTR, PK data_utworzenia + start)
+CREATE TABLE IF NOT EXISTS public.stop_events (
+    data_utworzenia TIMESTAMPTZ NOT NULL,
+    start_zmiany    TIMESTAMPTZ NOT NULL,
+    start           TIMESTAMPTZ NOT NULL,
+    koniec          TIMESTAMPTZ,
+    seconds         REAL,
+    breakdown       BOOLEAN,
+    PRIMARY KEY (data_utworzenia, start)
+);
+SELECT create_hypertable('public.stop_events','data_utworzenia', if_not_exists => true);
+
+-- 17) machine_events (dziennik stanów maszyny – tylko dopisywanie, PK start_time + type)
+CREATE TABLE IF NOT EXISTS public.machine_events (
+    start_time TIMESTAMPTZ NOT NULL,
+    type       TEXT        NOT NULL,
+    end_time   TIMESTAMPTZ NOT NULL,
+    seconds    REAL,
+    cycle      REAL,
+    sku        TEXT,
+    from_sku   TEXT,
+    to_sku     TEXT,
+    reason     TEXT,
+    PRIMARY KEY (start_time, type)
+);
+SELECT create_hypertable('public.machine_events','start_time', if_not_exists => true);
+CREATE INDEX IF NOT EXISTS idx_machine_events_type ON public.machine_events(type, start_time DESC);
+
+-- 18) shift_summary_revision (poprzednie wersje zmian przeliczonych cmd/recompute, PK data_utworzenia + revised_at)
+CREATE TABLE IF NOT EXISTS public.shift_summary_revision (
+    data_utworzenia TIMESTAMPTZ NOT NULL,
+    revised_at      TIMESTAMPTZ NOT NULL,
+    start_zmiany    TIMESTAMPTZ,
+    reason          TEXT,
+    old_values      JSONB,
+    new_values      JSONB,
+    PRIMARY KEY (data_utworzenia, revised_at)
+);
+SELECT create_hypertable('public.shift_summary_revision','data_utworzenia', if_not_exists => true);
+CREATE INDEX IF NOT EXISTS idx_shift_summary_revision_revised ON public.shift_summary_revision(revised_at DESC);